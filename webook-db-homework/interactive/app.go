@@ -1,11 +1,85 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
 	"webook/pkg/grpcx"
+	"webook/pkg/logger"
 	"webook/pkg/saramax"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/errgroup"
 )
 
 type App struct {
 	Server    *grpcx.Server
 	consumers []saramax.Consumer
+	l         logger.LoggerV1
+}
+
+// Ready 所有消费者都加入了消费者组才算就绪
+func (a *App) Ready() (bool, []saramax.Health) {
+	ready := true
+	healths := make([]saramax.Health, 0, len(a.consumers))
+	for _, c := range a.consumers {
+		h := c.Health()
+		ready = ready && h.Ready
+		healths = append(healths, h)
+	}
+	return ready, healths
+}
+
+// ServeHealth /health/ready 就绪返回 200，否则 503，响应里带每个消费者的状态和分区积压；
+// /metrics 是 gRPC 调用的耗时和错误数
+func (a *App) ServeHealth(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		ready, healths := a.Ready()
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"ready": ready, "consumers": healths})
+	})
+	mux.HandleFunc("/health/live", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Handle("/metrics", promhttp.Handler())
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// ServeAdmin 运维接口，没有鉴权，只能监听在本机地址上，/log/level 调整日志级别
+func (a *App) ServeAdmin(addr string) (*http.Server, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("运维接口只能监听本机地址，现在是 %s", addr)
+	}
+	mux := http.NewServeMux()
+	// GET 查看日志级别，PUT {"level":"debug"} 临时调整
+	if zl, ok := a.l.(*logger.ZapLogger); ok {
+		mux.Handle("/log/level", zl.LevelHandler())
+	}
+	return &http.Server{Addr: addr, Handler: mux}, nil
+}
+
+// StopConsumers 并发关闭所有消费者，ctx 超时之后不再等待
+func (a *App) StopConsumers(ctx context.Context) {
+	var eg errgroup.Group
+	for _, c := range a.consumers {
+		c := c
+		eg.Go(func() error {
+			err := c.Stop(ctx)
+			if err != nil {
+				a.l.Error("关闭消费者超时", logger.String("name", c.Health().Name), logger.Error(err))
+			}
+			return err
+		})
+	}
+	_ = eg.Wait()
 }
@@ -3,16 +3,40 @@ package main
 import (
 	"context"
 	"testing"
+	"time"
 
 	intrv1 "webook/api/proto/gen/intr/v1"
+	"webook/pkg/grpcx"
+	"webook/pkg/grpcx/balancer"
+	"webook/pkg/grpcx/interceptors"
+	"webook/pkg/grpcx/registry/etcd"
+	"webook/pkg/logger"
 
 	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 func TestClient(t *testing.T) {
-	cc, err := grpc.Dial("localhost:8090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	etcdClient, err := clientv3.New(clientv3.Config{Endpoints: []string{"localhost:12379"}})
+	require.NoError(t, err)
+	defer etcdClient.Close()
+	l := logger.NewZapLogger(zap.NewNop())
+	r := etcd.NewRegistry(etcdClient, l, 10)
+	// 通过 etcd 发现服务，按权重轮询，实例不可用的时候换一个实例重试
+	cc, err := grpcx.NewClientConn(grpcx.ClientConfig{
+		Target:      "etcd:///service/interactive",
+		Balancer:    balancer.WeightedRoundRobin,
+		HealthCheck: true,
+		Methods: []grpcx.MethodConfig{
+			{Service: intrv1.IntrService_ServiceDesc.ServiceName, Timeout: time.Second, MaxAttempts: 3},
+		},
+	}, r, l, grpc.WithChainUnaryInterceptor(
+		interceptors.NewLogBuilder(l).BuildClient(),
+		// 和 interactive/config/dev.yaml 的 grpc.auth.key 一致
+		interceptors.NewAuthBuilder([]byte("interactive-service-secret")).Service("webook").BuildClient(),
+	))
 	require.NoError(t, err)
 	defer cc.Close()
 	client := intrv1.NewIntrServiceClient(cc)
@@ -1,5 +1,10 @@
 package events
 
+import "webook/pkg/saramax"
+
+// ReadEventSchema 要和生产者 internal/events/article 的保持一致
+var ReadEventSchema = saramax.Schema[ReadEvent]{Type: "read_event", Version: 1}
+
 type ReadEvent struct {
 	Uid int64
 	Aid int64
@@ -16,10 +16,13 @@ type KafkaBatchConsumer struct {
 	repo      repository.InteractiveRepository
 	batchSize int
 	timeout   time.Duration
+	retrier   *saramax.Retrier
+	runner    *saramax.ConsumerRunner
 }
 
-func NewKafkaBatchConsumer(client sarama.Client, l logger.LoggerV1, repo repository.InteractiveRepository) saramax.Consumer {
-	return &KafkaBatchConsumer{client: client, l: l, repo: repo, batchSize: 100, timeout: time.Second * 10}
+func NewKafkaBatchConsumer(client sarama.Client, l logger.LoggerV1, repo repository.InteractiveRepository,
+	retrier *saramax.Retrier) saramax.Consumer {
+	return &KafkaBatchConsumer{client: client, l: l, repo: repo, batchSize: 100, timeout: time.Second * 10, retrier: retrier}
 }
 
 func (c *KafkaBatchConsumer) Start() error {
@@ -27,18 +30,28 @@ func (c *KafkaBatchConsumer) Start() error {
 	if err != nil {
 		return err
 	}
+	topics := append([]string{"read_event"}, c.retrier.Topics()...)
 	c.l.Info("启动 batch consumer", logger.Field{
 		Key:   "topics",
-		Value: []string{"read_event"},
+		Value: topics,
 	})
-	go func() {
-		err := cg.Consume(context.Background(), []string{"read_event"},
-			saramax.NewBatchHandler_[ReadEvent](c.Consume, c.l, c.batchSize, c.timeout))
-		if err != nil {
-			c.l.Error("消费读事件失败", logger.Error(err))
-		}
-	}()
-	return nil
+	c.runner = saramax.NewConsumerRunner("read_event_batch", cg, topics,
+		saramax.NewRetryBatchHandler[ReadEvent](c.Consume, c.l, c.batchSize, c.timeout, c.retrier), c.l)
+	return c.runner.Start()
+}
+
+func (c *KafkaBatchConsumer) Stop(ctx context.Context) error {
+	if c.runner == nil {
+		return nil
+	}
+	return c.runner.Stop(ctx)
+}
+
+func (c *KafkaBatchConsumer) Health() saramax.Health {
+	if c.runner == nil {
+		return saramax.Health{Name: "read_event_batch", State: saramax.StateStopped}
+	}
+	return c.runner.Health()
 }
 
 func (c *KafkaBatchConsumer) Consume(msgs []*sarama.ConsumerMessage, evts []ReadEvent) error {
@@ -52,12 +65,12 @@ func (c *KafkaBatchConsumer) Consume(msgs []*sarama.ConsumerMessage, evts []Read
 	}
 	err := c.repo.BatchIncRead(ctx, biz, ids)
 	if err != nil {
-		c.l.Error("批量增加阅读量失败", logger.Error(err))
-	} else {
-		c.l.Info("批量增加阅读量成功", logger.Field{
-			Key:   "count",
-			Value: len(ids),
-		})
+		// 交给 saramax 重试
+		return err
 	}
+	c.l.Info("批量增加阅读量成功", logger.Field{
+		Key:   "count",
+		Value: len(ids),
+	})
 	return nil
 }
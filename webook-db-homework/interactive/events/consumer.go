@@ -12,14 +12,29 @@ import (
 
 var _ saramax.Consumer = (*KafkaConsumer)(nil)
 
+// ConsumerConfig Workers 大于 1 的时候同一个分区的消息按 key 并发处理，否则逐条处理
+type ConsumerConfig struct {
+	// Topic 读事件的 topic，由 config.KafkaConfig.GetTopic 决定
+	Topic   string `mapstructure:"-"`
+	Workers int    `mapstructure:"workers"`
+	// Buffer 每个 worker 最多排队多少条消息
+	Buffer int `mapstructure:"buffer"`
+}
+
 type KafkaConsumer struct {
-	client sarama.Client
-	l      logger.LoggerV1
-	repo   repository.InteractiveRepository
+	client  sarama.Client
+	l       logger.LoggerV1
+	repo    repository.InteractiveRepository
+	retrier *saramax.Retrier
+	cfg     ConsumerConfig
+	// store 按事件 id 去重，重试和再平衡都可能让同一个事件被消费多次
+	store  saramax.IdempotencyStore
+	runner *saramax.ConsumerRunner
 }
 
-func NewKafkaConsumer(client sarama.Client, l logger.LoggerV1, repo repository.InteractiveRepository) *KafkaConsumer {
-	return &KafkaConsumer{client: client, l: l, repo: repo}
+func NewKafkaConsumer(client sarama.Client, l logger.LoggerV1, repo repository.InteractiveRepository,
+	retrier *saramax.Retrier, cfg ConsumerConfig, store saramax.IdempotencyStore) *KafkaConsumer {
+	return &KafkaConsumer{client: client, l: l, repo: repo, retrier: retrier, cfg: cfg, store: store}
 }
 
 func (c *KafkaConsumer) Start() error {
@@ -27,14 +42,26 @@ func (c *KafkaConsumer) Start() error {
 	if err != nil {
 		return err
 	}
-	go func() {
-		err := cg.Consume(context.Background(), []string{"read_event"},
-			saramax.NewHandler_[ReadEvent](c.Consume, c.l))
-		if err != nil {
-			c.l.Error("消费读事件失败", logger.Error(err))
-		}
-	}()
-	return nil
+	// 重试 topic 也是同一个消费者组在消费
+	topics := append([]string{c.cfg.Topic}, c.retrier.Topics()...)
+	c.runner = saramax.NewConsumerRunner("read_event", cg, topics,
+		saramax.NewDecodingHandler(ReadEventSchema.Decoder(), saramax.Idempotent(c.store, c.l, c.Consume),
+			c.l, c.retrier, c.cfg.Workers, c.cfg.Buffer), c.l)
+	return c.runner.Start()
+}
+
+func (c *KafkaConsumer) Stop(ctx context.Context) error {
+	if c.runner == nil {
+		return nil
+	}
+	return c.runner.Stop(ctx)
+}
+
+func (c *KafkaConsumer) Health() saramax.Health {
+	if c.runner == nil {
+		return saramax.Health{Name: "read_event", State: saramax.StateStopped}
+	}
+	return c.runner.Health()
 }
 
 func (c *KafkaConsumer) Consume(msg *sarama.ConsumerMessage, evt ReadEvent) error {
@@ -58,7 +58,7 @@ func (s *InteractiveTestSuite) SetupSuite() {
 	d := dao.NewInteractiveDAO(s.db)
 	rdb := startup.InitRedis()
 	c := cache.NewInteractiveCache(rdb)
-	s.repo = repository.NewInteractiveRepository(d, c)
+	s.repo = repository.NewInteractiveRepository(d, c, startup.InitLogger())
 
 	s.svc = startup.InitInteractiveService()
 
@@ -25,7 +25,7 @@ func InitInteractiveService() service.InteractiveService {
 	interactiveDAO := dao.NewInteractiveDAO(db)
 	cmdable := InitRedis()
 	interactiveCache := cache.NewInteractiveCache(cmdable)
-	interactiveRepository := repository.NewInteractiveRepository(interactiveDAO, interactiveCache)
+	interactiveRepository := repository.NewInteractiveRepository(interactiveDAO, interactiveCache, loggerV1)
 	interactiveService := service.NewInteractiveService(interactiveRepository)
 	return interactiveService
 }
@@ -36,7 +36,7 @@ func InitInteractiveGRPCServer() *grpc.InteractiveServiceServer {
 	interactiveDAO := dao.NewInteractiveDAO(db)
 	cmdable := InitRedis()
 	interactiveCache := cache.NewInteractiveCache(cmdable)
-	interactiveRepository := repository.NewInteractiveRepository(interactiveDAO, interactiveCache)
+	interactiveRepository := repository.NewInteractiveRepository(interactiveDAO, interactiveCache, loggerV1)
 	interactiveService := service.NewInteractiveService(interactiveRepository)
 	interactiveServiceServer := grpc.NewInteractiveServiceServer(interactiveService)
 	return interactiveServiceServer
@@ -0,0 +1,29 @@
+package ioc
+
+import (
+	"webook/pkg/grpcx/registry"
+	"webook/pkg/grpcx/registry/etcd"
+	"webook/pkg/logger"
+
+	"github.com/spf13/viper"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func InitEtcd() *clientv3.Client {
+	var cfg clientv3.Config
+	err := viper.UnmarshalKey("etcd", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	client, err := clientv3.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// InitRegistry 实例的租约 ttl 秒过期，进程挂掉之后最多 ttl 秒被摘掉
+func InitRegistry(client *clientv3.Client, l logger.LoggerV1) registry.Registry {
+	ttl := viper.GetInt64("etcd.lease_ttl")
+	return etcd.NewRegistry(client, l, ttl)
+}
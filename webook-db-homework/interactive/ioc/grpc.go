@@ -1,26 +1,99 @@
 package ioc
 
 import (
+	"context"
+	"time"
+	"webook/interactive/service"
 	"webook/pkg/grpcx"
+	"webook/pkg/grpcx/interceptors"
+	"webook/pkg/grpcx/registry"
+	"webook/pkg/logger"
+	limiter "webook/pkg/ratelimit/limiter"
 
 	grpc2 "webook/interactive/grpc"
 
+	"github.com/IBM/sarama"
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"gorm.io/gorm"
 )
 
-func InitGRPCServer(intrSvc *grpc2.InteractiveServiceServer) *grpcx.Server {
+func InitGRPCServer(intrSvc *grpc2.InteractiveServiceServer, l logger.LoggerV1,
+	db *gorm.DB, cmd redis.Cmdable, client sarama.Client, r registry.Registry) *grpcx.Server {
+	cfg := grpcx.DefaultConfig
+	err := viper.UnmarshalKey("grpc", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	server := grpcx.NewServer(cfg, l, InitGRPCInterceptors(l, cmd)...)
+	// 启动之后注册到 etcd，客户端通过 etcd:///service/interactive 发现
+	server.SetRegistry(r)
+	intrSvc.Register(server.Server)
+	// 任何一个依赖不可用就不接收流量
+	server.AddChecker(
+		grpcx.HealthChecker{Name: "mysql", Check: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}},
+		grpcx.HealthChecker{Name: "redis", Check: func(ctx context.Context) error {
+			return cmd.Ping(ctx).Err()
+		}},
+		grpcx.HealthChecker{Name: "kafka", Check: func(ctx context.Context) error {
+			// 能找到 controller 说明至少连上了一个 broker
+			_, err := client.Controller()
+			return err
+		}},
+	)
+	return server
+}
+
+// InitGRPCInterceptors 顺序是从外到里：指标和日志在最外面，能看到 panic 和错误转换之后的错误码
+func InitGRPCInterceptors(l logger.LoggerV1, cmd redis.Cmdable) []grpc.UnaryServerInterceptor {
+	type AuthConfig struct {
+		Key string `mapstructure:"key"`
+		// Allow 允许调用的服务，为空的时候签名正确就可以调用
+		Allow []string `mapstructure:"allow"`
+	}
+	type RateLimitConfig struct {
+		Interval time.Duration `mapstructure:"interval"`
+		Rate     int           `mapstructure:"rate"`
+	}
 	type Config struct {
-		Addr string `yaml:"addr"`
+		// TrustTraceMetadata 调用方传过来的 request id 和 trace id 直接用，只有调用方都是内部服务的时候才打开
+		TrustTraceMetadata bool            `mapstructure:"trust_trace_metadata"`
+		Auth               AuthConfig      `mapstructure:"auth"`
+		RateLimit          RateLimitConfig `mapstructure:"ratelimit"`
 	}
 	var cfg Config
 	err := viper.UnmarshalKey("grpc", &cfg)
 	if err != nil {
 		panic(err)
 	}
-	server := grpc.NewServer()
-	intrSvc.Register(server)
-	return &grpcx.Server{
-		Server: server,
-		Addr:   cfg.Addr}
+	res := []grpc.UnaryServerInterceptor{
+		// 最先执行，后面的日志都能带上 request_id、trace_id
+		interceptors.NewTraceBuilder().TrustMetadata(cfg.TrustTraceMetadata).BuildServer(),
+		interceptors.NewMetricsBuilder("webook", "interactive").BuildServer(),
+		interceptors.NewLogBuilder(l).BuildServer(),
+		interceptors.NewRecoveryBuilder(l).BuildServer(),
+	}
+	if cfg.Auth.Key != "" {
+		res = append(res, interceptors.NewAuthBuilder([]byte(cfg.Auth.Key)).
+			Allow(cfg.Auth.Allow...).BuildServer())
+	}
+	if cfg.RateLimit.Rate > 0 {
+		// Redis 出问题的时候降级成本地限流
+		lim := limiter.NewFallbackLimiter(
+			limiter.NewRedisSlideWindowLimiter(cmd, cfg.RateLimit.Interval, cfg.RateLimit.Rate),
+			limiter.NewLocalFixedWindowLimiter(cfg.RateLimit.Interval, cfg.RateLimit.Rate),
+			time.Second*10, l)
+		res = append(res, interceptors.NewRateLimitBuilder(lim, l).Prefix("interactive").ByCaller().BuildServer())
+	}
+	return append(res, interceptors.NewErrorBuilder(l).
+		Map(service.ErrInteractiveNotFound, codes.NotFound).
+		BuildServer())
 }
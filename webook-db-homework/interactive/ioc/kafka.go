@@ -1,10 +1,14 @@
 package ioc
 
 import (
+	"time"
 	"webook/interactive/events"
+	"webook/internal/config"
+	"webook/pkg/logger"
 	"webook/pkg/saramax"
 
 	"github.com/IBM/sarama"
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 )
 
@@ -15,6 +19,8 @@ func InitKafka() sarama.Client {
 	}
 	saramaCfg := sarama.NewConfig()
 	saramaCfg.Producer.Return.Successes = true
+	// ConsumerRunner 从 Errors() 里面读取消费者组的错误
+	saramaCfg.Consumer.Return.Errors = true
 	var cfg Config
 	err := viper.UnmarshalKey("kafka", &cfg)
 	if err != nil {
@@ -27,6 +33,68 @@ func InitKafka() sarama.Client {
 	return client
 }
 
+// InitSyncProducer 重试和死信转发用
+func InitSyncProducer(client sarama.Client) sarama.SyncProducer {
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		panic(err)
+	}
+	return producer
+}
+
+// InitReadEventRetrier 读事件的重试策略，没有配置就只在进程内重试
+func InitReadEventRetrier(producer sarama.SyncProducer, l logger.LoggerV1) *saramax.Retrier {
+	type RetryTopic struct {
+		Topic string        `mapstructure:"topic"`
+		Delay time.Duration `mapstructure:"delay"`
+	}
+	type Config struct {
+		MaxAttempts int           `mapstructure:"max_attempts"`
+		Backoff     time.Duration `mapstructure:"backoff"`
+		MaxBackoff  time.Duration `mapstructure:"max_backoff"`
+		RetryTopics []RetryTopic  `mapstructure:"retry_topics"`
+		DLQTopic    string        `mapstructure:"dlq_topic"`
+	}
+	if !viper.IsSet("kafka.retry.read_event") {
+		return saramax.NewRetrier(saramax.DefaultRetryPolicy, nil, l)
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("kafka.retry.read_event", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	policy := saramax.RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     cfg.Backoff,
+		MaxBackoff:  cfg.MaxBackoff,
+		DLQTopic:    cfg.DLQTopic,
+	}
+	for _, rt := range cfg.RetryTopics {
+		policy.RetryTopics = append(policy.RetryTopics, saramax.RetryTopic{Topic: rt.Topic, Delay: rt.Delay})
+	}
+	return saramax.NewRetrier(policy, producer, l)
+}
+
+// InitReadEventConsumerConfig 读事件的 topic 和并发度，默认逐条处理
+func InitReadEventConsumerConfig() events.ConsumerConfig {
+	var cfg events.ConsumerConfig
+	err := viper.UnmarshalKey("kafka.consumer.read_event", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	kafkaCfg := config.DefaultKafkaConfig()
+	for k, v := range viper.GetStringMapString("kafka.topics") {
+		kafkaCfg.Topics[k] = v
+	}
+	cfg.Topic = kafkaCfg.GetTopic("read_event")
+	return cfg
+}
+
+// InitIdempotencyStore 事件去重记录保留一天
+func InitIdempotencyStore(cmd redis.Cmdable) saramax.IdempotencyStore {
+	return saramax.NewRedisIdempotencyStore(cmd, "interactive:consumed", time.Hour*24)
+}
+
 // NewConsumers 面临的问题依旧是所有的 Consumer 在这里注册一下
 func NewConsumers(c1 *events.KafkaConsumer) []saramax.Consumer {
 	return []saramax.Consumer{c1}
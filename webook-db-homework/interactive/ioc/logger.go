@@ -4,32 +4,18 @@ import (
 	"webook/pkg/logger"
 
 	"github.com/spf13/viper"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
-// InitLogger 初始化并返回项目抽象的日志接口实现
+// InitLogger 初始化并返回项目抽象的日志接口实现，级别可以在运行的时候调整，见 main 里面的 OnConfigChange
 func InitLogger() logger.LoggerV1 {
-	cfg := zap.NewProductionConfig()
 	// 读取日志级别，可选: debug/info/warn/error
-	switch viper.GetString("log.level") {
-	case "debug":
-		cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
-	case "warn":
-		cfg.Level = zap.NewAtomicLevelAt(zapcore.WarnLevel)
-	case "error":
-		cfg.Level = zap.NewAtomicLevelAt(zapcore.ErrorLevel)
-	default:
-		cfg.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	cfg := logger.Config{Level: "info"}
+	if err := viper.UnmarshalKey("log", &cfg); err != nil {
+		panic(err)
 	}
-	// 时间格式更友好
-	cfg.EncoderConfig.TimeKey = "ts"
-	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-
-	zl, err := cfg.Build(zap.AddCaller())
+	l, err := logger.NewZapLoggerWithConfig(cfg)
 	if err != nil {
-		// 兜底：构建失败时使用默认
-		zl = zap.NewExample()
+		panic(err)
 	}
-	return logger.NewZapLogger(zl)
+	return l
 }
@@ -1,41 +1,98 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+
+	"webook/pkg/logger"
 )
 
 func main() {
 	initViperV1()
 	app := InitApp()
+	l := app.l
+	watchConfig(l)
 	for _, consumer := range app.consumers {
 		err := consumer.Start()
 		if err != nil {
 			panic(err)
 		}
+	}
 
+	healthAddr := viper.GetString("health.addr")
+	if healthAddr == "" {
+		healthAddr = ":8091"
+	}
+	healthServer := app.ServeHealth(healthAddr)
+	go func() {
+		if err := healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Error("健康检查服务退出", logger.Error(err))
+		}
+	}()
+	adminAddr := viper.GetString("admin.addr")
+	if adminAddr == "" {
+		adminAddr = "127.0.0.1:8092"
 	}
-	err := app.Server.Serve()
+	adminServer, err := app.ServeAdmin(adminAddr)
 	if err != nil {
 		panic(err)
 	}
+	go func() {
+		if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Error("运维接口退出", logger.Error(err))
+		}
+	}()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	// 收到退出信号之后先把健康状态改成 NOT_SERVING，再优雅退出，超时强制关闭
+	exitCode := 0
+	if err := app.Server.Run(ctx); err != nil {
+		// 启动失败或者中途退出都要让进程以非零状态码结束，部署系统才会重启
+		l.Error("gRPC 服务退出", logger.Error(err))
+		exitCode = 1
+	}
+	l.Info("gRPC 服务已关闭，开始关闭消费者")
 
+	// 再把消费者手上的消息处理完、提交偏移量
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+	app.StopConsumers(shutdownCtx)
+	_ = healthServer.Shutdown(shutdownCtx)
+	_ = adminServer.Shutdown(shutdownCtx)
+	os.Exit(exitCode)
 }
 
 func initViperV1() {
 	cfile := pflag.String("config", "./config/dev.yaml", "指定文件路径")
 	pflag.Parse()
 	viper.SetConfigFile(*cfile)
-	viper.WatchConfig()
-	viper.OnConfigChange(func(e fsnotify.Event) {
-		fmt.Println("配置文件发生变化:", e.Name)
-	})
 	viper.SetConfigType("yaml")
 	err := viper.ReadInConfig()
 	if err != nil {
 		panic(err)
 	}
 }
+
+// watchConfig 配置文件修改之后调整日志级别
+func watchConfig(l logger.LoggerV1) {
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		l.Info("配置文件发生变化", logger.String("file", e.Name))
+		zl, ok := l.(*logger.ZapLogger)
+		if !ok {
+			return
+		}
+		if err := zl.SetLevel(viper.GetString("log.level")); err != nil {
+			l.Error("调整日志级别失败", logger.Error(err))
+		}
+	})
+	viper.WatchConfig()
+}
@@ -2,15 +2,16 @@ package repository
 
 import (
 	"context"
-	"fmt"
-	"log"
 	"webook/interactive/domain"
 	"webook/interactive/repository/cache"
 	"webook/interactive/repository/dao"
+	"webook/pkg/logger"
 
 	"github.com/redis/go-redis/v9"
 )
 
+var ErrInteractiveNotFound = dao.ErrRecordNotFound
+
 type InteractiveRepository interface {
 	IncLike(ctx context.Context, biz string, id int64, uid int64) error
 	DecLike(ctx context.Context, biz string, id int64, uid int64) error
@@ -26,10 +27,11 @@ type InteractiveRepository interface {
 type InteractiveRepository_ struct {
 	dao   dao.InteractiveDAO
 	cache cache.InteractiveCache
+	l     logger.LoggerV1
 }
 
-func NewInteractiveRepository(dao dao.InteractiveDAO, cache cache.InteractiveCache) InteractiveRepository {
-	return &InteractiveRepository_{dao: dao, cache: cache}
+func NewInteractiveRepository(dao dao.InteractiveDAO, cache cache.InteractiveCache, l logger.LoggerV1) InteractiveRepository {
+	return &InteractiveRepository_{dao: dao, cache: cache, l: l}
 }
 
 func (r *InteractiveRepository_) GetByIds(ctx context.Context, biz string, ids []int64) (map[int64]domain.Interactive, error) {
@@ -80,7 +82,7 @@ func (r *InteractiveRepository_) IncRead(ctx context.Context, biz string, id int
 	if err != nil {
 		return err
 	}
-	fmt.Println("IncRead", biz, id)
+	logger.FromContext(ctx, r.l).Debug("增加阅读数", logger.String("biz", biz), logger.Int64("id", id))
 	return r.cache.IncrReadIfPresent(ctx, biz, id)
 }
 func (r *InteractiveRepository_) IncCollect(ctx context.Context, biz string, id int64, uid int64) error {
@@ -116,10 +118,11 @@ func (r *InteractiveRepository_) Get(ctx context.Context, biz string, id int64)
 	}
 
 	// 异步设置缓存
+	l := logger.FromContext(ctx, r.l)
 	go func() {
 		er := r.cache.Set(context.Background(), biz, id, data)
 		if er != nil {
-			log.Println("set cache error", er)
+			l.Error("回写互动缓存失败", logger.String("biz", biz), logger.Int64("id", id), logger.Error(er))
 		}
 	}()
 
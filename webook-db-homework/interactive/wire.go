@@ -27,6 +27,9 @@ var thirdPartySet = wire.NewSet(
 	ioc.InitLogger,
 	ioc.InitRedis,
 	ioc.InitKafka,
+	ioc.InitSyncProducer,
+	ioc.InitEtcd,
+	ioc.InitRegistry,
 )
 
 func InitApp() *App {
@@ -34,6 +37,9 @@ func InitApp() *App {
 		interactiveSvcProvider,
 		thirdPartySet,
 		events.NewKafkaConsumer,
+		ioc.InitReadEventRetrier,
+		ioc.InitReadEventConsumerConfig,
+		ioc.InitIdempotencyStore,
 		grpc.NewInteractiveServiceServer,
 		ioc.InitGRPCServer,
 		ioc.NewConsumers,
@@ -23,18 +23,25 @@ func InitApp() *App {
 	loggerV1 := ioc.InitLogger()
 	db := ioc.InitDB(loggerV1)
 	interactiveDAO := dao.NewInteractiveDAO(db)
-	cmdable := ioc.InitRedis()
+	cmdable := ioc.InitRedis(loggerV1)
 	interactiveCache := cache.NewInteractiveCache(cmdable)
-	interactiveRepository := repository.NewInteractiveRepository(interactiveDAO, interactiveCache)
+	interactiveRepository := repository.NewInteractiveRepository(interactiveDAO, interactiveCache, loggerV1)
 	interactiveService := service.NewInteractiveService(interactiveRepository)
 	interactiveServiceServer := grpc.NewInteractiveServiceServer(interactiveService)
-	server := ioc.InitGRPCServer(interactiveServiceServer)
 	client := ioc.InitKafka()
-	kafkaConsumer := events.NewKafkaConsumer(client, loggerV1, interactiveRepository)
+	clientv3Client := ioc.InitEtcd()
+	registry := ioc.InitRegistry(clientv3Client, loggerV1)
+	server := ioc.InitGRPCServer(interactiveServiceServer, loggerV1, db, cmdable, client, registry)
+	syncProducer := ioc.InitSyncProducer(client)
+	retrier := ioc.InitReadEventRetrier(syncProducer, loggerV1)
+	consumerConfig := ioc.InitReadEventConsumerConfig()
+	idempotencyStore := ioc.InitIdempotencyStore(cmdable)
+	kafkaConsumer := events.NewKafkaConsumer(client, loggerV1, interactiveRepository, retrier, consumerConfig, idempotencyStore)
 	v := ioc.NewConsumers(kafkaConsumer)
 	app := &App{
 		Server:    server,
 		consumers: v,
+		l:         loggerV1,
 	}
 	return app
 }
@@ -43,4 +50,4 @@ func InitApp() *App {
 
 var interactiveSvcProvider = wire.NewSet(service.NewInteractiveService, cache.NewInteractiveCache, dao.NewInteractiveDAO, repository.NewInteractiveRepository)
 
-var thirdPartySet = wire.NewSet(ioc.InitDB, ioc.InitLogger, ioc.InitRedis, ioc.InitKafka)
+var thirdPartySet = wire.NewSet(ioc.InitDB, ioc.InitLogger, ioc.InitRedis, ioc.InitKafka, ioc.InitSyncProducer, ioc.InitEtcd, ioc.InitRegistry)
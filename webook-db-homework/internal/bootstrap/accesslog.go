@@ -0,0 +1,41 @@
+package bootstrap
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	ginxlogger "webook/pkg/ginx/middlewares/logger"
+	"webook/pkg/logger"
+)
+
+type accessLogConfig struct {
+	ReqBody     bool     `mapstructure:"req_body"`
+	RespBody    bool     `mapstructure:"resp_body"`
+	MaxBodySize int      `mapstructure:"max_body_size"`
+	Redact      []string `mapstructure:"redact"`
+	SkipBody    []string `mapstructure:"skip_body"`
+}
+
+// InitAccessLog HTTP 访问日志，log.access 修改之后立刻生效，线上排查问题可以临时打开请求体和响应体
+func InitAccessLog(l logger.LoggerV1) gin.HandlerFunc {
+	builder := ginxlogger.NewBuilder(l)
+	apply := func() {
+		cfg := accessLogConfig{MaxBodySize: 1024}
+		if err := viper.UnmarshalKey("log.access", &cfg); err != nil {
+			l.Error("读取访问日志配置失败", logger.Error(err))
+			return
+		}
+		builder.AllowReqBody(cfg.ReqBody).
+			AllowRespBody(cfg.RespBody).
+			MaxBodySize(cfg.MaxBodySize).
+			Redact(cfg.Redact...).
+			SkipBody(cfg.SkipBody...)
+		l.Info("访问日志配置",
+			logger.Bool("req_body", cfg.ReqBody),
+			logger.Bool("resp_body", cfg.RespBody),
+			logger.Int("max_body_size", cfg.MaxBodySize))
+	}
+	apply()
+	OnConfigChange(apply)
+	return builder.Build()
+}
@@ -0,0 +1,33 @@
+package bootstrap
+
+import (
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+var (
+	configWatchMutex sync.Mutex
+	configWatchOnce  sync.Once
+	configWatchers   []func()
+)
+
+// OnConfigChange viper 只能注册一个回调，这里统一分发，配置文件修改之后按注册顺序执行
+func OnConfigChange(fn func()) {
+	configWatchMutex.Lock()
+	configWatchers = append(configWatchers, fn)
+	configWatchMutex.Unlock()
+	configWatchOnce.Do(func() {
+		viper.OnConfigChange(func(in fsnotify.Event) {
+			configWatchMutex.Lock()
+			watchers := make([]func(), len(configWatchers))
+			copy(watchers, configWatchers)
+			configWatchMutex.Unlock()
+			for _, w := range watchers {
+				w()
+			}
+		})
+		viper.WatchConfig()
+	})
+}
@@ -0,0 +1,22 @@
+package bootstrap
+
+import (
+	"github.com/spf13/viper"
+
+	"webook/internal/config"
+	events "webook/internal/events/article"
+)
+
+// InitKafkaConfig 在默认配置上覆盖 kafka.addrs 和 kafka.topics，
+// 同时设置文章事件使用的 topic 名字
+func InitKafkaConfig() *config.KafkaConfig {
+	cfg := config.DefaultKafkaConfig()
+	if addrs := viper.GetStringSlice("kafka.addrs"); len(addrs) > 0 {
+		cfg.Addrs = addrs
+	}
+	for k, v := range viper.GetStringMapString("kafka.topics") {
+		cfg.Topics[k] = v
+	}
+	events.InitTopics(cfg)
+	return cfg
+}
@@ -4,29 +4,25 @@ import (
 	"webook/pkg/logger"
 
 	"github.com/spf13/viper"
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
-// InitLogger 初始化并返回项目抽象的日志接口实现
+// InitLogger 初始化并返回项目抽象的日志接口实现，修改配置文件里的 log.level 之后立刻生效
 func InitLogger() logger.LoggerV1 {
-	cfg := zap.NewProductionConfig()
-	switch viper.GetString("log.level") {
-	case "debug":
-		cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
-	case "warn":
-		cfg.Level = zap.NewAtomicLevelAt(zapcore.WarnLevel)
-	case "error":
-		cfg.Level = zap.NewAtomicLevelAt(zapcore.ErrorLevel)
-	default:
-		cfg.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	cfg := logger.Config{Level: "info"}
+	if err := viper.UnmarshalKey("log", &cfg); err != nil {
+		panic(err)
 	}
-	cfg.EncoderConfig.TimeKey = "ts"
-	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-
-	zl, err := cfg.Build(zap.AddCaller())
+	l, err := logger.NewZapLoggerWithConfig(cfg)
 	if err != nil {
-		zl = zap.NewExample()
+		panic(err)
 	}
-	return logger.NewZapLogger(zl)
+	OnConfigChange(func() {
+		level := viper.GetString("log.level")
+		if err := l.SetLevel(level); err != nil {
+			l.Error("调整日志级别失败", logger.String("level", level), logger.Error(err))
+			return
+		}
+		l.Info("调整日志级别", logger.String("level", level))
+	})
+	return l
 }
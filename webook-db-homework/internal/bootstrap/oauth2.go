@@ -0,0 +1,79 @@
+package bootstrap
+
+import (
+	"crypto/rand"
+	"net/http"
+	"os"
+	"time"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/github"
+	"webook/internal/service/oauth2/wechat"
+	"webook/internal/web"
+	"webook/pkg/circuitbreaker"
+	"webook/pkg/logger"
+
+	"github.com/spf13/viper"
+)
+
+// InitOAuth2Providers 新接入一个第三方登录只需要在这里注册
+func InitOAuth2Providers(l logger.LoggerV1) *oauth2.Registry {
+	return oauth2.NewRegistry(
+		InitOAuthWechatService(l),
+		InitOAuthGithubService(),
+	)
+}
+
+func InitOAuthWechatService(l logger.LoggerV1) oauth2.Provider {
+	appId := os.Getenv("WECHAT_APP_ID")
+	if appId == "" {
+		appId = "test_app_id" // 默认值，用于开发测试
+	}
+	appSecret := os.Getenv("WECHAT_APP_SECRET")
+	if appSecret == "" {
+		appSecret = "test_app_secret" // 默认值，用于开发测试
+	}
+	// 微信接口不可用的时候快速失败，不要让登录请求都卡在超时上
+	breaker := circuitbreaker.NewBreaker(circuitbreaker.Config{
+		Name:                "wechat-oauth2",
+		FailureRatio:        0.5,
+		MinRequests:         10,
+		Interval:            time.Minute,
+		OpenTimeout:         time.Second * 30,
+		HalfOpenMaxRequests: 3,
+	}, l)
+	client := circuitbreaker.WrapClient(&http.Client{Timeout: time.Second * 5}, breaker)
+	return wechat.NewServiceWithClient(appId, appSecret, viper.GetString("oauth2.wechat.redirect_uri"), client)
+}
+
+func InitOAuthGithubService() oauth2.Provider {
+	clientId := os.Getenv("GITHUB_CLIENT_ID")
+	if clientId == "" {
+		clientId = "test_client_id" // 默认值，用于开发测试
+	}
+	clientSecret := os.Getenv("GITHUB_CLIENT_SECRET")
+	if clientSecret == "" {
+		clientSecret = "test_client_secret" // 默认值，用于开发测试
+	}
+	return github.NewService(clientId, clientSecret, viper.GetString("oauth2.github.redirect_uri"))
+}
+
+// InitOAuth2HandlerConfig state 的密钥优先读环境变量 OAUTH2_STATE_KEY，其次是 oauth2.state_key。
+// 都没有配置的时候随机生成一个，只适合单实例，重启之前拿到的授权链接回调会失败
+func InitOAuth2HandlerConfig(l logger.LoggerV1) web.OAuth2HandlerConfig {
+	key := os.Getenv("OAUTH2_STATE_KEY")
+	if key == "" {
+		key = viper.GetString("oauth2.state_key")
+	}
+	stateKey := []byte(key)
+	if key == "" {
+		l.Warn("没有配置第三方登录 state 的密钥，使用随机密钥，多实例部署必须配置 OAUTH2_STATE_KEY")
+		stateKey = make([]byte, 32)
+		if _, err := rand.Read(stateKey); err != nil {
+			panic(err)
+		}
+	}
+	return web.OAuth2HandlerConfig{
+		Secure:   viper.GetBool("oauth2.secure"),
+		StateKey: stateKey,
+	}
+}
@@ -0,0 +1,48 @@
+package bootstrap
+
+import (
+	"context"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+
+	"webook/internal/config"
+	"webook/pkg/logger"
+	"webook/pkg/outbox"
+)
+
+// InitOutboxRelay 后台把发件箱里的消息转发到 Kafka。
+// Kafka 连不上不影响启动，消息先留在发件箱里，连上之后再发；ctx 结束之后停止转发
+func InitOutboxRelay(ctx context.Context, db *gorm.DB, kafkaCfg *config.KafkaConfig, l logger.LoggerV1) {
+	if !viper.IsSet("kafka.addrs") {
+		l.Warn("没有配置 kafka.addrs，发件箱里的消息不会发送")
+		return
+	}
+	cfg := outbox.DefaultConfig
+	if err := viper.UnmarshalKey("outbox", &cfg); err != nil {
+		panic(err)
+	}
+	go func() {
+		saramaCfg := kafkaCfg.ToSaramaConfig()
+		saramaCfg.Producer.Return.Successes = true
+		saramaCfg.Producer.RequiredAcks = sarama.WaitForAll
+		for {
+			producer, err := sarama.NewSyncProducer(kafkaCfg.Addrs, saramaCfg)
+			if err == nil {
+				outbox.NewRelay(db, producer, l, cfg).Start(ctx)
+				if err = producer.Close(); err != nil {
+					l.Error("关闭发件箱的 Kafka producer 失败", logger.Error(err))
+				}
+				return
+			}
+			l.Warn("连接 Kafka 失败，稍后重试", logger.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second * 10):
+			}
+		}
+	}()
+}
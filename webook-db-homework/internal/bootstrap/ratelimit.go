@@ -0,0 +1,68 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+
+	"webook/pkg/ginx/middlewares/ratelimit"
+	"webook/pkg/logger"
+)
+
+// InitRateLimit 按路由限流。启动和配置文件修改的时候把 ratelimit 下面的策略表写进 Redis，
+// 所有节点定时从 Redis 加载，所以改一台机器的配置就能让整个集群生效。
+// 只有 ratelimit.version 比 Redis 里面的高才会写进去，用旧配置文件重启的节点不会覆盖新的策略；
+// 不合法的策略表不会写进去，Redis 里面的策略表不能用的时候先用本地配置的，不会启动失败
+func InitRateLimit(cmd redis.Cmdable, l logger.LoggerV1) gin.HandlerFunc {
+	store := ratelimit.NewRedisPolicyStore(cmd, "ratelimit:policy_set")
+	local := func() (ratelimit.PolicySet, error) {
+		var set ratelimit.PolicySet
+		if err := viper.UnmarshalKey("ratelimit", &set); err != nil {
+			return ratelimit.PolicySet{}, err
+		}
+		return set, set.Validate()
+	}
+	publish := func() error {
+		set, err := local()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err = store.Save(ctx, set)
+		if errors.Is(err, ratelimit.ErrStalePolicy) {
+			l.Warn("本地限流策略的版本号不比 Redis 里面的新，不发布，使用 Redis 里面的策略",
+				logger.Int64("version", set.Version))
+			return nil
+		}
+		return err
+	}
+	if err := publish(); err != nil {
+		l.Error("发布限流策略失败", logger.Error(err))
+	}
+	builder := ratelimit.NewPolicyBuilder(store, ratelimit.RedisLimiterFactory(cmd, l), l)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := builder.Reload(ctx); err != nil {
+		l.Error("加载 Redis 里面的限流策略失败，先使用本地配置的策略", logger.Error(err))
+		set, err := local()
+		if err == nil {
+			err = builder.Apply(set)
+		}
+		if err != nil {
+			// Watch 之后加载到合法的策略表才开始限流
+			l.Error("本地配置的限流策略也不能用，暂时不按路由限流", logger.Error(err))
+		}
+	}
+	OnConfigChange(func() {
+		if err := publish(); err != nil {
+			l.Error("发布限流策略失败", logger.Error(err))
+		}
+	})
+	go builder.Watch(context.Background(), time.Second*5)
+	return builder.Build()
+}
@@ -1,15 +1,15 @@
 package bootstrap
 
 import (
-	"fmt"
+	"webook/pkg/logger"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 )
 
-func InitRedis() redis.Cmdable {
+func InitRedis(l logger.LoggerV1) redis.Cmdable {
 	addr := viper.GetString("db.redis.addr")
-	fmt.Println("redis addr:", addr)
+	l.Info("连接 Redis", logger.String("addr", addr))
 	return redis.NewClient(&redis.Options{
 		Addr: addr,
 	})
@@ -0,0 +1,102 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"time"
+	"webook/internal/repository"
+	"webook/internal/repository/dao"
+	"webook/internal/service"
+	"webook/internal/service/sms"
+	"webook/internal/service/sms/async"
+	"webook/internal/service/sms/memory"
+	"webook/internal/service/sms/sendlog"
+	"webook/internal/service/sms/template"
+	"webook/pkg/cryptox"
+	"webook/pkg/logger"
+	ratelimit "webook/pkg/ratelimit/limiter"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// InitSMS 本地开发用内存实现，模板从 sms.templates 读取，修改配置文件之后自动重新加载。
+// 服务商出问题的时候切换到异步发送，后台 worker 在 ctx 取消之后退出
+func InitSMS(ctx context.Context, l logger.LoggerV1, logRepo repository.SMSLogRepository,
+	asyncRepo repository.AsyncSmsRepository) sms.Service {
+	var cfg template.Config
+	if err := viper.UnmarshalKey("sms.templates", &cfg); err != nil {
+		panic(err)
+	}
+	registry, err := template.NewRegistry(cfg)
+	if err != nil {
+		panic(err)
+	}
+	OnConfigChange(func() {
+		var newCfg template.Config
+		if er := viper.UnmarshalKey("sms.templates", &newCfg); er != nil {
+			l.Error("解析短信模板配置失败", logger.Error(er))
+			return
+		}
+		if er := registry.Load(newCfg); er != nil {
+			l.Error("重新加载短信模板失败", logger.Error(er))
+			return
+		}
+		l.Info("短信模板已重新加载")
+	})
+	svc := sendlog.NewSMSService(
+		template.NewSMSService(memory.NewService(l), "memory", registry),
+		"memory", logRepo, l)
+	asyncCfg := async.DefaultConfig()
+	if err = viper.UnmarshalKey("sms.async", &asyncCfg); err != nil {
+		panic(err)
+	}
+	asyncSvc := async.NewSMSService(svc, asyncRepo, l, asyncCfg)
+	asyncSvc.StartAsyncCycle(ctx)
+	return asyncSvc
+}
+
+// InitAsyncSmsRepository 异步短信的参数里面有验证码，加密之后再落库。
+// 密钥优先读环境变量 SMS_ASYNC_KEY，其次是 sms.async.key，都没有配置的时候随机生成，重启之前没发出去的短信就发不了了
+func InitAsyncSmsRepository(db *gorm.DB, l logger.LoggerV1) repository.AsyncSmsRepository {
+	key := []byte(os.Getenv("SMS_ASYNC_KEY"))
+	if len(key) == 0 {
+		key = []byte(viper.GetString("sms.async.key"))
+	}
+	if len(key) == 0 {
+		l.Warn("没有配置异步短信的加密密钥，使用随机密钥，线上必须配置 SMS_ASYNC_KEY")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	cipher, err := cryptox.NewAESGCM(key)
+	if err != nil {
+		panic(err)
+	}
+	return repository.NewAsyncSmsRepository(dao.NewAsyncSmsDAO(db), cipher)
+}
+
+// InitCodeLimits 发送验证码的分层限流：IP 每小时、手机号每天、业务每分钟
+func InitCodeLimits(cmd redis.Cmdable) []service.CodeLimit {
+	type Config struct {
+		IPHourly     int `mapstructure:"ip_hourly"`
+		PhoneDaily   int `mapstructure:"phone_daily"`
+		BizPerMinute int `mapstructure:"biz_per_minute"`
+	}
+	cfg := Config{
+		IPHourly:     20,
+		PhoneDaily:   10,
+		BizPerMinute: 1000,
+	}
+	if err := viper.UnmarshalKey("sms.code_limits", &cfg); err != nil {
+		panic(err)
+	}
+	return []service.CodeLimit{
+		{Scope: service.CodeLimitScopeIP, Limiter: ratelimit.NewRedisSlideWindowLimiter(cmd, time.Hour, cfg.IPHourly)},
+		{Scope: service.CodeLimitScopePhone, Limiter: ratelimit.NewRedisSlideWindowLimiter(cmd, time.Hour*24, cfg.PhoneDaily)},
+		{Scope: service.CodeLimitScopeBiz, Limiter: ratelimit.NewRedisSlideWindowLimiter(cmd, time.Minute, cfg.BizPerMinute)},
+	}
+}
@@ -0,0 +1,29 @@
+package bootstrap
+
+import (
+	"crypto/rand"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"webook/internal/web"
+	"webook/pkg/logger"
+)
+
+// InitTwoFactorChallenger 挑战 token 的密钥优先读环境变量 TWO_FACTOR_CHALLENGE_KEY，其次是 two_factor.challenge_key。
+// 都没有配置的时候随机生成一个，只适合单实例，重启之后还没完成的二次验证要重新登录
+func InitTwoFactorChallenger(l logger.LoggerV1) *web.TwoFactorChallenger {
+	key := os.Getenv("TWO_FACTOR_CHALLENGE_KEY")
+	if key == "" {
+		key = viper.GetString("two_factor.challenge_key")
+	}
+	if key != "" {
+		return web.NewTwoFactorChallenger([]byte(key))
+	}
+	l.Warn("没有配置二次验证的密钥，使用随机密钥，多实例部署必须配置 TWO_FACTOR_CHALLENGE_KEY")
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		panic(err)
+	}
+	return web.NewTwoFactorChallenger(random)
+}
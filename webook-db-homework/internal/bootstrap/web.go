@@ -0,0 +1,27 @@
+package bootstrap
+
+import (
+	"webook/pkg/ginx/middlewares/trace"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// InitTrustedProxies 只有 http.trusted_proxies 里面的代理转发过来的 X-Forwarded-For 才会被采信。
+// gin 默认信任所有代理，客户端自己带上 X-Forwarded-For 就能伪造 IP，绕过按 IP 的限流和登录风控，
+// 所以没有配置的时候一个都不信任，ClientIP 直接用 TCP 连接的地址
+func InitTrustedProxies(server *gin.Engine) {
+	proxies := viper.GetStringSlice("http.trusted_proxies")
+	if len(proxies) == 0 {
+		proxies = nil
+	}
+	if err := server.SetTrustedProxies(proxies); err != nil {
+		panic(err)
+	}
+}
+
+// InitTrace 默认不采用客户端传过来的 X-Request-ID、X-Trace-ID，
+// 只有前面的网关会重新生成或者校验这些请求头的时候才把 http.trust_trace_header 打开
+func InitTrace() gin.HandlerFunc {
+	return trace.NewBuilder().TrustHeader(viper.GetBool("http.trust_trace_header")).Build()
+}
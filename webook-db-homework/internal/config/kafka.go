@@ -40,8 +40,10 @@ func DefaultKafkaConfig() *KafkaConfig {
 	return &KafkaConfig{
 		Addrs: []string{"localhost:9094"},
 		Topics: map[string]string{
-			"read_event":    "read_event",
-			"article_event": "article_event",
+			"read_event":        "read_event",
+			"article_event":     "article_event",
+			"article_published": "article_published",
+			"article_withdrawn": "article_withdrawn",
 		},
 		Consumer: ConsumerConfig{
 			GroupID:       "webook_consumer_group",
@@ -83,6 +85,7 @@ func (c *KafkaConfig) ToSaramaConfig() *sarama.Config {
 
 	// 消费者配置
 	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	config.Consumer.Return.Errors = true
 	if c.Consumer.OffsetInitial == "oldest" {
 		config.Consumer.Offsets.Initial = sarama.OffsetOldest
 	} else {
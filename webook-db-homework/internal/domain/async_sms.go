@@ -0,0 +1,14 @@
+package domain
+
+// AsyncSms 服务商出问题的时候先存起来，后台再慢慢发
+type AsyncSms struct {
+	Id      int64
+	Biz     string
+	Args    []string
+	Numbers []string
+	// RetryMax 最多发送多少次
+	RetryMax int
+	// RetryCnt 加上这一次已经发送了多少次，Version 抢占之后的版本号，上报结果的时候用
+	RetryCnt int
+	Version  int64
+}
@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// 登录方式
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodTwoFactor = "2fa"
+	LoginMethodSMS       = "sms"
+	// LoginMethodOAuth2Prefix 第三方登录的方式是 oauth2:github 这种格式
+	LoginMethodOAuth2Prefix = "oauth2:"
+)
+
+// LoginLog 一次登录尝试，失败的时候 Uid 可能是 0
+type LoginLog struct {
+	Id int64
+	// Account 用户输入的账号，例如邮箱，用户不存在的时候也要记录下来
+	Account   string
+	Uid       int64
+	Method    string
+	IP        string
+	UserAgent string
+	Success   bool
+	// Reason 失败原因
+	Reason string
+	Ctime  time.Time
+}
+
+type LoginRiskAction uint8
+
+const (
+	LoginRiskActionNone LoginRiskAction = iota
+	// LoginRiskActionNotify 通知用户，例如新设备登录
+	LoginRiskActionNotify
+	// LoginRiskActionLock 临时锁定账号，UserService.Login 会拒绝登录
+	LoginRiskActionLock
+)
+
+// LoginRiskEvent 规则命中之后产生的事件
+type LoginRiskEvent struct {
+	Rule   string
+	Action LoginRiskAction
+	Log    LoginLog
+	Detail string
+}
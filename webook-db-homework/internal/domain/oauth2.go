@@ -0,0 +1,11 @@
+package domain
+
+// OAuth2User 第三方账号登录后拿到的身份信息
+// Provider + Subject 唯一确定一个第三方账号，比如 github + 用户的数字 id
+type OAuth2User struct {
+	Provider string
+	Subject  string
+	Nickname string
+	Email    string
+	Avatar   string
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// 内置的角色，启动的时候会初始化到数据库里面
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// 权限点，格式是 资源:动作
+const (
+	PermArticleWithdraw = "article:withdraw"
+	PermUserView        = "user:view"
+	PermUserBan         = "user:ban"
+	PermUser2FAReset    = "user:2fa:reset"
+	PermRoleAssign      = "role:assign"
+	PermAuditView       = "audit:view"
+	PermSMSStatsView    = "sms:stats:view"
+)
+
+// DefaultRolePermissions 内置角色拥有的权限
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermArticleWithdraw, PermUserView, PermUserBan,
+		PermUser2FAReset, PermRoleAssign, PermAuditView,
+		PermSMSStatsView,
+	},
+	RoleModerator: {
+		PermArticleWithdraw, PermUserView, PermUserBan,
+	},
+}
+
+// RoleLevels 角色的级别，管理操作只能作用在级别比自己低的用户上，没有角色的普通用户是 0
+var RoleLevels = map[string]int{
+	RoleAdmin:     2,
+	RoleModerator: 1,
+}
+
+// Authority 用户拥有的角色和权限，登录的时候放进 JWT 里面
+type Authority struct {
+	Roles       []string
+	Permissions []string
+}
+
+func (a Authority) HasPermission(perm string) bool {
+	return slices.Contains(a.Permissions, perm)
+}
+
+// AdminAudit 管理员操作的审计记录
+type AdminAudit struct {
+	Id         int64
+	OperatorId int64
+	Action     string
+	TargetType string
+	TargetId   int64
+	Detail     string
+	Ctime      time.Time
+}
+
+// Level 用户所有角色里面最高的级别
+func (a Authority) Level() int {
+	level := 0
+	for _, role := range a.Roles {
+		level = max(level, RoleLevels[role])
+	}
+	return level
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// SMSLog 一次短信发送尝试，一个手机号一条。不保存短信参数，避免验证码落库
+type SMSLog struct {
+	Id  int64
+	Biz string
+	// Phone 脱敏之后的手机号
+	Phone    string
+	Provider string
+	Latency  time.Duration
+	Success  bool
+	// ErrCode 服务商返回的错误码，成功的时候为空
+	ErrCode string
+	Ctime   time.Time
+}
+
+// SMSStat 某个服务商某一天的发送统计，Total 也就是计费的条数
+type SMSStat struct {
+	Provider string
+	Day      string
+	Total    int64
+	Failed   int64
+}
@@ -0,0 +1,16 @@
+package domain
+
+// TwoFactor 用户的 TOTP 二次验证设置，Enabled 为 false 代表已经生成密钥但是还没有完成绑定
+type TwoFactor struct {
+	Uid     int64
+	Secret  string
+	Enabled bool
+	// LastStep 最近一次验证通过的时间步，用来防止同一个验证码被重复使用
+	LastStep int64
+}
+
+// TwoFactorEnrollment 开启二次验证时返回给前端的信息，URI 就是二维码的内容
+type TwoFactorEnrollment struct {
+	Secret string
+	URI    string
+}
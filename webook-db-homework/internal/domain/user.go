@@ -11,5 +11,18 @@ type User struct {
 	Nickname   string
 	Ctime      time.Time
 	AboutMe    string
+	Status     UserStatus
 	WechatUser WechatUser //这里为什么不组合，因为可能还有其他比如DingDingInfo 可能会有同名的字段
 }
+
+type UserStatus uint8
+
+const (
+	UserStatusNormal UserStatus = iota
+	// UserStatusBanned 被管理员封禁，不能再登录
+	UserStatusBanned
+)
+
+func (u User) Banned() bool {
+	return u.Status == UserStatusBanned
+}
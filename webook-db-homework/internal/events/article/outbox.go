@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"webook/internal/config"
+	"webook/pkg/outbox"
+	"webook/pkg/saramax"
+
+	"gorm.io/gorm"
+)
+
+// 事件的 topic 类型，真正的 topic 名字通过 config.KafkaConfig.GetTopic 获取
+const (
+	TopicReadEvent        = "read_event"
+	TopicArticlePublished = "article_published"
+	TopicArticleWithdrawn = "article_withdrawn"
+)
+
+var (
+	ReadEventSchema        = saramax.Schema[ReadEvent]{Type: TopicReadEvent, Version: 1}
+	ArticlePublishedSchema = saramax.Schema[ArticleEvent]{Type: TopicArticlePublished, Version: 1}
+	ArticleWithdrawnSchema = saramax.Schema[ArticleEvent]{Type: TopicArticleWithdrawn, Version: 1}
+)
+
+var topics = config.DefaultKafkaConfig()
+
+// InitTopics 启动的时候用配置覆盖默认的 topic 名字
+func InitTopics(cfg *config.KafkaConfig) {
+	topics = cfg
+}
+
+// ArticleEvent 文章发布和撤回，只带 id，消费者需要详情自己去查
+type ArticleEvent struct {
+	Aid   int64
+	Uid   int64
+	UTime int64
+}
+
+// PublishedEvent 以文章 id 作为 key，同一篇文章的事件保持顺序
+func PublishedEvent(ctx context.Context, evt ArticleEvent) outbox.Event {
+	return newOutboxEvent(ctx, ArticlePublishedSchema, strconv.FormatInt(evt.Aid, 10), evt)
+}
+
+func WithdrawnEvent(ctx context.Context, evt ArticleEvent) outbox.Event {
+	return newOutboxEvent(ctx, ArticleWithdrawnSchema, strconv.FormatInt(evt.Aid, 10), evt)
+}
+
+func newOutboxEvent[T any](ctx context.Context, schema saramax.Schema[T], key string, evt T) outbox.Event {
+	env := schema.NewEnvelope(ctx, key)
+	headers := make(map[string]string)
+	for _, h := range env.Headers() {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	return outbox.Event{
+		Topic:   topics.GetTopic(schema.Type),
+		Key:     key,
+		Payload: evt,
+		Headers: headers,
+	}
+}
+
+// OutboxProducer 读事件先写进发件箱，由 outbox.Relay 发送到 Kafka
+type OutboxProducer struct {
+	db *gorm.DB
+}
+
+func NewOutboxProducer(db *gorm.DB) Producer {
+	return &OutboxProducer{db: db}
+}
+
+func (p *OutboxProducer) ProduceReadEvent(ctx context.Context, evt ReadEvent) error {
+	return outbox.Add(ctx, p.db, newOutboxEvent(ctx, ReadEventSchema, strconv.FormatInt(evt.Aid, 10), evt))
+}
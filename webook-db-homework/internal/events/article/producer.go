@@ -2,8 +2,9 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"strconv"
+	"webook/pkg/saramax"
 
 	"github.com/IBM/sarama"
 )
@@ -13,25 +14,17 @@ type Producer interface {
 }
 
 type KafkaProducer struct {
-	producer sarama.SyncProducer
+	readEvents *saramax.Producer[ReadEvent]
 }
 
 func NewKafkaProducer(producer sarama.SyncProducer) Producer {
 	return &KafkaProducer{
-		producer: producer,
+		readEvents: saramax.NewProducer(producer, topics.GetTopic(TopicReadEvent), ReadEventSchema),
 	}
 }
 
 func (p *KafkaProducer) ProduceReadEvent(ctx context.Context, evt ReadEvent) error {
-
-	data, err := json.Marshal(evt)
-	if err != nil {
-		return err
-	}
-	_, _, err = p.producer.SendMessage(&sarama.ProducerMessage{
-		Topic: "read_event",
-		Value: sarama.ByteEncoder(data),
-	})
+	_, err := p.readEvents.Produce(ctx, strconv.FormatInt(evt.Aid, 10), evt)
 	return err
 }
 
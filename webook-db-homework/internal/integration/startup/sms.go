@@ -3,22 +3,18 @@ package startup
 import (
 	"webook/internal/service/sms"
 	"webook/internal/service/sms/memory"
+	"webook/internal/service/sms/template"
 )
 
 func InitSMS() sms.Service {
-	/*
-		// 创建腾讯云短信客户端
-		credential := common.NewCredential(
-			"your_secret_id",  // 替换为您的SecretId
-			"your_secret_key", // 替换为您的SecretKey
-		)
-
-		client, err := smsClient.NewClient(credential, "ap-nanjing", profile.NewClientProfile())
-		if err != nil {
-			panic(err)
-		}
-	*/
-	smsService := memory.NewService()
-	// 创建短信服务
-	return smsService
+	// 测试环境直接写死模板，不依赖配置文件
+	registry, err := template.NewRegistry(template.Config{
+		template.BizLogin:         {"memory": {TemplateId: "login", ParamCount: 1}},
+		template.BizResetPassword: {"memory": {TemplateId: "reset_password", ParamCount: 1}},
+		template.BizBindPhone:     {"memory": {TemplateId: "bind_phone", ParamCount: 1}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return template.NewSMSService(memory.NewService(InitLogger()), "memory", registry)
 }
@@ -8,24 +8,28 @@ import (
 	ijwt "webook/internal/web/jwt"
 	"webook/internal/web/middleware"
 	"webook/pkg/ginx/middlewares/ratelimit"
+	"webook/pkg/logger"
+	limiter "webook/pkg/ratelimit/limiter"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 )
 
-func InitGin(mdls []gin.HandlerFunc, hdl *web.UserHandler, oauth2WechatHdl *web.OAuth2WechatHandler, articleHdl *web.ArticleHandler) *gin.Engine {
+func InitGin(mdls []gin.HandlerFunc, hdl *web.UserHandler, oauth2Hdl *web.OAuth2Handler, articleHdl *web.ArticleHandler,
+	adminHdl *web.AdminHandler) *gin.Engine {
 	server := gin.Default()
 	server.Use(mdls...)
 	hdl.RegisterRoutes(server)
-	oauth2WechatHdl.RegisterRoutes(server)
+	oauth2Hdl.RegisterRoutes(server)
 	articleHdl.RegisterRoutes(server)
+	adminHdl.RegisterRoutes(server)
 	return server
 }
 
-func InitMiddlewares(redisClient redis.Cmdable, jwtHandler ijwt.Handler) []gin.HandlerFunc {
+func InitMiddlewares(redisClient redis.Cmdable, jwtHandler ijwt.Handler, l logger.LoggerV1) []gin.HandlerFunc {
 	return []gin.HandlerFunc{
-		ratelimit.NewBuilder(redisClient, time.Second, 100).Build(),
+		ratelimit.NewBuilder(limiter.NewRedisSlideWindowLimiter(redisClient, time.Second, 100), l).Build(),
 		cors.New(cors.Config{
 			AllowOrigins:     []string{"http://localhost:3000", "http://localhost:8080", "http://127.0.0.1:8080", "http://localhost:5500", "http://127.0.0.1:5500"},
 			AllowMethods:     []string{"POST", "GET", "PUT", "DELETE", "OPTIONS"},
@@ -41,6 +45,6 @@ func InitMiddlewares(redisClient redis.Cmdable, jwtHandler ijwt.Handler) []gin.H
 
 			MaxAge: 12 * time.Hour,
 		}),
-		middleware.NewLoginJwtMiddlewareBuilder(jwtHandler).IgnorePaths("/users/login").IgnorePaths("/users/signup").IgnorePaths("/users/login_sms/code/send").IgnorePaths("/users/login_sms").IgnorePaths("oauth2/wechat/authurl").IgnorePaths("oauth2/wechat/callback").IgnorePaths("/users/login_jwt").IgnorePaths("/users/refresh_token").Build(),
+		middleware.NewLoginJwtMiddlewareBuilder(jwtHandler).IgnorePaths("/users/login").IgnorePaths("/users/signup").IgnorePaths("/users/login_sms/code/send").IgnorePaths("/users/login_sms").IgnorePaths("/oauth2").IgnorePaths("/users/login_jwt").IgnorePaths("/users/refresh_token").Build(),
 	}
 }
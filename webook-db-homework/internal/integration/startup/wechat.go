@@ -2,13 +2,18 @@ package startup
 
 import (
 	"os"
+	"webook/internal/service/oauth2"
 	"webook/internal/service/oauth2/wechat"
 	"webook/internal/web"
 
 	"github.com/spf13/viper"
 )
 
-func InitOAuthWechatService() wechat.Service {
+func InitOAuth2Providers() *oauth2.Registry {
+	return oauth2.NewRegistry(InitOAuthWechatService())
+}
+
+func InitOAuthWechatService() oauth2.Provider {
 	appId := os.Getenv("WECHAT_APP_ID")
 	if appId == "" {
 		appId = "test_app_id" // 默认值，用于开发测试
@@ -17,18 +22,20 @@ func InitOAuthWechatService() wechat.Service {
 	if appSecret == "" {
 		appSecret = "test_app_secret" // 默认值，用于开发测试
 	}
-	return wechat.NewService(appId, appSecret)
+	return wechat.NewService(appId, appSecret, viper.GetString("oauth2.wechat.redirect_uri"))
 }
 
-func NewWechatHandler() web.WechatHandlerConfig {
-	return web.WechatHandlerConfig{
-		Secure: false,
+func NewOAuth2HandlerConfig() web.OAuth2HandlerConfig {
+	return web.OAuth2HandlerConfig{
+		Secure:   false,
+		StateKey: []byte("test-state-key"),
 	}
 }
 
-func InitWechatHandlerConfig() web.WechatHandlerConfig {
-	secure := viper.GetBool("wechat.secure")
-	return web.WechatHandlerConfig{
-		Secure: secure,
+func InitOAuth2HandlerConfig() web.OAuth2HandlerConfig {
+	secure := viper.GetBool("oauth2.secure")
+	return web.OAuth2HandlerConfig{
+		Secure:   secure,
+		StateKey: []byte(viper.GetString("oauth2.state_key")),
 	}
 }
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+type AdminAuditRepository interface {
+	Create(ctx context.Context, audit domain.AdminAudit) error
+	List(ctx context.Context, offset int, limit int) ([]domain.AdminAudit, error)
+}
+
+type AdminAuditRepository_ struct {
+	dao dao.AdminAuditDAO
+}
+
+func NewAdminAuditRepository(dao dao.AdminAuditDAO) AdminAuditRepository {
+	return &AdminAuditRepository_{dao: dao}
+}
+
+func (r *AdminAuditRepository_) Create(ctx context.Context, audit domain.AdminAudit) error {
+	return r.dao.Insert(ctx, dao.AdminAuditLog{
+		OperatorId: audit.OperatorId,
+		Action:     audit.Action,
+		TargetType: audit.TargetType,
+		TargetId:   audit.TargetId,
+		Detail:     audit.Detail,
+	})
+}
+
+func (r *AdminAuditRepository_) List(ctx context.Context, offset int, limit int) ([]domain.AdminAudit, error) {
+	logs, err := r.dao.List(ctx, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AdminAudit, 0, len(logs))
+	for _, l := range logs {
+		res = append(res, domain.AdminAudit{
+			Id:         l.Id,
+			OperatorId: l.OperatorId,
+			Action:     l.Action,
+			TargetType: l.TargetType,
+			TargetId:   l.TargetId,
+			Detail:     l.Detail,
+			Ctime:      time.UnixMilli(l.CTime),
+		})
+	}
+	return res, nil
+}
@@ -3,11 +3,14 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 	"webook/internal/domain"
+	events "webook/internal/events/article"
 	"webook/internal/repository"
 	"webook/internal/repository/cache"
 	articledao "webook/internal/repository/dao/article"
 	"webook/pkg/logger"
+	"webook/pkg/outbox"
 
 	"github.com/ecodeclub/ekit/slice"
 	"gorm.io/gorm"
@@ -196,7 +199,12 @@ func (c *ArticleRepository_) toEntity(art domain.Article) articledao.Article {
 	}
 */
 func (c *ArticleRepository_) Sync2(ctx context.Context, art domain.Article) (int64, error) {
-	id, err := c.dao.Sync(ctx, c.toEntity(art))
+	// 发布事件和文章在同一个事务里面写进发件箱
+	id, err := c.dao.Sync(ctx, c.toEntity(art), func(tx *gorm.DB, id int64) error {
+		return outbox.Add(ctx, tx, events.PublishedEvent(ctx, events.ArticleEvent{
+			Aid: id, Uid: art.Author.ID, UTime: time.Now().UnixMilli(),
+		}))
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -219,7 +227,15 @@ func (c *ArticleRepository_) SyncStatus(ctx context.Context, art domain.Article)
 			c.cache.DelPub(ctx, art.ID)
 		}()
 	}
-	return c.dao.SyncStatus(ctx, c.toEntity(art))
+	var hooks []articledao.TxHook
+	if art.Status == domain.ArticleStatusWithdraw {
+		hooks = append(hooks, func(tx *gorm.DB, id int64) error {
+			return outbox.Add(ctx, tx, events.WithdrawnEvent(ctx, events.ArticleEvent{
+				Aid: id, Uid: art.Author.ID, UTime: time.Now().UnixMilli(),
+			}))
+		})
+	}
+	return c.dao.SyncStatus(ctx, c.toEntity(art), hooks...)
 }
 
 func (c *ArticleRepository_) List(ctx context.Context, uid int64, offset int, limit int) ([]domain.Article, error) {
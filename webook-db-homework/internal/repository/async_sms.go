@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+	"webook/pkg/cryptox"
+)
+
+var (
+	ErrWaitingSMSNotFound = dao.ErrWaitingSMSNotFound
+	ErrAsyncSMSPreempted  = dao.ErrAsyncSMSPreempted
+	// ErrAsyncSMSInvalid 抢到的短信解析不出来，已经标记为失败，不会再被抢到
+	ErrAsyncSMSInvalid = errors.New("异步短信数据无法解析")
+)
+
+type AsyncSmsRepository interface {
+	Add(ctx context.Context, s domain.AsyncSms) error
+	PreemptWaitingSMS(ctx context.Context) (domain.AsyncSms, error)
+	// ReportScheduleResult sms 是 PreemptWaitingSMS 返回的，超时之后被别人重新抢走的返回 ErrAsyncSMSPreempted
+	ReportScheduleResult(ctx context.Context, sms domain.AsyncSms, success bool) error
+	Backlog(ctx context.Context) (int64, error)
+}
+
+type AsyncSmsRepository_ struct {
+	dao    dao.AsyncSmsDAO
+	cipher *cryptox.AESGCM
+}
+
+func NewAsyncSmsRepository(dao dao.AsyncSmsDAO, cipher *cryptox.AESGCM) AsyncSmsRepository {
+	return &AsyncSmsRepository_{dao: dao, cipher: cipher}
+}
+
+// smsConfig 存到数据库里面的发送参数
+type smsConfig struct {
+	Biz     string   `json:"biz"`
+	Args    []string `json:"args"`
+	Numbers []string `json:"numbers"`
+}
+
+func (r *AsyncSmsRepository_) Add(ctx context.Context, s domain.AsyncSms) error {
+	cfg, err := json.Marshal(smsConfig{Biz: s.Biz, Args: s.Args, Numbers: s.Numbers})
+	if err != nil {
+		return err
+	}
+	encrypted, err := r.cipher.Encrypt(cfg)
+	if err != nil {
+		return err
+	}
+	return r.dao.Insert(ctx, dao.AsyncSms{
+		Config:   encrypted,
+		RetryMax: s.RetryMax,
+	})
+}
+
+func (r *AsyncSmsRepository_) PreemptWaitingSMS(ctx context.Context) (domain.AsyncSms, error) {
+	as, err := r.dao.GetWaitingSMS(ctx)
+	if err != nil {
+		return domain.AsyncSms{}, err
+	}
+	cfg, err := r.decode(as.Config)
+	if err != nil {
+		// 不标记的话每次都会被重新抢到
+		if er := r.dao.MarkAbandoned(ctx, as.Id, as.Version); er != nil {
+			return domain.AsyncSms{}, errors.Join(err, er)
+		}
+		return domain.AsyncSms{Id: as.Id}, fmt.Errorf("%w: %w", ErrAsyncSMSInvalid, err)
+	}
+	return domain.AsyncSms{
+		Id:       as.Id,
+		Biz:      cfg.Biz,
+		Args:     cfg.Args,
+		Numbers:  cfg.Numbers,
+		RetryMax: as.RetryMax,
+		RetryCnt: as.RetryCnt,
+		Version:  as.Version,
+	}, nil
+}
+
+func (r *AsyncSmsRepository_) ReportScheduleResult(ctx context.Context, sms domain.AsyncSms, success bool) error {
+	if success {
+		return r.dao.MarkSuccess(ctx, sms.Id, sms.Version)
+	}
+	return r.dao.MarkFailed(ctx, sms.Id, sms.Version, sms.RetryCnt)
+}
+
+func (r *AsyncSmsRepository_) Backlog(ctx context.Context) (int64, error) {
+	return r.dao.CountBacklog(ctx)
+}
+
+func (r *AsyncSmsRepository_) decode(config string) (smsConfig, error) {
+	var cfg smsConfig
+	data, err := r.cipher.Decrypt(config)
+	if err != nil {
+		return smsConfig{}, err
+	}
+	err = json.Unmarshal(data, &cfg)
+	return cfg, err
+}
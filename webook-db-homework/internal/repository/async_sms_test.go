@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+	"webook/pkg/cryptox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAsyncSmsDAO 只保存最后一条记录
+type memoryAsyncSmsDAO struct {
+	dao.AsyncSmsDAO
+	sms       dao.AsyncSms
+	abandoned []int64
+}
+
+func (d *memoryAsyncSmsDAO) Insert(ctx context.Context, s dao.AsyncSms) error {
+	d.sms = s
+	return nil
+}
+
+func (d *memoryAsyncSmsDAO) GetWaitingSMS(ctx context.Context) (dao.AsyncSms, error) {
+	return d.sms, nil
+}
+
+func (d *memoryAsyncSmsDAO) MarkAbandoned(ctx context.Context, id int64, version int64) error {
+	if version != d.sms.Version {
+		return dao.ErrAsyncSMSPreempted
+	}
+	d.abandoned = append(d.abandoned, id)
+	return nil
+}
+
+func TestAsyncSmsRepository(t *testing.T) {
+	cipher, err := cryptox.NewAESGCM([]byte("test-key"))
+	require.NoError(t, err)
+	testCases := []struct {
+		name string
+		// before 抢占之前数据库里面的记录
+		before func(t *testing.T, repo AsyncSmsRepository, d *memoryAsyncSmsDAO)
+
+		wantSms       domain.AsyncSms
+		wantErr       error
+		wantAbandoned []int64
+	}{
+		{
+			name: "加密保存，抢占的时候解密",
+			before: func(t *testing.T, repo AsyncSmsRepository, d *memoryAsyncSmsDAO) {
+				require.NoError(t, repo.Add(context.Background(), domain.AsyncSms{
+					Biz: "tpl", Args: []string{"123456"}, Numbers: []string{"152"}, RetryMax: 3,
+				}))
+				// 验证码不能以明文出现在数据库里面
+				assert.NotContains(t, d.sms.Config, "123456")
+				d.sms.Id, d.sms.RetryCnt, d.sms.Version = 1, 1, 2
+			},
+			wantSms: domain.AsyncSms{Id: 1, Biz: "tpl", Args: []string{"123456"}, Numbers: []string{"152"},
+				RetryMax: 3, RetryCnt: 1, Version: 2},
+		},
+		{
+			name: "解析失败，标记为失败",
+			before: func(t *testing.T, repo AsyncSmsRepository, d *memoryAsyncSmsDAO) {
+				d.sms = dao.AsyncSms{Id: 3, Config: "broken", Version: 4}
+			},
+			wantSms:       domain.AsyncSms{Id: 3},
+			wantErr:       ErrAsyncSMSInvalid,
+			wantAbandoned: []int64{3},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &memoryAsyncSmsDAO{}
+			repo := NewAsyncSmsRepository(d, cipher)
+			tc.before(t, repo, d)
+			as, err := repo.PreemptWaitingSMS(context.Background())
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantSms, as)
+			assert.Equal(t, tc.wantAbandoned, d.abandoned)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginRiskCache 登录风控规则用到的计数和状态
+type LoginRiskCache interface {
+	// IncrFailures 账号在窗口内连续失败的次数
+	IncrFailures(ctx context.Context, account string, window time.Duration) (int64, error)
+	ResetFailures(ctx context.Context, account string) error
+	// Lock 已经锁定的账号不会延长锁定时间
+	Lock(ctx context.Context, account string, duration time.Duration) error
+	Locked(ctx context.Context, account string) (bool, error)
+	// AddDevice 记录用户登录过的设备，返回这个设备是不是第一次出现，以及用户之前有没有登录过
+	AddDevice(ctx context.Context, uid int64, device string) (isNew bool, hasHistory bool, err error)
+	// AddIPAccount 记录 IP 在窗口内登录过的账号，返回不同账号的数量
+	AddIPAccount(ctx context.Context, ip string, account string, window time.Duration) (int64, error)
+}
+
+type RedisLoginRiskCache struct {
+	cmd redis.Cmdable
+	// deviceExpiration 太久没有用过的设备就当作新设备
+	deviceExpiration time.Duration
+}
+
+func NewLoginRiskCache(cmd redis.Cmdable) LoginRiskCache {
+	return &RedisLoginRiskCache{
+		cmd:              cmd,
+		deviceExpiration: time.Hour * 24 * 90,
+	}
+}
+
+func (c *RedisLoginRiskCache) IncrFailures(ctx context.Context, account string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf("webook:login:failures:%s", account)
+	pipe := c.cmd.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (c *RedisLoginRiskCache) ResetFailures(ctx context.Context, account string) error {
+	return c.cmd.Del(ctx, fmt.Sprintf("webook:login:failures:%s", account)).Err()
+}
+
+func (c *RedisLoginRiskCache) Lock(ctx context.Context, account string, duration time.Duration) error {
+	return c.cmd.SetNX(ctx, c.lockKey(account), "1", duration).Err()
+}
+
+func (c *RedisLoginRiskCache) Locked(ctx context.Context, account string) (bool, error) {
+	cnt, err := c.cmd.Exists(ctx, c.lockKey(account)).Result()
+	return cnt > 0, err
+}
+
+func (c *RedisLoginRiskCache) AddDevice(ctx context.Context, uid int64, device string) (bool, bool, error) {
+	key := fmt.Sprintf("webook:login:devices:%d", uid)
+	pipe := c.cmd.TxPipeline()
+	card := pipe.SCard(ctx, key)
+	added := pipe.SAdd(ctx, key, device)
+	pipe.Expire(ctx, key, c.deviceExpiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, false, err
+	}
+	return added.Val() > 0, card.Val() > 0, nil
+}
+
+func (c *RedisLoginRiskCache) AddIPAccount(ctx context.Context, ip string, account string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf("webook:login:ip_accounts:%s", ip)
+	pipe := c.cmd.TxPipeline()
+	pipe.SAdd(ctx, key, account)
+	pipe.ExpireNX(ctx, key, window)
+	card := pipe.SCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return card.Val(), nil
+}
+
+func (c *RedisLoginRiskCache) lockKey(account string) string {
+	return fmt.Sprintf("webook:login:locked:%s", account)
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TwoFactorCache 记录二次验证的尝试次数，用来限制暴力猜测验证码
+type TwoFactorCache interface {
+	IncrAttempts(ctx context.Context, uid int64) (int64, error)
+	ResetAttempts(ctx context.Context, uid int64) error
+}
+
+type RedisTwoFactorCache struct {
+	cmd redis.Cmdable
+	// window 计数的时间窗口，从第一次尝试开始算
+	window time.Duration
+}
+
+func NewTwoFactorCache(cmd redis.Cmdable) TwoFactorCache {
+	return &RedisTwoFactorCache{
+		cmd:    cmd,
+		window: time.Minute * 15,
+	}
+}
+
+func (c *RedisTwoFactorCache) IncrAttempts(ctx context.Context, uid int64) (int64, error) {
+	key := c.key(uid)
+	pipe := c.cmd.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	// NX 保证窗口从第一次尝试开始算，不会因为一直尝试而一直续期
+	pipe.ExpireNX(ctx, key, c.window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (c *RedisTwoFactorCache) ResetAttempts(ctx context.Context, uid int64) error {
+	return c.cmd.Del(ctx, c.key(uid)).Err()
+}
+
+func (c *RedisTwoFactorCache) key(uid int64) string {
+	return fmt.Sprintf("webook:2fa:attempts:%d", uid)
+}
@@ -0,0 +1,75 @@
+// Package layered 优先使用 Redis，Redis 不可用的时候降级到本地缓存
+package layered
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+	"webook/internal/repository/cache/vcode"
+	"webook/pkg/logger"
+)
+
+// LayeredCodeCache primary 出错的时候切换到 fallback，retryAfter 时间内都用 fallback，之后再尝试 primary。
+// 降级期间发出去的验证码只在当前节点有效，请求落到别的节点上会校验失败，这是可以接受的代价
+type LayeredCodeCache struct {
+	primary    vcode.CodeCache
+	fallback   vcode.CodeCache
+	l          logger.LoggerV1
+	retryAfter time.Duration
+	// degradedUntil 降级到什么时候，毫秒时间戳
+	degradedUntil atomic.Int64
+	now           func() time.Time
+}
+
+func NewLayeredCodeCache(primary vcode.CodeCache, fallback vcode.CodeCache,
+	retryAfter time.Duration, l logger.LoggerV1) *LayeredCodeCache {
+	return &LayeredCodeCache{
+		primary:    primary,
+		fallback:   fallback,
+		l:          l,
+		retryAfter: retryAfter,
+		now:        time.Now,
+	}
+}
+
+func (c *LayeredCodeCache) Set(ctx context.Context, biz string, phone string, code string) error {
+	return c.do(func(cache vcode.CodeCache) error {
+		return cache.Set(ctx, biz, phone, code)
+	})
+}
+
+func (c *LayeredCodeCache) CheckResend(ctx context.Context, biz string, phone string) error {
+	return c.do(func(cache vcode.CodeCache) error {
+		return cache.CheckResend(ctx, biz, phone)
+	})
+}
+
+func (c *LayeredCodeCache) Verify(ctx context.Context, biz string, phone string, code string) error {
+	return c.do(func(cache vcode.CodeCache) error {
+		return cache.Verify(ctx, biz, phone, code)
+	})
+}
+
+func (c *LayeredCodeCache) do(fn func(cache vcode.CodeCache) error) error {
+	now := c.now().UnixMilli()
+	if now < c.degradedUntil.Load() {
+		return fn(c.fallback)
+	}
+	err := fn(c.primary)
+	if err == nil || isBizErr(err) {
+		return err
+	}
+	old := c.degradedUntil.Load()
+	if c.degradedUntil.CompareAndSwap(old, now+c.retryAfter.Milliseconds()) && old <= now {
+		c.l.Warn("验证码缓存出错，降级到本地缓存", logger.Error(err))
+	}
+	return fn(c.fallback)
+}
+
+// isBizErr 这些错误是正常的业务结果，不代表缓存不可用
+func isBizErr(err error) bool {
+	return errors.Is(err, vcode.ErrSetCodeBusy) ||
+		errors.Is(err, vcode.ErrVarifyCodeTooMany) ||
+		errors.Is(err, vcode.ErrVarifyCodeInvalid)
+}
@@ -0,0 +1,86 @@
+package layered
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"webook/internal/repository/cache/vcode"
+	"webook/internal/repository/cache/vcode/memory"
+	"webook/internal/repository/cache/vcode/vcodetest"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// brokenCodeCache 模拟 Redis 不可用
+type brokenCodeCache struct {
+	calls int
+}
+
+func (c *brokenCodeCache) Set(ctx context.Context, biz string, phone string, code string) error {
+	c.calls++
+	return errors.New("redis 连接失败")
+}
+
+func (c *brokenCodeCache) CheckResend(ctx context.Context, biz string, phone string) error {
+	c.calls++
+	return errors.New("redis 连接失败")
+}
+
+func (c *brokenCodeCache) Verify(ctx context.Context, biz string, phone string, code string) error {
+	c.calls++
+	return errors.New("redis 连接失败")
+}
+
+// fakeClock 本地缓存和降级判断共用一个时间
+type fakeClock struct {
+	now time.Time
+}
+
+func newBackend(primary vcode.CodeCache, clock *fakeClock, fallback *memory.MemoryCodeCache) vcodetest.Backend {
+	c := NewLayeredCodeCache(primary, fallback, time.Second*10, logger.NewZapLogger(zap.NewNop()))
+	c.now = func() time.Time { return clock.now }
+	return vcodetest.Backend{
+		Cache: c,
+		Advance: func(d time.Duration) {
+			clock.now = clock.now.Add(d)
+		},
+	}
+}
+
+func TestLayeredCodeCache(t *testing.T) {
+	t.Run("primary 正常", func(t *testing.T) {
+		vcodetest.RunConformance(t, func(t *testing.T) vcodetest.Backend {
+			clock := &fakeClock{now: time.UnixMilli(1_000_000)}
+			primary := memory.NewMemoryCodeCache(100).Clock(func() time.Time { return clock.now })
+			return newBackend(primary, clock, memory.NewMemoryCodeCache(100))
+		})
+	})
+	t.Run("primary 不可用", func(t *testing.T) {
+		vcodetest.RunConformance(t, func(t *testing.T) vcodetest.Backend {
+			clock := &fakeClock{now: time.UnixMilli(1_000_000)}
+			fallback := memory.NewMemoryCodeCache(100).Clock(func() time.Time { return clock.now })
+			return newBackend(&brokenCodeCache{}, clock, fallback)
+		})
+	})
+}
+
+func TestLayeredCodeCache_Degrade(t *testing.T) {
+	ctx := context.Background()
+	now := time.UnixMilli(1_000_000)
+	primary := &brokenCodeCache{}
+	c := NewLayeredCodeCache(primary, memory.NewMemoryCodeCache(100), time.Second*10, logger.NewZapLogger(zap.NewNop()))
+	c.now = func() time.Time { return now }
+
+	assert.NoError(t, c.Set(ctx, "login", "13800000000", "123456"))
+	assert.Equal(t, 1, primary.calls)
+	// 降级期间不访问 primary
+	assert.NoError(t, c.Verify(ctx, "login", "13800000000", "123456"))
+	assert.Equal(t, 1, primary.calls)
+	// 过了 retryAfter 重新尝试 primary
+	now = now.Add(time.Second * 10)
+	assert.ErrorIs(t, c.Verify(ctx, "login", "13800000000", "123456"), vcode.ErrVarifyCodeInvalid)
+	assert.Equal(t, 2, primary.calls)
+}
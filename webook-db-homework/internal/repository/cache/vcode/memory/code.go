@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"container/list"
 	"context"
 	"fmt"
 	"sync"
@@ -8,115 +9,120 @@ import (
 	"webook/internal/repository/cache/vcode"
 )
 
-type CodeInfo struct {
-	Code  string
-	Cnt   int64
-	Utime int64
+type codeEntry struct {
+	key  string
+	code string
+	// cnt 剩余的校验次数
+	cnt      int
+	sendAt   time.Time
+	expireAt time.Time
 }
+
+// MemoryCodeCache 本地 LRU，超过容量的时候淘汰最久没有访问的验证码。
+// 只在当前节点有效，单机部署或者 Redis 不可用的时候用
 type MemoryCodeCache struct {
-	mp    map[string]CodeInfo
-	cache vcode.CodeCache
-	mu    sync.RWMutex
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+	now      func() time.Time
 }
 
-func NewMemoryCodeCache(mp map[string]CodeInfo, cache vcode.CodeCache) vcode.CodeCache {
+func NewMemoryCodeCache(capacity int) *MemoryCodeCache {
 	return &MemoryCodeCache{
-		mp:    mp,
-		cache: cache,
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element, capacity),
+		now:      time.Now,
 	}
 }
 
+// Clock 替换获取当前时间的方法，测试的时候用来控制过期
+func (c *MemoryCodeCache) Clock(now func() time.Time) *MemoryCodeCache {
+	c.now = now
+	return c
+}
+
 func (c *MemoryCodeCache) Set(ctx context.Context, biz string, phone string, code string) error {
 	key := c.key(biz, phone)
-	var res int
-	c.mu.RLock()
-	info, ok := c.mp[key]
-	if ok {
-		_, ttl := c.parse(info)
-		if ttl < 60 {
-			res = -1
-		} else if ttl < 0 {
-			res = -2
-		}
-	}
-	c.mu.RUnlock()
-	if res == -1 {
-		return vcode.ErrSetCodeBusy
-	} else if res == -2 {
-		return vcode.ErrSetCodeSystemError
-	}
+	now := c.now()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	newinfo, okNew := c.mp[key]
-	if newinfo != info || (!ok && okNew) {
-		return vcode.ErrSetCodeBusy
-	}
-	if ok && !okNew {
-		//可能是校验后删掉了
-		return vcode.ErrSetCodeSystemError
-	}
-	if okNew {
-		_, ttl := c.parse(newinfo)
-		if ttl > 60 {
-			c.mp[key] = CodeInfo{
-				Code:  code,
-				Cnt:   3,
-				Utime: time.Now().UnixMilli(),
-			}
-		} else if ttl < 0 {
-			return vcode.ErrSetCodeSystemError
-		} else {
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*codeEntry)
+		if now.Before(e.expireAt) && now.Sub(e.sendAt) < vcode.ResendInterval {
 			return vcode.ErrSetCodeBusy
 		}
-	} else {
-		c.mp[key] = CodeInfo{
-			Code:  code,
-			Cnt:   3,
-			Utime: time.Now().UnixMilli(),
-		}
+		e.code = code
+		e.cnt = vcode.MaxVerifyCnt
+		e.sendAt = now
+		e.expireAt = now.Add(vcode.CodeExpiration)
+		c.ll.MoveToFront(elem)
+		return nil
+	}
+	c.items[key] = c.ll.PushFront(&codeEntry{
+		key:      key,
+		code:     code,
+		cnt:      vcode.MaxVerifyCnt,
+		sendAt:   now,
+		expireAt: now.Add(vcode.CodeExpiration),
+	})
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
 	}
 	return nil
 }
 
-func (c *MemoryCodeCache) key(biz string, phone string) string {
-	return fmt.Sprintf("phone_code:%s:%s", biz, phone)
-}
+func (c *MemoryCodeCache) CheckResend(ctx context.Context, biz string, phone string) error {
+	key := c.key(biz, phone)
+	now := c.now()
 
-func (c *MemoryCodeCache) parse(info CodeInfo) (int64, int64) {
-	return info.Cnt, int64(time.Since(time.UnixMilli(info.Utime)).Seconds())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*codeEntry)
+		if now.Before(e.expireAt) && now.Sub(e.sendAt) < vcode.ResendInterval {
+			return vcode.ErrSetCodeBusy
+		}
+	}
+	return nil
 }
 
 func (c *MemoryCodeCache) Verify(ctx context.Context, biz string, phone string, code string) error {
 	key := c.key(biz, phone)
+	now := c.now()
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
-
-	info, ok := c.mp[key]
+	elem, ok := c.items[key]
 	if !ok {
 		return vcode.ErrVarifyCodeInvalid
 	}
-
-	cnt, ttl := c.parse(info)
-	if ttl < 0 || ttl > 600 {
+	e := elem.Value.(*codeEntry)
+	if !now.Before(e.expireAt) {
+		c.remove(elem)
 		return vcode.ErrVarifyCodeInvalid
 	}
-
-	if cnt <= 0 {
+	c.ll.MoveToFront(elem)
+	if e.cnt <= 0 {
 		return vcode.ErrVarifyCodeTooMany
 	}
-
-	if info.Code != code {
+	if e.code != code {
 		// 验证码错误，减少剩余次数
-		c.mp[key] = CodeInfo{
-			Code:  info.Code,
-			Cnt:   cnt - 1,
-			Utime: info.Utime,
-		}
+		e.cnt--
 		return vcode.ErrVarifyCodeInvalid
 	}
-
 	// 验证成功，删除验证码
-	delete(c.mp, key)
+	c.remove(elem)
 	return nil
 }
+
+func (c *MemoryCodeCache) remove(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*codeEntry).key)
+}
+
+func (c *MemoryCodeCache) key(biz string, phone string) string {
+	return fmt.Sprintf("phone_code:%s:%s", biz, phone)
+}
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+	"webook/internal/repository/cache/vcode"
+	"webook/internal/repository/cache/vcode/vcodetest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCodeCache(t *testing.T) {
+	vcodetest.RunConformance(t, func(t *testing.T) vcodetest.Backend {
+		now := time.UnixMilli(1_000_000)
+		c := NewMemoryCodeCache(100).Clock(func() time.Time { return now })
+		return vcodetest.Backend{
+			Cache: c,
+			Advance: func(d time.Duration) {
+				now = now.Add(d)
+			},
+		}
+	})
+}
+
+func TestMemoryCodeCache_Evict(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCodeCache(2)
+	assert.NoError(t, c.Set(ctx, "login", "1", "111111"))
+	assert.NoError(t, c.Set(ctx, "login", "2", "222222"))
+	// 访问过的不会被淘汰
+	assert.ErrorIs(t, c.Verify(ctx, "login", "1", "000000"), vcode.ErrVarifyCodeInvalid)
+	assert.NoError(t, c.Set(ctx, "login", "3", "333333"))
+
+	assert.Equal(t, 2, c.ll.Len())
+	assert.ErrorIs(t, c.Verify(ctx, "login", "2", "222222"), vcode.ErrVarifyCodeInvalid)
+	assert.NoError(t, c.Verify(ctx, "login", "1", "111111"))
+	assert.NoError(t, c.Verify(ctx, "login", "3", "333333"))
+}
@@ -17,7 +17,6 @@ var luaVarifyCode string
 
 type RedisCodeCache struct {
 	client redis.Cmdable
-	cache  vcode.CodeCache
 }
 
 func NewRedisCodeCache(client redis.Cmdable) vcode.CodeCache {
@@ -27,7 +26,9 @@ func NewRedisCodeCache(client redis.Cmdable) vcode.CodeCache {
 }
 
 func (c *RedisCodeCache) Set(ctx context.Context, biz string, phone string, code string) error {
-	res, err := c.client.Eval(ctx, luaSetCode, []string{c.key(biz, phone)}, code, 600).Int()
+	res, err := c.client.Eval(ctx, luaSetCode, []string{c.key(biz, phone)}, code,
+		int(vcode.CodeExpiration.Seconds()), int((vcode.CodeExpiration - vcode.ResendInterval).Seconds()),
+		vcode.MaxVerifyCnt).Int()
 	if err != nil {
 		return err
 	}
@@ -41,6 +42,25 @@ func (c *RedisCodeCache) Set(ctx context.Context, biz string, phone string, code
 	}
 }
 
+func (c *RedisCodeCache) CheckResend(ctx context.Context, biz string, phone string) error {
+	ttl, err := c.client.TTL(ctx, c.key(biz, phone)).Result()
+	if err != nil {
+		return err
+	}
+	switch {
+	case ttl == -2:
+		// 没有发送过
+		return nil
+	case ttl < 0:
+		// 没有设置过期时间，和 set_code.lua 一样当作系统错误
+		return vcode.ErrSetCodeSystemError
+	case ttl > vcode.CodeExpiration-vcode.ResendInterval:
+		return vcode.ErrSetCodeBusy
+	default:
+		return nil
+	}
+}
+
 func (c *RedisCodeCache) key(biz string, phone string) string {
 	return fmt.Sprintf("phone_code:%s:%s", biz, phone)
 }
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+	"webook/internal/repository/cache/vcode/vcodetest"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRedisCodeCache 需要一个可以随便清空验证码的 Redis，通过 WEBOOK_TEST_REDIS_ADDR 指定
+func TestRedisCodeCache(t *testing.T) {
+	addr := os.Getenv("WEBOOK_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("没有设置 WEBOOK_TEST_REDIS_ADDR")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	vcodetest.RunConformance(t, func(t *testing.T) vcodetest.Backend {
+		ctx := context.Background()
+		keys, err := client.Keys(ctx, "phone_code:*").Result()
+		require.NoError(t, err)
+		if len(keys) > 0 {
+			require.NoError(t, client.Del(ctx, keys...).Err())
+		}
+		return vcodetest.Backend{
+			Cache: NewRedisCodeCache(client),
+			// Redis 的时间没办法控制，只能把所有 key 的过期时间减掉 d
+			Advance: func(d time.Duration) {
+				keys, err := client.Keys(ctx, "phone_code:*").Result()
+				require.NoError(t, err)
+				for _, key := range keys {
+					ttl, err := client.PTTL(ctx, key).Result()
+					require.NoError(t, err)
+					if ttl-d <= 0 {
+						require.NoError(t, client.Del(ctx, key).Err())
+						continue
+					}
+					require.NoError(t, client.PExpire(ctx, key, ttl-d).Err())
+				}
+			},
+		}
+	})
+}
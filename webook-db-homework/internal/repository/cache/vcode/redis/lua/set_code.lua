@@ -1,18 +1,24 @@
 local key = KEYS[1]
 local cntKey = key..":cnt"
 local val = ARGV[1]
+-- 有效期，秒
+local expiration = tonumber(ARGV[2])
+-- 剩余时间小于这个值才允许重新发送，也就是 有效期 - 重发间隔
+local resendTTL = tonumber(ARGV[3])
+-- 可以校验的次数
+local maxCnt = tonumber(ARGV[4])
 local ttl = tonumber(redis.call("ttl", key))
 
 if ttl == -1 then
     --没有设置过期时间，返回系统错误
     return -2
-elseif ttl == -2 or ttl<540 then
+elseif ttl == -2 or ttl<=resendTTL then
     redis.call("set",key,val)
-    redis.call("expire",key,600)
-    redis.call("set",cntKey,3)
-    redis.call("expire",cntKey,600)
+    redis.call("expire",key,expiration)
+    redis.call("set",cntKey,maxCnt)
+    redis.call("expire",cntKey,expiration)
     return 0
 else
-    --设置过期时间，但是过期时间小于540秒，返回系统错误
+    --距离上一次发送还不到重发间隔，发送太频繁
     return -1
 end
@@ -3,6 +3,17 @@ package vcode
 import (
 	"context"
 	"errors"
+	"time"
+)
+
+// 所有实现都要遵守的规则，vcodetest 里面的测试会检查
+const (
+	// CodeExpiration 验证码的有效期
+	CodeExpiration = time.Minute * 10
+	// ResendInterval 同一个手机号两次发送之间至少间隔这么久
+	ResendInterval = time.Minute
+	// MaxVerifyCnt 一个验证码最多可以校验几次
+	MaxVerifyCnt = 3
 )
 
 var (
@@ -14,5 +25,7 @@ var (
 
 type CodeCache interface {
 	Set(ctx context.Context, biz string, phone string, code string) error
+	// CheckResend 只检查现在能不能重新发送，不修改数据，Set 的时候还会再检查一次
+	CheckResend(ctx context.Context, biz string, phone string) error
 	Verify(ctx context.Context, biz string, phone string, code string) error
 }
@@ -0,0 +1,115 @@
+// Package vcodetest 所有 vcode.CodeCache 实现都要通过的测试，保证语义一致
+package vcodetest
+
+import (
+	"context"
+	"testing"
+	"time"
+	"webook/internal/repository/cache/vcode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Backend 被测试的实现。Advance 让时间往前走，用来测试重发间隔和过期
+type Backend struct {
+	Cache   vcode.CodeCache
+	Advance func(d time.Duration)
+}
+
+// RunConformance 每个用例都会调用 newBackend 创建一个新的实现
+func RunConformance(t *testing.T, newBackend func(t *testing.T) Backend) {
+	const biz, phone = "login", "13800000000"
+	testCases := []struct {
+		name string
+		run  func(t *testing.T, b Backend)
+	}{
+		{
+			name: "校验成功之后验证码失效",
+			run: func(t *testing.T, b Backend) {
+				ctx := context.Background()
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "123456"))
+				assert.NoError(t, b.Cache.Verify(ctx, biz, phone, "123456"))
+				assert.ErrorIs(t, b.Cache.Verify(ctx, biz, phone, "123456"), vcode.ErrVarifyCodeInvalid)
+				// 校验成功之后可以马上重新发送
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "654321"))
+			},
+		},
+		{
+			name: "没有发送过",
+			run: func(t *testing.T, b Backend) {
+				assert.ErrorIs(t, b.Cache.Verify(context.Background(), biz, phone, "123456"), vcode.ErrVarifyCodeInvalid)
+			},
+		},
+		{
+			name: "重发间隔",
+			run: func(t *testing.T, b Backend) {
+				ctx := context.Background()
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "123456"))
+				assert.ErrorIs(t, b.Cache.Set(ctx, biz, phone, "111111"), vcode.ErrSetCodeBusy)
+				b.Advance(vcode.ResendInterval / 2)
+				assert.ErrorIs(t, b.Cache.Set(ctx, biz, phone, "111111"), vcode.ErrSetCodeBusy)
+				b.Advance(vcode.ResendInterval / 2)
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "222222"))
+				// 旧的验证码失效
+				assert.ErrorIs(t, b.Cache.Verify(ctx, biz, phone, "123456"), vcode.ErrVarifyCodeInvalid)
+				assert.NoError(t, b.Cache.Verify(ctx, biz, phone, "222222"))
+			},
+		},
+		{
+			name: "检查重发间隔不修改数据",
+			run: func(t *testing.T, b Backend) {
+				ctx := context.Background()
+				assert.NoError(t, b.Cache.CheckResend(ctx, biz, phone))
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "123456"))
+				assert.ErrorIs(t, b.Cache.CheckResend(ctx, biz, phone), vcode.ErrSetCodeBusy)
+				b.Advance(vcode.ResendInterval)
+				assert.NoError(t, b.Cache.CheckResend(ctx, biz, phone))
+				// 检查之后原来的验证码还在
+				assert.NoError(t, b.Cache.Verify(ctx, biz, phone, "123456"))
+			},
+		},
+		{
+			name: "校验次数用完",
+			run: func(t *testing.T, b Backend) {
+				ctx := context.Background()
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "123456"))
+				for i := 0; i < vcode.MaxVerifyCnt; i++ {
+					assert.ErrorIs(t, b.Cache.Verify(ctx, biz, phone, "000000"), vcode.ErrVarifyCodeInvalid)
+				}
+				assert.ErrorIs(t, b.Cache.Verify(ctx, biz, phone, "123456"), vcode.ErrVarifyCodeTooMany)
+				// 重新发送之后次数恢复
+				b.Advance(vcode.ResendInterval)
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "654321"))
+				assert.NoError(t, b.Cache.Verify(ctx, biz, phone, "654321"))
+			},
+		},
+		{
+			name: "过期",
+			run: func(t *testing.T, b Backend) {
+				ctx := context.Background()
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "123456"))
+				b.Advance(vcode.CodeExpiration + time.Second)
+				assert.ErrorIs(t, b.Cache.Verify(ctx, biz, phone, "123456"), vcode.ErrVarifyCodeInvalid)
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "654321"))
+			},
+		},
+		{
+			name: "不同业务和手机号互不影响",
+			run: func(t *testing.T, b Backend) {
+				ctx := context.Background()
+				assert.NoError(t, b.Cache.Set(ctx, biz, phone, "123456"))
+				assert.NoError(t, b.Cache.Set(ctx, "bind_phone", phone, "222222"))
+				assert.NoError(t, b.Cache.Set(ctx, biz, "13900000000", "333333"))
+				assert.ErrorIs(t, b.Cache.Verify(ctx, biz, phone, "222222"), vcode.ErrVarifyCodeInvalid)
+				assert.NoError(t, b.Cache.Verify(ctx, "bind_phone", phone, "222222"))
+				assert.NoError(t, b.Cache.Verify(ctx, biz, "13900000000", "333333"))
+				assert.NoError(t, b.Cache.Verify(ctx, biz, phone, "123456"))
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newBackend(t))
+		})
+	}
+}
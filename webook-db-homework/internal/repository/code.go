@@ -14,6 +14,8 @@ var (
 
 type CodeRepository interface {
 	Set(ctx context.Context, biz string, phone string, code string) error
+	// CheckResend 发送太频繁的时候返回 ErrCodeSendTooMany，不修改数据
+	CheckResend(ctx context.Context, biz string, phone string) error
 	Store(ctx context.Context, biz string, phone string, code string) error
 	Verify(ctx context.Context, biz string, phone string, code string) error
 }
@@ -32,6 +34,10 @@ func (r *CachedCodeRepository) Set(ctx context.Context, biz string, phone string
 	return r.cache.Set(ctx, biz, phone, code)
 }
 
+func (r *CachedCodeRepository) CheckResend(ctx context.Context, biz string, phone string) error {
+	return r.cache.CheckResend(ctx, biz, phone)
+}
+
 func (r *CachedCodeRepository) Store(ctx context.Context, biz string, phone string, code string) error {
 	return r.cache.Set(ctx, biz, phone, code)
 }
@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AdminAuditDAO interface {
+	Insert(ctx context.Context, log AdminAuditLog) error
+	List(ctx context.Context, offset int, limit int) ([]AdminAuditLog, error)
+}
+
+type GORMAdminAuditDAO struct {
+	db *gorm.DB
+}
+
+func NewAdminAuditDAO(db *gorm.DB) AdminAuditDAO {
+	return &GORMAdminAuditDAO{db: db}
+}
+
+// AdminAuditLog 审计记录只插入不修改
+type AdminAuditLog struct {
+	Id         int64  `gorm:"primaryKey,autoIncrement"`
+	OperatorId int64  `gorm:"index"`
+	Action     string `gorm:"type:varchar(64)"`
+	TargetType string `gorm:"type:varchar(32);index:target"`
+	TargetId   int64  `gorm:"index:target"`
+	Detail     string `gorm:"type:varchar(1024)"`
+	CTime      int64  `gorm:"column:c_time;index"`
+}
+
+func (dao *GORMAdminAuditDAO) Insert(ctx context.Context, log AdminAuditLog) error {
+	log.CTime = time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Create(&log).Error
+}
+
+func (dao *GORMAdminAuditDAO) List(ctx context.Context, offset int, limit int) ([]AdminAuditLog, error) {
+	var logs []AdminAuditLog
+	err := dao.db.WithContext(ctx).Order("id DESC").
+		Offset(offset).Limit(limit).Find(&logs).Error
+	return logs, err
+}
@@ -2,14 +2,19 @@ package dao
 
 import (
 	"context"
+
+	"gorm.io/gorm"
 )
 
+// TxHook 在 Sync 和 SyncStatus 的事务里面执行，id 是文章 id，比如用来写发件箱
+type TxHook func(tx *gorm.DB, id int64) error
+
 type ArticleDAO interface {
 	Insert(ctx context.Context, article Article) (int64, error)
 	Update(ctx context.Context, article Article) error
-	Sync(ctx context.Context, article Article) (int64, error)
+	Sync(ctx context.Context, article Article, hooks ...TxHook) (int64, error)
 	Upsert(ctx context.Context, article ReaderArticle) (int64, error)
-	SyncStatus(ctx context.Context, article Article) error
+	SyncStatus(ctx context.Context, article Article, hooks ...TxHook) error
 	GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]Article, error)
 	GetByID(ctx context.Context, id int64) (Article, error)
 	GetPubByID(ctx context.Context, id int64) (ReaderArticle, error)
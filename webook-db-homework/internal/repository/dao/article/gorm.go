@@ -76,35 +76,42 @@ func (dao *GORMArticleDAO) Update(ctx context.Context, article Article) error {
 	return nil
 }
 
-func (dao *GORMArticleDAO) Sync(ctx context.Context, article Article) (int64, error) {
+func (dao *GORMArticleDAO) Sync(ctx context.Context, article Article, hooks ...TxHook) (int64, error) {
 	var (
 		err error
 		id  int64
 	)
 	id = article.ID
-	err = dao.db.Transaction(func(tx *gorm.DB) error {
-
-		if err = tx.Error; err != nil {
-			return err
-		}
+	err = dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 制作库、线上库和 hooks 都要用同一个事务
+		txDAO := &GORMArticleDAO{db: tx}
 		if article.ID > 0 {
-			err = dao.Update(ctx, article)
+			err = txDAO.Update(ctx, article)
 		} else {
-			id, err = dao.Insert(ctx, article)
+			id, err = txDAO.Insert(ctx, article)
 		}
 		if err != nil {
 			return err
 		}
 		article.ID = id
-		id, err = dao.Upsert(ctx, ReaderArticle(article))
+		id, err = txDAO.Upsert(ctx, ReaderArticle(article))
 		if err != nil {
 			return err
 		}
-		return nil
+		return runHooks(tx, id, hooks)
 	})
 	return id, err
 }
 
+func runHooks(tx *gorm.DB, id int64, hooks []TxHook) error {
+	for _, hook := range hooks {
+		if err := hook(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (dao *GORMArticleDAO) Upsert(ctx context.Context, article ReaderArticle) (int64, error) {
 	now := time.Now().UnixMilli()
 	err := dao.db.WithContext(ctx).Clauses(clause.OnConflict{
@@ -120,7 +127,7 @@ func (dao *GORMArticleDAO) Upsert(ctx context.Context, article ReaderArticle) (i
 	return article.ID, err
 }
 
-func (dao *GORMArticleDAO) SyncStatus(ctx context.Context, article Article) error {
+func (dao *GORMArticleDAO) SyncStatus(ctx context.Context, article Article, hooks ...TxHook) error {
 	article.UpdatedAt = time.Now().UnixMilli()
 	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		res := tx.Model(&Article{}).
@@ -148,7 +155,7 @@ func (dao *GORMArticleDAO) SyncStatus(ctx context.Context, article Article) erro
 		if res.RowsAffected == 0 {
 			return errors.New("修改文章失败，可能是文章不存在或不是自己的文章")
 		}
-		return nil
+		return runHooks(tx, article.ID, hooks)
 	})
 }
 
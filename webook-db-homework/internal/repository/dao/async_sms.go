@@ -0,0 +1,178 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrWaitingSMSNotFound = gorm.ErrRecordNotFound
+
+// ErrAsyncSMSPreempted 乐观锁冲突，这条记录被别的 worker 抢走了
+var ErrAsyncSMSPreempted = errors.New("异步短信已经被其他节点抢占")
+
+const (
+	// asyncStatusWaiting 等待发送，包括发送失败等待重试
+	asyncStatusWaiting = iota
+	// asyncStatusSending 被 worker 抢占，正在发送
+	asyncStatusSending
+	// asyncStatusFailed 超过重试次数，不会再发送
+	asyncStatusFailed
+	asyncStatusSuccess
+)
+
+type AsyncSmsDAO interface {
+	Insert(ctx context.Context, s AsyncSms) error
+	// GetWaitingSMS 抢占一条到了重试时间的待发送短信，发送中但是长时间没有更新的也算，说明 worker 挂了；
+	// 已经发送了 retry_max 次的不再抢占，直接标记为最终失败
+	GetWaitingSMS(ctx context.Context) (AsyncSms, error)
+	// MarkSuccess、MarkFailed、MarkAbandoned 的 version 是抢占之后的版本号，
+	// 版本号对不上说明超时之后被别的 worker 重新抢走了，返回 ErrAsyncSMSPreempted，不会覆盖别人的状态
+	MarkSuccess(ctx context.Context, id int64, version int64) error
+	// MarkFailed 发送失败，还没有达到重试上限的放回去，退避一段时间之后再发送；retryCnt 是已经发送的次数
+	MarkFailed(ctx context.Context, id int64, version int64, retryCnt int) error
+	// MarkAbandoned 数据解析不出来，重试也没用，直接标记为最终失败
+	MarkAbandoned(ctx context.Context, id int64, version int64) error
+	// CountBacklog 还没有发送出去的短信数量
+	CountBacklog(ctx context.Context) (int64, error)
+}
+
+type GORMAsyncSmsDAO struct {
+	db *gorm.DB
+	// sendingTimeout 发送中超过这个时间就认为 worker 已经挂了，可以重新抢占
+	sendingTimeout time.Duration
+	// retryBackoff 第一次失败之后等多久再发，之后每次翻倍，最多 maxRetryBackoff，避免服务商挂了的时候一直空转
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+}
+
+func NewAsyncSmsDAO(db *gorm.DB) AsyncSmsDAO {
+	return &GORMAsyncSmsDAO{
+		db:              db,
+		sendingTimeout:  time.Minute,
+		retryBackoff:    time.Second * 10,
+		maxRetryBackoff: time.Minute * 5,
+	}
+}
+
+type AsyncSms struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// Config 是 biz、args、numbers 序列化之后的 JSON 加密之后的结果，参数里面有验证码，不能存明文
+	Config   string `gorm:"type:text"`
+	RetryCnt int
+	RetryMax int
+	Status   uint8 `gorm:"index:status_utime"`
+	// NextRetry 发送失败之后在这个时间之前不会再被抢占，毫秒
+	NextRetry int64 `gorm:"column:next_retry"`
+	// Version 乐观锁
+	Version int64
+	CTime   int64 `gorm:"column:c_time"`
+	UTime   int64 `gorm:"column:u_time;index:status_utime"`
+}
+
+func (dao *GORMAsyncSmsDAO) Insert(ctx context.Context, s AsyncSms) error {
+	now := time.Now().UnixMilli()
+	s.CTime = now
+	s.UTime = now
+	s.Status = asyncStatusWaiting
+	return dao.db.WithContext(ctx).Create(&s).Error
+}
+
+func (dao *GORMAsyncSmsDAO) GetWaitingSMS(ctx context.Context) (AsyncSms, error) {
+	now := time.Now().UnixMilli()
+	staleBefore := now - dao.sendingTimeout.Milliseconds()
+	// 最后一次发送的时候 worker 挂了，不会再被抢占，也不算积压，直接标记为最终失败，不然永远停在发送中
+	err := dao.db.WithContext(ctx).Model(&AsyncSms{}).
+		Where("status = ? AND u_time < ? AND retry_cnt >= retry_max", asyncStatusSending, staleBefore).
+		Updates(map[string]any{
+			"status":  asyncStatusFailed,
+			"version": gorm.Expr("version + 1"),
+			"u_time":  now,
+		}).Error
+	if err != nil {
+		return AsyncSms{}, err
+	}
+	var s AsyncSms
+	err = dao.db.WithContext(ctx).
+		Where("(status = ? AND next_retry <= ?) OR (status = ? AND u_time < ? AND retry_cnt < retry_max)",
+			asyncStatusWaiting, now, asyncStatusSending, staleBefore).
+		Order("u_time").First(&s).Error
+	if err != nil {
+		return AsyncSms{}, err
+	}
+	// 用 version 做乐观锁，只有一个 worker 能更新成功
+	res := dao.db.WithContext(ctx).Model(&AsyncSms{}).
+		Where("id = ? AND version = ?", s.Id, s.Version).
+		Updates(map[string]any{
+			"status":    asyncStatusSending,
+			"retry_cnt": gorm.Expr("retry_cnt + 1"),
+			"version":   gorm.Expr("version + 1"),
+			"u_time":    now,
+		})
+	if res.Error != nil {
+		return AsyncSms{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return AsyncSms{}, ErrAsyncSMSPreempted
+	}
+	s.RetryCnt++
+	s.Version++
+	s.Status = asyncStatusSending
+	return s, nil
+}
+
+func (dao *GORMAsyncSmsDAO) MarkSuccess(ctx context.Context, id int64, version int64) error {
+	return dao.update(ctx, id, version, map[string]any{
+		"status": asyncStatusSuccess,
+	})
+}
+
+func (dao *GORMAsyncSmsDAO) MarkFailed(ctx context.Context, id int64, version int64, retryCnt int) error {
+	return dao.update(ctx, id, version, map[string]any{
+		// 重试次数用完了就是最终失败
+		"status": gorm.Expr("CASE WHEN retry_cnt >= retry_max THEN ? ELSE ? END",
+			asyncStatusFailed, asyncStatusWaiting),
+		"next_retry": time.Now().Add(dao.backoff(retryCnt)).UnixMilli(),
+	})
+}
+
+func (dao *GORMAsyncSmsDAO) MarkAbandoned(ctx context.Context, id int64, version int64) error {
+	return dao.update(ctx, id, version, map[string]any{
+		"status": asyncStatusFailed,
+	})
+}
+
+// update 只有版本号还是抢占时的版本号才更新
+func (dao *GORMAsyncSmsDAO) update(ctx context.Context, id int64, version int64, values map[string]any) error {
+	values["version"] = gorm.Expr("version + 1")
+	values["u_time"] = time.Now().UnixMilli()
+	res := dao.db.WithContext(ctx).Model(&AsyncSms{}).
+		Where("id = ? AND version = ?", id, version).Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAsyncSMSPreempted
+	}
+	return nil
+}
+
+func (dao *GORMAsyncSmsDAO) backoff(retryCnt int) time.Duration {
+	d := dao.retryBackoff
+	for i := 1; i < retryCnt && d < dao.maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, dao.maxRetryBackoff)
+}
+
+func (dao *GORMAsyncSmsDAO) CountBacklog(ctx context.Context) (int64, error) {
+	var cnt int64
+	// 发送中的 worker 挂了，重试次数又用完了的不会再发送，不算积压
+	err := dao.db.WithContext(ctx).Model(&AsyncSms{}).
+		Where("status = ? OR (status = ? AND (u_time >= ? OR retry_cnt < retry_max))",
+			asyncStatusWaiting, asyncStatusSending, time.Now().UnixMilli()-dao.sendingTimeout.Milliseconds()).
+		Count(&cnt).Error
+	return cnt, err
+}
@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestGORMAsyncSmsDAO_GetWaitingSMS(t *testing.T) {
+	db, mock := newAsyncSmsMockDB(t)
+
+	// 发送中超时、重试次数又用完了的直接标记为最终失败
+	mock.ExpectExec("UPDATE `async_sms` SET .* WHERE status = \\? AND u_time < \\? AND retry_cnt >= retry_max").
+		WithArgs(asyncStatusFailed, sqlmock.AnyArg(), asyncStatusSending, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 等待中的要到了重试时间，发送中超时的记录只有重试次数还没用完才重新抢占
+	mock.ExpectQuery("SELECT \\* FROM `async_sms` WHERE \\(status = \\? AND next_retry <= \\?\\) OR \\(status = \\? AND u_time < \\? AND retry_cnt < retry_max\\) ORDER BY u_time").
+		WithArgs(asyncStatusWaiting, sqlmock.AnyArg(), asyncStatusSending, sqlmock.AnyArg(), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "config", "retry_cnt", "retry_max", "status", "version"}).
+			AddRow(1, "cfg", 1, 3, asyncStatusSending, 5))
+	mock.ExpectExec("UPDATE `async_sms` SET .* WHERE id = \\? AND version = \\?").
+		WithArgs(asyncStatusSending, sqlmock.AnyArg(), 1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	s, err := NewAsyncSmsDAO(db).GetWaitingSMS(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.RetryCnt)
+	assert.Equal(t, int64(6), s.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGORMAsyncSmsDAO_MarkFailed(t *testing.T) {
+	db, mock := newAsyncSmsMockDB(t)
+	// 带上抢占时的版本号，放回去的时候推迟下一次发送
+	mock.ExpectExec("UPDATE `async_sms` SET .* WHERE id = \\? AND version = \\?").
+		WithArgs(sqlmock.AnyArg(), asyncStatusFailed, asyncStatusWaiting, sqlmock.AnyArg(), 1, 6).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 超时之后被别的 worker 重新抢走了，版本号对不上
+	mock.ExpectExec("UPDATE `async_sms` SET .* WHERE id = \\? AND version = \\?").
+		WithArgs(asyncStatusSuccess, sqlmock.AnyArg(), 1, 6).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	d := NewAsyncSmsDAO(db)
+	assert.NoError(t, d.MarkFailed(context.Background(), 1, 6, 2))
+	assert.ErrorIs(t, d.MarkSuccess(context.Background(), 1, 6), ErrAsyncSMSPreempted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGORMAsyncSmsDAO_backoff(t *testing.T) {
+	d := NewAsyncSmsDAO(nil).(*GORMAsyncSmsDAO)
+	assert.Equal(t, time.Second*10, d.backoff(1))
+	assert.Equal(t, time.Second*20, d.backoff(2))
+	assert.Equal(t, time.Second*40, d.backoff(3))
+	assert.Equal(t, time.Minute*5, d.backoff(100))
+}
+
+func newAsyncSmsMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return db, mock
+}
@@ -2,18 +2,38 @@ package dao
 
 import (
 	intrdao "webook/interactive/repository/dao"
+	"webook/internal/domain"
 	article "webook/internal/repository/dao/article"
+	"webook/pkg/outbox"
 
 	"gorm.io/gorm"
 )
 
 func InitTables(db *gorm.DB) error {
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&User{},
+		&UserIdentity{},
+		&UserTOTP{},
+		&UserRecoveryCode{},
+		&Role{},
+		&RolePermission{},
+		&UserRole{},
+		&AdminAuditLog{},
+		&LoginLog{},
+		&AsyncSms{},
+		&SMSLog{},
 		&article.Article{},
 		&article.ReaderArticle{},
 		&intrdao.Interactive{},
 		&intrdao.UserLikeSomething{},
 		&intrdao.UserCollectSomething{},
+		&outbox.Message{},
 	)
+	if err != nil {
+		return err
+	}
+	if err = MigrateWechatIdentities(db); err != nil {
+		return err
+	}
+	return InitRoles(db, domain.DefaultRolePermissions)
 }
@@ -0,0 +1,43 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type LoginLogDAO interface {
+	BatchInsert(ctx context.Context, logs []LoginLog) error
+	FindByUid(ctx context.Context, uid int64, limit int) ([]LoginLog, error)
+}
+
+type GORMLoginLogDAO struct {
+	db *gorm.DB
+}
+
+func NewLoginLogDAO(db *gorm.DB) LoginLogDAO {
+	return &GORMLoginLogDAO{db: db}
+}
+
+type LoginLog struct {
+	Id        int64  `gorm:"primaryKey,autoIncrement"`
+	Account   string `gorm:"type:varchar(128);index"`
+	Uid       int64  `gorm:"index:uid_ctime"`
+	Method    string `gorm:"type:varchar(32)"`
+	IP        string `gorm:"column:ip;type:varchar(64);index"`
+	UserAgent string `gorm:"type:varchar(512)"`
+	Success   bool
+	Reason    string `gorm:"type:varchar(128)"`
+	CTime     int64  `gorm:"column:c_time;index:uid_ctime"`
+}
+
+func (dao *GORMLoginLogDAO) BatchInsert(ctx context.Context, logs []LoginLog) error {
+	return dao.db.WithContext(ctx).Create(&logs).Error
+}
+
+func (dao *GORMLoginLogDAO) FindByUid(ctx context.Context, uid int64, limit int) ([]LoginLog, error) {
+	var logs []LoginLog
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).
+		Order("c_time DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,123 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrRoleNotFound = errors.New("角色不存在")
+
+type RBACDAO interface {
+	// FindRoles 查询用户拥有的角色名
+	FindRoles(ctx context.Context, uid int64) ([]string, error)
+	// FindPermissions 查询用户所有角色的权限，已经去重
+	FindPermissions(ctx context.Context, uid int64) ([]string, error)
+	AssignRole(ctx context.Context, uid int64, role string) error
+	RevokeRole(ctx context.Context, uid int64, role string) error
+}
+
+type GORMRBACDAO struct {
+	db *gorm.DB
+}
+
+func NewRBACDAO(db *gorm.DB) RBACDAO {
+	return &GORMRBACDAO{db: db}
+}
+
+type Role struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Name  string `gorm:"type:varchar(64);uniqueIndex"`
+	CTime int64  `gorm:"column:c_time"`
+	UTime int64  `gorm:"column:u_time"`
+}
+
+type RolePermission struct {
+	Id         int64  `gorm:"primaryKey,autoIncrement"`
+	RoleId     int64  `gorm:"uniqueIndex:role_permission"`
+	Permission string `gorm:"type:varchar(64);uniqueIndex:role_permission"`
+	CTime      int64  `gorm:"column:c_time"`
+}
+
+type UserRole struct {
+	Id     int64 `gorm:"primaryKey,autoIncrement"`
+	Uid    int64 `gorm:"uniqueIndex:uid_role"`
+	RoleId int64 `gorm:"uniqueIndex:uid_role"`
+	CTime  int64 `gorm:"column:c_time"`
+}
+
+func (dao *GORMRBACDAO) FindRoles(ctx context.Context, uid int64) ([]string, error) {
+	var names []string
+	err := dao.db.WithContext(ctx).Model(&Role{}).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.uid = ?", uid).
+		Pluck("roles.name", &names).Error
+	return names, err
+}
+
+func (dao *GORMRBACDAO) FindPermissions(ctx context.Context, uid int64) ([]string, error) {
+	var perms []string
+	err := dao.db.WithContext(ctx).Model(&RolePermission{}).
+		Distinct("role_permissions.permission").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.uid = ?", uid).
+		Pluck("role_permissions.permission", &perms).Error
+	return perms, err
+}
+
+func (dao *GORMRBACDAO) AssignRole(ctx context.Context, uid int64, role string) error {
+	r, err := dao.findRole(ctx, role)
+	if err != nil {
+		return err
+	}
+	// 重复分配直接忽略
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&UserRole{Uid: uid, RoleId: r.Id, CTime: time.Now().UnixMilli()}).Error
+}
+
+func (dao *GORMRBACDAO) RevokeRole(ctx context.Context, uid int64, role string) error {
+	r, err := dao.findRole(ctx, role)
+	if err != nil {
+		return err
+	}
+	return dao.db.WithContext(ctx).Where("uid = ? AND role_id = ?", uid, r.Id).
+		Delete(&UserRole{}).Error
+}
+
+func (dao *GORMRBACDAO) findRole(ctx context.Context, role string) (Role, error) {
+	var r Role
+	err := dao.db.WithContext(ctx).Where("name = ?", role).First(&r).Error
+	if err == gorm.ErrRecordNotFound {
+		return Role{}, ErrRoleNotFound
+	}
+	return r, err
+}
+
+// InitRoles 初始化内置角色和它们的权限，已经存在的不会重复插入
+func InitRoles(db *gorm.DB, rolePerms map[string][]string) error {
+	now := time.Now().UnixMilli()
+	return db.Transaction(func(tx *gorm.DB) error {
+		for name, perms := range rolePerms {
+			err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&Role{Name: name, CTime: now, UTime: now}).Error
+			if err != nil {
+				return err
+			}
+			var r Role
+			if err = tx.Where("name = ?", name).First(&r).Error; err != nil {
+				return err
+			}
+			for _, perm := range perms {
+				err = tx.Clauses(clause.OnConflict{DoNothing: true}).
+					Create(&RolePermission{RoleId: r.Id, Permission: perm, CTime: now}).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type SMSLogDAO interface {
+	BatchInsert(ctx context.Context, logs []SMSLog) error
+	// Stats 按照服务商和日期聚合，provider 为空代表所有服务商，时间区间是 [start, end)
+	Stats(ctx context.Context, provider string, start int64, end int64) ([]SMSStat, error)
+}
+
+type GORMSMSLogDAO struct {
+	db *gorm.DB
+}
+
+func NewSMSLogDAO(db *gorm.DB) SMSLogDAO {
+	return &GORMSMSLogDAO{db: db}
+}
+
+type SMSLog struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Biz      string `gorm:"type:varchar(64)"`
+	Phone    string `gorm:"type:varchar(32)"`
+	Provider string `gorm:"type:varchar(32);index:provider_day"`
+	// Day 发送日期，格式 2006-01-02，方便按天聚合
+	Day       string `gorm:"type:varchar(10);index:provider_day"`
+	LatencyMs int64
+	Success   bool
+	ErrCode   string `gorm:"type:varchar(64)"`
+	CTime     int64  `gorm:"column:c_time;index"`
+}
+
+type SMSStat struct {
+	Provider string
+	Day      string
+	Total    int64
+	Failed   int64
+}
+
+func (dao *GORMSMSLogDAO) BatchInsert(ctx context.Context, logs []SMSLog) error {
+	return dao.db.WithContext(ctx).Create(&logs).Error
+}
+
+func (dao *GORMSMSLogDAO) Stats(ctx context.Context, provider string, start int64, end int64) ([]SMSStat, error) {
+	var stats []SMSStat
+	query := dao.db.WithContext(ctx).Model(&SMSLog{}).
+		Select("provider, day, COUNT(*) AS total, SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failed").
+		Where("c_time >= ? AND c_time < ?", start, end)
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	err := query.Group("provider, day").Order("day, provider").Scan(&stats).Error
+	return stats, err
+}
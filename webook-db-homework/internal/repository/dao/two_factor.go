@@ -0,0 +1,125 @@
+package dao
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrTwoFactorNotFound = gorm.ErrRecordNotFound
+
+type TwoFactorDAO interface {
+	FindByUid(ctx context.Context, uid int64) (UserTOTP, error)
+	// UpsertPending 保存新生成的密钥，绑定完成之前都是未启用状态
+	UpsertPending(ctx context.Context, uid int64, secret string) error
+	// Enable 启用二次验证，并且替换掉所有的恢复码
+	Enable(ctx context.Context, uid int64, step int64, codeHashes []string) error
+	// UpdateLastStep 只有 step 比记录的大才会更新，返回 false 代表验证码已经被使用过
+	UpdateLastStep(ctx context.Context, uid int64, step int64) (bool, error)
+	// UseRecoveryCode 恢复码只能用一次，返回 false 代表恢复码不存在或者已经使用过
+	UseRecoveryCode(ctx context.Context, uid int64, codeHash string) (bool, error)
+	Delete(ctx context.Context, uid int64) error
+}
+
+type GORMTwoFactorDAO struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorDAO(db *gorm.DB) TwoFactorDAO {
+	return &GORMTwoFactorDAO{db: db}
+}
+
+// UserTOTP 一个用户只有一个 TOTP 密钥
+type UserTOTP struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Uid      int64  `gorm:"uniqueIndex"`
+	Secret   string `gorm:"type:varchar(64)"`
+	Enabled  bool
+	LastStep int64
+	CTime    int64 `gorm:"column:c_time"`
+	UTime    int64 `gorm:"column:u_time"`
+}
+
+// UserRecoveryCode 只保存恢复码的哈希值
+type UserRecoveryCode struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Uid      int64  `gorm:"index:uid_code"`
+	CodeHash string `gorm:"type:varchar(64);index:uid_code"`
+	Used     bool
+	CTime    int64 `gorm:"column:c_time"`
+	UTime    int64 `gorm:"column:u_time"`
+}
+
+func (dao *GORMTwoFactorDAO) FindByUid(ctx context.Context, uid int64) (UserTOTP, error) {
+	var t UserTOTP
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).First(&t).Error
+	return t, err
+}
+
+func (dao *GORMTwoFactorDAO) UpsertPending(ctx context.Context, uid int64, secret string) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]any{
+			"secret":    secret,
+			"enabled":   false,
+			"last_step": 0,
+			"u_time":    now,
+		}),
+	}).Create(&UserTOTP{Uid: uid, Secret: secret, CTime: now, UTime: now}).Error
+}
+
+func (dao *GORMTwoFactorDAO) Enable(ctx context.Context, uid int64, step int64, codeHashes []string) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UserTOTP{}).Where("uid = ?", uid).Updates(map[string]any{
+			"enabled":   true,
+			"last_step": step,
+			"u_time":    now,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrTwoFactorNotFound
+		}
+		if err := tx.Where("uid = ?", uid).Delete(&UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]UserRecoveryCode, 0, len(codeHashes))
+		for _, h := range codeHashes {
+			codes = append(codes, UserRecoveryCode{Uid: uid, CodeHash: h, CTime: now, UTime: now})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (dao *GORMTwoFactorDAO) UpdateLastStep(ctx context.Context, uid int64, step int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&UserTOTP{}).
+		Where("uid = ? AND last_step < ?", uid, step).
+		Updates(map[string]any{
+			"last_step": step,
+			"u_time":    time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GORMTwoFactorDAO) UseRecoveryCode(ctx context.Context, uid int64, codeHash string) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&UserRecoveryCode{}).
+		Where("uid = ? AND code_hash = ? AND used = ?", uid, codeHash, false).
+		Updates(map[string]any{
+			"used":   true,
+			"u_time": time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GORMTwoFactorDAO) Delete(ctx context.Context, uid int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("uid = ?", uid).Delete(&UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("uid = ?", uid).Delete(&UserTOTP{}).Error
+	})
+}
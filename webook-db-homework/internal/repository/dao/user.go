@@ -4,10 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
 	"time"
 
 	"webook/internal/repository/cache"
+	"webook/pkg/logger"
 
 	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
@@ -24,33 +24,40 @@ type UserDAO interface {
 	FindById(ctx context.Context, id int64) (User, error)
 	UpdateUserProfile(ctx context.Context, u User) error
 	FindByPhone(ctx context.Context, phone string) (User, error)
-	FindByWechat(ctx context.Context, openID string) (User, error)
+	FindByIdentity(ctx context.Context, provider string, subject string) (User, error)
+	InsertWithIdentity(ctx context.Context, u User, identity UserIdentity) (int64, error)
+	UpdateStatus(ctx context.Context, id int64, status uint8) error
 }
 
 type GORMUserDAO struct {
 	db    *gorm.DB
 	cache cache.UserCache
+	l     logger.LoggerV1
 }
 
-func NewUserDAO(db *gorm.DB) UserDAO {
+func NewUserDAO(db *gorm.DB, l logger.LoggerV1) UserDAO {
 	return &GORMUserDAO{
 		db: db,
+		l:  l,
 	}
 }
 
 // 这里的User结构体是数据库中的user表的结构体
 type User struct {
-	Id            int64          `gorm:"primaryKey,autoIncrement"`
-	Email         sql.NullString `gorm:"unique"`
-	Phone         sql.NullString `gorm:"unique"`
-	Password      string
-	Birthday      string         `gorm:"column:birthday"`
-	AboutMe       string         `gorm:"column:about_me"`
-	Nickname      string         `gorm:"column:nickname"`
-	CTime         int64          `gorm:"column:c_time"`
-	UTime         int64          `gorm:"column:u_time"`
+	Id       int64          `gorm:"primaryKey,autoIncrement"`
+	Email    sql.NullString `gorm:"unique"`
+	Phone    sql.NullString `gorm:"unique"`
+	Password string
+	Birthday string `gorm:"column:birthday"`
+	AboutMe  string `gorm:"column:about_me"`
+	Nickname string `gorm:"column:nickname"`
+	CTime    int64  `gorm:"column:c_time"`
+	UTime    int64  `gorm:"column:u_time"`
+	// 微信账号已经迁移到 user_identities，这两列只给 MigrateWechatIdentities 读
 	WechatUnionID sql.NullString `gorm:"unique"`
 	WechatOpenID  sql.NullString `gorm:"unique"`
+	// Status 0 正常 1 封禁
+	Status uint8 `gorm:"column:status;default:0"`
 }
 
 func (dao *GORMUserDAO) Insert(ctx context.Context, u User) error {
@@ -104,17 +111,26 @@ func (dao *GORMUserDAO) UpdateUserProfile(ctx context.Context, u User) error {
 	if u.Email.Valid {
 		updates["email"] = u.Email.String
 	}
-	fmt.Printf("DAO层更新用户信息: ID=%v, updates=%+v\n", u.Id, updates)
+	l := logger.FromContext(ctx, dao.l).With(logger.Int64("id", u.Id))
+	l.Debug("更新用户信息", logger.Any("updates", updates))
 
 	err := dao.db.WithContext(ctx).Model(&User{}).Where("id=?", u.Id).Updates(updates).Error
 	if err != nil {
-		fmt.Printf("DAO层更新用户信息失败: %v\n", err)
+		l.Error("更新用户信息失败", logger.Error(err))
 	}
 	return err
 }
 
-func (dao *GORMUserDAO) FindByWechat(ctx context.Context, openID string) (User, error) {
-	var u User
-	err := dao.db.WithContext(ctx).Where("wechat_open_id=?", openID).First(&u).Error
-	return u, err
+func (dao *GORMUserDAO) UpdateStatus(ctx context.Context, id int64, status uint8) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Updates(map[string]any{
+		"status": status,
+		"u_time": time.Now().UnixMilli(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
 }
@@ -0,0 +1,88 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUserDuplicateIdentity = errors.New("第三方账号已经绑定过用户")
+
+// legacyWechatProvider 接入 user_identities 之前，微信登录直接把 openid 存在 users 表上。
+// 要和 wechat.ProviderName 保持一致
+const legacyWechatProvider = "wechat"
+
+// UserIdentity 第三方账号和用户的绑定关系，同一个用户可以绑定多个第三方账号
+type UserIdentity struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Provider string `gorm:"type:varchar(32);uniqueIndex:provider_subject"`
+	Subject  string `gorm:"type:varchar(128);uniqueIndex:provider_subject"`
+	UserId   int64  `gorm:"index"`
+	CTime    int64  `gorm:"column:c_time"`
+	UTime    int64  `gorm:"column:u_time"`
+}
+
+func (dao *GORMUserDAO) FindByIdentity(ctx context.Context, provider string, subject string) (User, error) {
+	var identity UserIdentity
+	err := dao.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	switch {
+	case err == nil:
+		return dao.FindById(ctx, identity.UserId)
+	case errors.Is(err, gorm.ErrRecordNotFound) && provider == legacyWechatProvider:
+		// 滚动发布的时候老节点还会往 users 表上写 openid，启动时的迁移覆盖不到
+		return dao.findLegacyWechat(ctx, subject)
+	default:
+		return User{}, err
+	}
+}
+
+// findLegacyWechat 找到老的微信用户之后顺手补上绑定关系，下次直接走 user_identities
+func (dao *GORMUserDAO) findLegacyWechat(ctx context.Context, openID string) (User, error) {
+	var u User
+	err := dao.db.WithContext(ctx).Where("wechat_open_id = ?", openID).First(&u).Error
+	if err != nil {
+		return User{}, err
+	}
+	now := time.Now().UnixMilli()
+	err = dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&UserIdentity{
+		Provider: legacyWechatProvider,
+		Subject:  openID,
+		UserId:   u.Id,
+		CTime:    now,
+		UTime:    now,
+	}).Error
+	return u, err
+}
+
+// MigrateWechatIdentities 把 users 表上老的微信 openid 补到 user_identities，已经迁移过的跳过，可以重复执行
+func MigrateWechatIdentities(db *gorm.DB) error {
+	now := time.Now().UnixMilli()
+	return db.Exec("INSERT IGNORE INTO user_identities (provider, subject, user_id, c_time, u_time) "+
+		"SELECT ?, wechat_open_id, id, ?, ? FROM users WHERE wechat_open_id IS NOT NULL AND wechat_open_id <> ''",
+		legacyWechatProvider, now, now).Error
+}
+
+// InsertWithIdentity 在同一个事务里面创建用户和绑定关系，避免出现没有绑定任何登录方式的用户
+func (dao *GORMUserDAO) InsertWithIdentity(ctx context.Context, u User, identity UserIdentity) (int64, error) {
+	now := time.Now().UnixMilli()
+	u.CTime, u.UTime = now, now
+	identity.CTime, identity.UTime = now, now
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&u).Error; err != nil {
+			return err
+		}
+		identity.UserId = u.Id
+		return tx.Create(&identity).Error
+	})
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		const uniqueIndexErrNo uint16 = 1062
+		if mysqlErr.Number == uniqueIndexErrNo {
+			return 0, ErrUserDuplicateIdentity
+		}
+	}
+	return u.Id, err
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"database/sql"
+	"webook/pkg/logger"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-playground/assert/v2"
@@ -86,10 +87,85 @@ func TestGORMUserDAO_Insert(t *testing.T) {
 				DisableAutomaticPing:   true, //禁用自动ping，因为gorm执行sql语句时会自动ping
 				SkipDefaultTransaction: true, //禁用事务，因为gorm执行sql语句时会自动开启事务
 			})
-			d := NewUserDAO(db)
+			d := NewUserDAO(db, logger.NewNopLogger())
 			err = d.Insert(tc.ctx, tc.u)
 			assert.Equal(t, tc.wantErr, err)
 		})
 	}
 
 }
+
+func TestGORMUserDAO_FindByIdentity(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(t *testing.T) *sql.DB
+		provider string
+		subject  string
+		wantId   int64
+		wantErr  error
+	}{
+		{
+			name: "已经绑定",
+			mock: func(t *testing.T) *sql.DB {
+				mockDB, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectQuery("SELECT \\* FROM `user_identities`.*").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "provider", "subject", "user_id"}).
+						AddRow(1, "github", "123", 10))
+				mock.ExpectQuery("SELECT \\* FROM `users`.*").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+				return mockDB
+			},
+			provider: "github",
+			subject:  "123",
+			wantId:   10,
+		},
+		{
+			name: "老的微信用户，补上绑定关系",
+			mock: func(t *testing.T) *sql.DB {
+				mockDB, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectQuery("SELECT \\* FROM `user_identities`.*").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectQuery("SELECT \\* FROM `users` WHERE wechat_open_id = .*").
+					WithArgs("openid", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(20))
+				mock.ExpectExec("INSERT INTO `user_identities`.*ON DUPLICATE KEY UPDATE.*").
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				return mockDB
+			},
+			provider: "wechat",
+			subject:  "openid",
+			wantId:   20,
+		},
+		{
+			name: "其他服务商不查老数据",
+			mock: func(t *testing.T) *sql.DB {
+				mockDB, mock, err := sqlmock.New()
+				require.NoError(t, err)
+				mock.ExpectQuery("SELECT \\* FROM `user_identities`.*").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				return mockDB
+			},
+			provider: "github",
+			subject:  "123",
+			wantErr:  ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+				Conn:                      tc.mock(t),
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			require.NoError(t, err)
+			d := NewUserDAO(db, logger.NewNopLogger())
+			u, err := d.FindByIdentity(context.Background(), tc.provider, tc.subject)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantId, u.Id)
+		})
+	}
+}
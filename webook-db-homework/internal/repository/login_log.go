@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+type LoginLogRepository interface {
+	BatchCreate(ctx context.Context, logs []domain.LoginLog) error
+	FindRecent(ctx context.Context, uid int64, limit int) ([]domain.LoginLog, error)
+}
+
+type LoginLogRepository_ struct {
+	dao dao.LoginLogDAO
+}
+
+func NewLoginLogRepository(dao dao.LoginLogDAO) LoginLogRepository {
+	return &LoginLogRepository_{dao: dao}
+}
+
+func (r *LoginLogRepository_) BatchCreate(ctx context.Context, logs []domain.LoginLog) error {
+	entities := make([]dao.LoginLog, 0, len(logs))
+	for _, l := range logs {
+		entities = append(entities, dao.LoginLog{
+			Account:   l.Account,
+			Uid:       l.Uid,
+			Method:    l.Method,
+			IP:        l.IP,
+			UserAgent: l.UserAgent,
+			Success:   l.Success,
+			Reason:    l.Reason,
+			CTime:     l.Ctime.UnixMilli(),
+		})
+	}
+	return r.dao.BatchInsert(ctx, entities)
+}
+
+func (r *LoginLogRepository_) FindRecent(ctx context.Context, uid int64, limit int) ([]domain.LoginLog, error) {
+	logs, err := r.dao.FindByUid(ctx, uid, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.LoginLog, 0, len(logs))
+	for _, l := range logs {
+		res = append(res, domain.LoginLog{
+			Id:        l.Id,
+			Account:   l.Account,
+			Uid:       l.Uid,
+			Method:    l.Method,
+			IP:        l.IP,
+			UserAgent: l.UserAgent,
+			Success:   l.Success,
+			Reason:    l.Reason,
+			Ctime:     time.UnixMilli(l.CTime),
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/repository/cache"
+)
+
+// LoginRiskRepository 风控数据都是短期的，只放在 Redis 里面
+type LoginRiskRepository interface {
+	IncrFailures(ctx context.Context, account string, window time.Duration) (int64, error)
+	ResetFailures(ctx context.Context, account string) error
+	Lock(ctx context.Context, account string, duration time.Duration) error
+	Locked(ctx context.Context, account string) (bool, error)
+	AddDevice(ctx context.Context, uid int64, device string) (isNew bool, hasHistory bool, err error)
+	AddIPAccount(ctx context.Context, ip string, account string, window time.Duration) (int64, error)
+}
+
+type CachedLoginRiskRepository struct {
+	cache.LoginRiskCache
+}
+
+func NewLoginRiskRepository(c cache.LoginRiskCache) LoginRiskRepository {
+	return &CachedLoginRiskRepository{LoginRiskCache: c}
+}
@@ -40,6 +40,20 @@ func (m *MockCodeRepository) EXPECT() *MockCodeRepositoryMockRecorder {
 	return m.recorder
 }
 
+// CheckResend mocks base method.
+func (m *MockCodeRepository) CheckResend(ctx context.Context, biz, phone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckResend", ctx, biz, phone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckResend indicates an expected call of CheckResend.
+func (mr *MockCodeRepositoryMockRecorder) CheckResend(ctx, biz, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckResend", reflect.TypeOf((*MockCodeRepository)(nil).CheckResend), ctx, biz, phone)
+}
+
 // Set mocks base method.
 func (m *MockCodeRepository) Set(ctx context.Context, biz, phone, code string) error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+var ErrRoleNotFound = dao.ErrRoleNotFound
+
+type RBACRepository interface {
+	FindAuthority(ctx context.Context, uid int64) (domain.Authority, error)
+	AssignRole(ctx context.Context, uid int64, role string) error
+	RevokeRole(ctx context.Context, uid int64, role string) error
+}
+
+type RBACRepository_ struct {
+	dao dao.RBACDAO
+}
+
+func NewRBACRepository(dao dao.RBACDAO) RBACRepository {
+	return &RBACRepository_{dao: dao}
+}
+
+func (r *RBACRepository_) FindAuthority(ctx context.Context, uid int64) (domain.Authority, error) {
+	roles, err := r.dao.FindRoles(ctx, uid)
+	if err != nil {
+		return domain.Authority{}, err
+	}
+	// 没有任何角色的普通用户，不需要再查权限
+	if len(roles) == 0 {
+		return domain.Authority{}, nil
+	}
+	perms, err := r.dao.FindPermissions(ctx, uid)
+	if err != nil {
+		return domain.Authority{}, err
+	}
+	return domain.Authority{Roles: roles, Permissions: perms}, nil
+}
+
+func (r *RBACRepository_) AssignRole(ctx context.Context, uid int64, role string) error {
+	return r.dao.AssignRole(ctx, uid, role)
+}
+
+func (r *RBACRepository_) RevokeRole(ctx context.Context, uid int64, role string) error {
+	return r.dao.RevokeRole(ctx, uid, role)
+}
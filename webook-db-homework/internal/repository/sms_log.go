@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+type SMSLogRepository interface {
+	BatchCreate(ctx context.Context, logs []domain.SMSLog) error
+	Stats(ctx context.Context, provider string, start time.Time, end time.Time) ([]domain.SMSStat, error)
+}
+
+type SMSLogRepository_ struct {
+	dao dao.SMSLogDAO
+}
+
+func NewSMSLogRepository(dao dao.SMSLogDAO) SMSLogRepository {
+	return &SMSLogRepository_{dao: dao}
+}
+
+func (r *SMSLogRepository_) BatchCreate(ctx context.Context, logs []domain.SMSLog) error {
+	entities := make([]dao.SMSLog, 0, len(logs))
+	for _, l := range logs {
+		entities = append(entities, dao.SMSLog{
+			Biz:       l.Biz,
+			Phone:     l.Phone,
+			Provider:  l.Provider,
+			Day:       l.Ctime.Format(time.DateOnly),
+			LatencyMs: l.Latency.Milliseconds(),
+			Success:   l.Success,
+			ErrCode:   l.ErrCode,
+			CTime:     l.Ctime.UnixMilli(),
+		})
+	}
+	return r.dao.BatchInsert(ctx, entities)
+}
+
+func (r *SMSLogRepository_) Stats(ctx context.Context, provider string,
+	start time.Time, end time.Time) ([]domain.SMSStat, error) {
+	stats, err := r.dao.Stats(ctx, provider, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SMSStat, 0, len(stats))
+	for _, s := range stats {
+		res = append(res, domain.SMSStat{
+			Provider: s.Provider,
+			Day:      s.Day,
+			Total:    s.Total,
+			Failed:   s.Failed,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+	"webook/internal/repository/dao"
+)
+
+var ErrTwoFactorNotFound = dao.ErrTwoFactorNotFound
+
+type TwoFactorRepository interface {
+	Find(ctx context.Context, uid int64) (domain.TwoFactor, error)
+	SavePending(ctx context.Context, uid int64, secret string) error
+	Enable(ctx context.Context, uid int64, step int64, codeHashes []string) error
+	ConsumeStep(ctx context.Context, uid int64, step int64) (bool, error)
+	ConsumeRecoveryCode(ctx context.Context, uid int64, codeHash string) (bool, error)
+	Delete(ctx context.Context, uid int64) error
+	IncrAttempts(ctx context.Context, uid int64) (int64, error)
+	ResetAttempts(ctx context.Context, uid int64) error
+}
+
+type CachedTwoFactorRepository struct {
+	dao   dao.TwoFactorDAO
+	cache cache.TwoFactorCache
+}
+
+func NewTwoFactorRepository(dao dao.TwoFactorDAO, cache cache.TwoFactorCache) TwoFactorRepository {
+	return &CachedTwoFactorRepository{
+		dao:   dao,
+		cache: cache,
+	}
+}
+
+func (r *CachedTwoFactorRepository) Find(ctx context.Context, uid int64) (domain.TwoFactor, error) {
+	t, err := r.dao.FindByUid(ctx, uid)
+	if err != nil {
+		return domain.TwoFactor{}, err
+	}
+	return domain.TwoFactor{
+		Uid:      t.Uid,
+		Secret:   t.Secret,
+		Enabled:  t.Enabled,
+		LastStep: t.LastStep,
+	}, nil
+}
+
+func (r *CachedTwoFactorRepository) SavePending(ctx context.Context, uid int64, secret string) error {
+	return r.dao.UpsertPending(ctx, uid, secret)
+}
+
+func (r *CachedTwoFactorRepository) Enable(ctx context.Context, uid int64, step int64, codeHashes []string) error {
+	return r.dao.Enable(ctx, uid, step, codeHashes)
+}
+
+func (r *CachedTwoFactorRepository) ConsumeStep(ctx context.Context, uid int64, step int64) (bool, error) {
+	return r.dao.UpdateLastStep(ctx, uid, step)
+}
+
+func (r *CachedTwoFactorRepository) ConsumeRecoveryCode(ctx context.Context, uid int64, codeHash string) (bool, error) {
+	return r.dao.UseRecoveryCode(ctx, uid, codeHash)
+}
+
+func (r *CachedTwoFactorRepository) Delete(ctx context.Context, uid int64) error {
+	return r.dao.Delete(ctx, uid)
+}
+
+func (r *CachedTwoFactorRepository) IncrAttempts(ctx context.Context, uid int64) (int64, error) {
+	return r.cache.IncrAttempts(ctx, uid)
+}
+
+func (r *CachedTwoFactorRepository) ResetAttempts(ctx context.Context, uid int64) error {
+	return r.cache.ResetAttempts(ctx, uid)
+}
@@ -10,6 +10,7 @@ import (
 
 var ErrUserDuplicateEmail = dao.ErrUserDuplicateEmail
 var ErrUserNotFound = dao.ErrUserNotFound
+var ErrUserDuplicateIdentity = dao.ErrUserDuplicateIdentity
 
 type UserRepository interface {
 	Create(ctx context.Context, u domain.User) error
@@ -17,7 +18,9 @@ type UserRepository interface {
 	FindById(ctx context.Context, id int64) (domain.User, error)
 	UpdateUserProfile(ctx context.Context, u domain.User) error
 	FindByPhone(ctx context.Context, phone string) (domain.User, error)
-	FindByWechat(ctx context.Context, openID string) (domain.User, error)
+	FindByOAuth2(ctx context.Context, provider string, subject string) (domain.User, error)
+	CreateWithOAuth2(ctx context.Context, u domain.User, info domain.OAuth2User) (int64, error)
+	UpdateStatus(ctx context.Context, id int64, status domain.UserStatus) error
 }
 
 type CachedUserRepository struct {
@@ -103,6 +106,7 @@ func DomainToEntity(u domain.User) dao.User {
 		Nickname:      u.Nickname,
 		Birthday:      u.Birthday,
 		AboutMe:       u.AboutMe,
+		Status:        uint8(u.Status),
 		WechatUnionID: sql.NullString{String: u.WechatUser.UnionID, Valid: u.WechatUser.UnionID != ""},
 		WechatOpenID:  sql.NullString{String: u.WechatUser.OpenID, Valid: u.WechatUser.OpenID != ""},
 	}
@@ -117,6 +121,7 @@ func EntityToDomain(u dao.User) domain.User {
 		Nickname: u.Nickname,
 		Birthday: u.Birthday,
 		AboutMe:  u.AboutMe,
+		Status:   domain.UserStatus(u.Status),
 		WechatUser: domain.WechatUser{
 			UnionID: u.WechatUnionID.String,
 			OpenID:  u.WechatOpenID.String,
@@ -129,7 +134,26 @@ func (r *CachedUserRepository) FindByPhone(ctx context.Context, phone string) (d
 	return EntityToDomain(u), err
 }
 
-func (r *CachedUserRepository) FindByWechat(ctx context.Context, openID string) (domain.User, error) {
-	u, err := r.dao.FindByWechat(ctx, openID)
+func (r *CachedUserRepository) FindByOAuth2(ctx context.Context, provider string, subject string) (domain.User, error) {
+	u, err := r.dao.FindByIdentity(ctx, provider, subject)
 	return EntityToDomain(u), err
 }
+
+func (r *CachedUserRepository) CreateWithOAuth2(ctx context.Context, u domain.User, info domain.OAuth2User) (int64, error) {
+	return r.dao.InsertWithIdentity(ctx, DomainToEntity(u), dao.UserIdentity{
+		Provider: info.Provider,
+		Subject:  info.Subject,
+	})
+}
+
+func (r *CachedUserRepository) UpdateStatus(ctx context.Context, id int64, status domain.UserStatus) error {
+	err := r.dao.UpdateStatus(ctx, id, uint8(status))
+	if err != nil {
+		return err
+	}
+	// 封禁要马上生效，这里同步删除缓存
+	if r.cache != nil {
+		return r.cache.Delete(ctx, id)
+	}
+	return nil
+}
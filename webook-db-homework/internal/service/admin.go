@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+)
+
+// 审计记录里面的动作
+const (
+	AuditActionWithdrawArticle = "withdraw_article"
+	AuditActionBanUser         = "ban_user"
+	AuditActionUnbanUser       = "unban_user"
+	AuditActionViewUser        = "view_user"
+	AuditActionResetTwoFactor  = "reset_2fa"
+	AuditActionAssignRole      = "assign_role"
+	AuditActionRevokeRole      = "revoke_role"
+)
+
+// ErrRoleTooLow 操作对象的角色级别不低于操作人，比如版主封禁管理员，
+// 或者要分配的角色级别不低于操作人，比如管理员再任命一个管理员
+var ErrRoleTooLow = errors.New("不能操作角色级别不低于自己的用户或角色")
+
+// AdminService 管理后台的操作，权限在 web 层校验，这里只负责执行和审计
+// 所有操作成功之后都会写一条审计记录，审计写失败也会返回错误
+type AdminService interface {
+	WithdrawArticle(ctx context.Context, operator int64, aid int64, reason string) error
+	BanUser(ctx context.Context, operator int64, uid int64, reason string) error
+	UnbanUser(ctx context.Context, operator int64, uid int64) error
+	UserDetail(ctx context.Context, operator int64, uid int64) (domain.User, domain.Authority, error)
+	ResetTwoFactor(ctx context.Context, operator int64, uid int64) error
+	AssignRole(ctx context.Context, operator int64, uid int64, role string) error
+	RevokeRole(ctx context.Context, operator int64, uid int64, role string) error
+	ListAudits(ctx context.Context, offset int, limit int) ([]domain.AdminAudit, error)
+	// SMSStats 短信按服务商和日期的发送统计，provider 为空代表所有服务商
+	SMSStats(ctx context.Context, provider string, start time.Time, end time.Time) ([]domain.SMSStat, error)
+	// SMSBacklog 服务商异常的时候存起来还没有发送出去的短信数量
+	SMSBacklog(ctx context.Context) (int64, error)
+}
+
+type AdminService_ struct {
+	userRepo     repository.UserRepository
+	rbacRepo     repository.RBACRepository
+	auditRepo    repository.AdminAuditRepository
+	articleSvc   ArticleService
+	twoFactorSvc TwoFactorService
+	smsLogRepo   repository.SMSLogRepository
+	asyncSmsRepo repository.AsyncSmsRepository
+}
+
+func NewAdminService(userRepo repository.UserRepository, rbacRepo repository.RBACRepository,
+	auditRepo repository.AdminAuditRepository, articleSvc ArticleService, twoFactorSvc TwoFactorService,
+	smsLogRepo repository.SMSLogRepository, asyncSmsRepo repository.AsyncSmsRepository) AdminService {
+	return &AdminService_{
+		userRepo:     userRepo,
+		rbacRepo:     rbacRepo,
+		auditRepo:    auditRepo,
+		articleSvc:   articleSvc,
+		twoFactorSvc: twoFactorSvc,
+		smsLogRepo:   smsLogRepo,
+		asyncSmsRepo: asyncSmsRepo,
+	}
+}
+
+func (svc *AdminService_) WithdrawArticle(ctx context.Context, operator int64, aid int64, reason string) error {
+	// 撤回要带上原作者的 id，所以先查一次
+	art, err := svc.articleSvc.Detail(ctx, aid)
+	if err != nil {
+		return err
+	}
+	err = svc.articleSvc.Withdraw(ctx, domain.Article{
+		ID:     aid,
+		Author: art.Author,
+	})
+	if err != nil {
+		return err
+	}
+	return svc.audit(ctx, operator, AuditActionWithdrawArticle, "article", aid,
+		fmt.Sprintf("author=%d reason=%s", art.Author.ID, reason))
+}
+
+func (svc *AdminService_) BanUser(ctx context.Context, operator int64, uid int64, reason string) error {
+	_, err := svc.checkOutranks(ctx, operator, uid)
+	if err != nil {
+		return err
+	}
+	// 封禁之后已经签发的 token 由登录中间件拦截，refresh 的时候也签发不了新的
+	err = svc.userRepo.UpdateStatus(ctx, uid, domain.UserStatusBanned)
+	if err != nil {
+		return err
+	}
+	return svc.audit(ctx, operator, AuditActionBanUser, "user", uid, "reason="+reason)
+}
+
+func (svc *AdminService_) UnbanUser(ctx context.Context, operator int64, uid int64) error {
+	_, err := svc.checkOutranks(ctx, operator, uid)
+	if err != nil {
+		return err
+	}
+	err = svc.userRepo.UpdateStatus(ctx, uid, domain.UserStatusNormal)
+	if err != nil {
+		return err
+	}
+	return svc.audit(ctx, operator, AuditActionUnbanUser, "user", uid, "")
+}
+
+func (svc *AdminService_) UserDetail(ctx context.Context, operator int64, uid int64) (domain.User, domain.Authority, error) {
+	u, err := svc.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return domain.User{}, domain.Authority{}, err
+	}
+	authority, err := svc.rbacRepo.FindAuthority(ctx, uid)
+	if err != nil {
+		return domain.User{}, domain.Authority{}, err
+	}
+	// 查看用户资料也涉及隐私，同样需要审计
+	err = svc.audit(ctx, operator, AuditActionViewUser, "user", uid, "")
+	if err != nil {
+		return domain.User{}, domain.Authority{}, err
+	}
+	return u, authority, nil
+}
+
+func (svc *AdminService_) ResetTwoFactor(ctx context.Context, operator int64, uid int64) error {
+	_, err := svc.checkOutranks(ctx, operator, uid)
+	if err != nil {
+		return err
+	}
+	err = svc.twoFactorSvc.Reset(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return svc.audit(ctx, operator, AuditActionResetTwoFactor, "user", uid, "")
+}
+
+func (svc *AdminService_) AssignRole(ctx context.Context, operator int64, uid int64, role string) error {
+	level, err := svc.checkOutranks(ctx, operator, uid)
+	if err != nil {
+		return err
+	}
+	// 只能分配比自己级别低的角色，不然管理员可以把别人提升成和自己平级，再互相操作
+	if domain.RoleLevels[role] >= level {
+		return ErrRoleTooLow
+	}
+	err = svc.rbacRepo.AssignRole(ctx, uid, role)
+	if err != nil {
+		return err
+	}
+	return svc.audit(ctx, operator, AuditActionAssignRole, "user", uid, "role="+role)
+}
+
+func (svc *AdminService_) RevokeRole(ctx context.Context, operator int64, uid int64, role string) error {
+	_, err := svc.checkOutranks(ctx, operator, uid)
+	if err != nil {
+		return err
+	}
+	err = svc.rbacRepo.RevokeRole(ctx, uid, role)
+	if err != nil {
+		return err
+	}
+	return svc.audit(ctx, operator, AuditActionRevokeRole, "user", uid, "role="+role)
+}
+
+func (svc *AdminService_) ListAudits(ctx context.Context, offset int, limit int) ([]domain.AdminAudit, error) {
+	return svc.auditRepo.List(ctx, offset, limit)
+}
+
+func (svc *AdminService_) SMSStats(ctx context.Context, provider string,
+	start time.Time, end time.Time) ([]domain.SMSStat, error) {
+	return svc.smsLogRepo.Stats(ctx, provider, start, end)
+}
+
+// checkOutranks 操作人的角色级别必须比目标用户高，不然版主就可以封禁管理员，
+// 管理员之间也可以互相撤销角色、重置二次验证。返回操作人的级别
+func (svc *AdminService_) checkOutranks(ctx context.Context, operator int64, uid int64) (int, error) {
+	op, err := svc.rbacRepo.FindAuthority(ctx, operator)
+	if err != nil {
+		return 0, err
+	}
+	target, err := svc.rbacRepo.FindAuthority(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+	if target.Level() >= op.Level() {
+		return 0, ErrRoleTooLow
+	}
+	return op.Level(), nil
+}
+
+func (svc *AdminService_) audit(ctx context.Context, operator int64, action string,
+	targetType string, targetId int64, detail string) error {
+	return svc.auditRepo.Create(ctx, domain.AdminAudit{
+		OperatorId: operator,
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Detail:     detail,
+	})
+}
+
+func (svc *AdminService_) SMSBacklog(ctx context.Context) (int64, error) {
+	return svc.asyncSmsRepo.Backlog(ctx)
+}
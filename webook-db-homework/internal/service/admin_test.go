@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+	svcmocks "webook/internal/service/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAdminService_WithdrawArticle(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (ArticleService, repository.AdminAuditRepository)
+		wantErr error
+	}{
+		{
+			name: "撤回成功",
+			mock: func(ctrl *gomock.Controller) (ArticleService, repository.AdminAuditRepository) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				artSvc.EXPECT().Detail(gomock.Any(), int64(10)).
+					Return(domain.Article{ID: 10, Author: domain.Author{ID: 2}}, nil)
+				// 用原作者的 id 撤回
+				artSvc.EXPECT().Withdraw(gomock.Any(), domain.Article{ID: 10, Author: domain.Author{ID: 2}}).
+					Return(nil)
+				auditRepo := repomocks.NewMockAdminAuditRepository(ctrl)
+				auditRepo.EXPECT().Create(gomock.Any(), domain.AdminAudit{
+					OperatorId: 1,
+					Action:     AuditActionWithdrawArticle,
+					TargetType: "article",
+					TargetId:   10,
+					Detail:     "author=2 reason=违规",
+				}).Return(nil)
+				return artSvc, auditRepo
+			},
+		},
+		{
+			name: "撤回失败不记录审计",
+			mock: func(ctrl *gomock.Controller) (ArticleService, repository.AdminAuditRepository) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				artSvc.EXPECT().Detail(gomock.Any(), int64(10)).
+					Return(domain.Article{ID: 10, Author: domain.Author{ID: 2}}, nil)
+				artSvc.EXPECT().Withdraw(gomock.Any(), gomock.Any()).Return(errors.New("db error"))
+				return artSvc, repomocks.NewMockAdminAuditRepository(ctrl)
+			},
+			wantErr: errors.New("db error"),
+		},
+		{
+			name: "审计写入失败",
+			mock: func(ctrl *gomock.Controller) (ArticleService, repository.AdminAuditRepository) {
+				artSvc := svcmocks.NewMockArticleService(ctrl)
+				artSvc.EXPECT().Detail(gomock.Any(), int64(10)).
+					Return(domain.Article{ID: 10, Author: domain.Author{ID: 2}}, nil)
+				artSvc.EXPECT().Withdraw(gomock.Any(), gomock.Any()).Return(nil)
+				auditRepo := repomocks.NewMockAdminAuditRepository(ctrl)
+				auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(errors.New("audit error"))
+				return artSvc, auditRepo
+			},
+			wantErr: errors.New("audit error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			artSvc, auditRepo := tc.mock(ctrl)
+			svc := NewAdminService(nil, nil, auditRepo, artSvc, nil, nil, nil)
+			err := svc.WithdrawArticle(context.Background(), 1, 10, "违规")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestAdminService_BanUser(t *testing.T) {
+	moderator := domain.Authority{Roles: []string{domain.RoleModerator}}
+	admin := domain.Authority{Roles: []string{domain.RoleAdmin}}
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (repository.UserRepository, repository.RBACRepository, repository.AdminAuditRepository)
+		wantErr error
+	}{
+		{
+			name: "封禁成功",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.RBACRepository, repository.AdminAuditRepository) {
+				rbacRepo := repomocks.NewMockRBACRepository(ctrl)
+				rbacRepo.EXPECT().FindAuthority(gomock.Any(), int64(1)).Return(moderator, nil)
+				rbacRepo.EXPECT().FindAuthority(gomock.Any(), int64(2)).Return(domain.Authority{}, nil)
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().UpdateStatus(gomock.Any(), int64(2), domain.UserStatusBanned).Return(nil)
+				auditRepo := repomocks.NewMockAdminAuditRepository(ctrl)
+				auditRepo.EXPECT().Create(gomock.Any(), domain.AdminAudit{
+					OperatorId: 1,
+					Action:     AuditActionBanUser,
+					TargetType: "user",
+					TargetId:   2,
+					Detail:     "reason=spam",
+				}).Return(nil)
+				return userRepo, rbacRepo, auditRepo
+			},
+		},
+		{
+			name: "用户不存在",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.RBACRepository, repository.AdminAuditRepository) {
+				rbacRepo := repomocks.NewMockRBACRepository(ctrl)
+				rbacRepo.EXPECT().FindAuthority(gomock.Any(), int64(1)).Return(admin, nil)
+				rbacRepo.EXPECT().FindAuthority(gomock.Any(), int64(2)).Return(domain.Authority{}, nil)
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().UpdateStatus(gomock.Any(), int64(2), domain.UserStatusBanned).
+					Return(repository.ErrUserNotFound)
+				return userRepo, rbacRepo, repomocks.NewMockAdminAuditRepository(ctrl)
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "版主不能封禁管理员",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.RBACRepository, repository.AdminAuditRepository) {
+				rbacRepo := repomocks.NewMockRBACRepository(ctrl)
+				rbacRepo.EXPECT().FindAuthority(gomock.Any(), int64(1)).Return(moderator, nil)
+				rbacRepo.EXPECT().FindAuthority(gomock.Any(), int64(2)).Return(admin, nil)
+				return repomocks.NewMockUserRepository(ctrl), rbacRepo, repomocks.NewMockAdminAuditRepository(ctrl)
+			},
+			wantErr: ErrRoleTooLow,
+		},
+		{
+			name: "版主不能封禁同级的版主",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.RBACRepository, repository.AdminAuditRepository) {
+				rbacRepo := repomocks.NewMockRBACRepository(ctrl)
+				rbacRepo.EXPECT().FindAuthority(gomock.Any(), int64(1)).Return(moderator, nil)
+				rbacRepo.EXPECT().FindAuthority(gomock.Any(), int64(2)).Return(moderator, nil)
+				return repomocks.NewMockUserRepository(ctrl), rbacRepo, repomocks.NewMockAdminAuditRepository(ctrl)
+			},
+			wantErr: ErrRoleTooLow,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			userRepo, rbacRepo, auditRepo := tc.mock(ctrl)
+			svc := NewAdminService(userRepo, rbacRepo, auditRepo, nil, nil, nil, nil)
+			err := svc.BanUser(context.Background(), 1, 2, "spam")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestAdminService_RoleAndTwoFactor(t *testing.T) {
+	moderator := domain.Authority{Roles: []string{domain.RoleModerator}}
+	admin := domain.Authority{Roles: []string{domain.RoleAdmin}}
+	testCases := []struct {
+		name    string
+		target  domain.Authority
+		mock    func(ctrl *gomock.Controller) (repository.RBACRepository, repository.AdminAuditRepository, TwoFactorService)
+		call    func(svc AdminService) error
+		wantErr error
+	}{
+		{
+			name:   "管理员任命版主",
+			target: domain.Authority{},
+			mock: func(ctrl *gomock.Controller) (repository.RBACRepository, repository.AdminAuditRepository, TwoFactorService) {
+				rbacRepo := repomocks.NewMockRBACRepository(ctrl)
+				rbacRepo.EXPECT().AssignRole(gomock.Any(), int64(2), domain.RoleModerator).Return(nil)
+				auditRepo := repomocks.NewMockAdminAuditRepository(ctrl)
+				auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+				return rbacRepo, auditRepo, svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			call: func(svc AdminService) error {
+				return svc.AssignRole(context.Background(), 1, 2, domain.RoleModerator)
+			},
+		},
+		{
+			name:   "管理员不能任命同级的管理员",
+			target: domain.Authority{},
+			mock: func(ctrl *gomock.Controller) (repository.RBACRepository, repository.AdminAuditRepository, TwoFactorService) {
+				return repomocks.NewMockRBACRepository(ctrl), repomocks.NewMockAdminAuditRepository(ctrl),
+					svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			call: func(svc AdminService) error {
+				return svc.AssignRole(context.Background(), 1, 2, domain.RoleAdmin)
+			},
+			wantErr: ErrRoleTooLow,
+		},
+		{
+			name:   "管理员不能给别的管理员改角色",
+			target: admin,
+			mock: func(ctrl *gomock.Controller) (repository.RBACRepository, repository.AdminAuditRepository, TwoFactorService) {
+				return repomocks.NewMockRBACRepository(ctrl), repomocks.NewMockAdminAuditRepository(ctrl),
+					svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			call: func(svc AdminService) error {
+				return svc.AssignRole(context.Background(), 1, 2, domain.RoleModerator)
+			},
+			wantErr: ErrRoleTooLow,
+		},
+		{
+			name:   "管理员撤销版主",
+			target: moderator,
+			mock: func(ctrl *gomock.Controller) (repository.RBACRepository, repository.AdminAuditRepository, TwoFactorService) {
+				rbacRepo := repomocks.NewMockRBACRepository(ctrl)
+				rbacRepo.EXPECT().RevokeRole(gomock.Any(), int64(2), domain.RoleModerator).Return(nil)
+				auditRepo := repomocks.NewMockAdminAuditRepository(ctrl)
+				auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+				return rbacRepo, auditRepo, svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			call: func(svc AdminService) error {
+				return svc.RevokeRole(context.Background(), 1, 2, domain.RoleModerator)
+			},
+		},
+		{
+			name:   "管理员不能撤销别的管理员",
+			target: admin,
+			mock: func(ctrl *gomock.Controller) (repository.RBACRepository, repository.AdminAuditRepository, TwoFactorService) {
+				return repomocks.NewMockRBACRepository(ctrl), repomocks.NewMockAdminAuditRepository(ctrl),
+					svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			call: func(svc AdminService) error {
+				return svc.RevokeRole(context.Background(), 1, 2, domain.RoleAdmin)
+			},
+			wantErr: ErrRoleTooLow,
+		},
+		{
+			name:   "重置普通用户的二次验证",
+			target: domain.Authority{},
+			mock: func(ctrl *gomock.Controller) (repository.RBACRepository, repository.AdminAuditRepository, TwoFactorService) {
+				twoFactorSvc := svcmocks.NewMockTwoFactorService(ctrl)
+				twoFactorSvc.EXPECT().Reset(gomock.Any(), int64(2)).Return(nil)
+				auditRepo := repomocks.NewMockAdminAuditRepository(ctrl)
+				auditRepo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+				return repomocks.NewMockRBACRepository(ctrl), auditRepo, twoFactorSvc
+			},
+			call: func(svc AdminService) error {
+				return svc.ResetTwoFactor(context.Background(), 1, 2)
+			},
+		},
+		{
+			name:   "不能重置别的管理员的二次验证",
+			target: admin,
+			mock: func(ctrl *gomock.Controller) (repository.RBACRepository, repository.AdminAuditRepository, TwoFactorService) {
+				return repomocks.NewMockRBACRepository(ctrl), repomocks.NewMockAdminAuditRepository(ctrl),
+					svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			call: func(svc AdminService) error {
+				return svc.ResetTwoFactor(context.Background(), 1, 2)
+			},
+			wantErr: ErrRoleTooLow,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			rbacRepo, auditRepo, twoFactorSvc := tc.mock(ctrl)
+			rbacMock := rbacRepo.(*repomocks.MockRBACRepository)
+			rbacMock.EXPECT().FindAuthority(gomock.Any(), int64(1)).Return(admin, nil)
+			rbacMock.EXPECT().FindAuthority(gomock.Any(), int64(2)).Return(tc.target, nil)
+			svc := NewAdminService(nil, rbacRepo, auditRepo, nil, twoFactorSvc, nil, nil)
+			assert.Equal(t, tc.wantErr, tc.call(svc))
+		})
+	}
+}
+
+func TestRBACService_Authority(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (repository.RBACRepository, repository.UserRepository)
+		wantAuth domain.Authority
+		wantErr  error
+	}{
+		{
+			name: "管理员",
+			mock: func(ctrl *gomock.Controller) (repository.RBACRepository, repository.UserRepository) {
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{Id: 1}, nil)
+				rbacRepo := repomocks.NewMockRBACRepository(ctrl)
+				rbacRepo.EXPECT().FindAuthority(gomock.Any(), int64(1)).Return(domain.Authority{
+					Roles:       []string{domain.RoleModerator},
+					Permissions: []string{domain.PermUserBan},
+				}, nil)
+				return rbacRepo, userRepo
+			},
+			wantAuth: domain.Authority{
+				Roles:       []string{domain.RoleModerator},
+				Permissions: []string{domain.PermUserBan},
+			},
+		},
+		{
+			name: "已被封禁",
+			mock: func(ctrl *gomock.Controller) (repository.RBACRepository, repository.UserRepository) {
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindById(gomock.Any(), int64(1)).
+					Return(domain.User{Id: 1, Status: domain.UserStatusBanned}, nil)
+				return repomocks.NewMockRBACRepository(ctrl), userRepo
+			},
+			wantErr: ErrUserBanned,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewRBACService(tc.mock(ctrl))
+			auth, err := svc.Authority(context.Background(), 1)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantAuth, auth)
+		})
+	}
+}
+
+func TestRBACService_CheckBanned(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	userRepo := repomocks.NewMockUserRepository(ctrl)
+	userRepo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{Id: 1}, nil)
+	userRepo.EXPECT().FindById(gomock.Any(), int64(2)).
+		Return(domain.User{Id: 2, Status: domain.UserStatusBanned}, nil)
+	svc := NewRBACService(repomocks.NewMockRBACRepository(ctrl), userRepo)
+	assert.NoError(t, svc.CheckBanned(context.Background(), 1))
+	assert.Equal(t, ErrUserBanned, svc.CheckBanned(context.Background(), 2))
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 	"webook/internal/domain"
 	events "webook/internal/events/article"
 	repository "webook/internal/repository/article"
@@ -106,9 +107,10 @@ func (s *ArticleService_) PubDetail(ctx context.Context, id int64, uid int64) (d
 
 	art, err := s.repo.GetPubByID(ctx, id)
 	if err == nil && s.producer != nil {
-		//异步生产读事件
+		//异步生产读事件，请求返回之后 ctx 就被取消了，不能直接用
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
 		go func() {
-
+			defer cancel()
 			//这里不要带上具体的参数，因为可能队列中的信息对应的字段早就被修改了，想要自己去查
 			er := s.producer.ProduceReadEvent(ctx, events.ReadEvent{Uid: uid, Aid: id})
 			if er != nil {
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"webook/internal/repository"
 	"webook/internal/service/sms"
+	ratelimit "webook/pkg/ratelimit/limiter"
 )
 
 var (
@@ -13,37 +15,82 @@ var (
 	ErrCodeSendSystemError = repository.ErrCodeSendSystemError
 	ErrCodeVerifyTooMany   = repository.ErrCodeVerifyTooMany
 	ErrCodeVerifyInvalid   = repository.ErrCodeVerifyInvalid
-	codetplId              = "1234567890"
+	// ErrCodeSendLimited 触发了发送验证码的限流，具体是哪个维度看 CodeLimitError
+	ErrCodeSendLimited = errors.New("发送验证码触发限流")
 )
 
+// CodeLimitScope 发送验证码的限流维度
+type CodeLimitScope string
+
+const (
+	// CodeLimitScopeIP 同一个 IP 的发送次数，防止一个人换着手机号刷
+	CodeLimitScopeIP CodeLimitScope = "ip"
+	// CodeLimitScopePhone 同一个手机号的发送次数，不区分业务
+	CodeLimitScopePhone CodeLimitScope = "phone"
+	// CodeLimitScopeBiz 整个业务的发送次数，兜底保护短信预算
+	CodeLimitScopeBiz CodeLimitScope = "biz"
+)
+
+// CodeLimit 一个维度的限流，窗口和阈值由 Limiter 决定，比如手机号每天 10 条、IP 每小时 20 条
+type CodeLimit struct {
+	Scope   CodeLimitScope
+	Limiter ratelimit.Limiter
+}
+
+// CodeLimitError 触发限流的维度，errors.Is(err, ErrCodeSendLimited) 为 true
+type CodeLimitError struct {
+	Scope CodeLimitScope
+}
+
+func (e *CodeLimitError) Error() string {
+	return fmt.Sprintf("%s, scope: %s", ErrCodeSendLimited.Error(), e.Scope)
+}
+
+func (e *CodeLimitError) Is(target error) bool {
+	return target == ErrCodeSendLimited
+}
+
 type CodeService interface {
-	Send(ctx context.Context, biz string, phone string) error
+	// Send ip 是请求方的 IP，用来按照 IP 限流
+	Send(ctx context.Context, biz string, phone string, ip string) error
 	Verify(ctx context.Context, biz string, phone string, inputCode string) error
 }
 
 type CodeService_ struct {
 	repo   repository.CodeRepository
 	smsSvc sms.Service
+	limits []CodeLimit
 }
 
-func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service) CodeService {
+// NewCodeService limits 按顺序检查，建议把 IP 放在前面，
+// 这样被 IP 拦下来的请求不会占用手机号和业务的额度
+func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service, limits ...CodeLimit) CodeService {
 	return &CodeService_{
 		repo:   repo,
 		smsSvc: smsSvc,
+		limits: limits,
 	}
 }
 
-func (svc *CodeService_) Send(ctx context.Context, biz string, phone string) error {
+func (svc *CodeService_) Send(ctx context.Context, biz string, phone string, ip string) error {
+	// 先检查重发间隔，发送太频繁的请求不占用限流额度
+	if err := svc.repo.CheckResend(ctx, biz, phone); err != nil {
+		return err
+	}
+	// 限流要在生成验证码之前，被限流的请求不会覆盖掉已经发出去的验证码
+	if err := svc.limit(ctx, biz, phone, ip); err != nil {
+		return err
+	}
 	//先生成验证码
 	num := svc.generateCode()
 	//存到redis中
-	err := svc.repo.Set(ctx, "login", phone, num)
+	err := svc.repo.Set(ctx, biz, phone, num)
 	if err != nil {
 		return err
 	}
 
-	// 发送验证码
-	err = svc.smsSvc.Send(ctx, codetplId, []string{num}, phone)
+	// 发送验证码，biz 就是业务名，由模板注册表换成服务商的模板 id
+	err = svc.smsSvc.Send(ctx, biz, []string{num}, phone)
 	if err != nil {
 		//这里要不要直接返回错误，需要把redis中的key删除掉吗
 		//由于err可能是超时连接的错误，因此实际上不需要删除key
@@ -54,6 +101,32 @@ func (svc *CodeService_) Send(ctx context.Context, biz string, phone string) err
 
 	return nil
 }
+
+func (svc *CodeService_) limit(ctx context.Context, biz string, phone string, ip string) error {
+	for _, l := range svc.limits {
+		var key string
+		switch l.Scope {
+		case CodeLimitScopeIP:
+			key = "code_limit:ip:" + ip
+		case CodeLimitScopePhone:
+			key = "code_limit:phone:" + phone
+		case CodeLimitScopeBiz:
+			key = "code_limit:biz:" + biz
+		default:
+			return fmt.Errorf("未知的验证码限流维度 %s", l.Scope)
+		}
+		limited, err := l.Limiter.Limit(ctx, key)
+		if err != nil {
+			// 限流器出错的时候不发送，短信是要花钱的
+			return fmt.Errorf("验证码限流检查失败: %w", err)
+		}
+		if limited {
+			return &CodeLimitError{Scope: l.Scope}
+		}
+	}
+	return nil
+}
+
 func (svc *CodeService_) Verify(ctx context.Context, biz string, phone string, inputCode string) error {
 	return svc.repo.Verify(ctx, biz, phone, inputCode)
 }
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+	"webook/internal/service/sms"
+	smsmocks "webook/internal/service/sms/mocks"
+	ratelimitmocks "webook/pkg/ratelimit/limiter/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCodeService_Send(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, []CodeLimit)
+
+		wantErr   error
+		wantScope CodeLimitScope
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, []CodeLimit) {
+				ipLimiter := ratelimitmocks.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), "code_limit:ip:127.0.0.1").Return(false, nil)
+				phoneLimiter := ratelimitmocks.NewMockLimiter(ctrl)
+				phoneLimiter.EXPECT().Limit(gomock.Any(), "code_limit:phone:13800000000").Return(false, nil)
+				bizLimiter := ratelimitmocks.NewMockLimiter(ctrl)
+				bizLimiter.EXPECT().Limit(gomock.Any(), "code_limit:biz:login").Return(false, nil)
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				repo.EXPECT().CheckResend(gomock.Any(), "login", "13800000000").Return(nil)
+				repo.EXPECT().Set(gomock.Any(), "login", "13800000000", gomock.Any()).Return(nil)
+				smsSvc := smsmocks.NewMockService(ctrl)
+				smsSvc.EXPECT().Send(gomock.Any(), "login", gomock.Any(), "13800000000").Return(nil)
+				return repo, smsSvc, []CodeLimit{
+					{Scope: CodeLimitScopeIP, Limiter: ipLimiter},
+					{Scope: CodeLimitScopePhone, Limiter: phoneLimiter},
+					{Scope: CodeLimitScopeBiz, Limiter: bizLimiter},
+				}
+			},
+		},
+		{
+			name: "IP 限流，后面的额度不会被占用",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, []CodeLimit) {
+				ipLimiter := ratelimitmocks.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(true, nil)
+				phoneLimiter := ratelimitmocks.NewMockLimiter(ctrl)
+				return passResend(ctrl), smsmocks.NewMockService(ctrl), []CodeLimit{
+					{Scope: CodeLimitScopeIP, Limiter: ipLimiter},
+					{Scope: CodeLimitScopePhone, Limiter: phoneLimiter},
+				}
+			},
+			wantErr:   ErrCodeSendLimited,
+			wantScope: CodeLimitScopeIP,
+		},
+		{
+			name: "手机号每天的额度用完了",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, []CodeLimit) {
+				ipLimiter := ratelimitmocks.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(false, nil)
+				phoneLimiter := ratelimitmocks.NewMockLimiter(ctrl)
+				phoneLimiter.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(true, nil)
+				return passResend(ctrl), smsmocks.NewMockService(ctrl), []CodeLimit{
+					{Scope: CodeLimitScopeIP, Limiter: ipLimiter},
+					{Scope: CodeLimitScopePhone, Limiter: phoneLimiter},
+				}
+			},
+			wantErr:   ErrCodeSendLimited,
+			wantScope: CodeLimitScopePhone,
+		},
+		{
+			name: "限流器出错不发送",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, []CodeLimit) {
+				bizLimiter := ratelimitmocks.NewMockLimiter(ctrl)
+				bizLimiter.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(false, errors.New("redis 错误"))
+				return passResend(ctrl), smsmocks.NewMockService(ctrl), []CodeLimit{
+					{Scope: CodeLimitScopeBiz, Limiter: bizLimiter},
+				}
+			},
+			wantErr: errors.New("验证码限流检查失败: redis 错误"),
+		},
+		{
+			name: "发送太频繁，不占用限流额度",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, []CodeLimit) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				repo.EXPECT().CheckResend(gomock.Any(), "login", "13800000000").Return(ErrCodeSendTooMany)
+				// 没有 EXPECT，调用 Limit 就会失败
+				ipLimiter := ratelimitmocks.NewMockLimiter(ctrl)
+				return repo, smsmocks.NewMockService(ctrl), []CodeLimit{
+					{Scope: CodeLimitScopeIP, Limiter: ipLimiter},
+				}
+			},
+			wantErr: ErrCodeSendTooMany,
+		},
+		{
+			name: "并发发送，检查通过之后 Set 仍然可能失败",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, []CodeLimit) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				repo.EXPECT().CheckResend(gomock.Any(), "login", "13800000000").Return(nil)
+				repo.EXPECT().Set(gomock.Any(), "login", "13800000000", gomock.Any()).Return(ErrCodeSendTooMany)
+				return repo, smsmocks.NewMockService(ctrl), nil
+			},
+			wantErr: ErrCodeSendTooMany,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, smsSvc, limits := tc.mock(ctrl)
+			svc := NewCodeService(repo, smsSvc, limits...)
+			err := svc.Send(context.Background(), "login", "13800000000", "127.0.0.1")
+			if tc.wantScope == "" {
+				if tc.wantErr == nil {
+					assert.NoError(t, err)
+				} else {
+					assert.EqualError(t, err, tc.wantErr.Error())
+				}
+				return
+			}
+			assert.ErrorIs(t, err, tc.wantErr)
+			var limitErr *CodeLimitError
+			assert.True(t, errors.As(err, &limitErr))
+			assert.Equal(t, tc.wantScope, limitErr.Scope)
+		})
+	}
+}
+
+// passResend 重发间隔检查通过的 repo
+func passResend(ctrl *gomock.Controller) repository.CodeRepository {
+	repo := repomocks.NewMockCodeRepository(ctrl)
+	repo.EXPECT().CheckResend(gomock.Any(), "login", "13800000000").Return(nil)
+	return repo
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/pkg/logger"
+)
+
+type LoginAuditService interface {
+	// Record 同步执行风控规则，再异步写入登录日志，写日志不会阻塞登录流程
+	Record(ctx context.Context, log domain.LoginLog)
+	Recent(ctx context.Context, uid int64, limit int) ([]domain.LoginLog, error)
+	// Close 把缓冲区里面的日志写完再返回，之后的 Record 直接丢弃
+	Close()
+}
+
+// AsyncLoginAuditService 风控规则在请求里面执行，用 channel 做缓冲，后台 goroutine 批量写入数据库
+type AsyncLoginAuditService struct {
+	repo repository.LoginLogRepository
+	// userRepo 登录失败的时候按账号查出用户 id，用户才能在自己的登录记录里面看到别人的尝试
+	userRepo repository.UserRepository
+	risk     LoginRiskService
+	l        logger.LoggerV1
+
+	logs          chan domain.LoginLog
+	batchSize     int
+	flushInterval time.Duration
+	// mu 保护 closed，Close 之后不能再往 logs 里面写
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+}
+
+func NewLoginAuditService(repo repository.LoginLogRepository, userRepo repository.UserRepository,
+	risk LoginRiskService, l logger.LoggerV1) LoginAuditService {
+	svc := &AsyncLoginAuditService{
+		repo:          repo,
+		userRepo:      userRepo,
+		risk:          risk,
+		l:             l,
+		logs:          make(chan domain.LoginLog, 1024),
+		batchSize:     100,
+		flushInterval: time.Second,
+		done:          make(chan struct{}),
+	}
+	go svc.run()
+	return svc
+}
+
+func (svc *AsyncLoginAuditService) Record(ctx context.Context, log domain.LoginLog) {
+	if log.Ctime.IsZero() {
+		log.Ctime = time.Now()
+	}
+	// 失败次数必须在请求里面计数，放到后台的话缓冲区被刷满之后失败就不再计数，锁定也就被绕过了。
+	// 客户端断开也要计数，所以不用请求的取消信号
+	if svc.risk != nil {
+		svc.risk.Evaluate(context.WithoutCancel(ctx), log)
+	}
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+	if svc.closed {
+		svc.l.Warn("登录日志服务已经关闭，丢弃日志",
+			logger.String("account", log.Account), logger.String("ip", log.IP))
+		return
+	}
+	select {
+	case svc.logs <- log:
+	default:
+		// 缓冲区满了说明数据库写不过来，丢掉比阻塞登录好，风控已经计过数了
+		svc.l.Warn("登录日志缓冲区已满，丢弃日志",
+			logger.String("account", log.Account), logger.String("ip", log.IP))
+	}
+}
+
+func (svc *AsyncLoginAuditService) Recent(ctx context.Context, uid int64, limit int) ([]domain.LoginLog, error) {
+	return svc.repo.FindRecent(ctx, uid, limit)
+}
+
+func (svc *AsyncLoginAuditService) Close() {
+	svc.mu.Lock()
+	if !svc.closed {
+		svc.closed = true
+		close(svc.logs)
+	}
+	svc.mu.Unlock()
+	<-svc.done
+}
+
+func (svc *AsyncLoginAuditService) run() {
+	defer close(svc.done)
+	ticker := time.NewTicker(svc.flushInterval)
+	defer ticker.Stop()
+	batch := make([]domain.LoginLog, 0, svc.batchSize)
+	for {
+		select {
+		case log, ok := <-svc.logs:
+			if !ok {
+				svc.flush(batch)
+				return
+			}
+			batch = append(batch, svc.resolveUid(log))
+			if len(batch) >= svc.batchSize {
+				svc.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			svc.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// resolveUid 登录失败的时候 handler 拿不到用户 id，按账号查出来，查不到的账号 uid 保持 0
+func (svc *AsyncLoginAuditService) resolveUid(log domain.LoginLog) domain.LoginLog {
+	if log.Uid != 0 || log.Account == "" || svc.userRepo == nil {
+		return log
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var (
+		u   domain.User
+		err error
+	)
+	switch log.Method {
+	case domain.LoginMethodPassword:
+		u, err = svc.userRepo.FindByEmail(ctx, log.Account)
+	case domain.LoginMethodSMS:
+		u, err = svc.userRepo.FindByPhone(ctx, log.Account)
+	default:
+		return log
+	}
+	if err != nil {
+		if err != repository.ErrUserNotFound {
+			svc.l.Warn("查询登录账号对应的用户失败", logger.String("account", log.Account), logger.Error(err))
+		}
+		return log
+	}
+	log.Uid = u.Id
+	return log
+}
+
+func (svc *AsyncLoginAuditService) flush(batch []domain.LoginLog) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	if err := svc.repo.BatchCreate(ctx, batch); err != nil {
+		svc.l.Error("写入登录日志失败", logger.Int("count", len(batch)), logger.Error(err))
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/pkg/logger"
+)
+
+var ErrUserLocked = errors.New("登录失败次数过多，账号已被临时锁定")
+
+// LoginRule 一条登录风控规则，返回 nil 代表没有命中
+type LoginRule interface {
+	Name() string
+	Evaluate(ctx context.Context, log domain.LoginLog) (*domain.LoginRiskEvent, error)
+}
+
+// LoginRiskNotifier 规则命中之后的通知，可以换成短信、邮件或者站内信
+type LoginRiskNotifier interface {
+	Notify(ctx context.Context, evt domain.LoginRiskEvent) error
+}
+
+type LoginRiskService interface {
+	// Locked 账号是不是被临时锁定了
+	Locked(ctx context.Context, account string) (bool, error)
+	// Evaluate 按顺序执行所有规则，命中之后锁定账号或者发送通知
+	Evaluate(ctx context.Context, log domain.LoginLog) []domain.LoginRiskEvent
+}
+
+type LoginRiskService_ struct {
+	repo         repository.LoginRiskRepository
+	rules        []LoginRule
+	notifier     LoginRiskNotifier
+	lockDuration time.Duration
+	l            logger.LoggerV1
+}
+
+func NewLoginRiskService(repo repository.LoginRiskRepository, notifier LoginRiskNotifier,
+	l logger.LoggerV1, rules ...LoginRule) LoginRiskService {
+	return &LoginRiskService_{
+		repo:         repo,
+		rules:        rules,
+		notifier:     notifier,
+		lockDuration: time.Minute * 15,
+		l:            l,
+	}
+}
+
+// DefaultLoginRules 默认的规则：15 分钟内失败 5 次锁定，新设备登录通知，同一个 IP 10 分钟内登录超过 10 个账号通知
+func DefaultLoginRules(repo repository.LoginRiskRepository) []LoginRule {
+	return []LoginRule{
+		NewBruteForceRule(repo, 5, time.Minute*15),
+		NewNewDeviceRule(repo),
+		NewIPAccountsRule(repo, 10, time.Minute*10),
+	}
+}
+
+func (svc *LoginRiskService_) Locked(ctx context.Context, account string) (bool, error) {
+	return svc.repo.Locked(ctx, account)
+}
+
+func (svc *LoginRiskService_) Evaluate(ctx context.Context, log domain.LoginLog) []domain.LoginRiskEvent {
+	var events []domain.LoginRiskEvent
+	for _, rule := range svc.rules {
+		evt, err := rule.Evaluate(ctx, log)
+		if err != nil {
+			// 风控出错不影响登录，记录下来就可以
+			svc.l.Error("登录风控规则执行失败", logger.String("rule", rule.Name()), logger.Error(err))
+			continue
+		}
+		if evt == nil {
+			continue
+		}
+		events = append(events, *evt)
+		switch evt.Action {
+		case domain.LoginRiskActionLock:
+			if err = svc.repo.Lock(ctx, log.Account, svc.lockDuration); err != nil {
+				svc.l.Error("锁定账号失败", logger.String("account", log.Account), logger.Error(err))
+			}
+		case domain.LoginRiskActionNotify:
+			if svc.notifier == nil {
+				continue
+			}
+			if err = svc.notifier.Notify(ctx, *evt); err != nil {
+				svc.l.Error("发送登录风险通知失败", logger.String("rule", rule.Name()), logger.Error(err))
+			}
+		}
+	}
+	return events
+}
+
+// BruteForceRule 账号在窗口内连续失败太多次就锁定，登录成功之后清零。
+// 锁定期间被拒绝的尝试不计数，不然攻击者一直尝试就能让账号一直处于锁定状态
+type BruteForceRule struct {
+	repo      repository.LoginRiskRepository
+	threshold int64
+	window    time.Duration
+}
+
+func NewBruteForceRule(repo repository.LoginRiskRepository, threshold int64, window time.Duration) *BruteForceRule {
+	return &BruteForceRule{repo: repo, threshold: threshold, window: window}
+}
+
+func (r *BruteForceRule) Name() string {
+	return "brute_force"
+}
+
+func (r *BruteForceRule) Evaluate(ctx context.Context, log domain.LoginLog) (*domain.LoginRiskEvent, error) {
+	if log.Account == "" {
+		return nil, nil
+	}
+	if log.Success {
+		return nil, r.repo.ResetFailures(ctx, log.Account)
+	}
+	locked, err := r.repo.Locked(ctx, log.Account)
+	if err != nil || locked {
+		return nil, err
+	}
+	cnt, err := r.repo.IncrFailures(ctx, log.Account, r.window)
+	if err != nil || cnt < r.threshold {
+		return nil, err
+	}
+	return &domain.LoginRiskEvent{
+		Rule:   r.Name(),
+		Action: domain.LoginRiskActionLock,
+		Log:    log,
+		Detail: fmt.Sprintf("%s 内失败 %d 次", r.window, cnt),
+	}, nil
+}
+
+// NewDeviceRule 用户在没见过的设备上登录成功就通知，第一次登录不算
+type NewDeviceRule struct {
+	repo repository.LoginRiskRepository
+}
+
+func NewNewDeviceRule(repo repository.LoginRiskRepository) *NewDeviceRule {
+	return &NewDeviceRule{repo: repo}
+}
+
+func (r *NewDeviceRule) Name() string {
+	return "new_device"
+}
+
+func (r *NewDeviceRule) Evaluate(ctx context.Context, log domain.LoginLog) (*domain.LoginRiskEvent, error) {
+	if !log.Success || log.Uid == 0 {
+		return nil, nil
+	}
+	isNew, hasHistory, err := r.repo.AddDevice(ctx, log.Uid, deviceFingerprint(log.UserAgent))
+	if err != nil || !isNew || !hasHistory {
+		return nil, err
+	}
+	return &domain.LoginRiskEvent{
+		Rule:   r.Name(),
+		Action: domain.LoginRiskActionNotify,
+		Log:    log,
+		Detail: "新设备登录: " + log.UserAgent,
+	}, nil
+}
+
+// IPAccountsRule 同一个 IP 短时间内尝试很多个账号，一般是撞库
+type IPAccountsRule struct {
+	repo      repository.LoginRiskRepository
+	threshold int64
+	window    time.Duration
+}
+
+func NewIPAccountsRule(repo repository.LoginRiskRepository, threshold int64, window time.Duration) *IPAccountsRule {
+	return &IPAccountsRule{repo: repo, threshold: threshold, window: window}
+}
+
+func (r *IPAccountsRule) Name() string {
+	return "ip_accounts"
+}
+
+func (r *IPAccountsRule) Evaluate(ctx context.Context, log domain.LoginLog) (*domain.LoginRiskEvent, error) {
+	if log.IP == "" || log.Account == "" {
+		return nil, nil
+	}
+	cnt, err := r.repo.AddIPAccount(ctx, log.IP, log.Account, r.window)
+	// 只在刚好达到阈值的时候通知一次
+	if err != nil || cnt != r.threshold {
+		return nil, err
+	}
+	return &domain.LoginRiskEvent{
+		Rule:   r.Name(),
+		Action: domain.LoginRiskActionNotify,
+		Log:    log,
+		Detail: fmt.Sprintf("IP %s 在 %s 内登录了 %d 个账号", log.IP, r.window, cnt),
+	}, nil
+}
+
+// deviceFingerprint 目前只用 UA 区分设备，存哈希避免集合里面的元素太长
+func deviceFingerprint(userAgent string) string {
+	sum := sha1.Sum([]byte(userAgent))
+	return hex.EncodeToString(sum[:8])
+}
+
+// LogLoginRiskNotifier 只打日志，还没有接入真正的通知渠道之前使用
+type LogLoginRiskNotifier struct {
+	l logger.LoggerV1
+}
+
+func NewLogLoginRiskNotifier(l logger.LoggerV1) LoginRiskNotifier {
+	return &LogLoginRiskNotifier{l: l}
+}
+
+func (n *LogLoginRiskNotifier) Notify(ctx context.Context, evt domain.LoginRiskEvent) error {
+	n.l.Warn("登录风险",
+		logger.String("rule", evt.Rule),
+		logger.Int64("uid", evt.Log.Uid),
+		logger.String("account", evt.Log.Account),
+		logger.String("ip", evt.Log.IP),
+		logger.String("detail", evt.Detail))
+	return nil
+}
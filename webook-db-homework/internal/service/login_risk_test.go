@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+	svcmocks "webook/internal/service/mocks"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestLoginRiskService_Evaluate(t *testing.T) {
+	const ua = "Mozilla/5.0"
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (repository.LoginRiskRepository, LoginRiskNotifier)
+		log  domain.LoginLog
+
+		wantRules []string
+	}{
+		{
+			name: "失败次数达到阈值，锁定账号",
+			mock: func(ctrl *gomock.Controller) (repository.LoginRiskRepository, LoginRiskNotifier) {
+				repo := repomocks.NewMockLoginRiskRepository(ctrl)
+				repo.EXPECT().Locked(gomock.Any(), "a@qq.com").Return(false, nil)
+				repo.EXPECT().IncrFailures(gomock.Any(), "a@qq.com", time.Minute*15).Return(int64(5), nil)
+				repo.EXPECT().Lock(gomock.Any(), "a@qq.com", time.Minute*15).Return(nil)
+				repo.EXPECT().AddIPAccount(gomock.Any(), "1.1.1.1", "a@qq.com", time.Minute*10).Return(int64(1), nil)
+				return repo, svcmocks.NewMockLoginRiskNotifier(ctrl)
+			},
+			log:       domain.LoginLog{Account: "a@qq.com", IP: "1.1.1.1", UserAgent: ua},
+			wantRules: []string{"brute_force"},
+		},
+		{
+			name: "失败次数没到阈值",
+			mock: func(ctrl *gomock.Controller) (repository.LoginRiskRepository, LoginRiskNotifier) {
+				repo := repomocks.NewMockLoginRiskRepository(ctrl)
+				repo.EXPECT().Locked(gomock.Any(), "a@qq.com").Return(false, nil)
+				repo.EXPECT().IncrFailures(gomock.Any(), "a@qq.com", time.Minute*15).Return(int64(2), nil)
+				repo.EXPECT().AddIPAccount(gomock.Any(), "1.1.1.1", "a@qq.com", time.Minute*10).Return(int64(1), nil)
+				return repo, svcmocks.NewMockLoginRiskNotifier(ctrl)
+			},
+			log: domain.LoginLog{Account: "a@qq.com", IP: "1.1.1.1", UserAgent: ua},
+		},
+		{
+			name: "锁定期间的失败不计数，也不会延长锁定",
+			mock: func(ctrl *gomock.Controller) (repository.LoginRiskRepository, LoginRiskNotifier) {
+				repo := repomocks.NewMockLoginRiskRepository(ctrl)
+				repo.EXPECT().Locked(gomock.Any(), "a@qq.com").Return(true, nil)
+				repo.EXPECT().AddIPAccount(gomock.Any(), "1.1.1.1", "a@qq.com", time.Minute*10).Return(int64(1), nil)
+				return repo, svcmocks.NewMockLoginRiskNotifier(ctrl)
+			},
+			log: domain.LoginLog{Account: "a@qq.com", IP: "1.1.1.1", UserAgent: ua},
+		},
+		{
+			name: "新设备登录，发送通知",
+			mock: func(ctrl *gomock.Controller) (repository.LoginRiskRepository, LoginRiskNotifier) {
+				repo := repomocks.NewMockLoginRiskRepository(ctrl)
+				repo.EXPECT().ResetFailures(gomock.Any(), "a@qq.com").Return(nil)
+				repo.EXPECT().AddDevice(gomock.Any(), int64(1), deviceFingerprint(ua)).Return(true, true, nil)
+				repo.EXPECT().AddIPAccount(gomock.Any(), "1.1.1.1", "a@qq.com", time.Minute*10).Return(int64(1), nil)
+				notifier := svcmocks.NewMockLoginRiskNotifier(ctrl)
+				notifier.EXPECT().Notify(gomock.Any(), gomock.Any()).Return(nil)
+				return repo, notifier
+			},
+			log:       domain.LoginLog{Account: "a@qq.com", Uid: 1, IP: "1.1.1.1", UserAgent: ua, Success: true},
+			wantRules: []string{"new_device"},
+		},
+		{
+			name: "第一次登录不算新设备",
+			mock: func(ctrl *gomock.Controller) (repository.LoginRiskRepository, LoginRiskNotifier) {
+				repo := repomocks.NewMockLoginRiskRepository(ctrl)
+				repo.EXPECT().ResetFailures(gomock.Any(), "a@qq.com").Return(nil)
+				repo.EXPECT().AddDevice(gomock.Any(), int64(1), deviceFingerprint(ua)).Return(true, false, nil)
+				repo.EXPECT().AddIPAccount(gomock.Any(), "1.1.1.1", "a@qq.com", time.Minute*10).Return(int64(1), nil)
+				return repo, svcmocks.NewMockLoginRiskNotifier(ctrl)
+			},
+			log: domain.LoginLog{Account: "a@qq.com", Uid: 1, IP: "1.1.1.1", UserAgent: ua, Success: true},
+		},
+		{
+			name: "同一个 IP 登录太多账号，规则出错不影响其他规则",
+			mock: func(ctrl *gomock.Controller) (repository.LoginRiskRepository, LoginRiskNotifier) {
+				repo := repomocks.NewMockLoginRiskRepository(ctrl)
+				repo.EXPECT().Locked(gomock.Any(), "z@qq.com").Return(false, errors.New("redis error"))
+				repo.EXPECT().AddIPAccount(gomock.Any(), "1.1.1.1", "z@qq.com", time.Minute*10).Return(int64(10), nil)
+				notifier := svcmocks.NewMockLoginRiskNotifier(ctrl)
+				notifier.EXPECT().Notify(gomock.Any(), gomock.Any()).Return(nil)
+				return repo, notifier
+			},
+			log:       domain.LoginLog{Account: "z@qq.com", IP: "1.1.1.1", UserAgent: ua},
+			wantRules: []string{"ip_accounts"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, notifier := tc.mock(ctrl)
+			svc := NewLoginRiskService(repo, notifier, logger.NewZapLogger(zap.NewNop()), DefaultLoginRules(repo)...)
+			events := svc.Evaluate(context.Background(), tc.log)
+			var rules []string
+			for _, evt := range events {
+				rules = append(rules, evt.Rule)
+			}
+			assert.Equal(t, tc.wantRules, rules)
+		})
+	}
+}
+
+func TestAsyncLoginAuditService_RecordAfterClose(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockLoginLogRepository(ctrl)
+	repo.EXPECT().BatchCreate(gomock.Any(), gomock.Len(1)).Return(nil)
+	svc := NewLoginAuditService(repo, nil, nil, logger.NewNopLogger())
+	svc.Record(context.Background(), domain.LoginLog{Account: "a@qq.com"})
+	svc.Close()
+	// 关闭之后再记录直接丢弃，重复关闭也没问题
+	assert.NotPanics(t, func() {
+		svc.Record(context.Background(), domain.LoginLog{Account: "b@qq.com"})
+		svc.Close()
+	})
+}
+
+func TestAsyncLoginAuditService_Record(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockLoginLogRepository(ctrl)
+	userRepo := repomocks.NewMockUserRepository(ctrl)
+	risk := svcmocks.NewMockLoginRiskService(ctrl)
+
+	failed := domain.LoginLog{Account: "a@qq.com", Method: domain.LoginMethodPassword}
+	unknown := domain.LoginLog{Account: "b@qq.com", Method: domain.LoginMethodPassword}
+	risk.EXPECT().Evaluate(gomock.Any(), gomock.Any()).Times(2)
+	userRepo.EXPECT().FindByEmail(gomock.Any(), "a@qq.com").Return(domain.User{Id: 10}, nil)
+	userRepo.EXPECT().FindByEmail(gomock.Any(), "b@qq.com").Return(domain.User{}, repository.ErrUserNotFound)
+	// 登录失败的日志也记在账号对应的用户下面，用户才能看到别人在尝试登录自己的账号
+	repo.EXPECT().BatchCreate(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, logs []domain.LoginLog) error {
+			assert.Len(t, logs, 2)
+			assert.Equal(t, int64(10), logs[0].Uid)
+			assert.Equal(t, int64(0), logs[1].Uid)
+			return nil
+		})
+	svc := NewLoginAuditService(repo, userRepo, risk, logger.NewNopLogger())
+	svc.Record(context.Background(), failed)
+	svc.Record(context.Background(), unknown)
+	svc.Close()
+}
+
+func TestAsyncLoginAuditService_RecordBufferFull(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	risk := svcmocks.NewMockLoginRiskService(ctrl)
+	// 缓冲区满了日志会丢，但是失败次数照样要计数，不然刷满缓冲区就能绕过锁定
+	risk.EXPECT().Evaluate(gomock.Any(), gomock.Any()).Times(3)
+	svc := &AsyncLoginAuditService{risk: risk, l: logger.NewNopLogger(), logs: make(chan domain.LoginLog)}
+	for i := 0; i < 3; i++ {
+		svc.Record(context.Background(), domain.LoginLog{Account: "a@qq.com"})
+	}
+}
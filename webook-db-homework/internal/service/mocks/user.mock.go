@@ -56,19 +56,19 @@ func (mr *MockUserServiceMockRecorder) FindOrCreate(ctx, phone any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreate", reflect.TypeOf((*MockUserService)(nil).FindOrCreate), ctx, phone)
 }
 
-// FindOrCreateByWechat mocks base method.
-func (m *MockUserService) FindOrCreateByWechat(ctx context.Context, wechatUser domain.WechatUser) (domain.User, error) {
+// FindOrCreateByOAuth2 mocks base method.
+func (m *MockUserService) FindOrCreateByOAuth2(ctx context.Context, info domain.OAuth2User) (domain.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindOrCreateByWechat", ctx, wechatUser)
+	ret := m.ctrl.Call(m, "FindOrCreateByOAuth2", ctx, info)
 	ret0, _ := ret[0].(domain.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// FindOrCreateByWechat indicates an expected call of FindOrCreateByWechat.
-func (mr *MockUserServiceMockRecorder) FindOrCreateByWechat(ctx, wechatUser any) *gomock.Call {
+// FindOrCreateByOAuth2 indicates an expected call of FindOrCreateByOAuth2.
+func (mr *MockUserServiceMockRecorder) FindOrCreateByOAuth2(ctx, info any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreateByWechat", reflect.TypeOf((*MockUserService)(nil).FindOrCreateByWechat), ctx, wechatUser)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreateByOAuth2", reflect.TypeOf((*MockUserService)(nil).FindOrCreateByOAuth2), ctx, info)
 }
 
 // GetUserById mocks base method.
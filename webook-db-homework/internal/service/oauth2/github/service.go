@@ -0,0 +1,161 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+)
+
+const ProviderName = "github"
+
+var _ oauth2.Provider = (*Service)(nil)
+
+// Endpoint GitHub 的几个接口地址，测试的时候可以替换成 httptest 的地址
+type Endpoint struct {
+	AuthURL  string
+	TokenURL string
+	UserURL  string
+}
+
+var DefaultEndpoint = Endpoint{
+	AuthURL:  "https://github.com/login/oauth/authorize",
+	TokenURL: "https://github.com/login/oauth/access_token",
+	UserURL:  "https://api.github.com/user",
+}
+
+type Service struct {
+	clientId     string
+	clientSecret string
+	redirectURI  string
+	endpoint     Endpoint
+	client       *http.Client
+}
+
+func NewService(clientId string, clientSecret string, redirectURI string) *Service {
+	return NewServiceWithEndpoint(clientId, clientSecret, redirectURI, DefaultEndpoint, http.DefaultClient)
+}
+
+func NewServiceWithEndpoint(clientId string, clientSecret string, redirectURI string,
+	endpoint Endpoint, client *http.Client) *Service {
+	return &Service{
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		redirectURI:  redirectURI,
+		endpoint:     endpoint,
+		client:       client,
+	}
+}
+
+func (s *Service) Name() string {
+	return ProviderName
+}
+
+func (s *Service) AuthURL(ctx context.Context, state string, codeChallenge string) (string, error) {
+	params := url.Values{}
+	params.Set("client_id", s.clientId)
+	params.Set("redirect_uri", s.redirectURI)
+	params.Set("scope", "read:user")
+	params.Set("state", state)
+	if codeChallenge != "" {
+		params.Set("code_challenge", codeChallenge)
+		params.Set("code_challenge_method", "S256")
+	}
+	return s.endpoint.AuthURL + "?" + params.Encode(), nil
+}
+
+func (s *Service) VerifyCode(ctx context.Context, code string, codeVerifier string) (domain.OAuth2User, error) {
+	token, err := s.accessToken(ctx, code, codeVerifier)
+	if err != nil {
+		return domain.OAuth2User{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint.UserURL, nil)
+	if err != nil {
+		return domain.OAuth2User{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return domain.OAuth2User{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return domain.OAuth2User{}, fmt.Errorf("获取 GitHub 用户信息失败, status: %d", resp.StatusCode)
+	}
+	var user userResult
+	if err = json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return domain.OAuth2User{}, err
+	}
+	if user.Id == 0 {
+		return domain.OAuth2User{}, errors.New("获取 GitHub 用户信息失败")
+	}
+	nickname := user.Name
+	if nickname == "" {
+		nickname = user.Login
+	}
+	return domain.OAuth2User{
+		Provider: ProviderName,
+		Subject:  strconv.FormatInt(user.Id, 10),
+		Nickname: nickname,
+		Email:    user.Email,
+		Avatar:   user.AvatarURL,
+	}, nil
+}
+
+func (s *Service) accessToken(ctx context.Context, code string, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("client_id", s.clientId)
+	form.Set("client_secret", s.clientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", s.redirectURI)
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// 不指定的话 GitHub 返回的是 form 编码
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result tokenResult
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	// GitHub 授权码错误的时候返回的也是 200，需要看 error 字段
+	if result.Error != "" {
+		return "", fmt.Errorf("获取 GitHub access token 失败: %s %s", result.Error, result.ErrorDescription)
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("获取 GitHub access token 失败")
+	}
+	return result.AccessToken, nil
+}
+
+type tokenResult struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	Scope            string `json:"scope"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type userResult struct {
+	Id        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	AvatarURL string `json:"avatar_url"`
+}
@@ -0,0 +1,126 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeGithub 用 httptest 模拟 GitHub 的 token 接口和用户接口
+func newFakeGithub(t *testing.T, verifier string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("client_secret") != "secret" ||
+			r.PostForm.Get("code") != "good-code" ||
+			r.PostForm.Get("code_verifier") != verifier {
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"error":             "bad_verification_code",
+				"error_description": "The code passed is incorrect or expired.",
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "gho_token",
+			"token_type":   "bearer",
+		})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":         123,
+			"login":      "octocat",
+			"name":       "",
+			"email":      "octocat@example.com",
+			"avatar_url": "https://example.com/a.png",
+		})
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestService_AuthURL(t *testing.T) {
+	svc := NewService("client", "secret", "http://localhost:8080/oauth2/github/callback")
+	verifier, err := oauth2.NewCodeVerifier()
+	require.NoError(t, err)
+	challenge := oauth2.CodeChallengeS256(verifier)
+
+	raw, err := svc.AuthURL(context.Background(), "my-state", challenge)
+	require.NoError(t, err)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, "github.com", u.Host)
+	assert.Equal(t, "client", q.Get("client_id"))
+	assert.Equal(t, "my-state", q.Get("state"))
+	assert.Equal(t, "http://localhost:8080/oauth2/github/callback", q.Get("redirect_uri"))
+	assert.Equal(t, challenge, q.Get("code_challenge"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+}
+
+func TestService_VerifyCode(t *testing.T) {
+	const verifier = "verifier-from-cookie"
+	server := newFakeGithub(t, verifier)
+	defer server.Close()
+
+	svc := NewServiceWithEndpoint("client", "secret", "http://localhost/callback", Endpoint{
+		AuthURL:  server.URL + "/login/oauth/authorize",
+		TokenURL: server.URL + "/login/oauth/access_token",
+		UserURL:  server.URL + "/user",
+	}, server.Client())
+
+	testCases := []struct {
+		name     string
+		code     string
+		verifier string
+		wantUser domain.OAuth2User
+		wantErr  bool
+	}{
+		{
+			name:     "登录成功",
+			code:     "good-code",
+			verifier: verifier,
+			wantUser: domain.OAuth2User{
+				Provider: "github",
+				Subject:  "123",
+				Nickname: "octocat",
+				Email:    "octocat@example.com",
+				Avatar:   "https://example.com/a.png",
+			},
+		},
+		{
+			name:     "授权码错误",
+			code:     "bad-code",
+			verifier: verifier,
+			wantErr:  true,
+		},
+		{
+			name:     "code_verifier 不匹配",
+			code:     "good-code",
+			verifier: "another-verifier",
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := svc.VerifyCode(context.Background(), tc.code, tc.verifier)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
@@ -0,0 +1,17 @@
+// 第三方账号登录的通用抽象，具体的服务商（微信、GitHub 等）放在子包里
+package oauth2
+
+import (
+	"context"
+	"webook/internal/domain"
+)
+
+type Provider interface {
+	// Name 服务商名字，同时也是路由 /oauth2/:provider 中的 provider
+	Name() string
+	// AuthURL 构造跳转到第三方授权页面的 URL
+	// state 由调用方生成并负责校验，codeChallenge 为空代表不使用 PKCE
+	AuthURL(ctx context.Context, state string, codeChallenge string) (string, error)
+	// VerifyCode 用回调拿到的授权码换取用户身份，不支持 PKCE 的服务商忽略 codeVerifier
+	VerifyCode(ctx context.Context, code string, codeVerifier string) (domain.OAuth2User, error)
+}
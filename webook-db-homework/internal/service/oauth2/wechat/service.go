@@ -1,4 +1,4 @@
-//这里我们可以在oauth包下面写钉钉，谷歌等等的其他的第三方账号登录
+// 微信扫码登录，实现了 oauth2.Provider，钉钉、谷歌等其他第三方账号登录也按照这个方式放在 oauth2 包下面
 
 package wechat
 
@@ -8,65 +8,74 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"webook/internal/domain"
-
-	uuid "github.com/lithammer/shortuuid/v4"
+	"webook/internal/service/oauth2"
 )
 
-var redirectURI = ""
+const ProviderName = "wechat"
 
-type Service interface {
-	AuthURL(ctx context.Context) (string, error)
-	VerifyCode(ctx context.Context, code string) (domain.WechatUser, error)
-}
+var _ oauth2.Provider = (*service)(nil)
 
 type service struct {
-	appId     string
-	appSecret string
-	client    *http.Client
+	appId       string
+	appSecret   string
+	redirectURI string
+	client      *http.Client
+}
+
+func NewService(appId string, appSecret string, redirectURI string) oauth2.Provider {
+	return NewServiceWithClient(appId, appSecret, redirectURI, http.DefaultClient)
 }
 
-func NewService(appId string, appSecret string) Service {
+// NewServiceWithClient 可以传入带熔断的 client
+func NewServiceWithClient(appId string, appSecret string, redirectURI string, client *http.Client) oauth2.Provider {
 	return &service{
-		appId:     appId,
-		appSecret: appSecret,
-		client:    http.DefaultClient,
+		appId:       appId,
+		appSecret:   appSecret,
+		redirectURI: redirectURI,
+		client:      client,
 	}
 }
 
-func (s *service) AuthURL(ctx context.Context) (string, error) {
+func (s *service) Name() string {
+	return ProviderName
+}
+
+// AuthURL 微信不支持 PKCE，codeChallenge 直接忽略
+func (s *service) AuthURL(ctx context.Context, state string, codeChallenge string) (string, error) {
 	// 微信扫码登录URL模板
 	const urlPattern = "https://open.weixin.qq.com/connect/qrconnect?appid=%s&redirect_uri=%s&response_type=code&scope=snsapi_login&state=%s#wechat_redirect"
-	state := uuid.New()
-	return fmt.Sprintf(urlPattern, s.appId, redirectURI, state), nil
+	return fmt.Sprintf(urlPattern, s.appId, url.QueryEscape(s.redirectURI), url.QueryEscape(state)), nil
 }
 
-func (s *service) VerifyCode(ctx context.Context, code string) (domain.WechatUser, error) {
+func (s *service) VerifyCode(ctx context.Context, code string, codeVerifier string) (domain.OAuth2User, error) {
 	const targetPattern = "https://api.weixin.qq.com/sns/oauth2/access_token?appid=%s&secret=%s&code=%s&grant_type=authorization_code"
-	targetURL := fmt.Sprintf(targetPattern, s.appId, s.appSecret, code)
+	targetURL := fmt.Sprintf(targetPattern, s.appId, s.appSecret, url.QueryEscape(code))
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
 	if err != nil {
-		return domain.WechatUser{}, err
+		return domain.OAuth2User{}, err
 	}
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return domain.WechatUser{}, err
+		return domain.OAuth2User{}, err
 	}
+	defer resp.Body.Close()
 
 	decoder := json.NewDecoder(resp.Body)
 	var result Result
 	err = decoder.Decode(&result)
 	if err != nil {
-		return domain.WechatUser{}, err
+		return domain.OAuth2User{}, err
 	}
 	if result.ErrCode != 0 {
-		return domain.WechatUser{}, errors.New("获取微信用户信息失败")
+		return domain.OAuth2User{}, errors.New("获取微信用户信息失败")
 	}
 
-	return domain.WechatUser{
-		OpenID:  result.OpenID,
-		UnionID: result.UnionID,
+	return domain.OAuth2User{
+		Provider: ProviderName,
+		Subject:  result.OpenID,
 	}, nil
 }
 
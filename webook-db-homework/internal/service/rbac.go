@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"webook/internal/domain"
+	"webook/internal/repository"
+)
+
+var (
+	ErrUserBanned   = errors.New("用户已被封禁")
+	ErrRoleNotFound = repository.ErrRoleNotFound
+)
+
+type RBACService interface {
+	// Authority 签发登录态之前调用，被封禁的用户返回 ErrUserBanned
+	Authority(ctx context.Context, uid int64) (domain.Authority, error)
+	// CheckBanned 已经登录的请求每次都会调用，被封禁的用户返回 ErrUserBanned
+	CheckBanned(ctx context.Context, uid int64) error
+	AssignRole(ctx context.Context, uid int64, role string) error
+	RevokeRole(ctx context.Context, uid int64, role string) error
+}
+
+type RBACService_ struct {
+	repo     repository.RBACRepository
+	userRepo repository.UserRepository
+}
+
+func NewRBACService(repo repository.RBACRepository, userRepo repository.UserRepository) RBACService {
+	return &RBACService_{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+func (svc *RBACService_) Authority(ctx context.Context, uid int64) (domain.Authority, error) {
+	u, err := svc.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return domain.Authority{}, err
+	}
+	if u.Banned() {
+		return domain.Authority{}, ErrUserBanned
+	}
+	return svc.repo.FindAuthority(ctx, uid)
+}
+
+func (svc *RBACService_) CheckBanned(ctx context.Context, uid int64) error {
+	// FindById 优先读缓存，封禁的时候会同步删除缓存，所以马上就能生效
+	u, err := svc.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if u.Banned() {
+		return ErrUserBanned
+	}
+	return nil
+}
+
+func (svc *RBACService_) AssignRole(ctx context.Context, uid int64, role string) error {
+	return svc.repo.AssignRole(ctx, uid, role)
+}
+
+func (svc *RBACService_) RevokeRole(ctx context.Context, uid int64, role string) error {
+	return svc.repo.RevokeRole(ctx, uid, role)
+}
@@ -0,0 +1,105 @@
+package aliyun
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// FakeMessage 假服务收到的一条短信
+type FakeMessage struct {
+	Numbers      []string
+	SignName     string
+	TemplateCode string
+	Params       map[string]string
+}
+
+// FakeServer 本地模拟阿里云短信接口，会校验签名，测试不用连外网。
+// 通过 Fail 可以让接下来的请求返回指定的错误码
+type FakeServer struct {
+	*httptest.Server
+	accessKeyId     string
+	accessKeySecret string
+
+	mutex    sync.Mutex
+	messages []FakeMessage
+	failures []fakeFailure
+}
+
+type fakeFailure struct {
+	status int
+	code   string
+}
+
+func NewFakeServer(accessKeyId string, accessKeySecret string) *FakeServer {
+	f := &FakeServer{
+		accessKeyId:     accessKeyId,
+		accessKeySecret: accessKeySecret,
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// Fail 接下来 cnt 个请求返回 status 和 code
+func (f *FakeServer) Fail(cnt int, status int, code string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i := 0; i < cnt; i++ {
+		f.failures = append(f.failures, fakeFailure{status: status, code: code})
+	}
+}
+
+func (f *FakeServer) Messages() []FakeMessage {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	res := make([]FakeMessage, len(f.messages))
+	copy(res, f.messages)
+	return res
+}
+
+func (f *FakeServer) handle(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if params.Get("AccessKeyId") != f.accessKeyId {
+		f.write(w, http.StatusNotFound, "InvalidAccessKeyId.NotFound")
+		return
+	}
+	if Sign(r.Method, params, f.accessKeySecret) != params.Get("Signature") {
+		f.write(w, http.StatusBadRequest, "SignatureDoesNotMatch")
+		return
+	}
+
+	f.mutex.Lock()
+	if len(f.failures) > 0 {
+		failure := f.failures[0]
+		f.failures = f.failures[1:]
+		f.mutex.Unlock()
+		f.write(w, failure.status, failure.code)
+		return
+	}
+	var tplParams map[string]string
+	if err := json.Unmarshal([]byte(params.Get("TemplateParam")), &tplParams); err != nil {
+		f.mutex.Unlock()
+		f.write(w, http.StatusBadRequest, "isv.INVALID_JSON_PARAM")
+		return
+	}
+	f.messages = append(f.messages, FakeMessage{
+		Numbers:      strings.Split(params.Get("PhoneNumbers"), ","),
+		SignName:     params.Get("SignName"),
+		TemplateCode: params.Get("TemplateCode"),
+		Params:       tplParams,
+	})
+	f.mutex.Unlock()
+	f.write(w, http.StatusOK, "OK")
+}
+
+func (f *FakeServer) write(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response{
+		Code:      code,
+		Message:   code,
+		RequestId: "fake-request-id",
+	})
+}
@@ -0,0 +1,190 @@
+// Package aliyun 阿里云短信，直接调用 HTTP 接口，按照阿里云 RPC 风格的规则签名
+package aliyun
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"webook/internal/service/sms"
+
+	"github.com/google/uuid"
+)
+
+const DefaultEndpoint = "https://dysmsapi.aliyuncs.com"
+
+// 这些错误码代表服务商那边暂时不可用，换个时间再发有可能成功。
+// 其他的比如余额不足、手机号不合法、模板不对，重试也没有用
+var retryableCodes = map[string]bool{
+	"Throttling":                 true,
+	"Throttling.User":            true,
+	"ServiceUnavailable":         true,
+	"InternalError":              true,
+	"isp.SYSTEM_ERROR":           true,
+	"isv.BUSINESS_LIMIT_CONTROL": true,
+	"SignatureNonceUsed":         true,
+	"isp.GATEWAY_ERROR":          true,
+}
+
+// Error 阿里云返回的业务错误。实现了 Temporary，
+// RetrySMSService 默认的 retryableFn 就能区分能不能重试
+type Error struct {
+	Code      string
+	Message   string
+	RequestId string
+	// HTTPStatus 网关直接返回 5xx 的时候没有业务错误码
+	HTTPStatus int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("aliyun sms 发送失败, status: %d, code: %s, message: %s, request_id: %s",
+		e.HTTPStatus, e.Code, e.Message, e.RequestId)
+}
+
+func (e *Error) Temporary() bool {
+	if e.HTTPStatus >= http.StatusInternalServerError || e.HTTPStatus == http.StatusTooManyRequests {
+		return true
+	}
+	return retryableCodes[e.Code]
+}
+
+// ErrorCode 发送记录里面保存的错误码，网关直接返回的错误没有业务错误码，用 HTTP 状态码代替
+func (e *Error) ErrorCode() string {
+	if e.Code == "" {
+		return "http_" + strconv.Itoa(e.HTTPStatus)
+	}
+	return e.Code
+}
+
+type Service struct {
+	client          *http.Client
+	endpoint        string
+	accessKeyId     string
+	accessKeySecret string
+	signature       string
+	now             func() time.Time
+}
+
+func NewService(client *http.Client, endpoint string, accessKeyId string,
+	accessKeySecret string, signature string) *Service {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	return &Service{
+		client:          client,
+		endpoint:        endpoint,
+		accessKeyId:     accessKeyId,
+		accessKeySecret: accessKeySecret,
+		signature:       signature,
+		now:             time.Now,
+	}
+}
+
+type response struct {
+	Code      string `json:"Code"`
+	Message   string `json:"Message"`
+	RequestId string `json:"RequestId"`
+	BizId     string `json:"BizId"`
+}
+
+// Send biz 是阿里云的模板 code。阿里云的模板参数是具名的，
+// 这里按照位置命名成 p1, p2 ...，配置模板的时候要用 ${p1} 这种写法
+func (s *Service) Send(ctx context.Context, biz string, args []string, numbers ...string) error {
+	signature := s.signature
+	if sig, ok := sms.SignatureFromContext(ctx); ok {
+		signature = sig
+	}
+	tplParam := make(map[string]string, len(args))
+	for i, arg := range args {
+		tplParam[ParamName(i)] = arg
+	}
+	tplParamJSON, err := json.Marshal(tplParam)
+	if err != nil {
+		return err
+	}
+	params := url.Values{}
+	params.Set("Action", "SendSms")
+	params.Set("Version", "2017-05-25")
+	params.Set("Format", "JSON")
+	params.Set("AccessKeyId", s.accessKeyId)
+	params.Set("SignatureMethod", "HMAC-SHA1")
+	params.Set("SignatureVersion", "1.0")
+	params.Set("SignatureNonce", uuid.New().String())
+	params.Set("Timestamp", s.now().UTC().Format("2006-01-02T15:04:05Z"))
+	params.Set("PhoneNumbers", strings.Join(numbers, ","))
+	params.Set("SignName", signature)
+	params.Set("TemplateCode", biz)
+	params.Set("TemplateParam", string(tplParamJSON))
+	params.Set("Signature", Sign(http.MethodGet, params, s.accessKeySecret))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		s.endpoint+"/?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var res response
+	if er := json.Unmarshal(body, &res); er != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("aliyun sms 解析响应失败: %w", er)
+	}
+	if resp.StatusCode == http.StatusOK && res.Code == "OK" {
+		return nil
+	}
+	return &Error{
+		Code:       res.Code,
+		Message:    res.Message,
+		RequestId:  res.RequestId,
+		HTTPStatus: resp.StatusCode,
+	}
+}
+
+func ParamName(idx int) string {
+	return "p" + strconv.Itoa(idx+1)
+}
+
+// Sign 阿里云 RPC 风格签名：参数排序之后按照 RFC3986 编码，再用 HMAC-SHA1 签名
+func Sign(method string, params url.Values, secret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == "Signature" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, percentEncode(k)+"="+percentEncode(params.Get(k)))
+	}
+	stringToSign := method + "&" + percentEncode("/") + "&" + percentEncode(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// percentEncode url.QueryEscape 的基础上按照阿里云的要求处理空格、* 和 ~
+func percentEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	s = strings.ReplaceAll(s, "%7E", "~")
+	return s
+}
@@ -0,0 +1,149 @@
+package aliyun
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+	"webook/internal/service/sms"
+	"webook/internal/service/sms/failover"
+	"webook/internal/service/sms/retry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Send(t *testing.T) {
+	testCases := []struct {
+		name   string
+		secret string
+		before func(server *FakeServer)
+		ctx    context.Context
+
+		wantErr       bool
+		wantRetryable bool
+		wantCode      string
+		wantMessages  []FakeMessage
+	}{
+		{
+			name:   "发送成功",
+			secret: "secret",
+			ctx:    context.Background(),
+			wantMessages: []FakeMessage{
+				{
+					Numbers:      []string{"13800000000"},
+					SignName:     "webook",
+					TemplateCode: "SMS_1001",
+					Params:       map[string]string{"p1": "123456"},
+				},
+			},
+		},
+		{
+			name:   "模板指定了签名",
+			secret: "secret",
+			ctx:    sms.WithSignature(context.Background(), "webook 运营"),
+			wantMessages: []FakeMessage{
+				{
+					Numbers:      []string{"13800000000"},
+					SignName:     "webook 运营",
+					TemplateCode: "SMS_1001",
+					Params:       map[string]string{"p1": "123456"},
+				},
+			},
+		},
+		{
+			name:     "签名不对",
+			secret:   "wrong",
+			ctx:      context.Background(),
+			wantErr:  true,
+			wantCode: "SignatureDoesNotMatch",
+		},
+		{
+			name:   "限流可以重试",
+			secret: "secret",
+			ctx:    context.Background(),
+			before: func(server *FakeServer) {
+				server.Fail(1, http.StatusOK, "isv.BUSINESS_LIMIT_CONTROL")
+			},
+			wantErr:       true,
+			wantRetryable: true,
+			wantCode:      "isv.BUSINESS_LIMIT_CONTROL",
+		},
+		{
+			name:   "网关 5xx 可以重试",
+			secret: "secret",
+			ctx:    context.Background(),
+			before: func(server *FakeServer) {
+				server.Fail(1, http.StatusServiceUnavailable, "ServiceUnavailable")
+			},
+			wantErr:       true,
+			wantRetryable: true,
+			wantCode:      "ServiceUnavailable",
+		},
+		{
+			name:   "余额不足不能重试",
+			secret: "secret",
+			ctx:    context.Background(),
+			before: func(server *FakeServer) {
+				server.Fail(1, http.StatusOK, "isv.AMOUNT_NOT_ENOUGH")
+			},
+			wantErr:  true,
+			wantCode: "isv.AMOUNT_NOT_ENOUGH",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := NewFakeServer("key", "secret")
+			defer server.Close()
+			if tc.before != nil {
+				tc.before(server)
+			}
+			svc := NewService(server.Client(), server.URL, "key", tc.secret, "webook")
+			err := svc.Send(tc.ctx, "SMS_1001", []string{"123456"}, "13800000000")
+			if !tc.wantErr {
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantMessages, server.Messages())
+				return
+			}
+			var aliErr *Error
+			require.True(t, errors.As(err, &aliErr))
+			assert.Equal(t, tc.wantCode, aliErr.Code)
+			assert.Equal(t, tc.wantRetryable, aliErr.Temporary())
+			assert.Empty(t, server.Messages())
+		})
+	}
+}
+
+// TestFailoverChain 不依赖外网，验证重试和 failover 能配合阿里云的错误码工作
+func TestFailoverChain(t *testing.T) {
+	primary := NewFakeServer("key", "secret")
+	defer primary.Close()
+	backup := NewFakeServer("key", "secret")
+	defer backup.Close()
+
+	newSvc := func(server *FakeServer) sms.Service {
+		svc := NewService(server.Client(), server.URL, "key", "secret", "webook")
+		return retry.NewRetrySMSService(svc, 2, time.Millisecond, time.Millisecond*5, 0, nil)
+	}
+	svc := failover.NewFailoverSMSService([]sms.Service{newSvc(primary), newSvc(backup)})
+
+	// 主服务商限流，重试一次就成功
+	primary.Fail(1, http.StatusOK, "Throttling")
+	backup.Fail(1, http.StatusOK, "Throttling")
+	require.NoError(t, svc.Send(context.Background(), "SMS_1001", []string{"1"}, "13800000000"))
+	require.NoError(t, svc.Send(context.Background(), "SMS_1001", []string{"2"}, "13800000000"))
+	assert.Len(t, primary.Messages(), 1)
+	assert.Len(t, backup.Messages(), 1)
+
+	// 轮询到备用服务商，不能重试的错误直接切回主服务商
+	backup.Fail(1, http.StatusOK, "isv.OUT_OF_SERVICE")
+	require.NoError(t, svc.Send(context.Background(), "SMS_1001", []string{"3"}, "13800000000"))
+	assert.Len(t, primary.Messages(), 2)
+	assert.Len(t, backup.Messages(), 1)
+
+	// 两个都不可用
+	primary.Fail(3, http.StatusServiceUnavailable, "ServiceUnavailable")
+	backup.Fail(3, http.StatusServiceUnavailable, "ServiceUnavailable")
+	assert.Error(t, svc.Send(context.Background(), "SMS_1001", []string{"4"}, "13800000000"))
+}
@@ -2,19 +2,187 @@ package async
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
 	"webook/internal/service/sms"
+	"webook/pkg/logger"
 )
 
+type Config struct {
+	// WindowSize 滑动窗口记录最近多少次发送
+	WindowSize int `mapstructure:"window_size"`
+	// MinSamples 样本数量太少的时候不做判断
+	MinSamples int `mapstructure:"min_samples"`
+	// ErrRateThreshold 错误率超过这个值切换到异步
+	ErrRateThreshold float64 `mapstructure:"err_rate_threshold"`
+	// LatencyThreshold 平均响应时间超过这个值切换到异步
+	LatencyThreshold time.Duration `mapstructure:"latency_threshold"`
+	// RecoverErrRate 异步模式下，错误率低于这个值并且响应时间正常就切换回同步
+	RecoverErrRate float64 `mapstructure:"recover_err_rate"`
+	// RetryMax 异步发送最多发送多少次
+	RetryMax int `mapstructure:"retry_max"`
+	// Workers 后台发送的 goroutine 数量
+	Workers int `mapstructure:"workers"`
+	// PollInterval 没有待发送短信的时候，等待多久再查一次
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		WindowSize:       100,
+		MinSamples:       20,
+		ErrRateThreshold: 0.3,
+		LatencyThreshold: time.Second * 2,
+		RecoverErrRate:   0.1,
+		RetryMax:         3,
+		Workers:          2,
+		PollInterval:     time.Second,
+	}
+}
+
+// SMSService 服务商正常的时候同步发送，出问题的时候把请求存到数据库，立刻返回成功，后台 worker 再发送
 type SMSService struct {
-	svc sms.Service
+	svc    sms.Service
+	repo   repository.AsyncSmsRepository
+	l      logger.LoggerV1
+	cfg    Config
+	window *slidingWindow
+	async  atomic.Bool
 }
 
-func NewSMSService(svc sms.Service) sms.Service {
+func NewSMSService(svc sms.Service, repo repository.AsyncSmsRepository, l logger.LoggerV1, cfg Config) *SMSService {
 	return &SMSService{
-		svc: svc,
+		svc:    svc,
+		repo:   repo,
+		l:      l,
+		cfg:    cfg,
+		window: newSlidingWindow(cfg.WindowSize),
 	}
 }
 
 func (s *SMSService) Send(ctx context.Context, biz string, args []string, numbers ...string) error {
-	return s.svc.Send(ctx, biz, args, numbers...)
+	if s.async.Load() {
+		// 存到数据库就算接收成功
+		err := s.repo.Add(ctx, domain.AsyncSms{
+			Biz:      biz,
+			Args:     args,
+			Numbers:  numbers,
+			RetryMax: s.cfg.RetryMax,
+		})
+		if err == nil {
+			return nil
+		}
+		// 数据库也出问题了，只能试一下同步发送
+		s.l.Error("保存异步短信失败，尝试同步发送", logger.Error(err))
+	}
+	return s.send(ctx, biz, args, numbers...)
+}
+
+// Async 当前是不是异步模式
+func (s *SMSService) Async() bool {
+	return s.async.Load()
+}
+
+// Backlog 积压的短信数量，给运维看的
+func (s *SMSService) Backlog(ctx context.Context) (int64, error) {
+	return s.repo.Backlog(ctx)
+}
+
+// StartAsyncCycle 启动后台 worker，ctx 取消之后退出
+func (s *SMSService) StartAsyncCycle(ctx context.Context) {
+	for i := 0; i < s.cfg.Workers; i++ {
+		go s.asyncCycle(ctx)
+	}
+}
+
+func (s *SMSService) asyncCycle(ctx context.Context) {
+	for ctx.Err() == nil {
+		found := s.sendOne(ctx)
+		if found {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.PollInterval):
+		}
+	}
+}
+
+// sendOne 返回 false 代表现在没有可以处理的短信
+func (s *SMSService) sendOne(ctx context.Context) bool {
+	dbCtx, cancel := context.WithTimeout(ctx, time.Second)
+	as, err := s.repo.PreemptWaitingSMS(dbCtx)
+	cancel()
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrAsyncSMSPreempted):
+		// 被别人抢走了，马上再试一次
+		return true
+	case errors.Is(err, repository.ErrWaitingSMSNotFound):
+		return false
+	case errors.Is(err, repository.ErrAsyncSMSInvalid):
+		// 已经标记为失败了，接着处理下一条
+		s.l.Error("异步短信数据无法解析，放弃发送", logger.Int64("id", as.Id), logger.Error(err))
+		return true
+	default:
+		s.l.Error("抢占异步短信失败", logger.Error(err))
+		return false
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, time.Second*5)
+	err = s.send(sendCtx, as.Biz, as.Args, as.Numbers...)
+	cancel()
+	if err != nil {
+		s.l.Error("异步发送短信失败", logger.Int64("id", as.Id), logger.Error(err))
+	}
+	dbCtx, cancel = context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	er := s.repo.ReportScheduleResult(dbCtx, as, err == nil)
+	switch {
+	case errors.Is(er, repository.ErrAsyncSMSPreempted):
+		// 发得太慢，超时之后被别的 worker 重新抢走了，状态以它为准
+		s.l.Warn("异步短信已经被重新抢占，不更新状态", logger.Int64("id", as.Id))
+	case er != nil:
+		s.l.Error("更新异步短信状态失败", logger.Int64("id", as.Id), logger.Error(er))
+	}
+	return true
+}
+
+// send 真正调用服务商，结果计入滑动窗口
+func (s *SMSService) send(ctx context.Context, biz string, args []string, numbers ...string) error {
+	start := time.Now()
+	err := s.svc.Send(ctx, biz, args, numbers...)
+	s.window.add(err != nil, time.Since(start))
+	s.adjust()
+	return err
+}
+
+// adjust 根据滑动窗口切换同步和异步模式
+func (s *SMSService) adjust() {
+	cnt, errRate, latency := s.window.stats()
+	if cnt < s.cfg.MinSamples {
+		return
+	}
+	if !s.async.Load() {
+		if errRate > s.cfg.ErrRateThreshold || latency > s.cfg.LatencyThreshold {
+			if s.async.CompareAndSwap(false, true) {
+				s.window.reset()
+				s.l.Warn("短信服务商异常，切换到异步发送",
+					logger.String("errRate", strconv.FormatFloat(errRate, 'f', 2, 64)), logger.String("latency", latency.String()))
+			}
+		}
+		return
+	}
+	// 异步模式下的样本来自后台 worker
+	if errRate < s.cfg.RecoverErrRate && latency <= s.cfg.LatencyThreshold {
+		if s.async.CompareAndSwap(true, false) {
+			s.window.reset()
+			s.l.Info("短信服务商恢复，切换回同步发送")
+		}
+	}
 }
@@ -0,0 +1,200 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+	"webook/internal/service/sms"
+	smsmocks "webook/internal/service/sms/mocks"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.WindowSize = 10
+	cfg.MinSamples = 4
+	return cfg
+}
+
+func TestSMSService_Send(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository)
+		// before 发送之前窗口里面的结果，true 代表失败
+		before    []bool
+		async     bool
+		wantErr   error
+		wantAsync bool
+	}{
+		{
+			name: "同步发送成功",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl", []string{"1234"}, "152").Return(nil)
+				return svc, repomocks.NewMockAsyncSmsRepository(ctrl)
+			},
+		},
+		{
+			name: "错误率过高，切换到异步",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl", []string{"1234"}, "152").Return(errors.New("服务商异常"))
+				return svc, repomocks.NewMockAsyncSmsRepository(ctrl)
+			},
+			before:    []bool{true, false, true},
+			wantErr:   errors.New("服务商异常"),
+			wantAsync: true,
+		},
+		{
+			name: "样本太少不切换",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl", []string{"1234"}, "152").Return(errors.New("服务商异常"))
+				return svc, repomocks.NewMockAsyncSmsRepository(ctrl)
+			},
+			before:  []bool{true},
+			wantErr: errors.New("服务商异常"),
+		},
+		{
+			name: "异步模式，存到数据库",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				repo := repomocks.NewMockAsyncSmsRepository(ctrl)
+				repo.EXPECT().Add(gomock.Any(), domain.AsyncSms{
+					Biz:      "tpl",
+					Args:     []string{"1234"},
+					Numbers:  []string{"152"},
+					RetryMax: 3,
+				}).Return(nil)
+				return smsmocks.NewMockService(ctrl), repo
+			},
+			async:     true,
+			wantAsync: true,
+		},
+		{
+			name: "异步模式，数据库出错就同步发送",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				repo := repomocks.NewMockAsyncSmsRepository(ctrl)
+				repo.EXPECT().Add(gomock.Any(), gomock.Any()).Return(errors.New("db error"))
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl", []string{"1234"}, "152").Return(nil)
+				return svc, repo
+			},
+			async:     true,
+			wantAsync: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc, repo := tc.mock(ctrl)
+			s := NewSMSService(svc, repo, logger.NewZapLogger(zap.NewNop()), testConfig())
+			s.async.Store(tc.async)
+			for _, failed := range tc.before {
+				s.window.add(failed, time.Millisecond)
+			}
+			err := s.Send(context.Background(), "tpl", []string{"1234"}, "152")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantAsync, s.Async())
+		})
+	}
+}
+
+func TestSMSService_sendOne(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository)
+		// before 异步模式下窗口里面已有的结果
+		before    []bool
+		wantFound bool
+		wantAsync bool
+	}{
+		{
+			name: "发送成功，服务商恢复切回同步",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				repo := repomocks.NewMockAsyncSmsRepository(ctrl)
+				as := domain.AsyncSms{
+					Id: 1, Biz: "tpl", Args: []string{"1234"}, Numbers: []string{"152"}, RetryCnt: 1, Version: 2,
+				}
+				repo.EXPECT().PreemptWaitingSMS(gomock.Any()).Return(as, nil)
+				// 带着抢占时的版本号上报
+				repo.EXPECT().ReportScheduleResult(gomock.Any(), as, true).Return(nil)
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl", []string{"1234"}, "152").Return(nil)
+				return svc, repo
+			},
+			before:    []bool{false, false, false},
+			wantFound: true,
+		},
+		{
+			name: "发送失败",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				repo := repomocks.NewMockAsyncSmsRepository(ctrl)
+				as := domain.AsyncSms{
+					Id: 1, Biz: "tpl", Args: []string{"1234"}, Numbers: []string{"152"}, RetryCnt: 1, Version: 2,
+				}
+				repo.EXPECT().PreemptWaitingSMS(gomock.Any()).Return(as, nil)
+				// 带着抢占时的版本号上报
+				repo.EXPECT().ReportScheduleResult(gomock.Any(), as, false).Return(nil)
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "tpl", []string{"1234"}, "152").Return(errors.New("服务商异常"))
+				return svc, repo
+			},
+			before:    []bool{false, true, false},
+			wantFound: true,
+			wantAsync: true,
+		},
+		{
+			name: "没有待发送的短信",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				repo := repomocks.NewMockAsyncSmsRepository(ctrl)
+				repo.EXPECT().PreemptWaitingSMS(gomock.Any()).Return(domain.AsyncSms{}, repository.ErrWaitingSMSNotFound)
+				return smsmocks.NewMockService(ctrl), repo
+			},
+			wantAsync: true,
+		},
+		{
+			name: "数据无法解析，跳过接着处理下一条",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				repo := repomocks.NewMockAsyncSmsRepository(ctrl)
+				repo.EXPECT().PreemptWaitingSMS(gomock.Any()).Return(domain.AsyncSms{Id: 1}, repository.ErrAsyncSMSInvalid)
+				return smsmocks.NewMockService(ctrl), repo
+			},
+			wantFound: true,
+			wantAsync: true,
+		},
+		{
+			name: "被其他节点抢占",
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.AsyncSmsRepository) {
+				repo := repomocks.NewMockAsyncSmsRepository(ctrl)
+				repo.EXPECT().PreemptWaitingSMS(gomock.Any()).Return(domain.AsyncSms{}, repository.ErrAsyncSMSPreempted)
+				return smsmocks.NewMockService(ctrl), repo
+			},
+			wantFound: true,
+			wantAsync: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc, repo := tc.mock(ctrl)
+			s := NewSMSService(svc, repo, logger.NewZapLogger(zap.NewNop()), testConfig())
+			s.async.Store(true)
+			for _, failed := range tc.before {
+				s.window.add(failed, time.Millisecond)
+			}
+			found := s.sendOne(context.Background())
+			assert.Equal(t, tc.wantFound, found)
+			assert.Equal(t, tc.wantAsync, s.Async())
+		})
+	}
+}
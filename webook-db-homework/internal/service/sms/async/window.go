@@ -0,0 +1,65 @@
+package async
+
+import (
+	"sync"
+	"time"
+)
+
+// slidingWindow 记录最近 N 次发送的结果，用来判断服务商是不是出问题了
+type slidingWindow struct {
+	mutex     sync.Mutex
+	failed    []bool
+	latencies []time.Duration
+	// pos 下一次写入的位置
+	pos  int
+	full bool
+}
+
+func newSlidingWindow(size int) *slidingWindow {
+	return &slidingWindow{
+		failed:    make([]bool, size),
+		latencies: make([]time.Duration, size),
+	}
+}
+
+func (w *slidingWindow) add(failed bool, latency time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.failed[w.pos] = failed
+	w.latencies[w.pos] = latency
+	w.pos++
+	if w.pos == len(w.failed) {
+		w.pos = 0
+		w.full = true
+	}
+}
+
+// stats 返回样本数量、错误率和平均响应时间
+func (w *slidingWindow) stats() (int, float64, time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	cnt := w.pos
+	if w.full {
+		cnt = len(w.failed)
+	}
+	if cnt == 0 {
+		return 0, 0, 0
+	}
+	var failedCnt int
+	var total time.Duration
+	for i := 0; i < cnt; i++ {
+		if w.failed[i] {
+			failedCnt++
+		}
+		total += w.latencies[i]
+	}
+	return cnt, float64(failedCnt) / float64(cnt), total / time.Duration(cnt)
+}
+
+// reset 切换模式之后清空，避免切换之前的数据影响下一次判断
+func (w *slidingWindow) reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.pos = 0
+	w.full = false
+}
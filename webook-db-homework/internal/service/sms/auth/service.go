@@ -25,7 +25,7 @@ func (s *SmsService) Send(ctx context.Context, biz string, args []string, number
 
 	//这里biz中存的就是jwt token，必须解析后才能正常调用业务，这样我们就可以根据不同的业务来提供不同的模板
 	token, err := jwt.ParseWithClaims(biz, &tc, func(token *jwt.Token) (interface{}, error) {
-		return s.key, nil //传入校验签名的密钥
+		return []byte(s.key), nil //传入校验签名的密钥
 	})
 	if err != nil {
 		return err
@@ -34,10 +34,11 @@ func (s *SmsService) Send(ctx context.Context, biz string, args []string, number
 		return errors.New("token is invalid")
 	}
 
-	return s.svc.Send(ctx, tc.Tpl, args, numbers...)
+	return s.svc.Send(ctx, tc.Biz, args, numbers...)
 }
 
 type Claims struct {
 	jwt.RegisteredClaims
-	Tpl string
+	// Biz 业务名，具体用哪个模板由 template.Registry 决定
+	Biz string
 }
@@ -0,0 +1,40 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"webook/internal/service/sms"
+	"webook/pkg/circuitbreaker"
+)
+
+// SMSService 服务商完全不可用的时候直接返回 circuitbreaker.ErrOpenState，
+// 外面套一层 failover 就会换下一个服务商
+type SMSService struct {
+	svc     sms.Service
+	breaker *circuitbreaker.Breaker
+}
+
+func NewSMSService(svc sms.Service, breaker *circuitbreaker.Breaker) sms.Service {
+	return &SMSService{
+		svc:     svc,
+		breaker: breaker,
+	}
+}
+
+func (s *SMSService) Send(ctx context.Context, biz string, args []string, numbers ...string) error {
+	done, err := s.breaker.Allow()
+	if err != nil {
+		return err
+	}
+	err = s.svc.Send(ctx, biz, args, numbers...)
+	switch {
+	case err == nil:
+		done(circuitbreaker.Success)
+	case errors.Is(ctx.Err(), context.Canceled):
+		// 调用方主动取消不代表服务商有问题，超时还是要算失败，服务商卡住也是不可用
+		done(circuitbreaker.Ignored)
+	default:
+		done(circuitbreaker.Failure)
+	}
+	return err
+}
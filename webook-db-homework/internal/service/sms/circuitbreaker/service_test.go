@@ -0,0 +1,68 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	smsmocks "webook/internal/service/sms/mocks"
+	"webook/pkg/circuitbreaker"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestSMSService_Send(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc := smsmocks.NewMockService(ctrl)
+	breaker := circuitbreaker.NewBreaker(circuitbreaker.Config{
+		Name:         "sms",
+		FailureRatio: 0.5,
+		MinRequests:  2,
+		OpenTimeout:  time.Minute,
+	}, logger.NewZapLogger(zap.NewNop()))
+	s := NewSMSService(svc, breaker)
+
+	// 调用方取消不算失败
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	svc.EXPECT().Send(gomock.Any(), "tpl", gomock.Any(), gomock.Any()).Return(context.Canceled).Times(2)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, context.Canceled, s.Send(canceled, "tpl", []string{"1"}, "152"))
+	}
+	assert.Equal(t, circuitbreaker.StateClosed, breaker.State())
+
+	svc.EXPECT().Send(gomock.Any(), "tpl", gomock.Any(), gomock.Any()).Return(errors.New("服务商异常")).Times(2)
+	for i := 0; i < 2; i++ {
+		assert.Error(t, s.Send(context.Background(), "tpl", []string{"1"}, "152"))
+	}
+	assert.Equal(t, circuitbreaker.StateOpen, breaker.State())
+
+	// 打开之后不会再调用服务商
+	assert.Equal(t, circuitbreaker.ErrOpenState, s.Send(context.Background(), "tpl", []string{"1"}, "152"))
+}
+
+func TestSMSService_SendTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc := smsmocks.NewMockService(ctrl)
+	breaker := circuitbreaker.NewBreaker(circuitbreaker.Config{
+		Name:         "sms",
+		FailureRatio: 0.5,
+		MinRequests:  2,
+		OpenTimeout:  time.Minute,
+	}, logger.NewZapLogger(zap.NewNop()))
+	s := NewSMSService(svc, breaker)
+
+	// 服务商卡住直到超时，和异步发送的 sendCtx 一样，要算失败
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	svc.EXPECT().Send(gomock.Any(), "tpl", gomock.Any(), gomock.Any()).Return(context.DeadlineExceeded).Times(2)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, context.DeadlineExceeded, s.Send(expired, "tpl", []string{"1"}, "152"))
+	}
+	assert.Equal(t, circuitbreaker.StateOpen, breaker.State())
+}
@@ -2,19 +2,45 @@ package failover
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"webook/internal/service/sms"
 )
 
+var errAllFailed = errors.New("全部服务商都发送失败")
+
 type FailoverSMSService struct {
 	svcs []sms.Service
+	// idx 每次发送的起始服务商，轮流往后移，让流量分散到各个服务商
+	idx uint64
 }
 
 func NewFailoverSMSService(svcs []sms.Service) sms.Service {
+	if len(svcs) == 0 {
+		panic("failover: 至少需要一个服务商")
+	}
 	return &FailoverSMSService{
 		svcs: svcs,
 	}
 }
 
 func (f *FailoverSMSService) Send(ctx context.Context, biz string, args []string, numbers ...string) error {
-
+	idx := atomic.AddUint64(&f.idx, 1)
+	length := uint64(len(f.svcs))
+	errs := make([]error, 0, length)
+	// 每个服务商最多试一次
+	for i := idx; i < idx+length; i++ {
+		svc := f.svcs[i%length]
+		err := svc.Send(ctx, biz, args, numbers...)
+		if err == nil {
+			return nil
+		}
+		// 要看调用方的 ctx，服务商自己的超时返回的也可能是 DeadlineExceeded，这种要换下一个
+		if ctx.Err() != nil {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("%w: %w", errAllFailed, errors.Join(errs...))
 }
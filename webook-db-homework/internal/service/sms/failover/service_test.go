@@ -0,0 +1,127 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"webook/internal/service/sms"
+	smsmocks "webook/internal/service/sms/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestFailoverSMSService_Send(t *testing.T) {
+	errVendor0, errVendor1 := errors.New("服务商 0 发送失败"), errors.New("服务商 1 发送失败")
+	// cancel 每个用例自己的 ctx，模拟调用方取消
+	var cancel context.CancelFunc
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) []sms.Service
+		// idx 发送之前的起始位置
+		idx     uint64
+		wantErr error
+		// wantCauses 全部失败的时候每个服务商的错误都要保留下来
+		wantCauses []error
+		wantIdx    uint64
+	}{
+		{
+			name: "第一个就发送成功",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, svc1}
+			},
+			wantIdx: 1,
+		},
+		{
+			name: "起始位置轮转",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				svc1 := smsmocks.NewMockService(ctrl)
+				return []sms.Service{svc0, svc1}
+			},
+			idx:     1,
+			wantIdx: 2,
+		},
+		{
+			name: "第一个失败，换下一个",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("发送失败"))
+				return []sms.Service{svc0, svc1}
+			},
+			wantIdx: 1,
+		},
+		{
+			name: "全部失败，每个只试一次",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errVendor0)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errVendor1)
+				return []sms.Service{svc0, svc1}
+			},
+			wantErr:    errAllFailed,
+			wantCauses: []error{errVendor0, errVendor1},
+			wantIdx:    1,
+		},
+		{
+			name: "服务商自己超时，换下一个",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(fmt.Errorf("调用服务商: %w", context.DeadlineExceeded))
+				return []sms.Service{svc0, svc1}
+			},
+			wantIdx: 1,
+		},
+		{
+			name: "调用方取消，不再换服务商",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, biz string, args []string, numbers ...string) error {
+						// 模拟发送过程中调用方取消
+						cancel()
+						return fmt.Errorf("调用服务商: %w", ctx.Err())
+					})
+				return []sms.Service{svc0, svc1}
+			},
+			wantErr: context.Canceled,
+			wantIdx: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			ctx, cancelCtx := context.WithCancel(context.Background())
+			defer cancelCtx()
+			cancel = cancelCtx
+			svc := NewFailoverSMSService(tc.mock(ctrl)).(*FailoverSMSService)
+			svc.idx = tc.idx
+			err := svc.Send(ctx, "1234", []string{"123456"}, "15212345678")
+			assert.ErrorIs(t, err, tc.wantErr)
+			for _, cause := range tc.wantCauses {
+				assert.ErrorIs(t, err, cause)
+			}
+			assert.Equal(t, tc.wantIdx, svc.idx)
+		})
+	}
+}
+
+func TestNewFailoverSMSService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	assert.Panics(t, func() { NewFailoverSMSService(nil) })
+	assert.NotPanics(t, func() { NewFailoverSMSService([]sms.Service{smsmocks.NewMockService(ctrl)}) })
+}
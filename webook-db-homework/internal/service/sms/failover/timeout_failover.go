@@ -2,6 +2,7 @@ package failover
 
 import (
 	"context"
+	"errors"
 	"sync/atomic"
 	"webook/internal/service/sms"
 )
@@ -10,29 +11,45 @@ type TimeoutFailoverSMSService struct {
 	svcs []sms.Service
 	//超时次数
 	cnt int32
-	//阈值，超过该次数触发切换服务商
+	//阈值，连续超时达到该次数触发切换服务商
 	threshold int32
 	// 服务商索引
 	idx int32
 }
 
+// NewTimeoutFailoverSMSService threshold 必须大于 0，不然每次发送都会切换服务商
+func NewTimeoutFailoverSMSService(svcs []sms.Service, threshold int32) sms.Service {
+	if len(svcs) == 0 {
+		panic("failover: 至少需要一个服务商")
+	}
+	if threshold <= 0 {
+		panic("failover: 超时切换的阈值必须大于 0")
+	}
+	return &TimeoutFailoverSMSService{
+		svcs:      svcs,
+		threshold: threshold,
+	}
+}
+
 func (t *TimeoutFailoverSMSService) Send(ctx context.Context, biz string, args []string, numbers ...string) error {
 	cnt := atomic.LoadInt32(&t.cnt)
 	idx := atomic.LoadInt32(&t.idx)
-	//如果超过阈值
-	if cnt > t.threshold {
+	//如果达到阈值
+	if cnt >= t.threshold {
 		newidx := (idx + 1) % int32(len(t.svcs))
 		if atomic.CompareAndSwapInt32(&t.idx, idx, newidx) { //对比内存地址上的值和刚获取的值，相同就把newidx写入
 			//这种操作更加轻量化，如果发现idx没有更新，则不进行重置
 			atomic.StoreInt32(&t.cnt, 0)
 		}
+		// 不管是不是自己切换成功的，都要用切换之后的服务商
+		idx = atomic.LoadInt32(&t.idx)
 	}
 	svc := t.svcs[idx]
 	err := svc.Send(ctx, biz, args, numbers...)
-	switch err {
-	case nil:
+	switch {
+	case err == nil:
 		atomic.StoreInt32(&t.cnt, 0)
-	case context.DeadlineExceeded:
+	case errors.Is(err, context.DeadlineExceeded):
 		atomic.AddInt32(&t.cnt, 1)
 	default:
 		//其他异常，不重试
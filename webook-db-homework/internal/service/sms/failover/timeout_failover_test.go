@@ -0,0 +1,113 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"webook/internal/service/sms"
+	smsmocks "webook/internal/service/sms/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTimeoutFailoverSMSService_Send(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) []sms.Service
+		threshold int32
+		idx       int32
+		cnt       int32
+
+		wantErr error
+		wantIdx int32
+		wantCnt int32
+	}{
+		{
+			name: "没有触发切换",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, smsmocks.NewMockService(ctrl)}
+			},
+			threshold: 3,
+			cnt:       2,
+			wantIdx:   0,
+			wantCnt:   0,
+		},
+		{
+			name: "达到阈值，切换之后用新的服务商发送",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{smsmocks.NewMockService(ctrl), svc1}
+			},
+			threshold: 3,
+			cnt:       3,
+			wantIdx:   1,
+			wantCnt:   0,
+		},
+		{
+			name: "最后一个切换回第一个",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, smsmocks.NewMockService(ctrl)}
+			},
+			threshold: 3,
+			idx:       1,
+			cnt:       3,
+			wantIdx:   0,
+			wantCnt:   0,
+		},
+		{
+			name: "超时，计数加一",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(context.DeadlineExceeded)
+				return []sms.Service{svc0, smsmocks.NewMockService(ctrl)}
+			},
+			threshold: 3,
+			cnt:       1,
+			wantErr:   context.DeadlineExceeded,
+			wantIdx:   0,
+			wantCnt:   2,
+		},
+		{
+			name: "其他错误，计数不变",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("发送失败"))
+				return []sms.Service{svc0, smsmocks.NewMockService(ctrl)}
+			},
+			threshold: 3,
+			cnt:       1,
+			wantErr:   errors.New("发送失败"),
+			wantIdx:   0,
+			wantCnt:   1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewTimeoutFailoverSMSService(tc.mock(ctrl), tc.threshold).(*TimeoutFailoverSMSService)
+			svc.idx = tc.idx
+			svc.cnt = tc.cnt
+			err := svc.Send(context.Background(), "1234", []string{"123456"}, "15212345678")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantIdx, svc.idx)
+			assert.Equal(t, tc.wantCnt, svc.cnt)
+		})
+	}
+}
+
+func TestNewTimeoutFailoverSMSService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svcs := []sms.Service{smsmocks.NewMockService(ctrl)}
+	assert.Panics(t, func() { NewTimeoutFailoverSMSService(svcs, 0) })
+	assert.Panics(t, func() { NewTimeoutFailoverSMSService(svcs, -1) })
+	assert.Panics(t, func() { NewTimeoutFailoverSMSService(nil, 3) })
+	assert.NotPanics(t, func() { NewTimeoutFailoverSMSService(svcs, 1) })
+}
@@ -2,17 +2,24 @@ package memory
 
 import (
 	"context"
-	"fmt"
+	"strings"
+
+	"webook/pkg/logger"
 )
 
+// Service 本地开发用，不真的发短信，把参数打印到日志里面
 type Service struct {
+	l logger.LoggerV1
 }
 
-func NewService() *Service {
-	return &Service{}
+func NewService(l logger.LoggerV1) *Service {
+	return &Service{l: l}
 }
 
 func (s *Service) Send(ctx context.Context, biz string, args []string, numbers ...string) error {
-	fmt.Println("code: ", args[0])
+	logger.FromContext(ctx, s.l).Info("发送短信",
+		logger.String("biz", biz),
+		logger.String("args", strings.Join(args, ",")),
+		logger.Int("numbers", len(numbers)))
 	return nil
 }
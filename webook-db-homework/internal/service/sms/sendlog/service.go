@@ -0,0 +1,102 @@
+// Package sendlog 记录每一次短信发送的结果，用来对账和排查问题
+package sendlog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/service/sms"
+	"webook/pkg/logger"
+)
+
+// 拿不到服务商错误码的时候用这几个
+const (
+	ErrCodeTimeout  = "timeout"
+	ErrCodeCanceled = "canceled"
+	ErrCodeUnknown  = "unknown"
+)
+
+// errorCoder 服务商的错误实现了这个接口就能记录具体的错误码
+type errorCoder interface {
+	ErrorCode() string
+}
+
+// SMSService 每个服务商单独套一层，要放在 template 外面，这样记录的是业务名而不是模板 id。
+// 短信参数里面有验证码，所以只记录业务名和脱敏之后的手机号
+type SMSService struct {
+	svc      sms.Service
+	provider string
+	repo     repository.SMSLogRepository
+	l        logger.LoggerV1
+	// saveTimeout 写日志的超时时间，日志写失败不影响发送结果
+	saveTimeout time.Duration
+}
+
+func NewSMSService(svc sms.Service, provider string, repo repository.SMSLogRepository, l logger.LoggerV1) sms.Service {
+	return &SMSService{
+		svc:         svc,
+		provider:    provider,
+		repo:        repo,
+		l:           l,
+		saveTimeout: time.Second,
+	}
+}
+
+func (s *SMSService) Send(ctx context.Context, biz string, args []string, numbers ...string) error {
+	start := time.Now()
+	err := s.svc.Send(ctx, biz, args, numbers...)
+	latency := time.Since(start)
+
+	errCode := ""
+	if err != nil {
+		errCode = errorCode(err)
+	}
+	logs := make([]domain.SMSLog, 0, len(numbers))
+	for _, number := range numbers {
+		logs = append(logs, domain.SMSLog{
+			Biz:      biz,
+			Phone:    MaskPhone(number),
+			Provider: s.provider,
+			Latency:  latency,
+			Success:  err == nil,
+			ErrCode:  errCode,
+			Ctime:    start,
+		})
+	}
+	// 调用方的 ctx 可能已经超时了，日志还是要写进去
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.saveTimeout)
+	defer cancel()
+	if er := s.repo.BatchCreate(saveCtx, logs); er != nil {
+		s.l.Error("保存短信发送记录失败",
+			logger.String("biz", biz),
+			logger.String("provider", s.provider),
+			logger.Error(er))
+	}
+	return err
+}
+
+func errorCode(err error) string {
+	var coder errorCoder
+	switch {
+	case errors.As(err, &coder) && coder.ErrorCode() != "":
+		return coder.ErrorCode()
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrCodeTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrCodeCanceled
+	default:
+		return ErrCodeUnknown
+	}
+}
+
+// MaskPhone 只保留前三位和后四位，比如 138****0000
+func MaskPhone(phone string) string {
+	if len(phone) < 8 {
+		return strings.Repeat("*", len(phone))
+	}
+	return phone[:3] + strings.Repeat("*", len(phone)-7) + phone[len(phone)-4:]
+}
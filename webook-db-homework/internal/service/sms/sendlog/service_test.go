@@ -0,0 +1,110 @@
+package sendlog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+	"webook/internal/service/sms"
+	"webook/internal/service/sms/aliyun"
+	smsmocks "webook/internal/service/sms/mocks"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestSMSService_Send(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (sms.Service, repository.SMSLogRepository)
+		numbers []string
+		wantErr error
+		// wantLogs 只比较这几个字段，时间和耗时不比较
+		wantLogs []domain.SMSLog
+	}{
+		{
+			name:    "发送成功",
+			numbers: []string{"13800001234", "13900005678"},
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.SMSLogRepository) {
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "login", []string{"123456"}, "13800001234", "13900005678").Return(nil)
+				return svc, repomocks.NewMockSMSLogRepository(ctrl)
+			},
+			wantLogs: []domain.SMSLog{
+				{Biz: "login", Phone: "138****1234", Provider: "aliyun", Success: true},
+				{Biz: "login", Phone: "139****5678", Provider: "aliyun", Success: true},
+			},
+		},
+		{
+			name:    "服务商错误码",
+			numbers: []string{"13800001234"},
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.SMSLogRepository) {
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(&aliyun.Error{Code: "isv.AMOUNT_NOT_ENOUGH"})
+				return svc, repomocks.NewMockSMSLogRepository(ctrl)
+			},
+			wantErr: &aliyun.Error{Code: "isv.AMOUNT_NOT_ENOUGH"},
+			wantLogs: []domain.SMSLog{
+				{Biz: "login", Phone: "138****1234", Provider: "aliyun", ErrCode: "isv.AMOUNT_NOT_ENOUGH"},
+			},
+		},
+		{
+			name:    "超时",
+			numbers: []string{"13800001234"},
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.SMSLogRepository) {
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(context.DeadlineExceeded)
+				return svc, repomocks.NewMockSMSLogRepository(ctrl)
+			},
+			wantErr: context.DeadlineExceeded,
+			wantLogs: []domain.SMSLog{
+				{Biz: "login", Phone: "138****1234", Provider: "aliyun", ErrCode: ErrCodeTimeout},
+			},
+		},
+		{
+			name:    "记录保存失败不影响发送结果",
+			numbers: []string{"13800001234"},
+			mock: func(ctrl *gomock.Controller) (sms.Service, repository.SMSLogRepository) {
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				repo := repomocks.NewMockSMSLogRepository(ctrl)
+				repo.EXPECT().BatchCreate(gomock.Any(), gomock.Any()).Return(errors.New("db 错误"))
+				return svc, repo
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			smsSvc, repo := tc.mock(ctrl)
+			if tc.wantLogs != nil {
+				repo.(*repomocks.MockSMSLogRepository).EXPECT().BatchCreate(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, logs []domain.SMSLog) error {
+						for i := range logs {
+							assert.False(t, logs[i].Ctime.IsZero())
+							logs[i].Ctime = tc.wantLogs[i].Ctime
+							logs[i].Latency = 0
+						}
+						assert.Equal(t, tc.wantLogs, logs)
+						return nil
+					})
+			}
+			svc := NewSMSService(smsSvc, "aliyun", repo, logger.NewZapLogger(zap.NewNop()))
+			err := svc.Send(context.Background(), "login", []string{"123456"}, tc.numbers...)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestMaskPhone(t *testing.T) {
+	assert.Equal(t, "138****1234", MaskPhone("13800001234"))
+	assert.Equal(t, "+86*******1234", MaskPhone("+8613800001234"))
+	assert.Equal(t, "****", MaskPhone("1234"))
+}
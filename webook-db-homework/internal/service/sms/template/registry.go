@@ -0,0 +1,82 @@
+// Package template 把业务名映射到各个服务商自己的模板，调用方只需要传业务名
+package template
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 业务名
+const (
+	BizLogin         = "login"
+	BizResetPassword = "reset_password"
+	BizBindPhone     = "bind_phone"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("短信模板不存在")
+	ErrInvalidArgs      = errors.New("短信模板参数数量不匹配")
+)
+
+// Template 某个服务商下面的一个模板
+type Template struct {
+	TemplateId string `mapstructure:"template_id"`
+	Signature  string `mapstructure:"signature"`
+	ParamCount int    `mapstructure:"param_count"`
+}
+
+// Config 业务名 -> 服务商 -> 模板
+type Config map[string]map[string]Template
+
+type Registry struct {
+	mutex     sync.RWMutex
+	templates Config
+}
+
+func NewRegistry(cfg Config) (*Registry, error) {
+	r := &Registry{}
+	if err := r.Load(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Load 整体替换所有模板，配置有问题的时候保留原来的模板
+func (r *Registry) Load(cfg Config) error {
+	templates := make(Config, len(cfg))
+	for biz, providers := range cfg {
+		templates[biz] = make(map[string]Template, len(providers))
+		for provider, tpl := range providers {
+			if tpl.TemplateId == "" {
+				return fmt.Errorf("业务 %s 服务商 %s 缺少模板 id", biz, provider)
+			}
+			if tpl.ParamCount < 0 {
+				return fmt.Errorf("业务 %s 服务商 %s 参数数量不合法", biz, provider)
+			}
+			templates[biz][provider] = tpl
+		}
+	}
+	r.mutex.Lock()
+	r.templates = templates
+	r.mutex.Unlock()
+	return nil
+}
+
+func (r *Registry) Get(biz string, provider string) (Template, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	tpl, ok := r.templates[biz][provider]
+	if !ok {
+		return Template{}, fmt.Errorf("%w, biz: %s, provider: %s", ErrTemplateNotFound, biz, provider)
+	}
+	return tpl, nil
+}
+
+// Validate 发送之前检查参数数量，避免服务商那边报错或者发出去一条残缺的短信
+func (t Template) Validate(args []string) error {
+	if len(args) != t.ParamCount {
+		return fmt.Errorf("%w, 需要 %d 个, 实际 %d 个", ErrInvalidArgs, t.ParamCount, len(args))
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package template
+
+import (
+	"context"
+	"webook/internal/service/sms"
+)
+
+// SMSService 套在具体的服务商外面，把业务名换成这个服务商的模板 id。
+// 每个服务商各自套一层，failover 切换服务商的时候就会自动用上对应的模板
+type SMSService struct {
+	svc      sms.Service
+	provider string
+	registry *Registry
+}
+
+func NewSMSService(svc sms.Service, provider string, registry *Registry) sms.Service {
+	return &SMSService{
+		svc:      svc,
+		provider: provider,
+		registry: registry,
+	}
+}
+
+func (s *SMSService) Send(ctx context.Context, biz string, args []string, numbers ...string) error {
+	tpl, err := s.registry.Get(biz, s.provider)
+	if err != nil {
+		return err
+	}
+	if err = tpl.Validate(args); err != nil {
+		return err
+	}
+	if tpl.Signature != "" {
+		ctx = sms.WithSignature(ctx, tpl.Signature)
+	}
+	return s.svc.Send(ctx, tpl.TemplateId, args, numbers...)
+}
@@ -0,0 +1,131 @@
+package template
+
+import (
+	"context"
+	"testing"
+	"webook/internal/service/sms"
+	smsmocks "webook/internal/service/sms/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSMSService_Send(t *testing.T) {
+	cfg := Config{
+		BizLogin: {
+			"tencent": {TemplateId: "1001", Signature: "webook", ParamCount: 1},
+			"memory":  {TemplateId: "login", ParamCount: 1},
+		},
+	}
+	testCases := []struct {
+		name     string
+		provider string
+		biz      string
+		args     []string
+		mock     func(ctrl *gomock.Controller) sms.Service
+		wantErr  error
+	}{
+		{
+			name:     "换成服务商的模板和签名",
+			provider: "tencent",
+			biz:      BizLogin,
+			args:     []string{"123456"},
+			mock: func(ctrl *gomock.Controller) sms.Service {
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "1001", []string{"123456"}, "13800000000").
+					DoAndReturn(func(ctx context.Context, tpl string, args []string, numbers ...string) error {
+						signature, ok := sms.SignatureFromContext(ctx)
+						assert.True(t, ok)
+						assert.Equal(t, "webook", signature)
+						return nil
+					})
+				return svc
+			},
+		},
+		{
+			name:     "没有配置签名",
+			provider: "memory",
+			biz:      BizLogin,
+			args:     []string{"123456"},
+			mock: func(ctrl *gomock.Controller) sms.Service {
+				svc := smsmocks.NewMockService(ctrl)
+				svc.EXPECT().Send(gomock.Any(), "login", []string{"123456"}, "13800000000").
+					DoAndReturn(func(ctx context.Context, tpl string, args []string, numbers ...string) error {
+						_, ok := sms.SignatureFromContext(ctx)
+						assert.False(t, ok)
+						return nil
+					})
+				return svc
+			},
+		},
+		{
+			name:     "业务没有模板",
+			provider: "tencent",
+			biz:      BizBindPhone,
+			args:     []string{"123456"},
+			mock: func(ctrl *gomock.Controller) sms.Service {
+				return smsmocks.NewMockService(ctrl)
+			},
+			wantErr: ErrTemplateNotFound,
+		},
+		{
+			name:     "服务商没有模板",
+			provider: "aliyun",
+			biz:      BizLogin,
+			args:     []string{"123456"},
+			mock: func(ctrl *gomock.Controller) sms.Service {
+				return smsmocks.NewMockService(ctrl)
+			},
+			wantErr: ErrTemplateNotFound,
+		},
+		{
+			name:     "参数数量不对",
+			provider: "tencent",
+			biz:      BizLogin,
+			args:     []string{"123456", "5"},
+			mock: func(ctrl *gomock.Controller) sms.Service {
+				return smsmocks.NewMockService(ctrl)
+			},
+			wantErr: ErrInvalidArgs,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			registry, err := NewRegistry(cfg)
+			require.NoError(t, err)
+			svc := NewSMSService(tc.mock(ctrl), tc.provider, registry)
+			err = svc.Send(context.Background(), tc.biz, tc.args, "13800000000")
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestRegistry_Load(t *testing.T) {
+	registry, err := NewRegistry(Config{
+		BizLogin: {"memory": {TemplateId: "login", ParamCount: 1}},
+	})
+	require.NoError(t, err)
+
+	// 不合法的配置不会覆盖原来的模板
+	err = registry.Load(Config{
+		BizLogin: {"memory": {ParamCount: 1}},
+	})
+	assert.Error(t, err)
+	tpl, err := registry.Get(BizLogin, "memory")
+	require.NoError(t, err)
+	assert.Equal(t, "login", tpl.TemplateId)
+
+	// 重新加载之后旧的模板就没有了
+	err = registry.Load(Config{
+		BizResetPassword: {"memory": {TemplateId: "reset", ParamCount: 1}},
+	})
+	require.NoError(t, err)
+	_, err = registry.Get(BizLogin, "memory")
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+	tpl, err = registry.Get(BizResetPassword, "memory")
+	require.NoError(t, err)
+	assert.Equal(t, "reset", tpl.TemplateId)
+}
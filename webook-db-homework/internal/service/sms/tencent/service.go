@@ -7,6 +7,8 @@ import (
 	"github.com/ecodeclub/ekit"
 	"github.com/ecodeclub/ekit/slice"
 
+	webooksms "webook/internal/service/sms"
+
 	sms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
 )
 
@@ -28,6 +30,9 @@ func (s *Service) Send(ctx context.Context, biz string, args []string, numbers .
 	req := sms.NewSendSmsRequest()
 	req.SmsSdkAppId = s.appId
 	req.SignName = s.signature
+	if signature, ok := webooksms.SignatureFromContext(ctx); ok {
+		req.SignName = ekit.ToPtr[string](signature)
+	}
 	req.TemplateId = ekit.ToPtr[string](biz)
 	req.PhoneNumberSet = slice.Map[string, *string](numbers, func(idx int, num string) *string {
 		return &num
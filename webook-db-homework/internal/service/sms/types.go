@@ -5,3 +5,15 @@ import "context"
 type Service interface {
 	Send(ctx context.Context, biz string, args []string, numbers ...string) error
 }
+
+type signatureKey struct{}
+
+// WithSignature 模板指定了签名的时候通过 context 传给服务商，覆盖服务商默认的签名
+func WithSignature(ctx context.Context, signature string) context.Context {
+	return context.WithValue(ctx, signatureKey{}, signature)
+}
+
+func SignatureFromContext(ctx context.Context) (string, bool) {
+	signature, ok := ctx.Value(signatureKey{}).(string)
+	return signature, ok && signature != ""
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/pkg/totp"
+)
+
+var (
+	ErrTwoFactorNotEnrolled     = errors.New("尚未开启二次验证")
+	ErrTwoFactorAlreadyEnabled  = errors.New("已经开启二次验证")
+	ErrTwoFactorInvalidCode     = errors.New("二次验证码错误")
+	ErrTwoFactorTooManyAttempts = errors.New("二次验证尝试次数过多，请稍后再试")
+	recoveryCodeEncoding        = base32.StdEncoding.WithPadding(base32.NoPadding)
+	twoFactorMaxAttempts        = int64(5)
+	twoFactorRecoveryCodeCount  = 10
+	twoFactorClockSkew          = 1
+)
+
+type TwoFactorService interface {
+	Enabled(ctx context.Context, uid int64) (bool, error)
+	// Enroll 生成新的密钥，用户用验证器 App 扫码之后还需要调用 Activate 才算开启
+	Enroll(ctx context.Context, uid int64, account string) (domain.TwoFactorEnrollment, error)
+	// Activate 校验第一个验证码，通过后开启二次验证并返回恢复码，恢复码只会返回这一次
+	Activate(ctx context.Context, uid int64, code string) ([]string, error)
+	// Verify 校验 TOTP 验证码或者恢复码
+	Verify(ctx context.Context, uid int64, code string) error
+	Disable(ctx context.Context, uid int64, code string) error
+	// Reset 管理员协助用户重置，用户丢失验证器和恢复码的时候使用
+	Reset(ctx context.Context, uid int64) error
+}
+
+type TwoFactorService_ struct {
+	repo   repository.TwoFactorRepository
+	issuer string
+	now    func() time.Time
+}
+
+func NewTwoFactorService(repo repository.TwoFactorRepository, issuer string) TwoFactorService {
+	return &TwoFactorService_{
+		repo:   repo,
+		issuer: issuer,
+		now:    time.Now,
+	}
+}
+
+func (svc *TwoFactorService_) Enabled(ctx context.Context, uid int64) (bool, error) {
+	tf, err := svc.repo.Find(ctx, uid)
+	if err == repository.ErrTwoFactorNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return tf.Enabled, nil
+}
+
+func (svc *TwoFactorService_) Enroll(ctx context.Context, uid int64, account string) (domain.TwoFactorEnrollment, error) {
+	enabled, err := svc.Enabled(ctx, uid)
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+	if enabled {
+		return domain.TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+	err = svc.repo.SavePending(ctx, uid, secret)
+	if err != nil {
+		return domain.TwoFactorEnrollment{}, err
+	}
+	return domain.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(svc.issuer, account, secret),
+	}, nil
+}
+
+func (svc *TwoFactorService_) Activate(ctx context.Context, uid int64, code string) ([]string, error) {
+	tf, err := svc.repo.Find(ctx, uid)
+	if err == repository.ErrTwoFactorNotFound {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if err = svc.checkAttempts(ctx, uid); err != nil {
+		return nil, err
+	}
+	step, ok := totp.Validate(tf.Secret, code, svc.now(), twoFactorClockSkew)
+	if !ok {
+		return nil, ErrTwoFactorInvalidCode
+	}
+	codes, hashes, err := svc.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = svc.repo.Enable(ctx, uid, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+	_ = svc.repo.ResetAttempts(ctx, uid)
+	return codes, nil
+}
+
+func (svc *TwoFactorService_) Verify(ctx context.Context, uid int64, code string) error {
+	tf, err := svc.repo.Find(ctx, uid)
+	if err == repository.ErrTwoFactorNotFound {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if !tf.Enabled {
+		return ErrTwoFactorNotEnrolled
+	}
+	if err = svc.checkAttempts(ctx, uid); err != nil {
+		return err
+	}
+	ok, err := svc.consume(ctx, tf, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTwoFactorInvalidCode
+	}
+	// 验证通过之后清掉计数，重置失败也不影响这一次的结果
+	_ = svc.repo.ResetAttempts(ctx, uid)
+	return nil
+}
+
+func (svc *TwoFactorService_) Disable(ctx context.Context, uid int64, code string) error {
+	err := svc.Verify(ctx, uid, code)
+	if err != nil {
+		return err
+	}
+	return svc.repo.Delete(ctx, uid)
+}
+
+func (svc *TwoFactorService_) Reset(ctx context.Context, uid int64) error {
+	err := svc.repo.Delete(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return svc.repo.ResetAttempts(ctx, uid)
+}
+
+// consume 先当成 TOTP 验证码校验，不是的话再当成恢复码
+func (svc *TwoFactorService_) consume(ctx context.Context, tf domain.TwoFactor, code string) (bool, error) {
+	if step, ok := totp.Validate(tf.Secret, code, svc.now(), twoFactorClockSkew); ok {
+		// 同一个时间步的验证码只能用一次
+		return svc.repo.ConsumeStep(ctx, tf.Uid, step)
+	}
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return false, nil
+	}
+	return svc.repo.ConsumeRecoveryCode(ctx, tf.Uid, hashRecoveryCode(normalized))
+}
+
+// checkAttempts 不管成功失败都计数，成功之后再清零，这样并发猜测也会被限制住
+func (svc *TwoFactorService_) checkAttempts(ctx context.Context, uid int64) error {
+	cnt, err := svc.repo.IncrAttempts(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if cnt > twoFactorMaxAttempts {
+		return ErrTwoFactorTooManyAttempts
+	}
+	return nil
+}
+
+func (svc *TwoFactorService_) generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, twoFactorRecoveryCodeCount)
+	hashes := make([]string, 0, twoFactorRecoveryCodeCount)
+	for i := 0; i < twoFactorRecoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))
+		codes = append(codes, raw[:4]+"-"+raw[4:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode 用户输入的时候可能带上了横线或者用了大写
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if len(code) != 8 {
+		return ""
+	}
+	return code
+}
+
+// hashRecoveryCode 恢复码是 40 位的随机数，不需要 bcrypt 这种慢哈希
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
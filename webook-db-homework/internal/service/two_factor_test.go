@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+	"webook/pkg/totp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTwoFactorService_Verify(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1700000000, 0)
+	step := totp.Step(now)
+	code, err := totp.GenerateCode(secret, step)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) repository.TwoFactorRepository
+		code    string
+		wantErr error
+	}{
+		{
+			name: "验证码正确",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{Uid: 1, Secret: secret, Enabled: true}, nil)
+				repo.EXPECT().IncrAttempts(gomock.Any(), int64(1)).Return(int64(1), nil)
+				repo.EXPECT().ConsumeStep(gomock.Any(), int64(1), step).Return(true, nil)
+				repo.EXPECT().ResetAttempts(gomock.Any(), int64(1)).Return(nil)
+				return repo
+			},
+			code: code,
+		},
+		{
+			name: "验证码重复使用",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{Uid: 1, Secret: secret, Enabled: true, LastStep: step}, nil)
+				repo.EXPECT().IncrAttempts(gomock.Any(), int64(1)).Return(int64(2), nil)
+				repo.EXPECT().ConsumeStep(gomock.Any(), int64(1), step).Return(false, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorInvalidCode,
+		},
+		{
+			name: "恢复码正确",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{Uid: 1, Secret: secret, Enabled: true}, nil)
+				repo.EXPECT().IncrAttempts(gomock.Any(), int64(1)).Return(int64(1), nil)
+				repo.EXPECT().ConsumeRecoveryCode(gomock.Any(), int64(1), hashRecoveryCode("abcd2345")).
+					Return(true, nil)
+				repo.EXPECT().ResetAttempts(gomock.Any(), int64(1)).Return(nil)
+				return repo
+			},
+			code: "ABCD-2345",
+		},
+		{
+			name: "验证码错误",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{Uid: 1, Secret: secret, Enabled: true}, nil)
+				repo.EXPECT().IncrAttempts(gomock.Any(), int64(1)).Return(int64(1), nil)
+				return repo
+			},
+			code:    "000",
+			wantErr: ErrTwoFactorInvalidCode,
+		},
+		{
+			name: "尝试次数过多",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{Uid: 1, Secret: secret, Enabled: true}, nil)
+				repo.EXPECT().IncrAttempts(gomock.Any(), int64(1)).Return(int64(6), nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorTooManyAttempts,
+		},
+		{
+			name: "还没有开启",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{Uid: 1, Secret: secret}, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorNotEnrolled,
+		},
+		{
+			name: "数据库错误",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{}, errors.New("db error"))
+				return repo
+			},
+			code:    code,
+			wantErr: errors.New("db error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewTwoFactorService(tc.mock(ctrl), "webook").(*TwoFactorService_)
+			svc.now = func() time.Time { return now }
+			err := svc.Verify(context.Background(), 1, tc.code)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestTwoFactorService_Activate(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1700000000, 0)
+	step := totp.Step(now)
+	code, err := totp.GenerateCode(secret, step)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) repository.TwoFactorRepository
+		code      string
+		wantCodes int
+		wantErr   error
+	}{
+		{
+			name: "开启成功",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{Uid: 1, Secret: secret}, nil)
+				repo.EXPECT().IncrAttempts(gomock.Any(), int64(1)).Return(int64(1), nil)
+				repo.EXPECT().Enable(gomock.Any(), int64(1), step, gomock.Len(10)).Return(nil)
+				repo.EXPECT().ResetAttempts(gomock.Any(), int64(1)).Return(nil)
+				return repo
+			},
+			code:      code,
+			wantCodes: 10,
+		},
+		{
+			name: "没有调用 Enroll",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{}, repository.ErrTwoFactorNotFound)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorNotEnrolled,
+		},
+		{
+			name: "已经开启",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{Uid: 1, Secret: secret, Enabled: true}, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorAlreadyEnabled,
+		},
+		{
+			name: "验证码错误",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().Find(gomock.Any(), int64(1)).
+					Return(domain.TwoFactor{Uid: 1, Secret: secret}, nil)
+				repo.EXPECT().IncrAttempts(gomock.Any(), int64(1)).Return(int64(1), nil)
+				return repo
+			},
+			code:    "123456",
+			wantErr: ErrTwoFactorInvalidCode,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewTwoFactorService(tc.mock(ctrl), "webook").(*TwoFactorService_)
+			svc.now = func() time.Time { return now }
+			codes, err := svc.Activate(context.Background(), 1, tc.code)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Len(t, codes, tc.wantCodes)
+			for _, c := range codes {
+				assert.Equal(t, c, normalizeRecoveryCode(c)[:4]+"-"+normalizeRecoveryCode(c)[4:])
+			}
+		})
+	}
+}
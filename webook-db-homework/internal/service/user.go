@@ -11,6 +11,7 @@ import (
 
 var ErrUserDuplicateEmail = repository.ErrUserDuplicateEmail
 var ErrInvalidUserOrPassword = errors.New("邮箱/密码错误")
+var ErrUserNotFound = repository.ErrUserNotFound
 
 type UserService interface {
 	Signup(ctx context.Context, u domain.User) error
@@ -18,16 +19,19 @@ type UserService interface {
 	UpdateUserProfile(ctx context.Context, u domain.User) error
 	GetUserById(ctx context.Context, id int64) (domain.User, error)
 	FindOrCreate(ctx context.Context, phone string) (domain.User, error)
-	FindOrCreateByWechat(ctx context.Context, wechatUser domain.WechatUser) (domain.User, error)
+	FindOrCreateByOAuth2(ctx context.Context, info domain.OAuth2User) (domain.User, error)
 }
 
 type UserService_ struct {
 	repo repository.UserRepository
+	// risk 可以为 nil，为 nil 的时候不检查账号锁定
+	risk LoginRiskService
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
+func NewUserService(repo repository.UserRepository, risk LoginRiskService) UserService {
 	return &UserService_{
 		repo: repo,
+		risk: risk,
 	}
 }
 
@@ -41,6 +45,13 @@ func (svc *UserService_) Signup(ctx context.Context, u domain.User) error {
 	return svc.repo.Create(ctx, u)
 }
 func (svc *UserService_) Login(ctx context.Context, email, password string) (domain.User, error) {
+	if svc.risk != nil {
+		// Redis 出错的时候降级，不因为风控不可用拒绝所有人登录
+		locked, err := svc.risk.Locked(ctx, email)
+		if err == nil && locked {
+			return domain.User{}, ErrUserLocked
+		}
+	}
 	u, err := svc.repo.FindByEmail(ctx, email)
 	if err == repository.ErrUserNotFound {
 		return domain.User{}, ErrInvalidUserOrPassword
@@ -52,6 +63,9 @@ func (svc *UserService_) Login(ctx context.Context, email, password string) (dom
 	if err != nil {
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
+	if u.Banned() {
+		return domain.User{}, ErrUserBanned
+	}
 	return u, nil
 }
 func (svc *UserService_) UpdateUserProfile(ctx context.Context, u domain.User) error {
@@ -82,26 +96,21 @@ func (svc *UserService_) FindOrCreate(ctx context.Context, phone string) (domain
 	return u, nil
 }
 
-func (svc *UserService_) FindOrCreateByWechat(ctx context.Context, wechatUser domain.WechatUser) (domain.User, error) {
-	u, err := svc.repo.FindByWechat(ctx, wechatUser.OpenID)
+// FindOrCreateByOAuth2 第三方账号第一次登录的时候自动注册
+// 这里故意不使用第三方返回的邮箱，因为第三方的邮箱不一定验证过，直接用来关联已有账号会有被冒用的风险
+func (svc *UserService_) FindOrCreateByOAuth2(ctx context.Context, info domain.OAuth2User) (domain.User, error) {
+	u, err := svc.repo.FindByOAuth2(ctx, info.Provider, info.Subject)
 	if err != repository.ErrUserNotFound {
-		return u, nil
+		return u, err
 	}
-	u = domain.User{
-		WechatUser: domain.WechatUser{
-			UnionID: wechatUser.UnionID,
-			OpenID:  wechatUser.OpenID,
-		},
-	}
-	err = svc.repo.Create(ctx, u)
-	if err != nil {
+	id, err := svc.repo.CreateWithOAuth2(ctx, domain.User{Nickname: info.Nickname}, info)
+	switch err {
+	case nil:
+		return svc.repo.FindById(ctx, id)
+	case repository.ErrUserDuplicateIdentity:
+		// 并发登录的时候另外一个请求已经创建好了
+		return svc.repo.FindByOAuth2(ctx, info.Provider, info.Subject)
+	default:
 		return domain.User{}, err
 	}
-	//这里的u只有一个phone，因此我们要再查一次
-	u, err = svc.repo.FindByWechat(ctx, wechatUser.OpenID)
-	//但是这里会遇到主从延迟的问题
-	if err != nil {
-		return domain.User{}, err
-	}
-	return u, nil
 }
@@ -8,6 +8,7 @@ import (
 	"webook/internal/domain"
 	"webook/internal/repository"
 	repomocks "webook/internal/repository/mocks"
+	svcmocks "webook/internal/service/mocks"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
@@ -97,7 +98,7 @@ func Test_userLogin(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
-			svc := NewUserService(tc.mock(ctrl))
+			svc := NewUserService(tc.mock(ctrl), nil)
 			user, err := svc.Login(context.Background(), tc.email, tc.password)
 			assert.Equal(t, tc.wantUser, user)
 			assert.Equal(t, tc.wantErr, err)
@@ -112,3 +113,90 @@ func Test_Encrypt(t *testing.T) {
 	}
 	t.Log(string(res))
 }
+
+func TestUserService_FindOrCreateByOAuth2(t *testing.T) {
+	info := domain.OAuth2User{
+		Provider: "github",
+		Subject:  "123",
+		Nickname: "octocat",
+		Email:    "octocat@example.com",
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.UserRepository
+
+		wantUser domain.User
+		wantErr  error
+	}{
+		{
+			name: "已经绑定过",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").
+					Return(domain.User{Id: 1, Nickname: "octocat"}, nil)
+				return repo
+			},
+			wantUser: domain.User{Id: 1, Nickname: "octocat"},
+		},
+		{
+			name: "第一次登录，自动注册并且不使用第三方邮箱",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").
+					Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().CreateWithOAuth2(gomock.Any(), domain.User{Nickname: "octocat"}, info).
+					Return(int64(2), nil)
+				repo.EXPECT().FindById(gomock.Any(), int64(2)).
+					Return(domain.User{Id: 2, Nickname: "octocat"}, nil)
+				return repo
+			},
+			wantUser: domain.User{Id: 2, Nickname: "octocat"},
+		},
+		{
+			name: "并发注册冲突，重新查询",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").
+					Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().CreateWithOAuth2(gomock.Any(), gomock.Any(), info).
+					Return(int64(0), repository.ErrUserDuplicateIdentity)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").
+					Return(domain.User{Id: 3}, nil)
+				return repo
+			},
+			wantUser: domain.User{Id: 3},
+		},
+		{
+			name: "查询出错",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").
+					Return(domain.User{}, errors.New("数据库错误"))
+				return repo
+			},
+			wantErr: errors.New("数据库错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewUserService(tc.mock(ctrl), nil)
+			u, err := svc.FindOrCreateByOAuth2(context.Background(), info)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
+
+func TestUserService_LoginLocked(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	risk := svcmocks.NewMockLoginRiskService(ctrl)
+	risk.EXPECT().Locked(gomock.Any(), "123456@qq.com").Return(true, nil)
+	// 被锁定之后不会再去查用户和校验密码
+	svc := NewUserService(repomocks.NewMockUserRepository(ctrl), risk)
+	_, err := svc.Login(context.Background(), "123456@qq.com", "Test123!")
+	assert.Equal(t, ErrUserLocked, err)
+}
@@ -0,0 +1,295 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/internal/web/middleware"
+	"webook/pkg/logger"
+)
+
+// AdminHandler 管理后台，每个路由分组都通过 PermissionMiddlewareBuilder 校验权限
+type AdminHandler struct {
+	svc service.AdminService
+	l   logger.LoggerV1
+}
+
+func NewAdminHandler(svc service.AdminService, l logger.LoggerV1) *AdminHandler {
+	return &AdminHandler{
+		svc: svc,
+		l:   l,
+	}
+}
+
+func (h *AdminHandler) RegisterRoutes(r *gin.Engine) {
+	g := r.Group("/admin")
+
+	ag := g.Group("/articles", middleware.NewPermissionMiddlewareBuilder(domain.PermArticleWithdraw).Build())
+	ag.POST("/:id/withdraw", h.WithdrawArticle)
+
+	ug := g.Group("/users", middleware.NewPermissionMiddlewareBuilder(domain.PermUserView).Build())
+	ug.GET("/:id", h.UserDetail)
+	ug.POST("/:id/ban", middleware.NewPermissionMiddlewareBuilder(domain.PermUserBan).Build(), h.BanUser)
+	ug.POST("/:id/unban", middleware.NewPermissionMiddlewareBuilder(domain.PermUserBan).Build(), h.UnbanUser)
+	ug.POST("/:id/2fa/reset", middleware.NewPermissionMiddlewareBuilder(domain.PermUser2FAReset).Build(), h.ResetTwoFactor)
+	ug.POST("/:id/roles", middleware.NewPermissionMiddlewareBuilder(domain.PermRoleAssign).Build(), h.AssignRole)
+	ug.POST("/:id/roles/revoke", middleware.NewPermissionMiddlewareBuilder(domain.PermRoleAssign).Build(), h.RevokeRole)
+
+	g.GET("/audits", middleware.NewPermissionMiddlewareBuilder(domain.PermAuditView).Build(), h.ListAudits)
+	g.GET("/sms/stats", middleware.NewPermissionMiddlewareBuilder(domain.PermSMSStatsView).Build(), h.SMSStats)
+	g.GET("/sms/backlog", middleware.NewPermissionMiddlewareBuilder(domain.PermSMSStatsView).Build(), h.SMSBacklog)
+}
+
+type AdminUserVO struct {
+	Id          int64    `json:"id"`
+	Email       string   `json:"email"`
+	Phone       string   `json:"phone"`
+	Nickname    string   `json:"nickname"`
+	Banned      bool     `json:"banned"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+type AdminAuditVO struct {
+	Id         int64  `json:"id"`
+	OperatorId int64  `json:"operatorId"`
+	Action     string `json:"action"`
+	TargetType string `json:"targetType"`
+	TargetId   int64  `json:"targetId"`
+	Detail     string `json:"detail"`
+	Ctime      string `json:"ctime"`
+}
+
+type SMSStatVO struct {
+	Provider string `json:"provider"`
+	Day      string `json:"day"`
+	Total    int64  `json:"total"`
+	Failed   int64  `json:"failed"`
+}
+
+func (h *AdminHandler) WithdrawArticle(c *gin.Context) {
+	type Req struct {
+		Reason string `json:"reason"`
+	}
+	var req Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Result[string]{Code: 400, Msg: "参数错误"})
+		return
+	}
+	aid, ok := h.pathId(c)
+	if !ok {
+		return
+	}
+	err := h.svc.WithdrawArticle(c.Request.Context(), c.GetInt64("userId"), aid, req.Reason)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusOK, Result[string]{Code: 404, Msg: "文章不存在"})
+		return
+	}
+	if err != nil {
+		h.l.Error("管理员撤回文章失败", logger.Int64("aid", aid), logger.Error(err))
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	c.JSON(http.StatusOK, Result[string]{Code: 0, Msg: "撤回成功"})
+}
+
+func (h *AdminHandler) UserDetail(c *gin.Context) {
+	uid, ok := h.pathId(c)
+	if !ok {
+		return
+	}
+	u, authority, err := h.svc.UserDetail(c.Request.Context(), c.GetInt64("userId"), uid)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusOK, Result[AdminUserVO]{Code: 404, Msg: "用户不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[AdminUserVO]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	c.JSON(http.StatusOK, Result[AdminUserVO]{Code: 0, Data: AdminUserVO{
+		Id:          u.Id,
+		Email:       u.Email,
+		Phone:       u.Phone,
+		Nickname:    u.Nickname,
+		Banned:      u.Banned(),
+		Roles:       authority.Roles,
+		Permissions: authority.Permissions,
+	}})
+}
+
+func (h *AdminHandler) BanUser(c *gin.Context) {
+	type Req struct {
+		Reason string `json:"reason"`
+	}
+	var req Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Result[string]{Code: 400, Msg: "参数错误"})
+		return
+	}
+	uid, ok := h.pathId(c)
+	if !ok {
+		return
+	}
+	operator := c.GetInt64("userId")
+	if uid == operator {
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "不能封禁自己"})
+		return
+	}
+	err := h.svc.BanUser(c.Request.Context(), operator, uid, req.Reason)
+	h.writeResult(c, err, "封禁成功")
+}
+
+func (h *AdminHandler) UnbanUser(c *gin.Context) {
+	uid, ok := h.pathId(c)
+	if !ok {
+		return
+	}
+	err := h.svc.UnbanUser(c.Request.Context(), c.GetInt64("userId"), uid)
+	h.writeResult(c, err, "解封成功")
+}
+
+func (h *AdminHandler) ResetTwoFactor(c *gin.Context) {
+	uid, ok := h.pathId(c)
+	if !ok {
+		return
+	}
+	err := h.svc.ResetTwoFactor(c.Request.Context(), c.GetInt64("userId"), uid)
+	h.writeResult(c, err, "二次验证已重置")
+}
+
+func (h *AdminHandler) AssignRole(c *gin.Context) {
+	type Req struct {
+		Role string `json:"role"`
+	}
+	var req Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Result[string]{Code: 400, Msg: "参数错误"})
+		return
+	}
+	uid, ok := h.pathId(c)
+	if !ok {
+		return
+	}
+	err := h.svc.AssignRole(c.Request.Context(), c.GetInt64("userId"), uid, req.Role)
+	h.writeResult(c, err, "分配角色成功")
+}
+
+func (h *AdminHandler) RevokeRole(c *gin.Context) {
+	type Req struct {
+		Role string `json:"role"`
+	}
+	var req Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Result[string]{Code: 400, Msg: "参数错误"})
+		return
+	}
+	uid, ok := h.pathId(c)
+	if !ok {
+		return
+	}
+	err := h.svc.RevokeRole(c.Request.Context(), c.GetInt64("userId"), uid, req.Role)
+	h.writeResult(c, err, "收回角色成功")
+}
+
+func (h *AdminHandler) ListAudits(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	audits, err := h.svc.ListAudits(c.Request.Context(), offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[[]AdminAuditVO]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	res := make([]AdminAuditVO, 0, len(audits))
+	for _, a := range audits {
+		res = append(res, AdminAuditVO{
+			Id:         a.Id,
+			OperatorId: a.OperatorId,
+			Action:     a.Action,
+			TargetType: a.TargetType,
+			TargetId:   a.TargetId,
+			Detail:     a.Detail,
+			Ctime:      a.Ctime.Format(time.DateTime),
+		})
+	}
+	c.JSON(http.StatusOK, Result[[]AdminAuditVO]{Code: 0, Data: res})
+}
+
+// SMSStats 按天统计短信发送量，start 和 end 是日期，包含 end 这一天，默认最近 7 天
+func (h *AdminHandler) SMSStats(c *gin.Context) {
+	now := time.Now()
+	today := now.Format(time.DateOnly)
+	start, err := time.ParseInLocation(time.DateOnly,
+		c.DefaultQuery("start", now.AddDate(0, 0, -6).Format(time.DateOnly)), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Result[string]{Code: 400, Msg: "start 格式不正确，使用 YYYY-MM-DD"})
+		return
+	}
+	end, err := time.ParseInLocation(time.DateOnly, c.DefaultQuery("end", today), time.Local)
+	if err != nil || end.Before(start) {
+		c.JSON(http.StatusBadRequest, Result[string]{Code: 400, Msg: "end 格式不正确，使用 YYYY-MM-DD"})
+		return
+	}
+	stats, err := h.svc.SMSStats(c.Request.Context(), c.Query("provider"), start, end.AddDate(0, 0, 1))
+	if err != nil {
+		h.l.Error("查询短信统计失败", logger.Error(err))
+		c.JSON(http.StatusInternalServerError, Result[[]SMSStatVO]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	res := make([]SMSStatVO, 0, len(stats))
+	for _, s := range stats {
+		res = append(res, SMSStatVO{
+			Provider: s.Provider,
+			Day:      s.Day,
+			Total:    s.Total,
+			Failed:   s.Failed,
+		})
+	}
+	c.JSON(http.StatusOK, Result[[]SMSStatVO]{Code: 0, Data: res})
+}
+
+// SMSBacklog 异步发送积压的短信数量，持续增长说明服务商一直没有恢复
+func (h *AdminHandler) SMSBacklog(c *gin.Context) {
+	backlog, err := h.svc.SMSBacklog(c.Request.Context())
+	if err != nil {
+		h.l.Error("查询短信积压失败", logger.Error(err))
+		c.JSON(http.StatusInternalServerError, Result[int64]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	c.JSON(http.StatusOK, Result[int64]{Code: 0, Data: backlog})
+}
+
+func (h *AdminHandler) pathId(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, Result[string]{Code: 400, Msg: "id 不合法"})
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *AdminHandler) writeResult(c *gin.Context, err error, msg string) {
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, Result[string]{Code: 0, Msg: msg})
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusOK, Result[string]{Code: 404, Msg: "用户不存在"})
+	case errors.Is(err, service.ErrRoleNotFound):
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "角色不存在"})
+	case errors.Is(err, service.ErrRoleTooLow):
+		c.JSON(http.StatusForbidden, Result[string]{Code: 403, Msg: "不能操作角色级别不低于自己的用户"})
+	default:
+		h.l.Error("管理员操作失败", logger.Error(err))
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"webook/internal/domain"
 	"webook/internal/service"
 	svcmocks "webook/internal/service/mocks"
-	ijwt "webook/internal/web/jwt"
 
 	"webook/pkg/logger"
 
@@ -50,7 +49,7 @@ func TestArticleHandler_Publish(t *testing.T) {
 			wantCode: http.StatusOK,
 			wantBody: Result[int64]{
 				Code: 0,
-				Msg:  "新建并发表成功",
+				Msg:  "发表成功",
 				Data: 1,
 			},
 		},
@@ -71,7 +70,7 @@ func TestArticleHandler_Publish(t *testing.T) {
 				"title": "test",
 				"content": "test123"
 			}`,
-			wantCode: http.StatusOK,
+			wantCode: http.StatusInternalServerError,
 			wantBody: Result[int64]{
 				Code: 500,
 				Msg:  "系统错误",
@@ -86,12 +85,10 @@ func TestArticleHandler_Publish(t *testing.T) {
 			server := gin.Default()
 
 			server.Use(func(c *gin.Context) {
-				c.Set("claims", &ijwt.UserClaims{
-					UserId: 666, //直接在这里模拟用户的登录
-				})
+				c.Set("userId", int64(666)) //直接在这里模拟用户的登录
 			})
 
-			h := NewArticleHandler(tc.mock(ctrl), logger.NewZapLogger(zap.NewExample()))
+			h := NewArticleHandler(tc.mock(ctrl), nil, logger.NewZapLogger(zap.NewExample()))
 
 			h.RegisterRoutes(server)
 
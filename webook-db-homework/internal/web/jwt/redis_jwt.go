@@ -21,27 +21,36 @@ var (
 var _ Handler = (*RedisJWTHandler)(nil)
 
 type RedisJWTHandler struct {
-	atKey []byte
-	rtKey []byte
-	Cmd   redis.Cmdable
+	atKey     []byte
+	rtKey     []byte
+	Cmd       redis.Cmdable
+	authority AuthorityLoader
 }
 
-func NewRedisJWTHandler(cmd redis.Cmdable) Handler {
+func NewRedisJWTHandler(cmd redis.Cmdable, authority AuthorityLoader) Handler {
 	return &RedisJWTHandler{
-		Cmd:   cmd,
-		atKey: AtKey,
-		rtKey: RtKey,
+		Cmd:       cmd,
+		atKey:     AtKey,
+		rtKey:     RtKey,
+		authority: authority,
 	}
 }
 
 func (h *RedisJWTHandler) SetJWTToken(c *gin.Context, userId int64, ssid string) error {
+	// 被封禁的用户在这里会返回错误，不管是哪种登录方式都签发不了 token
+	authority, err := h.authority.Authority(c.Request.Context(), userId)
+	if err != nil {
+		return err
+	}
 	claims := UserClaims{
 		SSid:   ssid,
 		UserId: userId,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 30)),
 		},
-		UserAgent: c.Request.UserAgent(),
+		UserAgent:   c.Request.UserAgent(),
+		Roles:       authority.Roles,
+		Permissions: authority.Permissions,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokentstr, err := token.SignedString(h.atKey)
@@ -86,6 +95,10 @@ func (h *RedisJWTHandler) CheckSSid(ctx *gin.Context, ssid string) error {
 	}
 }
 
+func (h *RedisJWTHandler) CheckUser(ctx *gin.Context, userId int64) error {
+	return h.authority.CheckBanned(ctx.Request.Context(), userId)
+}
+
 func (h *RedisJWTHandler) ExtractToken(c *gin.Context) string {
 	tokenHeader := c.GetHeader("Authorization")
 	if tokenHeader == "" {
@@ -1,6 +1,10 @@
 package jwt
 
 import (
+	"context"
+	"slices"
+	"webook/internal/domain"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -12,6 +16,14 @@ type Handler interface {
 	SetJWTToken(ctx *gin.Context, userId int64, ssid string) error
 	ClearToken(ctx *gin.Context) error
 	CheckSSid(ctx *gin.Context, ssid string) error
+	// CheckUser 检查 token 对应的用户现在还能不能访问，比如已经被封禁
+	CheckUser(ctx *gin.Context, userId int64) error
+}
+
+// AuthorityLoader 签发 token 的时候加载用户的角色和权限，service.RBACService 实现了这个接口
+type AuthorityLoader interface {
+	Authority(ctx context.Context, uid int64) (domain.Authority, error)
+	CheckBanned(ctx context.Context, uid int64) error
 }
 
 type UserClaims struct {
@@ -19,6 +31,13 @@ type UserClaims struct {
 	jwt.RegisteredClaims
 	UserAgent string `json:"user_agent"`
 	SSid      string `json:"ssid"`
+	// 角色和权限在签发的时候就确定了，修改之后要等用户重新登录才生效
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+}
+
+func (c *UserClaims) HasPermission(perm string) bool {
+	return slices.Contains(c.Permissions, perm)
 }
 
 type RefreshClaims struct {
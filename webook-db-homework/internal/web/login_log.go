@@ -0,0 +1,65 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webook/internal/domain"
+	"webook/internal/service"
+)
+
+type LoginLogVO struct {
+	Method    string `json:"method"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	Success   bool   `json:"success"`
+	Reason    string `json:"reason"`
+	Ctime     string `json:"ctime"`
+}
+
+// LoginLogs 当前用户最近的登录记录
+func (u *UserHandler) LoginLogs(c *gin.Context) {
+	uid := c.GetInt64("userId")
+	if uid == 0 {
+		c.JSON(http.StatusUnauthorized, Result[[]LoginLogVO]{Code: 401, Msg: "未登录"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	logs, err := u.loginAudit.Recent(c.Request.Context(), uid, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[[]LoginLogVO]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	res := make([]LoginLogVO, 0, len(logs))
+	for _, l := range logs {
+		res = append(res, LoginLogVO{
+			Method:    l.Method,
+			IP:        l.IP,
+			UserAgent: l.UserAgent,
+			Success:   l.Success,
+			Reason:    l.Reason,
+			Ctime:     l.Ctime.Format(time.DateTime),
+		})
+	}
+	c.JSON(http.StatusOK, Result[[]LoginLogVO]{Code: 0, Data: res})
+}
+
+// recordLogin 补上 IP 和 UA 之后异步记录，err 为 nil 代表登录成功
+func recordLogin(c *gin.Context, svc service.LoginAuditService, log domain.LoginLog, err error) {
+	if svc == nil {
+		return
+	}
+	log.IP = c.ClientIP()
+	log.UserAgent = c.Request.UserAgent()
+	log.Success = err == nil
+	if err != nil {
+		log.Reason = err.Error()
+	}
+	svc.Record(c.Request.Context(), log)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 	ijwt "webook/internal/web/jwt"
+	"webook/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -46,13 +47,14 @@ func (b *LoginJwtMiddlewareBuilder) Build() gin.HandlerFunc {
 						}
 						return []byte("1234567890"), nil
 					})
-					if err != nil || !token.Valid || claims.UserAgent != c.Request.UserAgent() || b.CheckSSid(c, claims.SSid) != nil {
+					if err != nil || !token.Valid || claims.UserAgent != c.Request.UserAgent() || b.CheckSSid(c, claims.SSid) != nil || b.CheckUser(c, claims.UserId) != nil {
 						// token 无效则按未登录处理
 						c.Next()
 						return
 					}
 					c.Set("userClaims", claims)
 					c.Set("userId", claims.UserId)
+					c.Request = c.Request.WithContext(logger.ContextWithUserID(c.Request.Context(), claims.UserId))
 					c.Next()
 					return
 				}
@@ -99,9 +101,20 @@ func (b *LoginJwtMiddlewareBuilder) Build() gin.HandlerFunc {
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		} //这里就是在检查是否登出
+		// 封禁之后还没过期的 token 也不能再用
+		err = b.CheckUser(c, claims.UserId)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code": 401,
+				"msg":  "用户状态异常",
+			})
+			return
+		}
 
 		c.Set("userClaims", claims)
 		c.Set("userId", claims.UserId)
+		// 之后的日志都带上 uid
+		c.Request = c.Request.WithContext(logger.ContextWithUserID(c.Request.Context(), claims.UserId))
 		c.Next()
 	}
 }
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	ijwt "webook/internal/web/jwt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionMiddlewareBuilder 校验 JWT 里面的权限，必须放在 LoginJwtMiddlewareBuilder 之后
+type PermissionMiddlewareBuilder struct {
+	perms []string
+}
+
+func NewPermissionMiddlewareBuilder(perms ...string) *PermissionMiddlewareBuilder {
+	return &PermissionMiddlewareBuilder{
+		perms: perms,
+	}
+}
+
+func (b *PermissionMiddlewareBuilder) Build() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		val, exists := c.Get("userClaims")
+		claims, ok := val.(*ijwt.UserClaims)
+		if !exists || !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code": 401,
+				"msg":  "未登录",
+			})
+			return
+		}
+		// 需要同时拥有所有的权限
+		for _, perm := range b.perms {
+			if !claims.HasPermission(perm) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"code": 403,
+					"msg":  "没有权限: " + perm,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
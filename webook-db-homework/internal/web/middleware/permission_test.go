@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/internal/domain"
+	ijwt "webook/internal/web/jwt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionMiddlewareBuilder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		claims   *ijwt.UserClaims
+		wantCode int
+	}{
+		{
+			name: "有权限",
+			claims: &ijwt.UserClaims{
+				UserId:      1,
+				Permissions: []string{domain.PermUserView, domain.PermUserBan},
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "缺少其中一个权限",
+			claims: &ijwt.UserClaims{
+				UserId:      1,
+				Permissions: []string{domain.PermUserView},
+			},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "未登录",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(c *gin.Context) {
+				if tc.claims != nil {
+					c.Set("userClaims", tc.claims)
+				}
+			})
+			server.GET("/admin/users/1",
+				NewPermissionMiddlewareBuilder(domain.PermUserView, domain.PermUserBan).Build(),
+				func(c *gin.Context) {
+					c.Status(http.StatusOK)
+				})
+			req := httptest.NewRequest(http.MethodGet, "/admin/users/1", nil)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+		})
+	}
+}
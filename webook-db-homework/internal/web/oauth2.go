@@ -0,0 +1,192 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/service"
+	"webook/internal/service/oauth2"
+	ijwt "webook/internal/web/jwt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const stateCookieName = "jwt-state"
+
+// OAuth2Handler 所有第三方账号登录共用的 handler，具体是哪个服务商由路由里的 :provider 决定
+type OAuth2Handler struct {
+	providers *oauth2.Registry
+	ijwt.Handler
+	UserService  service.UserService
+	twoFactorSvc service.TwoFactorService
+	challenger   *TwoFactorChallenger
+	loginAudit   service.LoginAuditService
+	stateKey     []byte
+	cfg          OAuth2HandlerConfig
+}
+
+type OAuth2HandlerConfig struct {
+	Secure bool
+	// StateKey 签名 state cookie 的密钥，多实例要配置成一样的
+	StateKey []byte
+}
+
+func NewOAuth2Handler(providers *oauth2.Registry, userService service.UserService, twoFactorSvc service.TwoFactorService,
+	challenger *TwoFactorChallenger, loginAudit service.LoginAuditService,
+	cfg OAuth2HandlerConfig, jwtHandler ijwt.Handler) *OAuth2Handler {
+	return &OAuth2Handler{
+		providers:    providers,
+		UserService:  userService,
+		twoFactorSvc: twoFactorSvc,
+		challenger:   challenger,
+		loginAudit:   loginAudit,
+		stateKey:     cfg.StateKey,
+		cfg:          cfg,
+		Handler:      jwtHandler,
+	}
+}
+
+func (h *OAuth2Handler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/oauth2/:provider")
+	g.GET("/authurl", h.AuthURL)
+	g.Any("/callback", h.Callback)
+}
+
+func (h *OAuth2Handler) AuthURL(ctx *gin.Context) {
+	p, err := h.providers.Get(ctx.Param("provider"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result[string]{Code: 4, Msg: err.Error()})
+		return
+	}
+	state := uuid.New().String()
+	verifier, err := oauth2.NewCodeVerifier()
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result[string]{Code: 5, Msg: "系统错误"})
+		return
+	}
+	url, err := p.AuthURL(ctx, state, oauth2.CodeChallengeS256(verifier))
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result[string]{Code: 5, Msg: "构造扫码登录URL失败"})
+		return
+	}
+	err = h.setStateCookie(ctx, p.Name(), state, verifier)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result[string]{Code: 5, Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result[string]{Code: 0, Data: url})
+}
+
+func (h *OAuth2Handler) Callback(ctx *gin.Context) {
+	p, err := h.providers.Get(ctx.Param("provider"))
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result[string]{Code: 4, Msg: err.Error()})
+		return
+	}
+	claims, err := h.verifyState(ctx, p.Name())
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result[string]{Code: 4, Msg: "非法请求"})
+		return
+	}
+	// state 只能用一次
+	h.clearStateCookie(ctx, p.Name())
+
+	log := domain.LoginLog{Method: domain.LoginMethodOAuth2Prefix + p.Name()}
+	info, err := p.VerifyCode(ctx, ctx.Query("code"), claims.CodeVerifier)
+	if err != nil {
+		recordLogin(ctx, h.loginAudit, log, err)
+		ctx.JSON(http.StatusOK, Result[string]{Code: 5, Msg: "授权码校验失败"})
+		return
+	}
+	log.Account = info.Provider + ":" + info.Subject
+	u, err := h.UserService.FindOrCreateByOAuth2(ctx, info)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result[string]{Code: 5, Msg: "登录失败"})
+		return
+	}
+	log.Uid = u.Id
+	enabled, err := h.twoFactorSvc.Enabled(ctx.Request.Context(), u.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result[string]{Code: 5, Msg: "系统错误"})
+		return
+	}
+	if enabled {
+		// 和密码登录一样，第三方登录也要先完成二次验证
+		h.challenger.Challenge(ctx, u.Id)
+		return
+	}
+	err = h.SetLoginToken(ctx, u.Id)
+	if err == service.ErrUserBanned {
+		recordLogin(ctx, h.loginAudit, log, err)
+		ctx.JSON(http.StatusOK, Result[string]{Code: 403, Msg: "账号已被封禁"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result[string]{Code: 5, Msg: "系统错误"})
+		return
+	}
+	recordLogin(ctx, h.loginAudit, log, nil)
+	ctx.JSON(http.StatusOK, Result[string]{Code: 0, Msg: "登录成功"})
+}
+
+func (h *OAuth2Handler) verifyState(ctx *gin.Context, provider string) (StateClaims, error) {
+	state := ctx.Query("state")
+	ck, err := ctx.Cookie(stateCookieName)
+	if err != nil {
+		return StateClaims{}, fmt.Errorf("无法获取jwt-state %w", err)
+	}
+	var stateClaims StateClaims
+	token, err := jwt.ParseWithClaims(ck, &stateClaims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("无效的签名算法: %v", token.Header["alg"])
+		}
+		return h.stateKey, nil
+	})
+	if err != nil || !token.Valid {
+		return StateClaims{}, fmt.Errorf("无法解析jwt-state %w", err)
+	}
+	if state == "" || state != stateClaims.State {
+		return StateClaims{}, errors.New("状态码不匹配")
+	}
+	if stateClaims.Provider != provider {
+		return StateClaims{}, errors.New("服务商不匹配")
+	}
+	return stateClaims, nil
+}
+
+func (h *OAuth2Handler) setStateCookie(ctx *gin.Context, provider string, state string, verifier string) error {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, StateClaims{
+		State:        state,
+		Provider:     provider,
+		CodeVerifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 10)),
+		},
+	})
+	tokenStr, err := token.SignedString(h.stateKey)
+	if err != nil {
+		return fmt.Errorf("无法设置jwt-state %w", err)
+	}
+	ctx.SetCookie(stateCookieName, tokenStr, 600, h.callbackPath(provider), "", h.cfg.Secure, true)
+	return nil
+}
+
+func (h *OAuth2Handler) clearStateCookie(ctx *gin.Context, provider string) {
+	ctx.SetCookie(stateCookieName, "", -1, h.callbackPath(provider), "", h.cfg.Secure, true)
+}
+
+func (h *OAuth2Handler) callbackPath(provider string) string {
+	return "/oauth2/" + provider + "/callback"
+}
+
+// StateClaims code_verifier 只放在 HttpOnly 的 cookie 里面，不会出现在跳转的 URL 上
+type StateClaims struct {
+	State        string `json:"state"`
+	Provider     string `json:"provider"`
+	CodeVerifier string `json:"code_verifier"`
+	jwt.RegisteredClaims
+}
@@ -0,0 +1,219 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"webook/internal/domain"
+	"webook/internal/service"
+	svcmocks "webook/internal/service/mocks"
+	"webook/internal/service/oauth2"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// fakeProvider 记住 AuthURL 收到的 code_challenge，VerifyCode 的时候像真的服务商一样校验 code_verifier
+type fakeProvider struct {
+	name      string
+	challenge string
+	state     string
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) AuthURL(ctx context.Context, state string, codeChallenge string) (string, error) {
+	p.state, p.challenge = state, codeChallenge
+	return "https://example.com/authorize?state=" + url.QueryEscape(state), nil
+}
+
+func (p *fakeProvider) VerifyCode(ctx context.Context, code string, codeVerifier string) (domain.OAuth2User, error) {
+	if oauth2.CodeChallengeS256(codeVerifier) != p.challenge {
+		return domain.OAuth2User{}, errors.New("code_verifier 不匹配")
+	}
+	return domain.OAuth2User{Provider: p.name, Subject: "sub-" + code}, nil
+}
+
+// fakeJWTHandler 只记录给谁签发了 token
+type fakeJWTHandler struct {
+	uid int64
+}
+
+func (h *fakeJWTHandler) ExtractToken(ctx *gin.Context) string { return "" }
+
+func (h *fakeJWTHandler) SetLoginToken(ctx *gin.Context, userId int64) error {
+	h.uid = userId
+	return nil
+}
+
+func (h *fakeJWTHandler) SetJWTToken(ctx *gin.Context, userId int64, ssid string) error { return nil }
+
+func (h *fakeJWTHandler) ClearToken(ctx *gin.Context) error { return nil }
+
+func (h *fakeJWTHandler) CheckSSid(ctx *gin.Context, ssid string) error { return nil }
+
+func (h *fakeJWTHandler) CheckUser(ctx *gin.Context, userId int64) error { return nil }
+
+func TestOAuth2Handler_Callback(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.TwoFactorService)
+		// callback 先走 AuthURL 拿到 state cookie，再构造回调请求
+		callback func(t *testing.T, server *gin.Engine, providers map[string]*fakeProvider) *http.Request
+
+		wantCode int
+		wantUid  int64
+	}{
+		{
+			name: "登录成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.TwoFactorService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindOrCreateByOAuth2(gomock.Any(), domain.OAuth2User{
+					Provider: "github",
+					Subject:  "sub-abc",
+				}).Return(domain.User{Id: 123}, nil)
+				twoFactorSvc := svcmocks.NewMockTwoFactorService(ctrl)
+				twoFactorSvc.EXPECT().Enabled(gomock.Any(), int64(123)).Return(false, nil)
+				return userSvc, twoFactorSvc
+			},
+			callback: func(t *testing.T, server *gin.Engine, providers map[string]*fakeProvider) *http.Request {
+				ck := authorize(t, server, "github")
+				return callbackReq("github", providers["github"].state, ck)
+			},
+			wantCode: 0,
+			wantUid:  123,
+		},
+		{
+			name: "开启了二次验证，不设置登录态",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.TwoFactorService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindOrCreateByOAuth2(gomock.Any(), gomock.Any()).Return(domain.User{Id: 123}, nil)
+				twoFactorSvc := svcmocks.NewMockTwoFactorService(ctrl)
+				twoFactorSvc.EXPECT().Enabled(gomock.Any(), int64(123)).Return(true, nil)
+				return userSvc, twoFactorSvc
+			},
+			callback: func(t *testing.T, server *gin.Engine, providers map[string]*fakeProvider) *http.Request {
+				ck := authorize(t, server, "github")
+				return callbackReq("github", providers["github"].state, ck)
+			},
+			wantCode: CodeTwoFactorRequired,
+		},
+		{
+			name: "state 不匹配",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.TwoFactorService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			callback: func(t *testing.T, server *gin.Engine, providers map[string]*fakeProvider) *http.Request {
+				ck := authorize(t, server, "github")
+				return callbackReq("github", "another-state", ck)
+			},
+			wantCode: 4,
+		},
+		{
+			name: "没有 state cookie",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.TwoFactorService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			callback: func(t *testing.T, server *gin.Engine, providers map[string]*fakeProvider) *http.Request {
+				authorize(t, server, "github")
+				return callbackReq("github", providers["github"].state, nil)
+			},
+			wantCode: 4,
+		},
+		{
+			name: "cookie 被篡改",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.TwoFactorService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			callback: func(t *testing.T, server *gin.Engine, providers map[string]*fakeProvider) *http.Request {
+				ck := authorize(t, server, "github")
+				ck.Value += "x"
+				return callbackReq("github", providers["github"].state, ck)
+			},
+			wantCode: 4,
+		},
+		{
+			name: "cookie 是另外一个服务商的",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.TwoFactorService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			callback: func(t *testing.T, server *gin.Engine, providers map[string]*fakeProvider) *http.Request {
+				ck := authorize(t, server, "wechat")
+				return callbackReq("github", providers["wechat"].state, ck)
+			},
+			wantCode: 4,
+		},
+		{
+			name: "code_verifier 不匹配",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.TwoFactorService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockTwoFactorService(ctrl)
+			},
+			callback: func(t *testing.T, server *gin.Engine, providers map[string]*fakeProvider) *http.Request {
+				// 第一次授权的 cookie 配上第二次授权的 code_challenge
+				ck := authorize(t, server, "github")
+				state := providers["github"].state
+				authorize(t, server, "github")
+				return callbackReq("github", state, ck)
+			},
+			wantCode: 5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			gin.SetMode(gin.TestMode)
+
+			providers := map[string]*fakeProvider{
+				"github": {name: "github"},
+				"wechat": {name: "wechat"},
+			}
+			jwtHdl := &fakeJWTHandler{}
+			userSvc, twoFactorSvc := tc.mock(ctrl)
+			h := NewOAuth2Handler(oauth2.NewRegistry(providers["github"], providers["wechat"]),
+				userSvc, twoFactorSvc, NewTwoFactorChallenger([]byte("test-key")), nil, OAuth2HandlerConfig{StateKey: []byte("state-key")}, jwtHdl)
+			server := gin.New()
+			h.RegisterRoutes(server)
+
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, tc.callback(t, server, providers))
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result[json.RawMessage]
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+			assert.Equal(t, tc.wantCode, res.Code)
+			assert.Equal(t, tc.wantUid, jwtHdl.uid)
+		})
+	}
+}
+
+// authorize 走一遍 AuthURL，返回设置的 state cookie
+func authorize(t *testing.T, server *gin.Engine, provider string) *http.Cookie {
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/"+provider+"/authurl", nil)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	for _, ck := range resp.Result().Cookies() {
+		if ck.Name == stateCookieName {
+			return ck
+		}
+	}
+	require.FailNow(t, "没有设置 state cookie")
+	return nil
+}
+
+func callbackReq(provider string, state string, ck *http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodGet,
+		"/oauth2/"+provider+"/callback?code=abc&state="+url.QueryEscape(state), nil)
+	if ck != nil {
+		req.AddCookie(&http.Cookie{Name: ck.Name, Value: ck.Value})
+	}
+	return req
+}
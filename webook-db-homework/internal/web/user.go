@@ -14,26 +14,39 @@ import (
 
 var ErrUserDuplicateEmail = service.ErrUserDuplicateEmail
 
-// UserHandler 精简版：邮箱注册/登录 + 个人资料编辑与查询
+// UserHandler 精简版：邮箱注册/登录、短信验证码登录 + 个人资料编辑与查询
 type UserHandler struct {
-	svc         service.UserService
-	emailExp    *regexp.Regexp
-	passwordExp *regexp.Regexp
+	svc          service.UserService
+	codeSvc      service.CodeService
+	twoFactorSvc service.TwoFactorService
+	loginAudit   service.LoginAuditService
+	emailExp     *regexp.Regexp
+	passwordExp  *regexp.Regexp
+	phoneExp     *regexp.Regexp
+	challenger   *TwoFactorChallenger
 	ijwt.Handler
 }
 
-func NewUserHandler(svc service.UserService, jwtHandler ijwt.Handler) *UserHandler {
+func NewUserHandler(svc service.UserService, codeSvc service.CodeService, twoFactorSvc service.TwoFactorService,
+	challenger *TwoFactorChallenger, loginAudit service.LoginAuditService, jwtHandler ijwt.Handler) *UserHandler {
 	const (
 		emailRegexPattern    = "^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\\.[a-zA-Z]{2,}$"
 		passWordRegexPattern = "^(?=.*[A-Za-z])(?=.*\\d)(?=.*[!@#$%^&*()_+])[A-Za-z\\d!@#$%^&*()_+]{8,}$"
+		phoneRegexPattern    = "^1\\d{10}$"
 	)
 	emailExp := regexp.MustCompile(emailRegexPattern, regexp.None)
 	passWordExp := regexp.MustCompile(passWordRegexPattern, regexp.None)
+	phoneExp := regexp.MustCompile(phoneRegexPattern, regexp.None)
 	return &UserHandler{
-		svc:         svc,
-		emailExp:    emailExp,
-		passwordExp: passWordExp,
-		Handler:     jwtHandler,
+		svc:          svc,
+		codeSvc:      codeSvc,
+		twoFactorSvc: twoFactorSvc,
+		loginAudit:   loginAudit,
+		emailExp:     emailExp,
+		passwordExp:  passWordExp,
+		phoneExp:     phoneExp,
+		challenger:   challenger,
+		Handler:      jwtHandler,
 	}
 }
 
@@ -41,9 +54,16 @@ func (u *UserHandler) RegisterRoutes(r *gin.Engine) {
 	ug := r.Group("/users")
 	ug.POST("/signup", u.Signup)
 	ug.POST("/login", u.Login)
+	ug.POST("/login_sms/code/send", u.SendSMSLoginCode)
+	ug.POST("/login_sms", u.LoginSMS)
 	ug.GET("/profile", u.Profile)
 	ug.POST("/edit", u.Edit)
 	ug.POST("/logout", u.Logout)
+	ug.POST("/login/2fa", u.LoginTwoFactor)
+	ug.POST("/2fa/enroll", u.EnrollTwoFactor)
+	ug.POST("/2fa/activate", u.ActivateTwoFactor)
+	ug.POST("/2fa/disable", u.DisableTwoFactor)
+	ug.GET("/login_logs", u.LoginLogs)
 }
 
 func (u *UserHandler) Signup(c *gin.Context) {
@@ -99,15 +119,40 @@ func (u *UserHandler) Login(c *gin.Context) {
 	}
 
 	user, err := u.svc.Login(c.Request.Context(), req.Email, req.Password)
+	log := domain.LoginLog{Account: req.Email, Uid: user.Id, Method: domain.LoginMethodPassword}
 	switch err {
 	case nil:
-		if er := u.SetLoginToken(c, user.Id); er != nil {
+		enabled, er := u.twoFactorSvc.Enabled(c.Request.Context(), user.Id)
+		if er != nil {
 			c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
 			return
 		}
+		if enabled {
+			// 开启了二次验证，先不设置登录态，只返回一个短期的挑战 token，等二次验证完成再记录
+			u.challenger.Challenge(c, user.Id)
+			return
+		}
+		er = u.SetLoginToken(c, user.Id)
+		if er == service.ErrUserBanned {
+			recordLogin(c, u.loginAudit, log, er)
+			c.JSON(http.StatusOK, Result[string]{Code: 403, Msg: "账号已被封禁"})
+			return
+		}
+		if er != nil {
+			c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+			return
+		}
+		recordLogin(c, u.loginAudit, log, nil)
 		c.JSON(http.StatusOK, Result[string]{Code: 0, Msg: "登录成功"})
 	case service.ErrInvalidUserOrPassword:
+		recordLogin(c, u.loginAudit, log, err)
 		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "邮箱或密码错误"})
+	case service.ErrUserBanned:
+		recordLogin(c, u.loginAudit, log, err)
+		c.JSON(http.StatusOK, Result[string]{Code: 403, Msg: "账号已被封禁"})
+	case service.ErrUserLocked:
+		recordLogin(c, u.loginAudit, log, err)
+		c.JSON(http.StatusOK, Result[string]{Code: 429, Msg: "登录失败次数过多，请稍后再试"})
 	default:
 		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
 	}
@@ -0,0 +1,211 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"webook/internal/domain"
+	"webook/internal/service"
+)
+
+// CodeTwoFactorRequired 登录时密码正确但是还需要二次验证
+const CodeTwoFactorRequired = 2
+
+// TwoFactorClaims 登录二次验证的挑战 token，只能用来调用 /users/login/2fa
+type TwoFactorClaims struct {
+	Uid       int64  `json:"uid"`
+	UserAgent string `json:"user_agent"`
+	jwt.RegisteredClaims
+}
+
+type TwoFactorChallengeVO struct {
+	Token string `json:"token"`
+}
+
+type TwoFactorEnrollVO struct {
+	Secret string `json:"secret"`
+	// URI otpauth:// 格式，前端直接把它渲染成二维码
+	URI string `json:"uri"`
+}
+
+// TwoFactorChallenger 签发和校验登录二次验证的挑战 token，密码、短信和第三方登录共用一个
+type TwoFactorChallenger struct {
+	// key 和登录态的 token 分开，多个实例要配置成一样的
+	key []byte
+}
+
+func NewTwoFactorChallenger(key []byte) *TwoFactorChallenger {
+	return &TwoFactorChallenger{key: key}
+}
+
+// Challenge 开启了二次验证的用户先不设置登录态，只返回一个短期的挑战 token
+func (t *TwoFactorChallenger) Challenge(c *gin.Context, uid int64) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, TwoFactorClaims{
+		Uid:       uid,
+		UserAgent: c.Request.UserAgent(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 5)),
+		},
+	})
+	tokenStr, err := token.SignedString(t.key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	c.JSON(http.StatusOK, Result[TwoFactorChallengeVO]{
+		Code: CodeTwoFactorRequired,
+		Msg:  "需要二次验证",
+		Data: TwoFactorChallengeVO{Token: tokenStr},
+	})
+}
+
+func (t *TwoFactorChallenger) Parse(c *gin.Context, tokenStr string) (TwoFactorClaims, error) {
+	var claims TwoFactorClaims
+	token, err := jwt.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("无效的签名算法: %v", token.Header["alg"])
+		}
+		return t.key, nil
+	})
+	if err != nil || !token.Valid {
+		return TwoFactorClaims{}, errors.New("二次验证 token 无效")
+	}
+	if claims.UserAgent != c.Request.UserAgent() {
+		return TwoFactorClaims{}, errors.New("用户代理不匹配")
+	}
+	return claims, nil
+}
+
+// LoginTwoFactor 登录第二步，验证通过后才设置登录态
+func (u *UserHandler) LoginTwoFactor(c *gin.Context) {
+	type Req struct {
+		Token string `json:"token"`
+		Code  string `json:"code"`
+	}
+	var req Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "参数错误: " + err.Error()})
+		return
+	}
+	claims, err := u.challenger.Parse(c, req.Token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, Result[string]{Code: 401, Msg: "二次验证已过期，请重新登录"})
+		return
+	}
+	// 记录日志的账号和密码登录保持一致，失败次数才能累计到同一个账号上
+	user, err := u.svc.GetUserById(c.Request.Context(), claims.Uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	log := domain.LoginLog{Account: user.Email, Uid: claims.Uid, Method: domain.LoginMethodTwoFactor}
+	err = u.twoFactorSvc.Verify(c.Request.Context(), claims.Uid, req.Code)
+	if !u.writeTwoFactorErr(c, err) {
+		recordLogin(c, u.loginAudit, log, err)
+		return
+	}
+	err = u.SetLoginToken(c, claims.Uid)
+	if err == service.ErrUserBanned {
+		recordLogin(c, u.loginAudit, log, err)
+		c.JSON(http.StatusOK, Result[string]{Code: 403, Msg: "账号已被封禁"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	recordLogin(c, u.loginAudit, log, nil)
+	c.JSON(http.StatusOK, Result[string]{Code: 0, Msg: "登录成功"})
+}
+
+func (u *UserHandler) EnrollTwoFactor(c *gin.Context) {
+	uid := c.GetInt64("userId")
+	if uid == 0 {
+		c.JSON(http.StatusUnauthorized, Result[string]{Code: 401, Msg: "未登录"})
+		return
+	}
+	user, err := u.svc.GetUserById(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	// 验证器 App 里面显示的账号名
+	account := user.Email
+	if account == "" {
+		account = user.Phone
+	}
+	if account == "" {
+		account = strconv.FormatInt(uid, 10)
+	}
+	enrollment, err := u.twoFactorSvc.Enroll(c.Request.Context(), uid, account)
+	if !u.writeTwoFactorErr(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, Result[TwoFactorEnrollVO]{Code: 0, Data: TwoFactorEnrollVO{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	}})
+}
+
+func (u *UserHandler) ActivateTwoFactor(c *gin.Context) {
+	type Req struct {
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "参数错误: " + err.Error()})
+		return
+	}
+	uid := c.GetInt64("userId")
+	if uid == 0 {
+		c.JSON(http.StatusUnauthorized, Result[string]{Code: 401, Msg: "未登录"})
+		return
+	}
+	codes, err := u.twoFactorSvc.Activate(c.Request.Context(), uid, req.Code)
+	if !u.writeTwoFactorErr(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, Result[[]string]{Code: 0, Msg: "二次验证已开启，请妥善保存恢复码", Data: codes})
+}
+
+func (u *UserHandler) DisableTwoFactor(c *gin.Context) {
+	type Req struct {
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "参数错误: " + err.Error()})
+		return
+	}
+	uid := c.GetInt64("userId")
+	if uid == 0 {
+		c.JSON(http.StatusUnauthorized, Result[string]{Code: 401, Msg: "未登录"})
+		return
+	}
+	err := u.twoFactorSvc.Disable(c.Request.Context(), uid, req.Code)
+	if !u.writeTwoFactorErr(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, Result[string]{Code: 0, Msg: "二次验证已关闭"})
+}
+
+// writeTwoFactorErr 把二次验证的错误转换成响应，返回 true 代表没有错误可以继续处理
+func (u *UserHandler) writeTwoFactorErr(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case service.ErrTwoFactorInvalidCode, service.ErrTwoFactorNotEnrolled, service.ErrTwoFactorAlreadyEnabled:
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: err.Error()})
+	case service.ErrTwoFactorTooManyAttempts:
+		c.JSON(http.StatusTooManyRequests, Result[string]{Code: 429, Msg: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"webook/internal/domain"
+	"webook/internal/service"
+)
+
+// bizLogin 短信登录的业务名，和短信模板配置里面的业务名一致
+const bizLogin = "login"
+
+// codeLimitMsg 不同的限流维度给用户不同的提示
+var codeLimitMsg = map[service.CodeLimitScope]string{
+	service.CodeLimitScopeIP:    "当前网络获取验证码过于频繁，请稍后再试",
+	service.CodeLimitScopePhone: "该手机号今天获取验证码次数过多，请明天再试",
+	service.CodeLimitScopeBiz:   "系统繁忙，请稍后再试",
+}
+
+// SendSMSLoginCode 发送登录验证码
+func (u *UserHandler) SendSMSLoginCode(c *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+	}
+	var req Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "参数错误: " + err.Error()})
+		return
+	}
+	ok, err := u.phoneExp.MatchString(req.Phone)
+	if err != nil || !ok {
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "手机号格式不正确"})
+		return
+	}
+	err = u.codeSvc.Send(c.Request.Context(), bizLogin, req.Phone, c.ClientIP())
+	var limitErr *service.CodeLimitError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, Result[string]{Code: 0, Msg: "发送成功"})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		c.JSON(http.StatusOK, Result[string]{Code: 429, Msg: "发送太频繁，请一分钟后再试"})
+	case errors.As(err, &limitErr):
+		c.JSON(http.StatusTooManyRequests, Result[string]{Code: 429, Msg: codeLimitMsg[limitErr.Scope]})
+	default:
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+	}
+}
+
+// LoginSMS 验证码登录，手机号没有注册过会自动注册
+func (u *UserHandler) LoginSMS(c *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+		Code  string `json:"code"`
+	}
+	var req Req
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "参数错误: " + err.Error()})
+		return
+	}
+	log := domain.LoginLog{Account: req.Phone, Method: domain.LoginMethodSMS}
+	err := u.codeSvc.Verify(c.Request.Context(), bizLogin, req.Phone, req.Code)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrCodeVerifyInvalid):
+		recordLogin(c, u.loginAudit, log, err)
+		c.JSON(http.StatusOK, Result[string]{Code: 400, Msg: "验证码错误"})
+		return
+	case errors.Is(err, service.ErrCodeVerifyTooMany):
+		recordLogin(c, u.loginAudit, log, err)
+		c.JSON(http.StatusOK, Result[string]{Code: 429, Msg: "验证码错误次数过多，请重新获取"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+		return
+	}
+
+	user, err := u.svc.FindOrCreate(c.Request.Context(), req.Phone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	log.Uid = user.Id
+	enabled, err := u.twoFactorSvc.Enabled(c.Request.Context(), user.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	if enabled {
+		u.challenger.Challenge(c, user.Id)
+		return
+	}
+	err = u.SetLoginToken(c, user.Id)
+	if err == service.ErrUserBanned {
+		recordLogin(c, u.loginAudit, log, err)
+		c.JSON(http.StatusOK, Result[string]{Code: 403, Msg: "账号已被封禁"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Result[string]{Code: 500, Msg: "系统错误"})
+		return
+	}
+	recordLogin(c, u.loginAudit, log, nil)
+	c.JSON(http.StatusOK, Result[string]{Code: 0, Msg: "登录成功"})
+}
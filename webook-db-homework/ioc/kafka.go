@@ -5,7 +5,9 @@ import (
 	"webook/interactive/repository"
 	"webook/internal/config"
 	events2 "webook/internal/events"
+	articleevents "webook/internal/events/article"
 	"webook/pkg/logger"
+	"webook/pkg/saramax"
 
 	"github.com/IBM/sarama"
 	"github.com/spf13/viper"
@@ -82,12 +84,13 @@ func InitKafkaConsumer(l logger.LoggerV1) sarama.ConsumerGroup {
 
 // InitArticleEventProducer 初始化文章事件生产者
 func InitArticleEventProducer(producer sarama.SyncProducer) events2.Producer {
+	articleevents.InitTopics(getKafkaConfig())
 	return events2.NewKafkaProducer(producer)
 }
 
 // InitArticleEventConsumer 初始化文章事件消费者
 func InitArticleEventConsumer(client sarama.Client, l logger.LoggerV1, repo repository.InteractiveRepository) events.Consumer {
-	return events.NewKafkaBatchConsumer(client, l, repo)
+	return events.NewKafkaBatchConsumer(client, l, repo, saramax.NewRetrier(saramax.DefaultRetryPolicy, nil, l))
 }
 
 // getKafkaConfig 从配置中获取 Kafka 配置
@@ -1,8 +1,6 @@
 package ioc
 
 import (
-	"fmt"
-
 	rlock "github.com/gotomicro/redis-lock"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
@@ -10,7 +8,6 @@ import (
 
 func InitRedis() redis.Cmdable {
 	addr := viper.GetString("db.redis.addr")
-	fmt.Println(addr)
 	redisClient := redis.NewClient(&redis.Options{
 		Addr: addr,
 	})
@@ -1,11 +1,15 @@
 package ioc
 
 import (
+	"webook/internal/repository"
 	"webook/internal/service/sms"
 	"webook/internal/service/sms/memory"
+	"webook/internal/service/sms/sendlog"
+	"webook/internal/service/sms/template"
+	"webook/pkg/logger"
 )
 
-func InitSMS() sms.Service {
+func InitSMS(registry *template.Registry, logRepo repository.SMSLogRepository, l logger.LoggerV1) sms.Service {
 	/*
 		// 创建腾讯云短信客户端
 		credential := common.NewCredential(
@@ -18,7 +22,10 @@ func InitSMS() sms.Service {
 			panic(err)
 		}
 	*/
-	smsService := memory.NewService()
+	// 每个服务商单独套一层模板，调用方只传业务名，发送记录放在最外层，记录的是业务名
+	smsService := sendlog.NewSMSService(
+		template.NewSMSService(memory.NewService(l), "memory", registry),
+		"memory", logRepo, l)
 	// 创建短信服务
 	return smsService
 }
@@ -1,35 +1,60 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 
 	"webook/internal/bootstrap"
+	events "webook/internal/events/article"
 	"webook/internal/repository"
 	articlerepo "webook/internal/repository/article"
 	"webook/internal/repository/cache"
+	layeredcode "webook/internal/repository/cache/vcode/layered"
+	memorycode "webook/internal/repository/cache/vcode/memory"
+	rediscode "webook/internal/repository/cache/vcode/redis"
 	userdao "webook/internal/repository/dao"
 	articledao "webook/internal/repository/dao/article"
 	"webook/internal/service"
 	"webook/internal/web"
 	ijwt "webook/internal/web/jwt"
 	"webook/internal/web/middleware"
+	"webook/pkg/logger"
 )
 
 func main() {
 	initConfig()
+	// 收到退出信号之后 ctx 取消，后台任务跟着退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	l := bootstrap.InitLogger()
 	db := bootstrap.InitDB(l)
-	redisClient := bootstrap.InitRedis()
+	redisClient := bootstrap.InitRedis(l)
+	bootstrap.InitOutboxRelay(ctx, db, bootstrap.InitKafkaConfig(), l)
 
 	// repository 层
-	userDAO := userdao.NewUserDAO(db)
+	userDAO := userdao.NewUserDAO(db, l)
 	userCache := cache.NewUserCache(redisClient)
 	userRepo := repository.NewUserRepository(userDAO, userCache)
+	twoFactorRepo := repository.NewTwoFactorRepository(userdao.NewTwoFactorDAO(db), cache.NewTwoFactorCache(redisClient))
+	rbacRepo := repository.NewRBACRepository(userdao.NewRBACDAO(db))
+	auditRepo := repository.NewAdminAuditRepository(userdao.NewAdminAuditDAO(db))
+	loginLogRepo := repository.NewLoginLogRepository(userdao.NewLoginLogDAO(db))
+	loginRiskRepo := repository.NewLoginRiskRepository(cache.NewLoginRiskCache(redisClient))
+	smsLogRepo := repository.NewSMSLogRepository(userdao.NewSMSLogDAO(db))
+	asyncSmsRepo := bootstrap.InitAsyncSmsRepository(db, l)
+	// Redis 不可用的时候验证码降级到本地缓存，不影响登录
+	codeRepo := repository.NewCodeRepository(layeredcode.NewLayeredCodeCache(
+		rediscode.NewRedisCodeCache(redisClient), memorycode.NewMemoryCodeCache(100000), time.Second*10, l))
 
 	articleDAO := articledao.NewArticleDAO(db)
 	articleCache := cache.NewRedisArticleCache(redisClient)
@@ -37,15 +62,38 @@ func main() {
 	interactiveSvc := service.NewDBInteractiveService(db)
 
 	// service 层
-	userSvc := service.NewUserService(userRepo)
-	articleSvc := service.NewArticleService(articleRepo, l, nil)
+	loginRiskSvc := service.NewLoginRiskService(loginRiskRepo, service.NewLogLoginRiskNotifier(l), l,
+		service.DefaultLoginRules(loginRiskRepo)...)
+	loginAuditSvc := service.NewLoginAuditService(loginLogRepo, userRepo, loginRiskSvc, l)
+	defer loginAuditSvc.Close()
+	userSvc := service.NewUserService(userRepo, loginRiskSvc)
+	smsSvc := bootstrap.InitSMS(ctx, l, smsLogRepo, asyncSmsRepo)
+	codeSvc := service.NewCodeService(codeRepo, smsSvc, bootstrap.InitCodeLimits(redisClient)...)
+	twoFactorSvc := service.NewTwoFactorService(twoFactorRepo, "webook")
+	// 读事件写进发件箱，由后台转发到 Kafka
+	articleSvc := service.NewArticleService(articleRepo, l, events.NewOutboxProducer(db))
+	rbacSvc := service.NewRBACService(rbacRepo, userRepo)
+	adminSvc := service.NewAdminService(userRepo, rbacRepo, auditRepo, articleSvc, twoFactorSvc, smsLogRepo, asyncSmsRepo)
 
 	// handler & middleware
-	jwtHandler := ijwt.NewRedisJWTHandler(redisClient)
-	userHdl := web.NewUserHandler(userSvc, jwtHandler)
+	jwtHandler := ijwt.NewRedisJWTHandler(redisClient, rbacSvc)
+	challenger := bootstrap.InitTwoFactorChallenger(l)
+	userHdl := web.NewUserHandler(userSvc, codeSvc, twoFactorSvc, challenger, loginAuditSvc, jwtHandler)
+	oauth2Hdl := web.NewOAuth2Handler(bootstrap.InitOAuth2Providers(l), userSvc, twoFactorSvc, challenger, loginAuditSvc,
+		bootstrap.InitOAuth2HandlerConfig(l), jwtHandler)
 	articleHdl := web.NewArticleHandler(articleSvc, interactiveSvc, l)
+	adminHdl := web.NewAdminHandler(adminSvc, l)
 
-	server := gin.Default()
+	// 不用 gin 自带的访问日志，统一走 LoggerV1
+	server := gin.New()
+	server.Use(gin.Recovery())
+	// 验证码、按路由限流和登录风控都按 ClientIP 处理，不能让客户端伪造
+	bootstrap.InitTrustedProxies(server)
+	// service 拿到的是 *gin.Context，打开之后才能读到请求 ctx 里的 request_id、trace_id、uid
+	server.ContextWithFallback = true
+	server.Use(bootstrap.InitTrace())
+	// 放在 trace 后面，访问日志才能带上 request_id
+	server.Use(bootstrap.InitAccessLog(l))
 	// 允许前端开发端口跨域访问
 	server.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:3000"},
@@ -61,15 +109,36 @@ func main() {
 		IgnorePaths("/health").
 		IgnorePaths("/users/login").
 		IgnorePaths("/users/signup").
+		IgnorePaths("/users/login_sms").
 		IgnorePaths("/articles/pub").
+		IgnorePaths("/oauth2").
 		Build())
+	// 按路由限流要放在登录校验后面，才能按照用户 id 限流
+	server.Use(bootstrap.InitRateLimit(redisClient, l))
 
 	userHdl.RegisterRoutes(server)
+	oauth2Hdl.RegisterRoutes(server)
 	articleHdl.RegisterRoutes(server)
+	adminHdl.RegisterRoutes(server)
 
-	if err := server.Run(":8080"); err != nil {
-		log.Fatalf("start server failed: %v", err)
+	srv := &http.Server{Addr: ":8080", Handler: server}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		l.Info("收到退出信号，开始关闭 HTTP 服务")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			l.Error("关闭 HTTP 服务超时", logger.Error(err))
+		}
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		l.Error("HTTP 服务启动失败", logger.Error(err))
+		os.Exit(1)
 	}
+	// 等正在处理的请求结束，再关闭登录日志等后台任务
+	<-shutdown
 }
 
 func initConfig() {
@@ -0,0 +1,253 @@
+// Package circuitbreaker 熔断器，下游完全不可用的时候直接拒绝请求，避免一直打到下游上
+//
+// 关闭状态下统计一个周期内的请求，请求数量达到 MinRequests 并且失败比例达到 FailureRatio 就打开；
+// 打开 OpenTimeout 之后进入半开状态，放行最多 HalfOpenMaxRequests 个请求试探，
+// 全部成功就关闭，有一个失败就重新打开。
+// 半开状态放行的请求超过 ProbeTimeout 还没有报告结果，就当作丢失，重新放行新的试探请求。
+package circuitbreaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"webook/pkg/logger"
+)
+
+var (
+	ErrOpenState       = errors.New("熔断器已打开")
+	ErrTooManyRequests = errors.New("熔断器半开状态请求过多")
+)
+
+// Outcome 请求结果，Ignored 代表这次请求不能说明下游的好坏，比如调用方主动取消
+type Outcome uint8
+
+const (
+	Success Outcome = iota
+	Failure
+	Ignored
+)
+
+type State int32
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Clock 测试的时候替换成假的时钟
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+type Config struct {
+	// Name 打日志用来区分是哪个熔断器
+	Name string
+	// FailureRatio 失败比例达到这个值就打开
+	FailureRatio float64
+	// MinRequests 一个周期内请求数量太少的时候不打开
+	MinRequests uint32
+	// Interval 关闭状态下的统计周期，为 0 代表一直累计到状态切换
+	Interval time.Duration
+	// OpenTimeout 打开之后多久进入半开状态
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests 半开状态最多放行多少个请求
+	HalfOpenMaxRequests uint32
+	// ProbeTimeout 半开状态的请求多久没有报告结果就释放名额，为 0 的时候使用 OpenTimeout
+	ProbeTimeout time.Duration
+	// Clock 为 nil 的时候使用系统时间
+	Clock Clock
+}
+
+type counts struct {
+	requests             uint32
+	failures             uint32
+	consecutiveSuccesses uint32
+}
+
+const defaultProbeTimeout = time.Second * 10
+
+type Breaker struct {
+	cfg   Config
+	clock Clock
+	l     logger.LoggerV1
+
+	mutex sync.Mutex
+	state State
+	cnts  counts
+	// generation 每次切换状态或者开始新的统计周期都加一，旧周期的请求结果直接丢弃
+	generation uint64
+	// expiry 关闭状态下是统计周期的结束时间，打开状态下是进入半开的时间，
+	// 半开状态下是最后一个试探请求的超时时间
+	expiry time.Time
+}
+
+func NewBreaker(cfg Config, l logger.LoggerV1) *Breaker {
+	if cfg.HalfOpenMaxRequests == 0 {
+		cfg.HalfOpenMaxRequests = 1
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = cfg.OpenTimeout
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = defaultProbeTimeout
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	b := &Breaker{
+		cfg:   cfg,
+		clock: clock,
+		l:     l,
+	}
+	b.toNewGeneration(clock.Now())
+	return b
+}
+
+// Execute 熔断器允许的时候执行 fn，fn 返回的错误算作失败
+func (b *Breaker) Execute(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if err == nil {
+		done(Success)
+	} else {
+		done(Failure)
+	}
+	return err
+}
+
+// Allow 检查是否允许请求，允许的话调用方需要在请求结束之后调用 done 报告结果
+func (b *Breaker) Allow() (done func(outcome Outcome), err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := b.clock.Now()
+	state, generation := b.currentState(now)
+	switch state {
+	case StateOpen:
+		return nil, ErrOpenState
+	case StateHalfOpen:
+		if b.cnts.requests >= b.cfg.HalfOpenMaxRequests {
+			return nil, ErrTooManyRequests
+		}
+		b.expiry = now.Add(b.cfg.ProbeTimeout)
+	}
+	b.cnts.requests++
+	return func(outcome Outcome) {
+		b.report(generation, outcome)
+	}, nil
+}
+
+func (b *Breaker) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	state, _ := b.currentState(b.clock.Now())
+	return state
+}
+
+func (b *Breaker) report(generation uint64, outcome Outcome) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := b.clock.Now()
+	state, current := b.currentState(now)
+	if generation != current {
+		return
+	}
+	switch outcome {
+	case Ignored:
+		// 不计入统计，半开状态下把名额还回去
+		b.cnts.requests--
+		return
+	case Success:
+		b.cnts.consecutiveSuccesses++
+		if state == StateHalfOpen && b.cnts.consecutiveSuccesses >= b.cfg.HalfOpenMaxRequests {
+			b.setState(StateClosed, now)
+		}
+		return
+	}
+	b.cnts.failures++
+	b.cnts.consecutiveSuccesses = 0
+	switch state {
+	case StateHalfOpen:
+		b.setState(StateOpen, now)
+	case StateClosed:
+		if b.cnts.requests >= b.cfg.MinRequests &&
+			float64(b.cnts.failures)/float64(b.cnts.requests) >= b.cfg.FailureRatio {
+			b.setState(StateOpen, now)
+		}
+	}
+}
+
+// currentState 处理到期之后的状态切换
+func (b *Breaker) currentState(now time.Time) (State, uint64) {
+	switch b.state {
+	case StateClosed:
+		if !b.expiry.IsZero() && b.expiry.Before(now) {
+			b.toNewGeneration(now)
+		}
+	case StateOpen:
+		if b.expiry.Before(now) {
+			b.setState(StateHalfOpen, now)
+		}
+	case StateHalfOpen:
+		if !b.expiry.IsZero() && b.expiry.Before(now) {
+			// 试探请求一直没有结果，丢弃这一轮，重新放行
+			b.l.Warn("熔断器半开试探超时",
+				logger.String("name", b.cfg.Name))
+			b.toNewGeneration(now)
+		}
+	}
+	return b.state, b.generation
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+	prev := b.state
+	b.state = state
+	b.toNewGeneration(now)
+	b.l.Warn("熔断器状态变化",
+		logger.String("name", b.cfg.Name),
+		logger.String("from", prev.String()),
+		logger.String("to", state.String()))
+}
+
+func (b *Breaker) toNewGeneration(now time.Time) {
+	b.generation++
+	b.cnts = counts{}
+	switch b.state {
+	case StateClosed:
+		if b.cfg.Interval == 0 {
+			b.expiry = time.Time{}
+		} else {
+			b.expiry = now.Add(b.cfg.Interval)
+		}
+	case StateOpen:
+		b.expiry = now.Add(b.cfg.OpenTimeout)
+	default:
+		b.expiry = time.Time{}
+	}
+}
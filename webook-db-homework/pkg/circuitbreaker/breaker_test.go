@@ -0,0 +1,183 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestBreaker(clock *fakeClock) *Breaker {
+	return NewBreaker(Config{
+		Name:                "test",
+		FailureRatio:        0.5,
+		MinRequests:         4,
+		Interval:            time.Minute,
+		OpenTimeout:         time.Second * 10,
+		HalfOpenMaxRequests: 2,
+		Clock:               clock,
+	}, logger.NewZapLogger(zap.NewNop()))
+}
+
+var errFailed = errors.New("下游失败")
+
+func succeed() error { return nil }
+func fail() error    { return errFailed }
+
+func TestBreaker(t *testing.T) {
+	testCases := []struct {
+		name string
+		// steps 依次执行的操作
+		steps     func(t *testing.T, b *Breaker, clock *fakeClock)
+		wantState State
+	}{
+		{
+			name: "请求数量不够，不打开",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				for i := 0; i < 3; i++ {
+					assert.Equal(t, errFailed, b.Execute(fail))
+				}
+			},
+			wantState: StateClosed,
+		},
+		{
+			name: "失败比例达到阈值，打开之后拒绝请求",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				assert.NoError(t, b.Execute(succeed))
+				assert.NoError(t, b.Execute(succeed))
+				assert.Equal(t, errFailed, b.Execute(fail))
+				assert.Equal(t, errFailed, b.Execute(fail))
+				assert.Equal(t, ErrOpenState, b.Execute(succeed))
+			},
+			wantState: StateOpen,
+		},
+		{
+			name: "统计周期过了就重新计数",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				assert.Equal(t, errFailed, b.Execute(fail))
+				assert.Equal(t, errFailed, b.Execute(fail))
+				assert.Equal(t, errFailed, b.Execute(fail))
+				clock.Advance(time.Minute + time.Second)
+				assert.Equal(t, errFailed, b.Execute(fail))
+			},
+			wantState: StateClosed,
+		},
+		{
+			name: "打开超时之后进入半开",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				openBreaker(t, b)
+				clock.Advance(time.Second * 11)
+			},
+			wantState: StateHalfOpen,
+		},
+		{
+			name: "半开状态限制请求数量",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				openBreaker(t, b)
+				clock.Advance(time.Second * 11)
+				_, err := b.Allow()
+				assert.NoError(t, err)
+				_, err = b.Allow()
+				assert.NoError(t, err)
+				_, err = b.Allow()
+				assert.Equal(t, ErrTooManyRequests, err)
+			},
+			wantState: StateHalfOpen,
+		},
+		{
+			name: "半开状态全部成功就关闭",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				openBreaker(t, b)
+				clock.Advance(time.Second * 11)
+				assert.NoError(t, b.Execute(succeed))
+				assert.NoError(t, b.Execute(succeed))
+			},
+			wantState: StateClosed,
+		},
+		{
+			name: "半开状态失败就重新打开",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				openBreaker(t, b)
+				clock.Advance(time.Second * 11)
+				assert.NoError(t, b.Execute(succeed))
+				assert.Equal(t, errFailed, b.Execute(fail))
+			},
+			wantState: StateOpen,
+		},
+		{
+			name: "旧周期的请求结果不影响新状态",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				done, err := b.Allow()
+				assert.NoError(t, err)
+				openBreaker(t, b)
+				clock.Advance(time.Second * 11)
+				assert.Equal(t, StateHalfOpen, b.State())
+				// 打开之前发出去的请求现在才失败
+				done(Failure)
+			},
+			wantState: StateHalfOpen,
+		},
+		{
+			name: "半开状态的请求一直没有结果，超时之后释放名额",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				openBreaker(t, b)
+				clock.Advance(time.Second * 11)
+				done, err := b.Allow()
+				assert.NoError(t, err)
+				_, err = b.Allow()
+				assert.NoError(t, err)
+				_, err = b.Allow()
+				assert.Equal(t, ErrTooManyRequests, err)
+				clock.Advance(time.Second * 11)
+				// 超时的请求再报告结果也没有用
+				done(Failure)
+				assert.NoError(t, b.Execute(succeed))
+				assert.NoError(t, b.Execute(succeed))
+			},
+			wantState: StateClosed,
+		},
+		{
+			name: "忽略的请求不计数，也会还回半开名额",
+			steps: func(t *testing.T, b *Breaker, clock *fakeClock) {
+				openBreaker(t, b)
+				clock.Advance(time.Second * 11)
+				for i := 0; i < 3; i++ {
+					done, err := b.Allow()
+					assert.NoError(t, err)
+					done(Ignored)
+				}
+			},
+			wantState: StateHalfOpen,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(1700000000, 0)}
+			b := newTestBreaker(clock)
+			tc.steps(t, b, clock)
+			assert.Equal(t, tc.wantState, b.State())
+		})
+	}
+}
+
+func openBreaker(t *testing.T, b *Breaker) {
+	for i := 0; i < 4; i++ {
+		_ = b.Execute(fail)
+	}
+	assert.Equal(t, StateOpen, b.State())
+}
@@ -0,0 +1,53 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// RoundTripper 给 http.Client 加上熔断，网络错误、超时和 5xx 都算失败，
+// 只有调用方主动取消不算。http.Client 的 Timeout 也是通过请求的 ctx 生效的，
+// 所以超时必须算失败，不然下游卡住的时候熔断永远不会打开
+type RoundTripper struct {
+	next    http.RoundTripper
+	breaker *Breaker
+}
+
+func NewRoundTripper(next http.RoundTripper, breaker *Breaker) *RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RoundTripper{
+		next:    next,
+		breaker: breaker,
+	}
+}
+
+func (r *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := r.breaker.Allow()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Host, err)
+	}
+	resp, err := r.next.RoundTrip(req)
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		done(Ignored)
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		done(Failure)
+	default:
+		done(Success)
+	}
+	return resp, err
+}
+
+// WrapClient 复制一份 client，只替换 Transport，不修改原来的 client
+func WrapClient(client *http.Client, breaker *Breaker) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = NewRoundTripper(client.Transport, breaker)
+	return &c
+}
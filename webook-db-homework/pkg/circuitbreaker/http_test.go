@@ -0,0 +1,93 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapClient(t *testing.T) {
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	client := WrapClient(server.Client(), newTestBreaker(clock))
+
+	// 5xx 也算失败，4 次之后打开
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+	_, err := client.Get(server.URL)
+	assert.True(t, errors.Is(err, ErrOpenState))
+
+	// 下游恢复之后，半开状态放行的请求成功就关闭
+	status = http.StatusOK
+	clock.Advance(time.Second * 11)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	assert.Equal(t, StateClosed, client.Transport.(*RoundTripper).breaker.State())
+}
+
+func TestWrapClient_CallerCanceled(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	breaker := newTestBreaker(clock)
+	client := WrapClient(server.Client(), breaker)
+
+	// 调用方自己取消的请求不算下游失败
+	for i := 0; i < 4; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		time.AfterFunc(time.Millisecond*10, cancel)
+		_, err = client.Do(req)
+		cancel()
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+	assert.Equal(t, StateClosed, breaker.State())
+}
+
+func TestWrapClient_Timeout(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	breaker := newTestBreaker(clock)
+	c := server.Client()
+	c.Timeout = time.Millisecond * 10
+	client := WrapClient(c, breaker)
+
+	// http.Client 的 Timeout 是通过请求的 ctx 生效的，下游卡住要算失败
+	for i := 0; i < 4; i++ {
+		_, err := client.Get(server.URL)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, StateOpen, breaker.State())
+	_, err := client.Get(server.URL)
+	assert.ErrorIs(t, err, ErrOpenState)
+}
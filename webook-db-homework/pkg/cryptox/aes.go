@@ -0,0 +1,61 @@
+// Package cryptox 需要落库的敏感数据（验证码之类）的对称加密
+package cryptox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+)
+
+var ErrInvalidCiphertext = errors.New("密文格式错误")
+
+// AESGCM 密文格式是 base64(nonce + 密文)，可以直接存到字符串字段里面
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM key 可以是任意长度的口令，用 SHA-256 派生出 AES-256 的密钥
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	if len(key) == 0 {
+		return nil, errors.New("cryptox: 密钥不能为空")
+	}
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+func (c *AESGCM) Encrypt(plain []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+func (c *AESGCM) Decrypt(ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	size := c.aead.NonceSize()
+	if len(data) < size {
+		return nil, ErrInvalidCiphertext
+	}
+	plain, err := c.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		// 密钥不对或者被篡改
+		return nil, ErrInvalidCiphertext
+	}
+	return plain, nil
+}
@@ -0,0 +1,38 @@
+package cryptox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESGCM(t *testing.T) {
+	c, err := NewAESGCM([]byte("test-key"))
+	require.NoError(t, err)
+	ciphertext, err := c.Encrypt([]byte(`{"args":["123456"]}`))
+	require.NoError(t, err)
+	assert.NotContains(t, ciphertext, "123456")
+
+	plain, err := c.Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, `{"args":["123456"]}`, string(plain))
+
+	// 同样的明文每次加密的结果都不一样
+	another, err := c.Encrypt([]byte(`{"args":["123456"]}`))
+	require.NoError(t, err)
+	assert.NotEqual(t, ciphertext, another)
+
+	other, err := NewAESGCM([]byte("other-key"))
+	require.NoError(t, err)
+	_, err = other.Decrypt(ciphertext)
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	_, err = c.Decrypt("not base64!")
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+	_, err = c.Decrypt("")
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	_, err = NewAESGCM(nil)
+	assert.Error(t, err)
+}
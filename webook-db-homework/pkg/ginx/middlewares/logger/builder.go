@@ -1 +1,230 @@
 package logger
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"webook/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultRedactKeys 请求体、响应体和查询参数里面这些字段的值会被替换成 ***，不区分大小写
+var DefaultRedactKeys = []string{
+	"password", "confirmPassword", "oldPassword", "newPassword",
+	"code", "code_verifier", "token", "access_token", "refresh_token", "secret", "uri",
+}
+
+// DefaultSkipBodyPaths 这些路径和它们的子路径不管开关怎么设置都不记录请求体和响应体，
+// 比如 2FA 绑定返回的密钥、恢复码，OAuth2 回调里面的授权码，脱敏规则很难覆盖全
+var DefaultSkipBodyPaths = []string{"/users/2fa", "/oauth2"}
+
+const redacted = "***"
+
+// Builder 访问日志，请求体和响应体默认不打印，所有开关都可以在运行的时候修改，
+// 比如线上排查问题的时候临时打开一会儿
+type Builder struct {
+	l           logger.LoggerV1
+	reqBody     atomic.Bool
+	respBody    atomic.Bool
+	maxBodySize atomic.Int64
+	redactor    atomic.Pointer[redactor]
+	skipBody    atomic.Pointer[[]string]
+}
+
+func NewBuilder(l logger.LoggerV1) *Builder {
+	b := &Builder{l: l}
+	b.maxBodySize.Store(1024)
+	b.redactor.Store(newRedactor(DefaultRedactKeys))
+	b.skipBody.Store(&DefaultSkipBodyPaths)
+	return b
+}
+
+func (b *Builder) AllowReqBody(ok bool) *Builder {
+	b.reqBody.Store(ok)
+	return b
+}
+
+func (b *Builder) AllowRespBody(ok bool) *Builder {
+	b.respBody.Store(ok)
+	return b
+}
+
+// MaxBodySize 请求体和响应体最多打印多少字节，超过的部分截断
+func (b *Builder) MaxBodySize(size int) *Builder {
+	b.maxBodySize.Store(int64(size))
+	return b
+}
+
+// Redact 在 DefaultRedactKeys 之外还要脱敏的字段
+func (b *Builder) Redact(keys ...string) *Builder {
+	b.redactor.Store(newRedactor(append(append([]string{}, DefaultRedactKeys...), keys...)))
+	return b
+}
+
+// SkipBody 在 DefaultSkipBodyPaths 之外还不记录请求体和响应体的路径
+func (b *Builder) SkipBody(paths ...string) *Builder {
+	all := append(append([]string{}, DefaultSkipBodyPaths...), paths...)
+	b.skipBody.Store(&all)
+	return b
+}
+
+func (b *Builder) bodyAllowed(path string) bool {
+	for _, p := range *b.skipBody.Load() {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		maxSize := b.maxBodySize.Load()
+		bodyAllowed := b.bodyAllowed(ctx.Request.URL.Path)
+		var (
+			reqBody      string
+			reqTruncated bool
+		)
+		if bodyAllowed && b.reqBody.Load() && ctx.Request.Body != nil {
+			reqBody, reqTruncated = b.readReqBody(ctx, maxSize)
+		}
+		var resp *responseWriter
+		if bodyAllowed && b.respBody.Load() {
+			resp = &responseWriter{ResponseWriter: ctx.Writer, maxSize: maxSize}
+			ctx.Writer = resp
+		}
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		r := b.redactor.Load()
+		fields := []logger.Field{
+			logger.String("method", ctx.Request.Method),
+			logger.String("path", ctx.Request.URL.Path),
+			logger.Int("status", status),
+			logger.Int64("latency_ms", time.Since(start).Milliseconds()),
+			logger.String("ip", ctx.ClientIP()),
+		}
+		if route := ctx.FullPath(); route != "" {
+			fields = append(fields, logger.String("route", route))
+		}
+		if query := ctx.Request.URL.RawQuery; query != "" {
+			fields = append(fields, logger.String("query", r.redactForm(query)))
+		}
+		if reqBody != "" {
+			fields = append(fields, logger.String("req_body", r.redact(ctx.ContentType(), reqBody, reqTruncated)))
+		}
+		if resp != nil && resp.body.Len() > 0 {
+			fields = append(fields, logger.String("resp_body",
+				r.redact(ctx.Writer.Header().Get("Content-Type"), resp.body.String(), resp.truncated)))
+		}
+		if len(ctx.Errors) > 0 {
+			fields = append(fields, logger.String("errors", ctx.Errors.String()))
+		}
+		// 登录校验在后面的中间件里面，执行完之后 ctx 里面才有 uid
+		l := logger.FromContext(ctx.Request.Context(), b.l)
+		if status >= 500 {
+			l.Error("访问日志", fields...)
+			return
+		}
+		l.Info("访问日志", fields...)
+	}
+}
+
+// readReqBody 只读取前 maxSize 字节用来打印，剩下的原样留给后面的 handler，大文件上传不会整个读进内存
+func (b *Builder) readReqBody(ctx *gin.Context, maxSize int64) (string, bool) {
+	head, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxSize+1))
+	ctx.Request.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(head), ctx.Request.Body),
+		Closer: ctx.Request.Body,
+	}
+	if err != nil {
+		return "", false
+	}
+	if int64(len(head)) > maxSize {
+		return string(head[:maxSize]), true
+	}
+	return string(head), false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type responseWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	maxSize   int64
+	truncated bool
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseWriter) capture(data []byte) {
+	remain := w.maxSize - int64(w.body.Len())
+	if remain <= 0 {
+		w.truncated = w.truncated || len(data) > 0
+		return
+	}
+	if int64(len(data)) > remain {
+		data = data[:remain]
+		w.truncated = true
+	}
+	w.body.Write(data)
+}
+
+// redactor 用正则替换而不是解析 JSON，截断之后不完整的 JSON 也能脱敏。
+// 只替换字符串的值，响应里面数字的错误码 code 不受影响。
+// 字符串里面的 URL 查询参数也会脱敏，比如 otpauth://...?secret=xxx
+type redactor struct {
+	json  *regexp.Regexp
+	form  *regexp.Regexp
+	inURL *regexp.Regexp
+}
+
+func newRedactor(keys []string) *redactor {
+	quoted := make([]string, 0, len(keys))
+	for _, k := range keys {
+		quoted = append(quoted, regexp.QuoteMeta(k))
+	}
+	names := strings.Join(quoted, "|")
+	return &redactor{
+		// 结尾的引号可能因为截断没有了
+		json:  regexp.MustCompile(`(?i)("(?:` + names + `)"\s*:\s*)"(?:[^"\\]|\\.)*"?`),
+		form:  regexp.MustCompile(`(?i)((?:^|&)(?:` + names + `)=)[^&]*`),
+		inURL: regexp.MustCompile(`(?i)([?&](?:` + names + `)=)[^&"\\\s#]*`),
+	}
+}
+
+// redact 先脱敏再加截断标记，不然被截断的值会把标记一起替换掉
+func (r *redactor) redact(contentType, body string, truncated bool) string {
+	if strings.Contains(contentType, "application/x-www-form-urlencoded") {
+		body = r.redactForm(body)
+	} else {
+		body = r.inURL.ReplaceAllString(body, "${1}"+redacted)
+		body = r.json.ReplaceAllString(body, `${1}"`+redacted+`"`)
+	}
+	if truncated {
+		body += "...(截断)"
+	}
+	return body
+}
+
+func (r *redactor) redactForm(body string) string {
+	return r.form.ReplaceAllString(body, "${1}"+redacted)
+}
@@ -0,0 +1,249 @@
+package logger
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"webook/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordLogger 记录最后一条日志的级别和字段
+type recordLogger struct {
+	logger.NopLogger
+	mu     sync.Mutex
+	level  string
+	fields map[string]any
+}
+
+func (r *recordLogger) record(level string, fields []logger.Field) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.level = level
+	r.fields = make(map[string]any, len(fields))
+	for _, f := range fields {
+		r.fields[f.Key] = f.Value
+	}
+}
+
+func (r *recordLogger) Info(msg string, fields ...logger.Field) { r.record("info", fields) }
+
+func (r *recordLogger) Error(msg string, fields ...logger.Field) { r.record("error", fields) }
+
+func (r *recordLogger) With(fields ...logger.Field) logger.LoggerV1 { return r }
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name        string
+		builder     func(l logger.LoggerV1) *Builder
+		contentType string
+		reqBody     string
+		query       string
+		status      int
+		respBody    string
+
+		wantLevel  string
+		wantFields map[string]any
+		// 这些字段不应该出现
+		wantAbsent []string
+	}{
+		{
+			name:        "默认不打印请求体和响应体",
+			builder:     NewBuilder,
+			contentType: "application/json",
+			reqBody:     `{"email":"a@b.com","password":"hello"}`,
+			status:      http.StatusOK,
+			respBody:    `{"code":0,"msg":"ok"}`,
+			wantLevel:   "info",
+			wantFields: map[string]any{
+				"method": http.MethodPost,
+				"path":   "/users/login",
+				"route":  "/users/login",
+				"status": http.StatusOK,
+			},
+			wantAbsent: []string{"req_body", "resp_body"},
+		},
+		{
+			name: "打印请求体和响应体并脱敏",
+			builder: func(l logger.LoggerV1) *Builder {
+				return NewBuilder(l).AllowReqBody(true).AllowRespBody(true)
+			},
+			contentType: "application/json",
+			reqBody:     `{"email":"a@b.com","Password":"he\"llo","code":"123456"}`,
+			status:      http.StatusOK,
+			respBody:    `{"code":0,"msg":"ok","data":{"token":"abc"}}`,
+			wantLevel:   "info",
+			wantFields: map[string]any{
+				"req_body":  `{"email":"a@b.com","Password":"***","code":"***"}`,
+				"resp_body": `{"code":0,"msg":"ok","data":{"token":"***"}}`,
+			},
+		},
+		{
+			name: "字符串里面 URL 的查询参数也脱敏",
+			builder: func(l logger.LoggerV1) *Builder {
+				return NewBuilder(l).AllowRespBody(true)
+			},
+			contentType: "application/json",
+			status:      http.StatusOK,
+			respBody:    `{"data":{"uri":"otpauth://totp/webook?secret=ABC","link":"https://a.com/cb?code=xyz&state=1"}}`,
+			wantLevel:   "info",
+			wantFields: map[string]any{
+				"resp_body": `{"data":{"uri":"***","link":"https://a.com/cb?code=***&state=1"}}`,
+			},
+		},
+		{
+			name: "超过长度截断之后也脱敏",
+			builder: func(l logger.LoggerV1) *Builder {
+				return NewBuilder(l).AllowReqBody(true).MaxBodySize(35)
+			},
+			contentType: "application/json",
+			// 截断在 password 的值中间
+			reqBody:   `{"email":"a@b.com","password":"hello world"}`,
+			status:    http.StatusOK,
+			wantLevel: "info",
+			wantFields: map[string]any{
+				"req_body": `{"email":"a@b.com","password":"***"...(截断)`,
+			},
+		},
+		{
+			name: "表单和查询参数脱敏",
+			builder: func(l logger.LoggerV1) *Builder {
+				return NewBuilder(l).AllowReqBody(true).Redact("phone")
+			},
+			contentType: "application/x-www-form-urlencoded",
+			reqBody:     "phone=13800000000&nickname=tom&secret=xyz",
+			query:       "code=abc&state=1",
+			status:      http.StatusOK,
+			wantLevel:   "info",
+			wantFields: map[string]any{
+				"req_body": "phone=***&nickname=tom&secret=***",
+				"query":    "code=***&state=1",
+			},
+		},
+		{
+			name:        "5xx 打 error",
+			builder:     NewBuilder,
+			contentType: "application/json",
+			status:      http.StatusInternalServerError,
+			wantLevel:   "error",
+			wantFields: map[string]any{
+				"status": http.StatusInternalServerError,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			l := &recordLogger{}
+			server := gin.New()
+			server.Use(tc.builder(l).Build())
+			var gotBody string
+			server.POST("/users/login", func(ctx *gin.Context) {
+				// 打印请求体之后 handler 还要能读到完整的请求体
+				data, err := io.ReadAll(ctx.Request.Body)
+				require.NoError(t, err)
+				gotBody = string(data)
+				ctx.Data(tc.status, "application/json", []byte(tc.respBody))
+			})
+
+			target := "/users/login"
+			if tc.query != "" {
+				target += "?" + tc.query
+			}
+			req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(tc.reqBody))
+			req.Header.Set("Content-Type", tc.contentType)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.reqBody, gotBody)
+			assert.Equal(t, tc.respBody, recorder.Body.String())
+			assert.Equal(t, tc.wantLevel, l.level)
+			for k, v := range tc.wantFields {
+				assert.Equal(t, v, l.fields[k], k)
+			}
+			for _, k := range tc.wantAbsent {
+				assert.NotContains(t, l.fields, k)
+			}
+		})
+	}
+}
+
+func TestBuilder_Toggle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := &recordLogger{}
+	builder := NewBuilder(l)
+	server := gin.New()
+	server.Use(builder.Build())
+	server.POST("/test", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	send := func() {
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(`{"a":1}`))
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send()
+	assert.NotContains(t, l.fields, "req_body")
+
+	// 运行的时候打开，不需要重新构造中间件
+	builder.AllowReqBody(true).AllowRespBody(true)
+	send()
+	assert.Equal(t, `{"a":1}`, l.fields["req_body"])
+	assert.Equal(t, "ok", l.fields["resp_body"])
+
+	builder.AllowReqBody(false).AllowRespBody(false)
+	send()
+	assert.NotContains(t, l.fields, "req_body")
+	assert.NotContains(t, l.fields, "resp_body")
+}
+
+func TestBuilder_SkipBody(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		respBody string
+	}{
+		{
+			name:     "2FA 绑定返回的密钥",
+			path:     "/users/2fa/enroll",
+			respBody: `{"code":0,"data":{"uri":"otpauth://totp/webook:1?secret=JBSWY3DPEHPK3PXP&issuer=webook"}}`,
+		},
+		{
+			name:     "2FA 激活返回的恢复码",
+			path:     "/users/2fa/activate",
+			respBody: `{"code":0,"data":["a1b2c3d4","e5f6g7h8"]}`,
+		},
+		{
+			name:     "OAuth2 回调",
+			path:     "/oauth2/github/callback",
+			respBody: `{"code":0,"msg":"ok"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			l := &recordLogger{}
+			server := gin.New()
+			server.Use(NewBuilder(l).AllowReqBody(true).AllowRespBody(true).Build())
+			server.Any("/*path", func(ctx *gin.Context) {
+				ctx.Data(http.StatusOK, "application/json", []byte(tc.respBody))
+			})
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(`{"code":"123456"}`))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.respBody, recorder.Body.String())
+			assert.Equal(t, tc.path, l.fields["path"])
+			assert.NotContains(t, l.fields, "req_body")
+			assert.NotContains(t, l.fields, "resp_body")
+		})
+	}
+}
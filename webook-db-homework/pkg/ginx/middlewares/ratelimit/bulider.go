@@ -1,33 +1,27 @@
 package ratelimit
 
 import (
-	_ "embed"
 	"fmt"
-	"log"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	redis "github.com/redis/go-redis/v9"
+
+	"webook/pkg/logger"
+	limiter "webook/pkg/ratelimit/limiter"
 )
 
+// Builder 按照 IP 限流，具体的算法由 limiter 决定
 type Builder struct {
-	prefix   string
-	cmd      redis.Cmdable
-	interval time.Duration
-	// 阈值
-	rate int
+	prefix  string
+	limiter limiter.Limiter
+	l       logger.LoggerV1
 }
 
-//go:embed slide_window.lua
-var luaScript string
-
-func NewBuilder(cmd redis.Cmdable, interval time.Duration, rate int) *Builder {
+func NewBuilder(lim limiter.Limiter, l logger.LoggerV1) *Builder {
 	return &Builder{
-		cmd:      cmd,
-		prefix:   "ip-limiter",
-		interval: interval,
-		rate:     rate,
+		prefix:  "ip-limiter",
+		limiter: lim,
+		l:       l,
 	}
 }
 
@@ -40,14 +34,13 @@ func (b *Builder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		limited, err := b.limit(ctx)
 		if err != nil {
-			log.Println(err)
+			logger.FromContext(ctx.Request.Context(), b.l).Error("限流器出错", logger.Error(err))
 			// 这一步很有意思，就是如果这边出错了
-			// 要怎么办？
+			// 要怎么办？不想直接失败的话用 FallbackLimiter 降级到本地限流
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
 		if limited {
-			log.Println(err)
 			ctx.AbortWithStatus(http.StatusTooManyRequests)
 			return
 		}
@@ -57,6 +50,5 @@ func (b *Builder) Build() gin.HandlerFunc {
 
 func (b *Builder) limit(ctx *gin.Context) (bool, error) {
 	key := fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP())
-	return b.cmd.Eval(ctx, luaScript, []string{key},
-		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli()).Bool()
+	return b.limiter.Limit(ctx, key)
 }
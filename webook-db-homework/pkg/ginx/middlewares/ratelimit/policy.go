@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	limiter "webook/pkg/ratelimit/limiter"
+)
+
+// 限流对象
+const (
+	KeyByIP = "ip"
+	// KeyByUser 按照用户 id 限流，没有登录的请求按照 IP 限流
+	KeyByUser = "user"
+	// KeyByIPAndUser IP 和用户 id 组合在一起
+	KeyByIPAndUser = "ip_user"
+)
+
+// 限流算法
+const (
+	AlgorithmSlideWindow = "slide_window"
+	AlgorithmFixedWindow = "fixed_window"
+	AlgorithmTokenBucket = "token_bucket"
+)
+
+// Policy 一条限流策略。Path 按照 / 分段匹配，:id 这种分段匹配任意一段，
+// 最后一段是 * 的时候匹配剩下所有的分段；Method 为空或者 * 匹配所有方法
+type Policy struct {
+	Name      string        `json:"name" mapstructure:"name"`
+	Method    string        `json:"method" mapstructure:"method"`
+	Path      string        `json:"path" mapstructure:"path"`
+	KeyBy     string        `json:"key_by" mapstructure:"key_by"`
+	Algorithm string        `json:"algorithm" mapstructure:"algorithm"`
+	Interval  time.Duration `json:"interval" mapstructure:"interval"`
+	Rate      int           `json:"rate" mapstructure:"rate"`
+	// Capacity 令牌桶的容量，不设置的时候等于 Rate
+	Capacity int `json:"capacity" mapstructure:"capacity"`
+}
+
+func (p Policy) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("限流策略缺少名字, path: %s", p.Path)
+	}
+	if !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("限流策略 %s 的 path 必须以 / 开头", p.Name)
+	}
+	switch p.KeyBy {
+	case KeyByIP, KeyByUser, KeyByIPAndUser:
+	default:
+		return fmt.Errorf("限流策略 %s 的 key_by 不合法: %s", p.Name, p.KeyBy)
+	}
+	switch p.Algorithm {
+	case AlgorithmSlideWindow, AlgorithmFixedWindow, AlgorithmTokenBucket:
+	default:
+		return fmt.Errorf("限流策略 %s 的 algorithm 不合法: %s", p.Name, p.Algorithm)
+	}
+	if p.Interval <= 0 || p.Rate <= 0 || p.Capacity < 0 {
+		return fmt.Errorf("限流策略 %s 的阈值不合法", p.Name)
+	}
+	return nil
+}
+
+func (p Policy) match(method string, segments []string) bool {
+	if p.Method != "" && p.Method != "*" && !strings.EqualFold(p.Method, method) {
+		return false
+	}
+	patterns := splitPath(p.Path)
+	for i, pattern := range patterns {
+		if pattern == "*" && i == len(patterns)-1 {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if !strings.HasPrefix(pattern, ":") && pattern != segments[i] {
+			return false
+		}
+	}
+	return len(patterns) == len(segments)
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// LimiterFactory 根据策略创建限流器，默认用 Redis，所有节点共享计数
+type LimiterFactory func(p Policy) limiter.ResultLimiter
+
+type compiledPolicy struct {
+	Policy
+	limiter limiter.ResultLimiter
+}
+
+// policyTable 编译之后的策略表，按照顺序匹配，第一个匹配上的生效
+type policyTable struct {
+	policies []compiledPolicy
+}
+
+func validatePolicies(policies []Policy) error {
+	names := make(map[string]struct{}, len(policies))
+	for _, p := range policies {
+		if err := p.validate(); err != nil {
+			return err
+		}
+		// 名字是 Redis key 的一部分，重复的话两个策略会共用计数
+		if _, ok := names[p.Name]; ok {
+			return fmt.Errorf("限流策略 %s 重复", p.Name)
+		}
+		names[p.Name] = struct{}{}
+	}
+	return nil
+}
+
+func newPolicyTable(policies []Policy, factory LimiterFactory) (*policyTable, error) {
+	if err := validatePolicies(policies); err != nil {
+		return nil, err
+	}
+	t := &policyTable{policies: make([]compiledPolicy, 0, len(policies))}
+	for _, p := range policies {
+		if p.Capacity == 0 {
+			p.Capacity = p.Rate
+		}
+		t.policies = append(t.policies, compiledPolicy{Policy: p, limiter: factory(p)})
+	}
+	return t, nil
+}
+
+func (t *policyTable) match(method string, path string) (compiledPolicy, bool) {
+	segments := splitPath(path)
+	for _, p := range t.policies {
+		if p.match(method, segments) {
+			return p, true
+		}
+	}
+	return compiledPolicy{}, false
+}
@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	redis "github.com/redis/go-redis/v9"
+
+	"webook/pkg/logger"
+	limiter "webook/pkg/ratelimit/limiter"
+)
+
+// PolicyBuilder 按照策略表限流，不同的路由用不同的对象、算法和阈值。
+// 策略表保存在 PolicyStore 里面，Watch 定时检查，修改之后所有节点都会重新加载
+type PolicyBuilder struct {
+	prefix  string
+	store   PolicyStore
+	factory LimiterFactory
+	l       logger.LoggerV1
+	// uidFn 从请求里面拿到用户 id，没有登录返回 0
+	uidFn func(ctx *gin.Context) int64
+
+	table atomic.Pointer[policyTable]
+	// mutex 保护 current，避免并发 Reload 的时候用旧的策略覆盖新的
+	mutex   sync.Mutex
+	current []Policy
+}
+
+func NewPolicyBuilder(store PolicyStore, factory LimiterFactory, l logger.LoggerV1) *PolicyBuilder {
+	return &PolicyBuilder{
+		prefix:  "route-limiter",
+		store:   store,
+		factory: factory,
+		l:       l,
+		uidFn: func(ctx *gin.Context) int64 {
+			return ctx.GetInt64("userId")
+		},
+	}
+}
+
+func (b *PolicyBuilder) Prefix(prefix string) *PolicyBuilder {
+	b.prefix = prefix
+	return b
+}
+
+func (b *PolicyBuilder) UidFn(fn func(ctx *gin.Context) int64) *PolicyBuilder {
+	b.uidFn = fn
+	return b
+}
+
+// Reload 从 store 加载策略表，没有变化的时候不会重新创建限流器。
+// 新的策略表不合法的时候返回错误，继续使用原来的策略表
+func (b *PolicyBuilder) Reload(ctx context.Context) error {
+	set, err := b.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	return b.Apply(set)
+}
+
+// Apply 直接使用 set，不合法的时候返回错误，继续使用原来的策略表。
+// store 里面的策略表不能用的时候，启动阶段可以先用本地配置的策略表
+func (b *PolicyBuilder) Apply(set PolicySet) error {
+	policies := set.Policies
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.table.Load() != nil && slices.Equal(policies, b.current) {
+		return nil
+	}
+	table, err := newPolicyTable(policies, b.factory)
+	if err != nil {
+		return err
+	}
+	b.table.Store(table)
+	b.current = policies
+	b.l.Info("限流策略已加载", logger.Int64("version", set.Version), logger.Int("policies", len(policies)))
+	return nil
+}
+
+// Watch 每隔 interval 检查一次策略表，直到 ctx 结束
+func (b *PolicyBuilder) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Reload(ctx); err != nil {
+				b.l.Error("加载限流策略失败", logger.Error(err))
+			}
+		}
+	}
+}
+
+// Build 要放在登录校验的 middleware 后面，不然拿不到用户 id
+func (b *PolicyBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		table := b.table.Load()
+		if table == nil {
+			ctx.Next()
+			return
+		}
+		p, ok := table.match(ctx.Request.Method, ctx.Request.URL.Path)
+		if !ok {
+			ctx.Next()
+			return
+		}
+		res, err := p.limiter.LimitResult(ctx, b.key(ctx, p.Policy))
+		if err != nil {
+			b.l.Error("限流检查失败", logger.String("policy", p.Name), logger.Error(err))
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		header := ctx.Writer.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		header.Set("X-RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+		if res.Limited {
+			header.Set("Retry-After", strconv.Itoa(max(seconds(res.RetryAfter), 1)))
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func (b *PolicyBuilder) key(ctx *gin.Context, p Policy) string {
+	ip := ctx.ClientIP()
+	uid := b.uidFn(ctx)
+	var subject string
+	switch {
+	case p.KeyBy == KeyByIP:
+		subject = "ip:" + ip
+	case p.KeyBy == KeyByUser && uid > 0:
+		subject = "uid:" + strconv.FormatInt(uid, 10)
+	case p.KeyBy == KeyByUser:
+		// 没有登录只能按照 IP 限流
+		subject = "ip:" + ip
+	default:
+		subject = "ip:" + ip + ":uid:" + strconv.FormatInt(uid, 10)
+	}
+	return b.prefix + ":" + p.Name + ":" + subject
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// RedisLimiterFactory 用 Redis 限流，所有节点共享计数。
+// Redis 出错的时候降级到本地的同类限流器，本地没有滑动窗口，用固定窗口代替
+func RedisLimiterFactory(cmd redis.Cmdable, l logger.LoggerV1) LimiterFactory {
+	return func(p Policy) limiter.ResultLimiter {
+		var primary, fallback limiter.ResultLimiter
+		switch p.Algorithm {
+		case AlgorithmTokenBucket:
+			primary = limiter.NewRedisTokenBucketLimiter(cmd, p.Interval, p.Rate, p.Capacity)
+			fallback = limiter.NewLocalTokenBucketLimiter(p.Interval, p.Rate, p.Capacity)
+		case AlgorithmFixedWindow:
+			primary = limiter.NewRedisFixedWindowLimiter(cmd, p.Interval, p.Rate)
+			fallback = limiter.NewLocalFixedWindowLimiter(p.Interval, p.Rate)
+		default:
+			primary = limiter.NewRedisSlideWindowLimiter(cmd, p.Interval, p.Rate)
+			fallback = limiter.NewLocalFixedWindowLimiter(p.Interval, p.Rate)
+		}
+		return limiter.NewFallbackLimiter(primary, fallback, time.Second*10, l)
+	}
+}
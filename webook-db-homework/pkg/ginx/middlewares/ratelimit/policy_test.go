@@ -0,0 +1,229 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"webook/pkg/logger"
+	limiter "webook/pkg/ratelimit/limiter"
+)
+
+func TestPolicy_Match(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy Policy
+		method string
+		path   string
+		want   bool
+	}{
+		{
+			name:   "完全匹配",
+			policy: Policy{Method: http.MethodPost, Path: "/users/login"},
+			method: http.MethodPost,
+			path:   "/users/login",
+			want:   true,
+		},
+		{
+			name:   "方法不匹配",
+			policy: Policy{Method: http.MethodPost, Path: "/users/login"},
+			method: http.MethodGet,
+			path:   "/users/login",
+		},
+		{
+			name:   "参数分段",
+			policy: Policy{Path: "/articles/pub/:id"},
+			method: http.MethodGet,
+			path:   "/articles/pub/123",
+			want:   true,
+		},
+		{
+			name:   "参数分段不能匹配多段",
+			policy: Policy{Path: "/articles/pub/:id"},
+			method: http.MethodGet,
+			path:   "/articles/pub/123/like",
+		},
+		{
+			name:   "通配符",
+			policy: Policy{Method: "*", Path: "/articles/*"},
+			method: http.MethodPost,
+			path:   "/articles/pub/123/like",
+			want:   true,
+		},
+		{
+			name:   "通配符匹配所有路径",
+			policy: Policy{Path: "/*"},
+			method: http.MethodGet,
+			path:   "/",
+			want:   true,
+		},
+		{
+			name:   "路径更短",
+			policy: Policy{Path: "/users/login"},
+			method: http.MethodPost,
+			path:   "/users",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.policy.match(tc.method, splitPath(tc.path)))
+		})
+	}
+}
+
+// memoryPolicyStore 和 save_policy.lua 一样检查版本号
+type memoryPolicyStore struct {
+	set   PolicySet
+	saved bool
+}
+
+func (s *memoryPolicyStore) Save(ctx context.Context, set PolicySet) error {
+	if s.saved && (s.set.Version > set.Version ||
+		s.set.Version == set.Version && !slices.Equal(s.set.Policies, set.Policies)) {
+		return ErrStalePolicy
+	}
+	s.set, s.saved = set, true
+	return nil
+}
+
+func (s *memoryPolicyStore) Load(ctx context.Context) (PolicySet, error) {
+	return s.set, nil
+}
+
+func localFactory(p Policy) limiter.ResultLimiter {
+	if p.Algorithm == AlgorithmTokenBucket {
+		return limiter.NewLocalTokenBucketLimiter(p.Interval, p.Rate, p.Capacity)
+	}
+	return limiter.NewLocalFixedWindowLimiter(p.Interval, p.Rate)
+}
+
+func TestPolicyBuilder_Build(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryPolicyStore{}
+	require.NoError(t, store.Save(context.Background(), PolicySet{Version: 1, Policies: []Policy{
+		{Name: "login", Method: http.MethodPost, Path: "/users/login",
+			KeyBy: KeyByIP, Algorithm: AlgorithmFixedWindow, Interval: time.Minute, Rate: 1},
+		{Name: "publish", Method: http.MethodPost, Path: "/articles/publish",
+			KeyBy: KeyByUser, Algorithm: AlgorithmTokenBucket, Interval: time.Minute, Rate: 1, Capacity: 2},
+	}}))
+	b := NewPolicyBuilder(store, localFactory, logger.NewZapLogger(zap.NewNop()))
+	require.NoError(t, b.Reload(context.Background()))
+
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		if uid := ctx.GetHeader("uid"); uid != "" {
+			ctx.Set("userId", int64(len(uid)))
+		}
+	}, b.Build())
+	ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	server.POST("/users/login", ok)
+	server.POST("/articles/publish", ok)
+	server.GET("/articles/pub/1", ok)
+
+	do := func(method, path, uid string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("uid", uid)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	resp := do(http.MethodPost, "/users/login", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "1", resp.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", resp.Header().Get("X-RateLimit-Remaining"))
+	resp = do(http.MethodPost, "/users/login", "")
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.NotEmpty(t, resp.Header().Get("Retry-After"))
+
+	// 按用户限流，不同的用户互不影响
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/articles/publish", "a").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/articles/publish", "a").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, "/articles/publish", "a").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/articles/publish", "bb").Code)
+
+	// 没有匹配的策略不限流
+	resp = do(http.MethodGet, "/articles/pub/1", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Empty(t, resp.Header().Get("X-RateLimit-Limit"))
+
+	// 不合法的策略不会覆盖原来的
+	require.NoError(t, store.Save(context.Background(), PolicySet{Version: 2, Policies: []Policy{{Name: "bad", Path: "/*"}}}))
+	assert.Error(t, b.Reload(context.Background()))
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, "/users/login", "").Code)
+
+	// 修改策略之后重新加载
+	require.NoError(t, store.Save(context.Background(), PolicySet{Version: 3, Policies: []Policy{
+		{Name: "login", Method: http.MethodPost, Path: "/users/login",
+			KeyBy: KeyByIP, Algorithm: AlgorithmFixedWindow, Interval: time.Minute, Rate: 5},
+	}}))
+	require.NoError(t, b.Reload(context.Background()))
+	resp = do(http.MethodPost, "/users/login", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "5", resp.Header().Get("X-RateLimit-Limit"))
+
+	// 用旧配置文件启动的节点不能覆盖新的策略
+	assert.ErrorIs(t, store.Save(context.Background(), PolicySet{Version: 1, Policies: []Policy{
+		{Name: "login", Method: http.MethodPost, Path: "/users/login",
+			KeyBy: KeyByIP, Algorithm: AlgorithmFixedWindow, Interval: time.Minute, Rate: 1},
+	}}), ErrStalePolicy)
+	require.NoError(t, b.Reload(context.Background()))
+	assert.Equal(t, "5", do(http.MethodPost, "/users/login", "").Header().Get("X-RateLimit-Limit"))
+}
+
+func TestPolicySet_Validate(t *testing.T) {
+	valid := Policy{Name: "login", Path: "/users/login", KeyBy: KeyByIP,
+		Algorithm: AlgorithmFixedWindow, Interval: time.Minute, Rate: 1}
+	assert.NoError(t, PolicySet{Version: 1, Policies: []Policy{valid}}.Validate())
+
+	badAlgorithm := valid
+	badAlgorithm.Algorithm = "leaky_bucket"
+	zeroRate := valid
+	zeroRate.Rate = 0
+	badPath := valid
+	badPath.Path = "users/login"
+	for _, set := range []PolicySet{
+		{Version: 2, Policies: []Policy{badAlgorithm}},
+		{Version: 2, Policies: []Policy{zeroRate}},
+		{Version: 2, Policies: []Policy{badPath}},
+		{Version: 2, Policies: []Policy{valid, valid}},
+	} {
+		assert.Error(t, set.Validate())
+		// 不合法的策略表写不进 Redis，不会让整个集群加载失败
+		assert.Error(t, NewRedisPolicyStore(nil, "ratelimit:policy_set").Save(context.Background(), set))
+	}
+}
+
+func TestPolicyBuilder_Apply(t *testing.T) {
+	// store 里面是不合法的策略表，先用本地配置的
+	store := &memoryPolicyStore{}
+	require.NoError(t, store.Save(context.Background(), PolicySet{Version: 2, Policies: []Policy{{Name: "bad", Path: "/*"}}}))
+	b := NewPolicyBuilder(store, localFactory, logger.NewZapLogger(zap.NewNop()))
+	assert.Error(t, b.Reload(context.Background()))
+	require.NoError(t, b.Apply(PolicySet{Version: 1, Policies: []Policy{
+		{Name: "login", Path: "/users/login", KeyBy: KeyByIP,
+			Algorithm: AlgorithmFixedWindow, Interval: time.Minute, Rate: 1},
+	}}))
+
+	server := gin.New()
+	server.Use(b.Build())
+	server.POST("/users/login", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	do := func() int {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users/login", nil))
+		return recorder.Code
+	}
+	assert.Equal(t, http.StatusOK, do())
+	assert.Equal(t, http.StatusTooManyRequests, do())
+	// 加载失败的时候继续用原来的
+	assert.Error(t, b.Reload(context.Background()))
+	assert.Equal(t, http.StatusTooManyRequests, do())
+}
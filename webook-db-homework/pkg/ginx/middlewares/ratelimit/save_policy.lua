@@ -0,0 +1,19 @@
+-- KEYS[1] 策略表的 key，hash 里面保存 version 和 policies
+-- ARGV[1] 新的版本号，ARGV[2] 新的策略表 JSON
+-- 返回 1 保存成功，0 版本号比当前的低，或者版本号一样但是内容不一样
+local cur = redis.call('HGET', KEYS[1], 'version')
+if cur then
+    cur = tonumber(cur)
+    local version = tonumber(ARGV[1])
+    if cur > version then
+        return 0
+    end
+    if cur == version then
+        if redis.call('HGET', KEYS[1], 'policies') == ARGV[2] then
+            return 1
+        end
+        return 0
+    end
+end
+redis.call('HSET', KEYS[1], 'version', ARGV[1], 'policies', ARGV[2])
+return 1
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+//go:embed save_policy.lua
+var luaSavePolicy string
+
+// ErrStalePolicy 要保存的策略表版本号比已经保存的低，或者版本号一样但是内容不一样。
+// 用旧配置文件启动的节点不会覆盖整个集群的策略
+var ErrStalePolicy = errors.New("限流策略的版本号过旧")
+
+// PolicySet 带版本号的策略表，修改策略的时候版本号要加一
+type PolicySet struct {
+	Version  int64    `json:"version" mapstructure:"version"`
+	Policies []Policy `json:"policies" mapstructure:"policies"`
+}
+
+// Validate 检查每一条策略和策略名字是否重复，不合法的策略表不能发布，
+// 否则所有节点加载的时候都会失败
+func (s PolicySet) Validate() error {
+	return validatePolicies(s.Policies)
+}
+
+// PolicyStore 保存限流策略表，所有节点从同一个地方读取，保证策略一致
+type PolicyStore interface {
+	// Save 策略表不合法的时候返回错误，版本号比已经保存的低的时候返回 ErrStalePolicy，
+	// 版本号和内容都一样的时候什么也不做
+	Save(ctx context.Context, set PolicySet) error
+	// Load 还没有保存过策略的时候返回零值
+	Load(ctx context.Context) (PolicySet, error)
+}
+
+type RedisPolicyStore struct {
+	cmd redis.Cmdable
+	key string
+}
+
+func NewRedisPolicyStore(cmd redis.Cmdable, key string) *RedisPolicyStore {
+	return &RedisPolicyStore{
+		cmd: cmd,
+		key: key,
+	}
+}
+
+func (s *RedisPolicyStore) Save(ctx context.Context, set PolicySet) error {
+	if err := set.Validate(); err != nil {
+		return err
+	}
+	val, err := json.Marshal(set.Policies)
+	if err != nil {
+		return err
+	}
+	ok, err := s.cmd.Eval(ctx, luaSavePolicy, []string{s.key}, set.Version, val).Int()
+	if err != nil {
+		return err
+	}
+	if ok != 1 {
+		return ErrStalePolicy
+	}
+	return nil
+}
+
+func (s *RedisPolicyStore) Load(ctx context.Context) (PolicySet, error) {
+	vals, err := s.cmd.HMGet(ctx, s.key, "version", "policies").Result()
+	if err != nil {
+		return PolicySet{}, err
+	}
+	version, _ := vals[0].(string)
+	policies, _ := vals[1].(string)
+	if version == "" {
+		return PolicySet{}, nil
+	}
+	var set PolicySet
+	if set.Version, err = strconv.ParseInt(version, 10, 64); err != nil {
+		return PolicySet{}, err
+	}
+	err = json.Unmarshal([]byte(policies), &set.Policies)
+	return set, err
+}
@@ -0,0 +1,90 @@
+package trace
+
+import (
+	"regexp"
+	"strings"
+
+	"webook/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderRequestID = "X-Request-ID"
+	HeaderTraceID   = "X-Trace-ID"
+	// headerTraceparent W3C 的格式 00-{trace id}-{span id}-{flags}
+	headerTraceparent = "traceparent"
+)
+
+var (
+	// requestIDPattern 信任请求头的时候也只接受长度有限、不带特殊字符的 id，避免往日志里面塞任意内容
+	requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	// traceIDPattern W3C 规定 trace id 是 32 位小写十六进制，全 0 无效
+	traceIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+// Builder 把 request_id 和 trace_id 放进请求的 ctx，之后 logger.FromContext 打印的日志都带上；
+// 要让 service 拿到这些字段，gin.Engine 要打开 ContextWithFallback
+type Builder struct {
+	// trustHeader 网关或者上游服务传过来的 id 直接用，不可信的话每次都生成新的
+	trustHeader bool
+}
+
+// NewBuilder 默认不信任请求头，直接面对客户端的时候任何人都能伪造 id 去串别人的日志
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// TrustHeader 只有前面有会重新生成或者校验这些请求头的网关时才打开，格式不对的 id 仍然会重新生成
+func (b *Builder) TrustHeader(trust bool) *Builder {
+	b.trustHeader = trust
+	return b
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var requestID, traceID string
+		if b.trustHeader {
+			requestID = validRequestID(ctx.GetHeader(HeaderRequestID))
+			traceID = validTraceID(ctx.GetHeader(HeaderTraceID))
+			if traceID == "" {
+				traceID = parseTraceparent(ctx.GetHeader(headerTraceparent))
+			}
+		}
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		if traceID == "" {
+			traceID = strings.ReplaceAll(uuid.NewString(), "-", "")
+		}
+		c := logger.ContextWithRequestID(ctx.Request.Context(), requestID)
+		c = logger.ContextWithTraceID(c, traceID)
+		ctx.Request = ctx.Request.WithContext(c)
+		// 前端报错的时候带上这个 id 就能查到日志
+		ctx.Header(HeaderRequestID, requestID)
+		ctx.Next()
+	}
+}
+
+func parseTraceparent(val string) string {
+	parts := strings.Split(val, "-")
+	if len(parts) != 4 {
+		return ""
+	}
+	return validTraceID(parts[1])
+}
+
+func validRequestID(val string) string {
+	if !requestIDPattern.MatchString(val) {
+		return ""
+	}
+	return val
+}
+
+func validTraceID(val string) string {
+	if !traceIDPattern.MatchString(val) || strings.Trim(val, "0") == "" {
+		return ""
+	}
+	return val
+}
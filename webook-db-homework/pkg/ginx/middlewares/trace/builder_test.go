@@ -0,0 +1,117 @@
+package trace
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"webook/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name        string
+		builder     *Builder
+		header      map[string]string
+		wantRequest string
+		wantTrace   string
+		// notWantRequest、notWantTrace 请求头里面的 id 不能被采用
+		notWantRequest string
+		notWantTrace   string
+	}{
+		{
+			name:    "生成新的 id",
+			builder: NewBuilder(),
+		},
+		{
+			name:    "使用上游传过来的 id",
+			builder: NewBuilder().TrustHeader(true),
+			header: map[string]string{
+				HeaderRequestID: "req-1",
+				HeaderTraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			},
+			wantRequest: "req-1",
+			wantTrace:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:    "从 traceparent 里面取 trace id",
+			builder: NewBuilder().TrustHeader(true),
+			header: map[string]string{
+				headerTraceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			},
+			wantTrace: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:    "默认不信任请求头",
+			builder: NewBuilder(),
+			header: map[string]string{
+				HeaderRequestID: "req-1",
+				HeaderTraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			},
+			notWantTrace: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:    "信任请求头但是格式不对",
+			builder: NewBuilder().TrustHeader(true),
+			header: map[string]string{
+				HeaderRequestID:   "req-1\n伪造的日志",
+				HeaderTraceID:     "trace-1",
+				headerTraceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			},
+			notWantTrace: "trace-1",
+		},
+		{
+			name:    "信任请求头但是太长",
+			builder: NewBuilder().TrustHeader(true),
+			header: map[string]string{
+				HeaderRequestID: strings.Repeat("a", 65),
+			},
+			notWantRequest: strings.Repeat("a", 65),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			server := gin.New()
+			server.ContextWithFallback = true
+			server.Use(tc.builder.Build())
+			var requestID, traceID string
+			server.GET("/test", func(ctx *gin.Context) {
+				// service 拿到的是 *gin.Context，靠 ContextWithFallback 读到请求的 ctx
+				requestID = logger.RequestIDFromContext(ctx)
+				traceID = logger.TraceIDFromContext(ctx)
+			})
+			req, err := http.NewRequest(http.MethodGet, "/test", nil)
+			require.NoError(t, err)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.NotEmpty(t, requestID)
+			assert.NotEmpty(t, traceID)
+			assert.Equal(t, requestID, recorder.Header().Get(HeaderRequestID))
+			if tc.wantRequest != "" {
+				assert.Equal(t, tc.wantRequest, requestID)
+			} else {
+				assert.NotEqual(t, "req-1", requestID)
+			}
+			if tc.wantTrace != "" {
+				assert.Equal(t, tc.wantTrace, traceID)
+			}
+			if tc.notWantRequest != "" {
+				assert.NotEqual(t, tc.notWantRequest, requestID)
+			}
+			if tc.notWantTrace != "" {
+				assert.NotEqual(t, tc.notWantTrace, traceID)
+			}
+			assert.NotEqual(t, "00000000000000000000000000000000", traceID)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package balancer_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"webook/pkg/grpcx"
+	"webook/pkg/grpcx/balancer"
+	"webook/pkg/grpcx/registry"
+	"webook/pkg/grpcx/registry/memory"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const serviceName = "test"
+
+// instance 用健康检查服务当作被调用的服务，统计每个实例收到的请求
+type instance struct {
+	healthpb.UnimplementedHealthServer
+	addr   string
+	cnt    atomic.Int64
+	handle func(ctx context.Context) error
+}
+
+func (i *instance) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	i.cnt.Add(1)
+	if i.handle != nil {
+		if err := i.handle(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func startInstance(t *testing.T, r registry.Registry, weight int, handle func(ctx context.Context) error) *instance {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ins := &instance{addr: lis.Addr().String(), handle: handle}
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, ins)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+	require.NoError(t, r.Register(context.Background(), registry.ServiceInstance{
+		Name: serviceName, Addr: ins.addr, Weight: weight,
+	}))
+	return ins
+}
+
+func newClient(t *testing.T, r registry.Registry, cfg grpcx.ClientConfig) healthpb.HealthClient {
+	cfg.Target = "etcd:///service/" + serviceName
+	cc, err := grpcx.NewClientConn(cfg, r, logger.NewZapLogger(zap.NewNop()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cc.Close()
+	})
+	return healthpb.NewHealthClient(cc)
+}
+
+// warmUp 等所有实例都连上再开始统计，连接没有就绪的实例不会被选中
+func warmUp(t *testing.T, client healthpb.HealthClient, instances ...*instance) {
+	assert.Eventually(t, func() bool {
+		_, _ = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		for _, ins := range instances {
+			if ins.cnt.Load() == 0 {
+				return false
+			}
+		}
+		return true
+	}, time.Second*3, time.Millisecond)
+	for _, ins := range instances {
+		ins.cnt.Store(0)
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	r := memory.NewRegistry()
+	instances := []*instance{
+		startInstance(t, r, 100, nil),
+		startInstance(t, r, 200, nil),
+		startInstance(t, r, 300, nil),
+	}
+	client := newClient(t, r, grpcx.ClientConfig{Balancer: balancer.WeightedRoundRobin})
+	warmUp(t, client, instances...)
+
+	for i := 0; i < 600; i++ {
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+	}
+	// 平滑加权轮询每一轮都严格按权重分配
+	assert.InDelta(t, 100, instances[0].cnt.Load(), 1)
+	assert.InDelta(t, 200, instances[1].cnt.Load(), 1)
+	assert.InDelta(t, 300, instances[2].cnt.Load(), 1)
+
+	// 权重变了之后按新的权重分配
+	require.NoError(t, r.Register(context.Background(), registry.ServiceInstance{
+		Name: serviceName, Addr: instances[2].addr, Weight: 100,
+	}))
+	assert.Eventually(t, func() bool {
+		for _, ins := range instances {
+			ins.cnt.Store(0)
+		}
+		for i := 0; i < 400; i++ {
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			require.NoError(t, err)
+		}
+		return instances[0].cnt.Load() == 100 && instances[1].cnt.Load() == 200 &&
+			instances[2].cnt.Load() == 100
+	}, time.Second*3, time.Millisecond*10)
+}
+
+func TestLeastRequest(t *testing.T) {
+	r := memory.NewRegistry()
+	release := make(chan struct{})
+	var slowReady atomic.Bool
+	slow := startInstance(t, r, 1, func(ctx context.Context) error {
+		// 预热的请求直接返回，之后的请求一直卡住
+		if !slowReady.Load() {
+			return nil
+		}
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	})
+	fast := startInstance(t, r, 1, nil)
+	client := newClient(t, r, grpcx.ClientConfig{Balancer: balancer.LeastRequest})
+	warmUp(t, client, slow, fast)
+	slowReady.Store(true)
+	defer close(release)
+
+	for i := 0; i < 20; i++ {
+		fastCnt := fast.cnt.Load()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		}()
+		assert.Eventually(t, func() bool {
+			return slow.cnt.Load()+fast.cnt.Load() == int64(i+1)
+		}, time.Second, time.Millisecond)
+		// 落到快的实例上的请求等它结束，落到慢的实例上的一直卡着
+		if fast.cnt.Load() > fastCnt {
+			<-done
+		}
+	}
+	// 慢的实例手上一直有一个请求，之后的请求都落到快的实例上
+	assert.Equal(t, int64(1), slow.cnt.Load())
+	assert.Equal(t, int64(19), fast.cnt.Load())
+}
+
+func TestFailover(t *testing.T) {
+	testCases := []struct {
+		name     string
+		balancer string
+	}{
+		{name: "加权轮询", balancer: balancer.WeightedRoundRobin},
+		{name: "最少请求", balancer: balancer.LeastRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := memory.NewRegistry()
+			var broken atomic.Bool
+			bad := startInstance(t, r, 100, func(ctx context.Context) error {
+				if broken.Load() {
+					return status.Error(codes.Unavailable, "实例不可用")
+				}
+				return nil
+			})
+			good := startInstance(t, r, 100, nil)
+			client := newClient(t, r, grpcx.ClientConfig{
+				Balancer: tc.balancer,
+				Methods: []grpcx.MethodConfig{
+					{Service: healthpb.Health_ServiceDesc.ServiceName, MaxAttempts: 3,
+						InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond * 10},
+				},
+			})
+			warmUp(t, client, bad, good)
+			broken.Store(true)
+
+			for i := 0; i < 30; i++ {
+				_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+				require.NoError(t, err)
+			}
+			// 返回 Unavailable 之后重试换到别的实例，并且冷却期间不再选它
+			assert.LessOrEqual(t, bad.cnt.Load(), int64(1))
+			assert.Equal(t, int64(30), good.cnt.Load())
+		})
+	}
+}
+
+func TestMethodTimeout(t *testing.T) {
+	r := memory.NewRegistry()
+	var slow atomic.Bool
+	ins := startInstance(t, r, 1, func(ctx context.Context) error {
+		if slow.Load() {
+			<-ctx.Done()
+		}
+		return nil
+	})
+	client := newClient(t, r, grpcx.ClientConfig{
+		Methods: []grpcx.MethodConfig{
+			{Service: healthpb.Health_ServiceDesc.ServiceName, Method: "Check", Timeout: time.Millisecond * 50},
+		},
+	})
+	warmUp(t, client, ins)
+	slow.Store(true)
+
+	start := time.Now()
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Less(t, time.Since(start), time.Second)
+}
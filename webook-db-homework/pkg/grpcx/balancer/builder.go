@@ -0,0 +1,26 @@
+package balancer
+
+import (
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+)
+
+// builder 每个 ClientConn 创建一个新的 PickerBuilder。
+// 实例的状态（正在处理的请求数、当前权重、冷却时间）保存在 PickerBuilder 里面，
+// 实例上下线重新生成 Picker 的时候不会丢，不同的 ClientConn 之间也不会互相影响
+type builder struct {
+	name      string
+	newPicker func() base.PickerBuilder
+}
+
+func newBuilder(name string, newPicker func() base.PickerBuilder) balancer.Builder {
+	return &builder{name: name, newPicker: newPicker}
+}
+
+func (b *builder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	return base.NewBalancerBuilder(b.name, b.newPicker(), base.Config{HealthCheck: true}).Build(cc, opts)
+}
+
+func (b *builder) Name() string {
+	return b.name
+}
@@ -0,0 +1,46 @@
+package balancer
+
+import (
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// cooldown 实例返回 Unavailable 之后多久不再选它，重试的时候就会落到别的实例上
+var cooldown = time.Second
+
+// conn 两种负载均衡共用的连接状态
+type conn struct {
+	sc balancer.SubConn
+	// unavailableUntil 在这个时间之前尽量不选这个实例，UnixNano
+	unavailableUntil atomic.Int64
+}
+
+func (c *conn) available(now int64) bool {
+	return c.unavailableUntil.Load() <= now
+}
+
+// done 调用结束的时候检查是不是 Unavailable
+func (c *conn) done(info balancer.DoneInfo) {
+	if status.Code(info.Err) == codes.Unavailable {
+		c.unavailableUntil.Store(time.Now().Add(cooldown).UnixNano())
+	}
+}
+
+// candidates 过滤掉冷却中的实例，全部都在冷却的话就都可以选，总比直接失败好
+func candidates[T interface{ available(int64) bool }](conns []T) []T {
+	now := time.Now().UnixNano()
+	res := make([]T, 0, len(conns))
+	for _, c := range conns {
+		if c.available(now) {
+			res = append(res, c)
+		}
+	}
+	if len(res) == 0 {
+		return conns
+	}
+	return res
+}
@@ -0,0 +1,79 @@
+package balancer
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+)
+
+// LeastRequest 选正在处理的请求最少的实例，实例之间处理速度差别大的时候用
+const LeastRequest = "webook_least_request"
+
+func init() {
+	balancer.Register(newBuilder(LeastRequest, func() base.PickerBuilder {
+		return &lrPickerBuilder{conns: map[balancer.SubConn]*lrConn{}}
+	}))
+}
+
+// lrPickerBuilder conns 按 SubConn 保存状态，重新生成 Picker 的时候沿用，
+// 否则还没结束的请求数会清零，旧 Picker 上的请求结束之后也扣不到新的计数上
+type lrPickerBuilder struct {
+	mu    sync.Mutex
+	conns map[balancer.SubConn]*lrConn
+}
+
+func (b *lrPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sc := range b.conns {
+		if _, ok := info.ReadySCs[sc]; !ok {
+			delete(b.conns, sc)
+		}
+	}
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	conns := make([]*lrConn, 0, len(info.ReadySCs))
+	for sc := range info.ReadySCs {
+		c, ok := b.conns[sc]
+		if !ok {
+			c = &lrConn{conn: &conn{sc: sc}}
+			b.conns[sc] = c
+		}
+		conns = append(conns, c)
+	}
+	return &lrPicker{conns: conns}
+}
+
+type lrConn struct {
+	*conn
+	inflight atomic.Int64
+}
+
+type lrPicker struct {
+	conns []*lrConn
+	// next 请求数一样的时候轮流选，避免总是打到第一个
+	next atomic.Uint64
+}
+
+func (p *lrPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	conns := candidates(p.conns)
+	start := int(p.next.Add(1) % uint64(len(conns)))
+	var selected *lrConn
+	for i := range conns {
+		c := conns[(start+i)%len(conns)]
+		if selected == nil || c.inflight.Load() < selected.inflight.Load() {
+			selected = c
+		}
+	}
+	selected.inflight.Add(1)
+	return balancer.PickResult{
+		SubConn: selected.sc,
+		Done: func(info balancer.DoneInfo) {
+			selected.inflight.Add(-1)
+			selected.done(info)
+		},
+	}, nil
+}
@@ -0,0 +1,81 @@
+package balancer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+func buildInfo(scs ...*fakeSubConn) base.PickerBuildInfo {
+	info := base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{}}
+	for _, sc := range scs {
+		info.ReadySCs[sc] = base.SubConnInfo{}
+	}
+	return info
+}
+
+func pick(t *testing.T, p balancer.Picker) (*fakeSubConn, func(balancer.DoneInfo)) {
+	res, err := p.Pick(balancer.PickInfo{})
+	require.NoError(t, err)
+	return res.SubConn.(*fakeSubConn), res.Done
+}
+
+func TestLrPickerBuilder_Rebuild(t *testing.T) {
+	a, b, c := &fakeSubConn{name: "a"}, &fakeSubConn{name: "b"}, &fakeSubConn{name: "c"}
+	pb := &lrPickerBuilder{conns: map[balancer.SubConn]*lrConn{}}
+
+	// a 手上有一个请求还没结束的时候来了一个新实例
+	p := pb.Build(buildInfo(a))
+	_, doneA := pick(t, p)
+	p = pb.Build(buildInfo(a, b, c))
+	first, doneFirst := pick(t, p)
+	second, _ := pick(t, p)
+	assert.NotEqual(t, a, first)
+	assert.NotEqual(t, a, second)
+	assert.NotEqual(t, first, second)
+
+	// 旧 Picker 上的请求结束之后扣的是同一个计数
+	doneA(balancer.DoneInfo{})
+	got, _ := pick(t, p)
+	assert.Equal(t, a, got)
+
+	// 冷却也不会因为重新生成 Picker 丢掉
+	doneFirst(balancer.DoneInfo{Err: status.Error(codes.Unavailable, "实例不可用")})
+	p = pb.Build(buildInfo(a, b, c))
+	for i := 0; i < 10; i++ {
+		got, done := pick(t, p)
+		assert.NotEqual(t, first, got)
+		done(balancer.DoneInfo{})
+	}
+
+	// 下线的实例不再保存状态
+	pb.Build(buildInfo(b))
+	assert.Len(t, pb.conns, 1)
+}
+
+func TestWrrPickerBuilder_Rebuild(t *testing.T) {
+	scs := []*fakeSubConn{{name: "a"}, {name: "b"}, {name: "c"}}
+	pb := &wrrPickerBuilder{conns: map[balancer.SubConn]*weightedConn{}}
+	cnt := map[string]int{}
+	// 每次选之前都重新生成 Picker，当前权重还在的话仍然严格轮流选
+	for i := 0; i < 30; i++ {
+		got, _ := pick(t, pb.Build(buildInfo(scs...)))
+		cnt[got.name]++
+	}
+	assert.Equal(t, map[string]int{"a": 10, "b": 10, "c": 10}, cnt)
+
+	pb.Build(buildInfo(scs[0]))
+	assert.Len(t, pb.conns, 1)
+	pb.Build(base.PickerBuildInfo{})
+	assert.Empty(t, pb.conns)
+}
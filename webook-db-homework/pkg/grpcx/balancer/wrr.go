@@ -0,0 +1,86 @@
+package balancer
+
+import (
+	"sync"
+
+	"webook/pkg/grpcx/registry"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+)
+
+// WeightedRoundRobin 按注册中心里的权重做平滑加权轮询
+const WeightedRoundRobin = "webook_weighted_round_robin"
+
+func init() {
+	balancer.Register(newBuilder(WeightedRoundRobin, func() base.PickerBuilder {
+		return &wrrPickerBuilder{conns: map[balancer.SubConn]*weightedConn{}}
+	}))
+}
+
+// wrrPickerBuilder conns 按 SubConn 保存当前权重，重新生成 Picker 的时候沿用，
+// 否则实例每次上下线都从头开始轮询，权重大的实例会连续收到请求
+type wrrPickerBuilder struct {
+	// mu 保护 conns 和每个实例的 current，新旧 Picker 共用同一份状态
+	mu    sync.Mutex
+	conns map[balancer.SubConn]*weightedConn
+}
+
+func (b *wrrPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sc := range b.conns {
+		if _, ok := info.ReadySCs[sc]; !ok {
+			delete(b.conns, sc)
+		}
+	}
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	conns := make([]*weightedConn, 0, len(info.ReadySCs))
+	for sc, sci := range info.ReadySCs {
+		c, ok := b.conns[sc]
+		if !ok {
+			c = &weightedConn{conn: &conn{sc: sc}}
+			b.conns[sc] = c
+		}
+		// 注册中心里的权重可能改了
+		c.weight = registry.WeightFromAddress(sci.Address)
+		conns = append(conns, c)
+	}
+	return &wrrPicker{mu: &b.mu, conns: conns}
+}
+
+type weightedConn struct {
+	*conn
+	weight int
+	// current 平滑加权轮询的当前权重
+	current int
+}
+
+// wrrPicker 平滑加权轮询，权重 5、1、1 的三个实例选出来的顺序是 a a b a c a a，
+// 不会连续把请求都打到权重大的实例上
+type wrrPicker struct {
+	mu    *sync.Mutex
+	conns []*weightedConn
+}
+
+func (p *wrrPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	p.mu.Lock()
+	conns := candidates(p.conns)
+	total := 0
+	var selected *weightedConn
+	for _, c := range conns {
+		total += c.weight
+		c.current += c.weight
+		if selected == nil || c.current > selected.current {
+			selected = c
+		}
+	}
+	selected.current -= total
+	p.mu.Unlock()
+	return balancer.PickResult{
+		SubConn: selected.sc,
+		Done:    selected.done,
+	}, nil
+}
@@ -0,0 +1,128 @@
+package grpcx
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"webook/pkg/grpcx/balancer"
+	"webook/pkg/grpcx/registry"
+	"webook/pkg/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type ClientConfig struct {
+	// Target 比如 etcd:///service/interactive
+	Target string `mapstructure:"target"`
+	// Balancer 可选 balancer.WeightedRoundRobin、balancer.LeastRequest，默认加权轮询
+	Balancer string `mapstructure:"balancer"`
+	// HealthCheck 开启之后只把请求发给健康检查是 SERVING 的实例，服务端退出摘流量的时候依赖这个
+	HealthCheck bool           `mapstructure:"health_check"`
+	Methods     []MethodConfig `mapstructure:"methods"`
+}
+
+// MethodConfig 单个方法的超时和重试，Method 为空的时候对整个服务生效
+type MethodConfig struct {
+	Service string        `mapstructure:"service"`
+	Method  string        `mapstructure:"method"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxAttempts 包括第一次调用，小于 2 不重试
+	MaxAttempts    int           `mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	// RetryableCodes 默认只重试 UNAVAILABLE，也就是实例不可用，换一个实例重试；
+	// 其它错误服务端可能已经处理了，非幂等的方法不要加
+	RetryableCodes []string `mapstructure:"retryable_codes"`
+}
+
+// NewClientConn 通过注册中心发现服务，按 cfg 配置负载均衡、超时和重试
+func NewClientConn(cfg ClientConfig, r registry.Registry, l logger.LoggerV1,
+	opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	sc, err := cfg.ServiceConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]grpc.DialOption{
+		grpc.WithResolvers(registry.NewResolverBuilder(r, l)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(sc),
+	}, opts...)
+	return grpc.NewClient(cfg.Target, opts...)
+}
+
+// ServiceConfig 生成 gRPC 的 service config JSON
+func (cfg ClientConfig) ServiceConfig() (string, error) {
+	type name struct {
+		Service string `json:"service"`
+		Method  string `json:"method,omitempty"`
+	}
+	type retryPolicy struct {
+		MaxAttempts          int      `json:"maxAttempts"`
+		InitialBackoff       string   `json:"initialBackoff"`
+		MaxBackoff           string   `json:"maxBackoff"`
+		BackoffMultiplier    float64  `json:"backoffMultiplier"`
+		RetryableStatusCodes []string `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		Name        []name       `json:"name"`
+		Timeout     string       `json:"timeout,omitempty"`
+		RetryPolicy *retryPolicy `json:"retryPolicy,omitempty"`
+	}
+	type healthCheckConfig struct {
+		ServiceName string `json:"serviceName"`
+	}
+	type serviceConfig struct {
+		LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig"`
+		HealthCheckConfig   *healthCheckConfig    `json:"healthCheckConfig,omitempty"`
+		MethodConfig        []methodConfig        `json:"methodConfig,omitempty"`
+	}
+
+	lb := cfg.Balancer
+	if lb == "" {
+		lb = balancer.WeightedRoundRobin
+	}
+	res := serviceConfig{
+		LoadBalancingConfig: []map[string]struct{}{{lb: {}}},
+	}
+	if cfg.HealthCheck {
+		// 空的服务名表示检查整个服务
+		res.HealthCheckConfig = &healthCheckConfig{}
+	}
+	for _, m := range cfg.Methods {
+		mc := methodConfig{
+			Name: []name{{Service: m.Service, Method: m.Method}},
+		}
+		if m.Timeout > 0 {
+			mc.Timeout = duration(m.Timeout)
+		}
+		if m.MaxAttempts > 1 {
+			rp := &retryPolicy{
+				MaxAttempts:          m.MaxAttempts,
+				InitialBackoff:       duration(m.InitialBackoff),
+				MaxBackoff:           duration(m.MaxBackoff),
+				BackoffMultiplier:    2,
+				RetryableStatusCodes: m.RetryableCodes,
+			}
+			if m.InitialBackoff <= 0 {
+				rp.InitialBackoff = "0.1s"
+			}
+			if m.MaxBackoff <= 0 {
+				rp.MaxBackoff = "1s"
+			}
+			if len(rp.RetryableStatusCodes) == 0 {
+				rp.RetryableStatusCodes = []string{"UNAVAILABLE"}
+			}
+			mc.RetryPolicy = rp
+		}
+		res.MethodConfig = append(res.MethodConfig, mc)
+	}
+	val, err := json.Marshal(res)
+	return string(val), err
+}
+
+// duration service config 里的时间格式是秒数加 s，比如 0.5s
+func duration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
@@ -0,0 +1,60 @@
+package grpcx
+
+import (
+	"testing"
+	"time"
+
+	"webook/pkg/grpcx/balancer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestClientConfig_ServiceConfig(t *testing.T) {
+	testCases := []struct {
+		name string
+		cfg  ClientConfig
+		want string
+	}{
+		{
+			name: "默认加权轮询",
+			want: `{"loadBalancingConfig":[{"webook_weighted_round_robin":{}}]}`,
+		},
+		{
+			name: "超时和重试",
+			cfg: ClientConfig{
+				Balancer:    balancer.LeastRequest,
+				HealthCheck: true,
+				Methods: []MethodConfig{
+					{Service: "intr.v1.IntrService", MaxAttempts: 3},
+					{Service: "intr.v1.IntrService", Method: "Get", Timeout: time.Millisecond * 500,
+						MaxAttempts: 2, InitialBackoff: time.Millisecond * 50, MaxBackoff: time.Millisecond * 200,
+						RetryableCodes: []string{"UNAVAILABLE", "RESOURCE_EXHAUSTED"}},
+					// 只有超时，不重试
+					{Service: "intr.v1.IntrService", Method: "Like", Timeout: time.Second, MaxAttempts: 1},
+				},
+			},
+			want: `{"loadBalancingConfig":[{"webook_least_request":{}}],` +
+				`"healthCheckConfig":{"serviceName":""},` +
+				`"methodConfig":[` +
+				`{"name":[{"service":"intr.v1.IntrService"}],"retryPolicy":{"maxAttempts":3,"initialBackoff":"0.1s","maxBackoff":"1s","backoffMultiplier":2,"retryableStatusCodes":["UNAVAILABLE"]}},` +
+				`{"name":[{"service":"intr.v1.IntrService","method":"Get"}],"timeout":"0.5s","retryPolicy":{"maxAttempts":2,"initialBackoff":"0.05s","maxBackoff":"0.2s","backoffMultiplier":2,"retryableStatusCodes":["UNAVAILABLE","RESOURCE_EXHAUSTED"]}},` +
+				`{"name":[{"service":"intr.v1.IntrService","method":"Like"}],"timeout":"1s"}]}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sc, err := tc.cfg.ServiceConfig()
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.want, sc)
+			// gRPC 能解析才算对
+			cc, err := grpc.NewClient("passthrough:///localhost:8090",
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithDefaultServiceConfig(sc))
+			require.NoError(t, err)
+			_ = cc.Close()
+		})
+	}
+}
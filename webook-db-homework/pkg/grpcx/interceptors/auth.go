@@ -0,0 +1,156 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"webook/pkg/logger"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const authorizationKey = "authorization"
+
+type callerKey struct{}
+
+// CallerFromContext 鉴权通过之后可以拿到调用方的服务名
+func CallerFromContext(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(callerKey{}).(string)
+	return caller, ok
+}
+
+// ServiceClaims 服务之间调用的 token，Subject 是调用方的服务名
+type ServiceClaims struct {
+	jwt.RegisteredClaims
+}
+
+// AuthBuilder 服务之间用共享密钥签名的 token 鉴权，
+// 客户端每次调用都签一个短期的 token 放在 metadata 的 authorization 里面
+type AuthBuilder struct {
+	key []byte
+	// service 客户端自己的服务名，写在 token 的 Subject 里面
+	service    string
+	expiration time.Duration
+	// skip 不需要鉴权的方法或者服务，负载均衡的健康检查和 grpcurl 用到的反射不带 token
+	skip    map[string]struct{}
+	callers map[string]struct{}
+}
+
+func NewAuthBuilder(key []byte) *AuthBuilder {
+	return &AuthBuilder{
+		key:        key,
+		expiration: time.Minute,
+		skip: map[string]struct{}{
+			"grpc.health.v1.Health":                    {},
+			"grpc.reflection.v1.ServerReflection":      {},
+			"grpc.reflection.v1alpha.ServerReflection": {},
+		},
+		callers: map[string]struct{}{},
+	}
+}
+
+// Service 客户端要设置，服务端根据它判断是谁在调用
+func (b *AuthBuilder) Service(name string) *AuthBuilder {
+	b.service = name
+	return b
+}
+
+// Skip 参数是完整的方法名 /intr.v1.IntrService/Get 或者服务名 intr.v1.IntrService
+func (b *AuthBuilder) Skip(methods ...string) *AuthBuilder {
+	for _, m := range methods {
+		b.skip[m] = struct{}{}
+	}
+	return b
+}
+
+// Allow 只允许这些服务调用，不设置的话签名正确就可以调用
+func (b *AuthBuilder) Allow(callers ...string) *AuthBuilder {
+	for _, c := range callers {
+		b.callers[c] = struct{}{}
+	}
+	return b
+}
+
+func (b *AuthBuilder) Expiration(d time.Duration) *AuthBuilder {
+	b.expiration = d
+	return b
+}
+
+func (b *AuthBuilder) BuildServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if b.skipped(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		caller, err := b.verify(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// 只有鉴权通过的内部服务传过来的 uid 才能放进 ctx，不然谁都可以冒充别的用户打日志
+		if uid := userIDFromMetadata(ctx); uid > 0 {
+			ctx = logger.ContextWithUserID(ctx, uid)
+		}
+		return handler(context.WithValue(ctx, callerKey{}, caller), req)
+	}
+}
+
+func (b *AuthBuilder) BuildClient() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		token, err := b.sign()
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, authorizationKey, "Bearer "+token)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (b *AuthBuilder) sign() (string, error) {
+	now := time.Now()
+	claims := ServiceClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   b.service,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(b.expiration)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(b.key)
+}
+
+func (b *AuthBuilder) verify(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	vals := md.Get(authorizationKey)
+	if len(vals) == 0 {
+		return "", status.Error(codes.Unauthenticated, "缺少 token")
+	}
+	tokenStr := strings.TrimPrefix(vals[0], "Bearer ")
+	var claims ServiceClaims
+	token, err := jwt.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (any, error) {
+		return b.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid || claims.Subject == "" {
+		return "", status.Error(codes.Unauthenticated, "token 不合法")
+	}
+	if _, ok := b.callers[claims.Subject]; len(b.callers) > 0 && !ok {
+		return "", status.Errorf(codes.PermissionDenied, "%s 不允许调用", claims.Subject)
+	}
+	return claims.Subject, nil
+}
+
+func (b *AuthBuilder) skipped(fullMethod string) bool {
+	if _, ok := b.skip[fullMethod]; ok {
+		return true
+	}
+	// /intr.v1.IntrService/Get 的服务名是 intr.v1.IntrService
+	service := strings.TrimPrefix(fullMethod, "/")
+	if idx := strings.LastIndex(service, "/"); idx >= 0 {
+		service = service[:idx]
+	}
+	_, ok := b.skip[service]
+	return ok
+}
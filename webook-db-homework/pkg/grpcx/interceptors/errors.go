@@ -0,0 +1,64 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+
+	"webook/pkg/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorBuilder 把业务错误转成 gRPC 的错误码，没有登记的错误一律是 codes.Internal，
+// 原始的错误信息只打日志，不返回给调用方
+type ErrorBuilder struct {
+	l        logger.LoggerV1
+	mappings []errorMapping
+}
+
+type errorMapping struct {
+	err  error
+	code codes.Code
+}
+
+func NewErrorBuilder(l logger.LoggerV1) *ErrorBuilder {
+	return &ErrorBuilder{l: l}
+}
+
+// Map 用 errors.Is 判断，先登记的优先
+func (b *ErrorBuilder) Map(err error, code codes.Code) *ErrorBuilder {
+	b.mappings = append(b.mappings, errorMapping{err: err, code: code})
+	return b
+}
+
+func (b *ErrorBuilder) BuildServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		return resp, b.convert(info.FullMethod, err)
+	}
+}
+
+func (b *ErrorBuilder) convert(method string, err error) error {
+	// 已经是 gRPC 的错误了，说明是故意返回的
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	for _, m := range b.mappings {
+		if errors.Is(err, m.err) {
+			return status.Error(m.code, err.Error())
+		}
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	b.l.Error("gRPC 处理请求失败", logger.String("method", method), logger.Error(err))
+	return status.Error(codes.Internal, "系统错误")
+}
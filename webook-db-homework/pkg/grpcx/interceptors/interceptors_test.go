@@ -0,0 +1,416 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"webook/pkg/logger"
+	limitermocks "webook/pkg/ratelimit/limiter/mocks"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	info  = &grpc.UnaryServerInfo{FullMethod: "/intr.v1.IntrService/Get"}
+	nopL  = logger.NewZapLogger(zap.NewNop())
+	errNF = errors.New("记录不存在")
+)
+
+// recordLogger 记录每条日志的级别
+type recordLogger struct {
+	mu     sync.Mutex
+	levels []string
+	fields [][]logger.Field
+}
+
+func (r *recordLogger) record(level string, fields []logger.Field) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.levels = append(r.levels, level)
+	r.fields = append(r.fields, fields)
+}
+
+func (r *recordLogger) Info(msg string, fields ...logger.Field)  { r.record("info", fields) }
+func (r *recordLogger) Debug(msg string, fields ...logger.Field) { r.record("debug", fields) }
+func (r *recordLogger) Warn(msg string, fields ...logger.Field)  { r.record("warn", fields) }
+func (r *recordLogger) Error(msg string, fields ...logger.Field) { r.record("error", fields) }
+func (r *recordLogger) With(fields ...logger.Field) logger.LoggerV1 {
+	return &withLogger{parent: r, fields: fields}
+}
+
+// withLogger 把 With 的字段加在每条日志前面，记录到同一个 recordLogger
+type withLogger struct {
+	parent *recordLogger
+	fields []logger.Field
+}
+
+func (w *withLogger) merge(fields []logger.Field) []logger.Field {
+	return append(append([]logger.Field{}, w.fields...), fields...)
+}
+
+func (w *withLogger) Info(msg string, fields ...logger.Field) {
+	w.parent.record("info", w.merge(fields))
+}
+func (w *withLogger) Debug(msg string, fields ...logger.Field) {
+	w.parent.record("debug", w.merge(fields))
+}
+func (w *withLogger) Warn(msg string, fields ...logger.Field) {
+	w.parent.record("warn", w.merge(fields))
+}
+func (w *withLogger) Error(msg string, fields ...logger.Field) {
+	w.parent.record("error", w.merge(fields))
+}
+func (w *withLogger) With(fields ...logger.Field) logger.LoggerV1 {
+	return &withLogger{parent: w.parent, fields: w.merge(fields)}
+}
+
+func TestErrorBuilder(t *testing.T) {
+	interceptor := NewErrorBuilder(nopL).
+		Map(errNF, codes.NotFound).
+		BuildServer()
+	testCases := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+	}{
+		{name: "成功", code: codes.OK},
+		{name: "业务错误", err: fmt.Errorf("查询互动: %w", errNF), code: codes.NotFound, message: "查询互动: 记录不存在"},
+		{name: "已经是 gRPC 错误", err: status.Error(codes.InvalidArgument, "参数不对"), code: codes.InvalidArgument, message: "参数不对"},
+		{name: "超时", err: context.DeadlineExceeded, code: codes.DeadlineExceeded, message: context.DeadlineExceeded.Error()},
+		// 不认识的错误不把内部信息返回给调用方
+		{name: "未知错误", err: errors.New("数据库密码错误"), code: codes.Internal, message: "系统错误"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+				return nil, tc.err
+			})
+			st, _ := status.FromError(err)
+			assert.Equal(t, tc.code, st.Code())
+			if tc.err != nil {
+				assert.Equal(t, tc.message, st.Message())
+			}
+		})
+	}
+}
+
+func TestRecoveryBuilder(t *testing.T) {
+	l := &recordLogger{}
+	interceptor := NewRecoveryBuilder(l).BuildServer()
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		panic("空指针")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, []string{"error"}, l.levels)
+
+	resp, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+}
+
+func TestLogBuilder(t *testing.T) {
+	testCases := []struct {
+		name  string
+		err   error
+		sleep time.Duration
+		level string
+	}{
+		{name: "成功", level: "info"},
+		{name: "调用方的错误", err: status.Error(codes.NotFound, "不存在"), level: "warn"},
+		{name: "慢请求", sleep: time.Millisecond * 20, level: "warn"},
+		{name: "服务端的错误", err: status.Error(codes.Internal, "系统错误"), level: "error"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &recordLogger{}
+			interceptor := NewLogBuilder(l).SlowThreshold(time.Millisecond * 10).BuildServer()
+			_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+				time.Sleep(tc.sleep)
+				return nil, tc.err
+			})
+			require.Len(t, l.levels, 1)
+			assert.Equal(t, tc.level, l.levels[0])
+			assert.Contains(t, l.fields[0], logger.String("method", info.FullMethod))
+			assert.Contains(t, l.fields[0], logger.String("code", status.Code(tc.err).String()))
+		})
+	}
+}
+
+func TestMetricsBuilder(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	b := NewMetricsBuilder("webook", "interactive").Registerer(reg)
+	interceptor := b.BuildServer()
+	// 重复构建复用同一组指标，不会 panic
+	_ = b.BuildServer()
+	for _, err := range []error{nil, nil, status.Error(codes.NotFound, "不存在")} {
+		_, _ = interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			return nil, err
+		})
+	}
+	assert.Equal(t, 2, testutil.CollectAndCount(reg, "webook_interactive_grpc_server_handling_seconds"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(
+		b.newMetrics("server").errs.WithLabelValues(info.FullMethod, codes.NotFound.String())))
+}
+
+func TestRateLimitBuilder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) *limitermocks.MockLimiter
+		byCaller bool
+		ctx      context.Context
+		code     codes.Code
+	}{
+		{
+			name: "没有触发限流",
+			mock: func(ctrl *gomock.Controller) *limitermocks.MockLimiter {
+				lim := limitermocks.NewMockLimiter(ctrl)
+				lim.EXPECT().Limit(gomock.Any(), "grpc-limiter:/intr.v1.IntrService/Get").Return(false, nil)
+				return lim
+			},
+			ctx:  context.Background(),
+			code: codes.OK,
+		},
+		{
+			name: "触发限流",
+			mock: func(ctrl *gomock.Controller) *limitermocks.MockLimiter {
+				lim := limitermocks.NewMockLimiter(ctrl)
+				lim.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(true, nil)
+				return lim
+			},
+			ctx:  context.Background(),
+			code: codes.ResourceExhausted,
+		},
+		{
+			name: "限流器出错",
+			mock: func(ctrl *gomock.Controller) *limitermocks.MockLimiter {
+				lim := limitermocks.NewMockLimiter(ctrl)
+				lim.EXPECT().Limit(gomock.Any(), gomock.Any()).Return(false, errors.New("redis 崩了"))
+				return lim
+			},
+			ctx:  context.Background(),
+			code: codes.Internal,
+		},
+		{
+			name: "按调用方限流",
+			mock: func(ctrl *gomock.Controller) *limitermocks.MockLimiter {
+				lim := limitermocks.NewMockLimiter(ctrl)
+				lim.EXPECT().Limit(gomock.Any(), "grpc-limiter:webook:/intr.v1.IntrService/Get").Return(false, nil)
+				return lim
+			},
+			byCaller: true,
+			ctx:      context.WithValue(context.Background(), callerKey{}, "webook"),
+			code:     codes.OK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			b := NewRateLimitBuilder(tc.mock(ctrl), nopL)
+			if tc.byCaller {
+				b.ByCaller()
+			}
+			_, err := b.BuildServer()(tc.ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+				return nil, nil
+			})
+			assert.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
+
+func TestAuthBuilder(t *testing.T) {
+	key := []byte("service-secret")
+	// 客户端签名，把 metadata 交给服务端校验
+	call := func(client *AuthBuilder, server *AuthBuilder, method string) (string, error) {
+		var incoming context.Context
+		err := client.BuildClient()(context.Background(), method, nil, nil, nil,
+			func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				incoming = metadata.NewIncomingContext(context.Background(), md)
+				return nil
+			})
+		require.NoError(t, err)
+		var caller string
+		_, err = server.BuildServer()(incoming, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req any) (any, error) {
+				caller, _ = CallerFromContext(ctx)
+				return nil, nil
+			})
+		return caller, err
+	}
+
+	testCases := []struct {
+		name   string
+		client *AuthBuilder
+		server *AuthBuilder
+		method string
+		caller string
+		code   codes.Code
+	}{
+		{
+			name:   "签名正确",
+			client: NewAuthBuilder(key).Service("webook"),
+			server: NewAuthBuilder(key),
+			method: info.FullMethod,
+			caller: "webook",
+			code:   codes.OK,
+		},
+		{
+			name:   "密钥不对",
+			client: NewAuthBuilder([]byte("wrong")).Service("webook"),
+			server: NewAuthBuilder(key),
+			method: info.FullMethod,
+			code:   codes.Unauthenticated,
+		},
+		{
+			name:   "token 过期",
+			client: NewAuthBuilder(key).Service("webook").Expiration(-time.Second),
+			server: NewAuthBuilder(key),
+			method: info.FullMethod,
+			code:   codes.Unauthenticated,
+		},
+		{
+			name:   "不允许的调用方",
+			client: NewAuthBuilder(key).Service("crawler"),
+			server: NewAuthBuilder(key).Allow("webook"),
+			method: info.FullMethod,
+			code:   codes.PermissionDenied,
+		},
+		{
+			name:   "健康检查不需要 token",
+			client: NewAuthBuilder([]byte("wrong")),
+			server: NewAuthBuilder(key),
+			method: "/grpc.health.v1.Health/Check",
+			code:   codes.OK,
+		},
+		{
+			name:   "跳过整个服务",
+			client: NewAuthBuilder([]byte("wrong")),
+			server: NewAuthBuilder(key).Skip("intr.v1.IntrService"),
+			method: info.FullMethod,
+			code:   codes.OK,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			caller, err := call(tc.client, tc.server, tc.method)
+			assert.Equal(t, tc.code, status.Code(err))
+			assert.Equal(t, tc.caller, caller)
+		})
+	}
+
+	// 没有 token
+	_, err := NewAuthBuilder(key).BuildServer()(context.Background(), nil, info,
+		func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// 其它签名算法的 token 不接受
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, ServiceClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "webook",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(authorizationKey, "Bearer "+token))
+	_, err = NewAuthBuilder(key).BuildServer()(ctx, nil, info,
+		func(ctx context.Context, req any) (any, error) {
+			return nil, nil
+		})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestTraceBuilder(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	b := NewTraceBuilder().TrustMetadata(true)
+	ctx := logger.ContextWithRequestID(context.Background(), "req-1")
+	ctx = logger.ContextWithTraceID(ctx, traceID)
+	ctx = logger.ContextWithUserID(ctx, 123)
+	// 客户端把字段放进 metadata，服务端再放回 ctx
+	var incoming context.Context
+	err := b.BuildClient()(ctx, info.FullMethod, nil, nil, nil,
+		func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			incoming = metadata.NewIncomingContext(context.Background(), md)
+			return nil
+		})
+	require.NoError(t, err)
+	var fields []logger.Field
+	_, err = b.BuildServer()(incoming, nil, info, func(ctx context.Context, req any) (any, error) {
+		fields = logger.FieldsFromContext(ctx)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	// 没有经过鉴权，uid 不采用
+	assert.Equal(t, []logger.Field{logger.RequestID("req-1"), logger.TraceID(traceID)}, fields)
+
+	// 鉴权通过之后才放入 uid
+	key := []byte("interactive-service-secret")
+	err = NewAuthBuilder(key).Service("webook").BuildClient()(incoming, info.FullMethod, nil, nil, nil,
+		func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromIncomingContext(incoming)
+			out, _ := metadata.FromOutgoingContext(ctx)
+			incoming = metadata.NewIncomingContext(context.Background(), metadata.Join(md, out))
+			return nil
+		})
+	require.NoError(t, err)
+	_, err = NewAuthBuilder(key).BuildServer()(incoming, nil, info, func(ctx context.Context, req any) (any, error) {
+		uid, ok := logger.UserIDFromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, int64(123), uid)
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	// 调用方没有传的时候生成新的
+	_, err = b.BuildServer()(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		assert.NotEmpty(t, logger.RequestIDFromContext(ctx))
+		assert.NotEmpty(t, logger.TraceIDFromContext(ctx))
+		_, ok := logger.UserIDFromContext(ctx)
+		assert.False(t, ok)
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestTraceBuilder_Untrusted(t *testing.T) {
+	md := metadata.Pairs(requestIDKey, "req-1", traceIDKey, "4bf92f3577b34da6a3ce929d0e0e4736")
+	bad := metadata.Pairs(requestIDKey, "req\nforged=1", traceIDKey, "00000000000000000000000000000000")
+	testCases := []struct {
+		name string
+		b    *TraceBuilder
+		md   metadata.MD
+	}{
+		{name: "默认不信任 metadata", b: NewTraceBuilder(), md: md},
+		{name: "信任的时候格式不对也重新生成", b: NewTraceBuilder().TrustMetadata(true), md: bad},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tc.md)
+			_, err := tc.b.BuildServer()(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+				assert.NotEqual(t, tc.md.Get(requestIDKey)[0], logger.RequestIDFromContext(ctx))
+				assert.NotEqual(t, tc.md.Get(traceIDKey)[0], logger.TraceIDFromContext(ctx))
+				assert.Len(t, logger.TraceIDFromContext(ctx), 32)
+				return nil, nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"webook/pkg/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// LogBuilder 每次调用打一条访问日志
+type LogBuilder struct {
+	l logger.LoggerV1
+	// slowThreshold 超过这个耗时按 Warn 打印
+	slowThreshold time.Duration
+}
+
+func NewLogBuilder(l logger.LoggerV1) *LogBuilder {
+	return &LogBuilder{
+		l:             l,
+		slowThreshold: time.Second,
+	}
+}
+
+func (b *LogBuilder) SlowThreshold(d time.Duration) *LogBuilder {
+	b.slowThreshold = d
+	return b
+}
+
+func (b *LogBuilder) BuildServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		b.log(ctx, "gRPC 服务端", info.FullMethod, time.Since(start), err)
+		return resp, err
+	}
+}
+
+func (b *LogBuilder) BuildClient() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.log(ctx, "gRPC 客户端", method, time.Since(start), err)
+		return err
+	}
+}
+
+func (b *LogBuilder) log(ctx context.Context, msg, method string, duration time.Duration, err error) {
+	st, _ := status.FromError(err)
+	fields := []logger.Field{
+		logger.String("method", method),
+		logger.String("code", st.Code().String()),
+		logger.Int64("duration_ms", duration.Milliseconds()),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, logger.String("peer", p.Addr.String()))
+	}
+	if caller, ok := CallerFromContext(ctx); ok {
+		fields = append(fields, logger.String("caller", caller))
+	}
+	if err != nil {
+		fields = append(fields, logger.String("error", st.Message()))
+	}
+	// 带上 ctx 里面的 request_id、trace_id、uid
+	l := logger.FromContext(ctx, b.l)
+	switch {
+	case isServerError(st.Code()):
+		l.Error(msg, fields...)
+	case err != nil, duration > b.slowThreshold:
+		l.Warn(msg, fields...)
+	default:
+		l.Info(msg, fields...)
+	}
+}
+
+// isServerError 服务端自己的问题，需要报警的错误
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,101 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsBuilder 按方法和错误码统计耗时和错误数
+type MetricsBuilder struct {
+	Namespace string
+	Subsystem string
+	reg       prometheus.Registerer
+}
+
+func NewMetricsBuilder(namespace, subsystem string) *MetricsBuilder {
+	return &MetricsBuilder{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		reg:       prometheus.DefaultRegisterer,
+	}
+}
+
+// Registerer 测试的时候换成单独的 registry
+func (b *MetricsBuilder) Registerer(reg prometheus.Registerer) *MetricsBuilder {
+	b.reg = reg
+	return b
+}
+
+func (b *MetricsBuilder) BuildServer() grpc.UnaryServerInterceptor {
+	m := b.newMetrics("server")
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observe(info.FullMethod, time.Since(start), err)
+		return resp, err
+	}
+}
+
+func (b *MetricsBuilder) BuildClient() grpc.UnaryClientInterceptor {
+	m := b.newMetrics("client")
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.observe(method, time.Since(start), err)
+		return err
+	}
+}
+
+type metrics struct {
+	latency *prometheus.HistogramVec
+	errs    *prometheus.CounterVec
+}
+
+func (b *MetricsBuilder) newMetrics(side string) *metrics {
+	latency := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      "grpc_" + side + "_handling_seconds",
+		Help:      "gRPC 调用耗时",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"method", "code"})
+	errs := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      "grpc_" + side + "_errors_total",
+		Help:      "gRPC 调用失败次数",
+	}, []string{"method", "code"})
+	return &metrics{
+		latency: register(b.reg, latency),
+		errs:    register(b.reg, errs),
+	}
+}
+
+func (m *metrics) observe(method string, duration time.Duration, err error) {
+	code := status.Code(err).String()
+	m.latency.WithLabelValues(method, code).Observe(duration.Seconds())
+	if err != nil {
+		m.errs.WithLabelValues(method, code).Inc()
+	}
+}
+
+// register 同一个指标注册多次的时候复用已经注册的那个，比如一个进程里面有多个 gRPC 客户端
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	err := reg.Register(c)
+	if err == nil {
+		return c
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+	panic(err)
+}
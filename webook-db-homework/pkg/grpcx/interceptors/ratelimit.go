@@ -0,0 +1,78 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+
+	"webook/pkg/logger"
+	limiter "webook/pkg/ratelimit/limiter"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimitBuilder 按方法限流，具体的算法由 limiter 决定
+type RateLimitBuilder struct {
+	prefix   string
+	limiter  limiter.Limiter
+	l        logger.LoggerV1
+	byCaller bool
+}
+
+func NewRateLimitBuilder(lim limiter.Limiter, l logger.LoggerV1) *RateLimitBuilder {
+	return &RateLimitBuilder{
+		prefix:  "grpc-limiter",
+		limiter: lim,
+		l:       l,
+	}
+}
+
+func (b *RateLimitBuilder) Prefix(prefix string) *RateLimitBuilder {
+	b.prefix = prefix
+	return b
+}
+
+// ByCaller 每个调用方单独计数，一个调用方把额度用完了不影响别的调用方，要放在鉴权之后
+func (b *RateLimitBuilder) ByCaller() *RateLimitBuilder {
+	b.byCaller = true
+	return b
+}
+
+// BuildServer 被限流返回 codes.ResourceExhausted
+func (b *RateLimitBuilder) BuildServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := b.limit(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// BuildClient 在调用方这边限流，被限流的请求不会发出去
+func (b *RateLimitBuilder) BuildClient() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := b.limit(ctx, method); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (b *RateLimitBuilder) limit(ctx context.Context, method string) error {
+	key := fmt.Sprintf("%s:%s", b.prefix, method)
+	if caller, ok := CallerFromContext(ctx); ok && b.byCaller {
+		key = fmt.Sprintf("%s:%s:%s", b.prefix, caller, method)
+	}
+	limited, err := b.limiter.Limit(ctx, key)
+	if err != nil {
+		// 和 HTTP 的限流一样，不想直接失败的话用 FallbackLimiter 降级到本地限流
+		b.l.Error("限流器出错", logger.String("key", key), logger.Error(err))
+		return status.Error(codes.Internal, "系统错误")
+	}
+	if limited {
+		return status.Error(codes.ResourceExhausted, "请求太频繁")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"webook/pkg/logger"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type RecoveryBuilder struct {
+	l logger.LoggerV1
+}
+
+func NewRecoveryBuilder(l logger.LoggerV1) *RecoveryBuilder {
+	return &RecoveryBuilder{l: l}
+}
+
+// BuildServer panic 转成 codes.Internal，不把 panic 的内容返回给调用方
+func (b *RecoveryBuilder) BuildServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				b.l.Error("gRPC 处理请求 panic",
+					logger.String("method", info.FullMethod),
+					logger.String("panic", fmt.Sprint(r)),
+					logger.String("stack", string(debug.Stack())))
+				err = status.Error(codes.Internal, "系统错误")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
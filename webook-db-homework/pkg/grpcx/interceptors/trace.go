@@ -0,0 +1,119 @@
+package interceptors
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"webook/pkg/logger"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// 跨服务传递的 metadata，和 HTTP 的请求头保持一致
+const (
+	requestIDKey = "x-request-id"
+	traceIDKey   = "x-trace-id"
+	userIDKey    = "x-uid"
+)
+
+// 和 HTTP 入口的 trace 中间件一样，只接受长度有限、不带特殊字符的 request id 和 W3C 格式的 trace id
+var (
+	requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+	traceIDPattern   = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+// TraceBuilder 在服务之间传递 request_id、trace_id 和 uid，日志里面可以串起一次请求。
+// uid 只有通过了 AuthBuilder 鉴权的调用方传过来才会采用，见 AuthBuilder.BuildServer
+type TraceBuilder struct {
+	// trustMetadata 调用方传过来的 id 直接用，不可信的话每次都生成新的
+	trustMetadata bool
+}
+
+// NewTraceBuilder 默认不信任 metadata，端口暴露出去的时候任何人都能伪造 id 去串别人的日志
+func NewTraceBuilder() *TraceBuilder {
+	return &TraceBuilder{}
+}
+
+// TrustMetadata 只有调用方都是内部服务的时候才打开，格式不对的 id 仍然会重新生成
+func (b *TraceBuilder) TrustMetadata(trust bool) *TraceBuilder {
+	b.trustMetadata = trust
+	return b
+}
+
+// BuildServer 从 metadata 里面取出来放进 ctx，不信任或者调用方没有传的话生成新的，要放在日志拦截器前面
+func (b *TraceBuilder) BuildServer() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var requestID, traceID string
+		if b.trustMetadata {
+			md, _ := metadata.FromIncomingContext(ctx)
+			requestID = validRequestID(first(md, requestIDKey))
+			traceID = validTraceID(first(md, traceIDKey))
+		}
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		if traceID == "" {
+			traceID = strings.ReplaceAll(uuid.NewString(), "-", "")
+		}
+		ctx = logger.ContextWithRequestID(ctx, requestID)
+		ctx = logger.ContextWithTraceID(ctx, traceID)
+		return handler(ctx, req)
+	}
+}
+
+// BuildClient 把 ctx 里面的字段放进 metadata 传给下游
+func (b *TraceBuilder) BuildClient() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		kv := make([]string, 0, 6)
+		if id := logger.RequestIDFromContext(ctx); id != "" {
+			kv = append(kv, requestIDKey, id)
+		}
+		if id := logger.TraceIDFromContext(ctx); id != "" {
+			kv = append(kv, traceIDKey, id)
+		}
+		if uid, ok := logger.UserIDFromContext(ctx); ok {
+			kv = append(kv, userIDKey, strconv.FormatInt(uid, 10))
+		}
+		if len(kv) > 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, kv...)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// userIDFromMetadata 调用方没有传或者格式不对的时候返回 0
+func userIDFromMetadata(ctx context.Context) int64 {
+	md, _ := metadata.FromIncomingContext(ctx)
+	uid, err := strconv.ParseInt(first(md, userIDKey), 10, 64)
+	if err != nil || uid <= 0 {
+		return 0
+	}
+	return uid
+}
+
+func validRequestID(val string) string {
+	if !requestIDPattern.MatchString(val) {
+		return ""
+	}
+	return val
+}
+
+func validTraceID(val string) string {
+	if !traceIDPattern.MatchString(val) || strings.Trim(val, "0") == "" {
+		return ""
+	}
+	return val
+}
+
+func first(md metadata.MD, key string) string {
+	vals := md.Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
@@ -0,0 +1,203 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"webook/pkg/grpcx/registry"
+	"webook/pkg/logger"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type Registry struct {
+	client *clientv3.Client
+	l      logger.LoggerV1
+	// ttl 租约的过期时间，单位秒，进程挂了之后最多 ttl 秒实例就会被摘掉
+	ttl int64
+
+	mu sync.Mutex
+	// registrations key 是实例的 key，value 用来停止续约
+	registrations map[string]*registration
+}
+
+type registration struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	leaseID clientv3.LeaseID
+}
+
+// NewRegistry client 由调用者负责关闭
+func NewRegistry(client *clientv3.Client, l logger.LoggerV1, ttl int64) *Registry {
+	if ttl <= 0 {
+		ttl = 10
+	}
+	return &Registry{
+		client:        client,
+		l:             l,
+		ttl:           ttl,
+		registrations: make(map[string]*registration),
+	}
+}
+
+// Register 申请租约然后写入实例，之后在后台续约；
+// 租约丢了（比如 etcd 重启或者网络断开太久）会重新注册
+func (r *Registry) Register(ctx context.Context, si registry.ServiceInstance) error {
+	key := registry.InstanceKey(si)
+	val, err := json.Marshal(si)
+	if err != nil {
+		return err
+	}
+	leaseID, err := r.put(ctx, key, string(val))
+	if err != nil {
+		return err
+	}
+	kaCtx, cancel := context.WithCancel(context.Background())
+	reg := &registration{cancel: cancel, done: make(chan struct{}), leaseID: leaseID}
+	r.mu.Lock()
+	if old, ok := r.registrations[key]; ok {
+		old.cancel()
+	}
+	r.registrations[key] = reg
+	r.mu.Unlock()
+	go r.keepAlive(kaCtx, reg, key, string(val))
+	return nil
+}
+
+func (r *Registry) put(ctx context.Context, key, val string) (clientv3.LeaseID, error) {
+	lease, err := r.client.Grant(ctx, r.ttl)
+	if err != nil {
+		return 0, err
+	}
+	_, err = r.client.Put(ctx, key, val, clientv3.WithLease(lease.ID))
+	return lease.ID, err
+}
+
+func (r *Registry) keepAlive(ctx context.Context, reg *registration, key, val string) {
+	defer close(reg.done)
+	for {
+		ch, err := r.client.KeepAlive(ctx, reg.leaseID)
+		if err == nil {
+			// channel 被关闭说明续约失败或者 ctx 结束
+			for range ch {
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		r.l.Warn("续约失败，重新注册", logger.String("key", key), logger.Error(err))
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			pctx, cancel := context.WithTimeout(ctx, time.Second*3)
+			leaseID, err := r.put(pctx, key, val)
+			cancel()
+			if err == nil {
+				reg.leaseID = leaseID
+				break
+			}
+			r.l.Error("重新注册失败", logger.String("key", key), logger.Error(err))
+		}
+	}
+}
+
+// UnRegister 停止续约，删除实例并且撤销租约
+func (r *Registry) UnRegister(ctx context.Context, si registry.ServiceInstance) error {
+	key := registry.InstanceKey(si)
+	r.mu.Lock()
+	reg, ok := r.registrations[key]
+	delete(r.registrations, key)
+	r.mu.Unlock()
+	if ok {
+		reg.cancel()
+		<-reg.done
+	}
+	_, err := r.client.Delete(ctx, key)
+	if err != nil {
+		return err
+	}
+	if ok {
+		_, err = r.client.Revoke(ctx, reg.leaseID)
+	}
+	return err
+}
+
+func (r *Registry) ListServices(ctx context.Context, name string) ([]registry.ServiceInstance, error) {
+	resp, err := r.client.Get(ctx, registry.ServiceKey(name), clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	res := make([]registry.ServiceInstance, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var si registry.ServiceInstance
+		if err = json.Unmarshal(kv.Value, &si); err != nil {
+			r.l.Error("实例数据格式不对", logger.String("key", string(kv.Key)), logger.Error(err))
+			continue
+		}
+		res = append(res, si)
+	}
+	return res, nil
+}
+
+// Subscribe etcd 失去 leader 或者 compaction 之后 watch 会被取消，这时候关闭返回的 channel，
+// 由调用方重新订阅，registry 的 resolver 会退避之后重新订阅再拉全量
+func (r *Registry) Subscribe(ctx context.Context, name string) (<-chan registry.Event, error) {
+	ctx = clientv3.WithRequireLeader(ctx)
+	watchCh := r.client.Watch(ctx, registry.ServiceKey(name), clientv3.WithPrefix())
+	res := make(chan registry.Event)
+	go func() {
+		defer close(res)
+		for resp := range watchCh {
+			if err := resp.Err(); err != nil {
+				r.l.Error("监听服务变化失败", logger.String("name", name), logger.Error(err))
+				if resp.Canceled {
+					return
+				}
+				continue
+			}
+			for _, evt := range resp.Events {
+				e := registry.Event{Type: registry.EventTypeAdd}
+				if evt.Type == clientv3.EventTypeDelete {
+					// 删除事件没有 value，只能从 key 里面拿到地址
+					e.Type = registry.EventTypeDelete
+					e.Instance = registry.ServiceInstance{
+						Name: name,
+						Addr: string(evt.Kv.Key[len(registry.ServiceKey(name)):]),
+					}
+				} else if err := json.Unmarshal(evt.Kv.Value, &e.Instance); err != nil {
+					continue
+				}
+				select {
+				case res <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return res, nil
+}
+
+// Close 摘掉这个进程注册的所有实例
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	regs := r.registrations
+	r.registrations = make(map[string]*registration)
+	r.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	var err error
+	for _, reg := range regs {
+		reg.cancel()
+		<-reg.done
+		if _, er := r.client.Revoke(ctx, reg.leaseID); er != nil {
+			err = er
+		}
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package etcd
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"webook/pkg/grpcx/registry"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+	"go.uber.org/zap"
+)
+
+// startEtcd 启动一个嵌入的单节点 etcd，端口随机分配
+func startEtcd(t *testing.T) *clientv3.Client {
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	clientURL, _ := url.Parse("http://127.0.0.1:0")
+	peerURL, _ := url.Parse("http://127.0.0.1:0")
+	cfg.ListenClientUrls = []url.URL{*clientURL}
+	cfg.AdvertiseClientUrls = []url.URL{*clientURL}
+	cfg.ListenPeerUrls = []url.URL{*peerURL}
+	cfg.AdvertisePeerUrls = []url.URL{*peerURL}
+	cfg.InitialCluster = cfg.Name + "=" + peerURL.String()
+	e, err := embed.StartEtcd(cfg)
+	require.NoError(t, err)
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(time.Second * 10):
+		t.Fatal("etcd 启动超时")
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{e.Clients[0].Addr().String()},
+		DialTimeout: time.Second * 3,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestRegistry(t *testing.T) {
+	client := startEtcd(t)
+	r := NewRegistry(client, logger.NewZapLogger(zap.NewNop()), 2)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	events, err := r.Subscribe(ctx, "interactive")
+	require.NoError(t, err)
+
+	si := registry.ServiceInstance{Name: "interactive", Addr: "10.0.0.1:8090", Weight: 3,
+		Metadata: map[string]string{"zone": "a"}}
+	require.NoError(t, r.Register(ctx, si))
+	assert.Equal(t, registry.Event{Type: registry.EventTypeAdd, Instance: si}, <-events)
+	instances, err := r.ListServices(ctx, "interactive")
+	require.NoError(t, err)
+	assert.Equal(t, []registry.ServiceInstance{si}, instances)
+
+	// 超过 ttl 之后续约让实例一直存在
+	time.Sleep(time.Second * 4)
+	instances, err = r.ListServices(ctx, "interactive")
+	require.NoError(t, err)
+	assert.Equal(t, []registry.ServiceInstance{si}, instances)
+
+	// 租约丢了之后会重新注册
+	r.mu.Lock()
+	leaseID := r.registrations[registry.InstanceKey(si)].leaseID
+	r.mu.Unlock()
+	_, err = client.Revoke(ctx, leaseID)
+	require.NoError(t, err)
+	assert.Equal(t, registry.EventTypeDelete, (<-events).Type)
+	evt := <-events
+	assert.Equal(t, registry.Event{Type: registry.EventTypeAdd, Instance: si}, evt)
+
+	require.NoError(t, r.UnRegister(ctx, si))
+	assert.Equal(t, registry.Event{
+		Type:     registry.EventTypeDelete,
+		Instance: registry.ServiceInstance{Name: "interactive", Addr: si.Addr},
+	}, <-events)
+	instances, err = r.ListServices(ctx, "interactive")
+	require.NoError(t, err)
+	assert.Empty(t, instances)
+
+	// ctx 结束之后订阅关闭
+	subCtx, subCancel := context.WithCancel(ctx)
+	ch, err := r.Subscribe(subCtx, "interactive")
+	require.NoError(t, err)
+	subCancel()
+	assert.Eventually(t, func() bool {
+		_, ok := <-ch
+		return !ok
+	}, time.Second*3, time.Millisecond*10)
+}
+
+func TestRegistry_Close(t *testing.T) {
+	client := startEtcd(t)
+	r := NewRegistry(client, logger.NewZapLogger(zap.NewNop()), 10)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	si := registry.ServiceInstance{Name: "interactive", Addr: "10.0.0.1:8090"}
+	require.NoError(t, r.Register(ctx, si))
+	// Close 撤销租约，不用等 ttl 过期实例就被摘掉
+	require.NoError(t, r.Close())
+	instances, err := r.ListServices(ctx, "interactive")
+	require.NoError(t, err)
+	assert.Empty(t, instances)
+}
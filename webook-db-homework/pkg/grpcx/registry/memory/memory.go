@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"webook/pkg/grpcx/registry"
+)
+
+// Registry 进程内的注册中心，测试和本地开发用，不会续约也不会过期
+type Registry struct {
+	mu          sync.RWMutex
+	services    map[string]map[string]registry.ServiceInstance
+	subscribers map[string][]chan registry.Event
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		services:    make(map[string]map[string]registry.ServiceInstance),
+		subscribers: make(map[string][]chan registry.Event),
+	}
+}
+
+func (r *Registry) Register(ctx context.Context, si registry.ServiceInstance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	instances, ok := r.services[si.Name]
+	if !ok {
+		instances = make(map[string]registry.ServiceInstance)
+		r.services[si.Name] = instances
+	}
+	instances[si.Addr] = si
+	r.notify(registry.Event{Type: registry.EventTypeAdd, Instance: si})
+	return nil
+}
+
+func (r *Registry) UnRegister(ctx context.Context, si registry.ServiceInstance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.services[si.Name][si.Addr]; !ok {
+		return nil
+	}
+	delete(r.services[si.Name], si.Addr)
+	r.notify(registry.Event{Type: registry.EventTypeDelete, Instance: si})
+	return nil
+}
+
+func (r *Registry) ListServices(ctx context.Context, name string) ([]registry.ServiceInstance, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	res := make([]registry.ServiceInstance, 0, len(r.services[name]))
+	for _, si := range r.services[name] {
+		res = append(res, si)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Addr < res[j].Addr
+	})
+	return res, nil
+}
+
+func (r *Registry) Subscribe(ctx context.Context, name string) (<-chan registry.Event, error) {
+	ch := make(chan registry.Event, 16)
+	r.mu.Lock()
+	r.subscribers[name] = append(r.subscribers[name], ch)
+	r.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		subs := r.subscribers[name]
+		for i, c := range subs {
+			if c == ch {
+				r.subscribers[name] = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, subs := range r.subscribers {
+		for _, ch := range subs {
+			close(ch)
+		}
+		delete(r.subscribers, name)
+	}
+	return nil
+}
+
+// notify 订阅者处理不过来的时候丢掉事件，反正订阅者收到事件之后会拉全量
+func (r *Registry) notify(evt registry.Event) {
+	for _, ch := range r.subscribers[evt.Instance.Name] {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
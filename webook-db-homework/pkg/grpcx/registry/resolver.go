@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"context"
+	"time"
+
+	"webook/pkg/logger"
+
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+)
+
+// Scheme 客户端用 etcd:///service/interactive 发现服务
+const Scheme = "etcd"
+
+type weightKey struct{}
+
+type metadataKey struct{}
+
+// WeightFromAddress 读取注册中心里配置的权重，负载均衡用，没有配置的返回 1
+func WeightFromAddress(addr resolver.Address) int {
+	w, ok := addr.Attributes.Value(weightKey{}).(int)
+	if !ok || w <= 0 {
+		return 1
+	}
+	return w
+}
+
+// MetadataFromAddress 读取注册中心里的元数据
+func MetadataFromAddress(addr resolver.Address) map[string]string {
+	md, _ := addr.Attributes.Value(metadataKey{}).(metadataValue)
+	return md.m
+}
+
+// metadataValue attributes 比较值的时候要求可比较，map 不行，所以包一层
+type metadataValue struct {
+	m map[string]string
+}
+
+func (m metadataValue) Equal(o any) bool {
+	other, ok := o.(metadataValue)
+	if !ok || len(m.m) != len(other.m) {
+		return false
+	}
+	for k, v := range m.m {
+		if ov, ok := other.m[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+type ResolverBuilder struct {
+	r      Registry
+	l      logger.LoggerV1
+	scheme string
+	// retryInterval 订阅断开之后第一次重新订阅的等待时间，之后每次翻倍，最多 maxRetryInterval
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+}
+
+// NewResolverBuilder 通过 grpc.WithResolvers 传给客户端，不注册到全局
+func NewResolverBuilder(r Registry, l logger.LoggerV1) *ResolverBuilder {
+	return &ResolverBuilder{
+		r:                r,
+		l:                l,
+		scheme:           Scheme,
+		retryInterval:    time.Second,
+		maxRetryInterval: time.Second * 30,
+	}
+}
+
+// WithRetryInterval 订阅断开之后重新订阅的退避时间
+func (b *ResolverBuilder) WithRetryInterval(interval, maxInterval time.Duration) *ResolverBuilder {
+	b.retryInterval = interval
+	b.maxRetryInterval = maxInterval
+	return b
+}
+
+// WithScheme 同一个进程里面有多个注册中心的时候用来区分
+func (b *ResolverBuilder) WithScheme(scheme string) *ResolverBuilder {
+	b.scheme = scheme
+	return b
+}
+
+func (b *ResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn,
+	opts resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	res := &registryResolver{
+		name:   ServiceName(target.Endpoint()),
+		r:      b.r,
+		cc:     cc,
+		l:      b.l,
+		ctx:    ctx,
+		cancel: cancel,
+		now:    make(chan struct{}, 1),
+
+		retryInterval:    b.retryInterval,
+		maxRetryInterval: b.maxRetryInterval,
+	}
+	// 先订阅再拉全量，避免中间的变化丢掉
+	events, err := b.r.Subscribe(ctx, res.name)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.resolve()
+	go res.watch(events)
+	return res, nil
+}
+
+func (b *ResolverBuilder) Scheme() string {
+	return b.scheme
+}
+
+type registryResolver struct {
+	name   string
+	r      Registry
+	cc     resolver.ClientConn
+	l      logger.LoggerV1
+	ctx    context.Context
+	cancel context.CancelFunc
+	now    chan struct{}
+
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+}
+
+func (r *registryResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.now <- struct{}{}:
+	default:
+	}
+}
+
+func (r *registryResolver) Close() {
+	r.cancel()
+}
+
+// watch 收到变化之后重新拉全量，事件只用来触发。
+// 订阅可能被注册中心关闭（比如 etcd 切换 leader 或者 compaction），
+// 这时候退避之后重新订阅，成功之后拉一次全量补上断开期间的变化
+func (r *registryResolver) watch(events <-chan Event) {
+	backoff := r.retryInterval
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				r.l.Warn("服务变化的订阅断开，稍后重新订阅", logger.String("name", r.name))
+				// nil channel 永远不会就绪，重新订阅之前只响应 ResolveNow
+				events = nil
+				retry, backoff = time.After(backoff), r.nextBackoff(backoff)
+				continue
+			}
+			backoff = r.retryInterval
+		case <-retry:
+			retry = nil
+			ch, err := r.r.Subscribe(r.ctx, r.name)
+			if err != nil {
+				r.l.Error("重新订阅服务变化失败", logger.String("name", r.name), logger.Error(err))
+				retry, backoff = time.After(backoff), r.nextBackoff(backoff)
+				continue
+			}
+			events = ch
+		case <-r.now:
+		}
+		r.resolve()
+	}
+}
+
+func (r *registryResolver) nextBackoff(backoff time.Duration) time.Duration {
+	return min(backoff*2, r.maxRetryInterval)
+}
+
+func (r *registryResolver) resolve() {
+	ctx, cancel := context.WithTimeout(r.ctx, time.Second*3)
+	defer cancel()
+	instances, err := r.r.ListServices(ctx, r.name)
+	if err != nil {
+		r.l.Error("服务发现失败", logger.String("name", r.name), logger.Error(err))
+		r.cc.ReportError(err)
+		return
+	}
+	addrs := make([]resolver.Address, 0, len(instances))
+	for _, si := range instances {
+		// 权重放在 Attributes 而不是 BalancerAttributes，
+		// 权重变了地址就不一样，负载均衡会重新建连接拿到新的权重
+		addrs = append(addrs, resolver.Address{
+			Addr: si.Addr,
+			Attributes: attributes.New(metadataKey{}, metadataValue{m: si.Metadata}).
+				WithValue(weightKey{}, si.Weight),
+		})
+	}
+	err = r.cc.UpdateState(resolver.State{Addresses: addrs})
+	if err != nil {
+		r.l.Error("更新服务地址失败", logger.String("name", r.name), logger.Error(err))
+	}
+}
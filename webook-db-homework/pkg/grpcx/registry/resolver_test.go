@@ -0,0 +1,150 @@
+package registry_test
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"webook/pkg/grpcx/registry"
+	"webook/pkg/grpcx/registry/memory"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+type fakeClientConn struct {
+	resolver.ClientConn
+	mu     sync.Mutex
+	states []resolver.State
+}
+
+func (c *fakeClientConn) UpdateState(state resolver.State) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.states = append(c.states, state)
+	return nil
+}
+
+func (c *fakeClientConn) ReportError(error) {}
+
+func (c *fakeClientConn) ParseServiceConfig(string) *serviceconfig.ParseResult {
+	return nil
+}
+
+func (c *fakeClientConn) addrs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.states) == 0 {
+		return nil
+	}
+	res := make([]string, 0)
+	for _, a := range c.states[len(c.states)-1].Addresses {
+		res = append(res, a.Addr)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (c *fakeClientConn) last() resolver.State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.states[len(c.states)-1]
+}
+
+func TestResolver(t *testing.T) {
+	r := memory.NewRegistry()
+	ctx := context.Background()
+	si1 := registry.ServiceInstance{Name: "interactive", Addr: "10.0.0.1:8090", Weight: 3,
+		Metadata: map[string]string{"zone": "a"}}
+	si2 := registry.ServiceInstance{Name: "interactive", Addr: "10.0.0.2:8090"}
+	// 其它服务的变化不应该影响结果
+	other := registry.ServiceInstance{Name: "user", Addr: "10.0.0.3:8090"}
+	require.NoError(t, r.Register(ctx, si1))
+	require.NoError(t, r.Register(ctx, other))
+
+	cc := &fakeClientConn{}
+	b := registry.NewResolverBuilder(r, logger.NewZapLogger(zap.NewNop()))
+	assert.Equal(t, registry.Scheme, b.Scheme())
+	res, err := b.Build(resolver.Target{URL: *mustParse(t, "etcd:///service/interactive")}, cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer res.Close()
+
+	assert.Equal(t, []string{"10.0.0.1:8090"}, cc.addrs())
+	addr := cc.last().Addresses[0]
+	assert.Equal(t, 3, registry.WeightFromAddress(addr))
+	assert.Equal(t, map[string]string{"zone": "a"}, registry.MetadataFromAddress(addr))
+
+	require.NoError(t, r.Register(ctx, si2))
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"10.0.0.1:8090", "10.0.0.2:8090"}, cc.addrs())
+	}, time.Second, time.Millisecond*10)
+	for _, a := range cc.last().Addresses {
+		if a.Addr == si2.Addr {
+			// 没有配置权重的按 1 处理
+			assert.Equal(t, 1, registry.WeightFromAddress(a))
+		}
+	}
+
+	require.NoError(t, r.UnRegister(ctx, si1))
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"10.0.0.2:8090"}, cc.addrs())
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestResolver_Resubscribe(t *testing.T) {
+	r := memory.NewRegistry()
+	ctx := context.Background()
+	si1 := registry.ServiceInstance{Name: "interactive", Addr: "10.0.0.1:8090"}
+	si2 := registry.ServiceInstance{Name: "interactive", Addr: "10.0.0.2:8090"}
+	require.NoError(t, r.Register(ctx, si1))
+
+	cc := &fakeClientConn{}
+	b := registry.NewResolverBuilder(r, logger.NewZapLogger(zap.NewNop())).
+		WithRetryInterval(time.Millisecond*10, time.Millisecond*50)
+	res, err := b.Build(resolver.Target{URL: *mustParse(t, "etcd:///service/interactive")}, cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer res.Close()
+	assert.Equal(t, []string{"10.0.0.1:8090"}, cc.addrs())
+
+	// 模拟注册中心关闭了订阅，比如 etcd 切换 leader，断开期间的变化要在重新订阅之后补上
+	require.NoError(t, r.Close())
+	require.NoError(t, r.Register(ctx, si2))
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"10.0.0.1:8090", "10.0.0.2:8090"}, cc.addrs())
+	}, time.Second, time.Millisecond*10)
+
+	// 重新订阅之后还能继续收到变化
+	require.NoError(t, r.UnRegister(ctx, si1))
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"10.0.0.2:8090"}, cc.addrs())
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestServiceName(t *testing.T) {
+	testCases := []struct {
+		endpoint string
+		want     string
+	}{
+		{endpoint: "service/interactive", want: "interactive"},
+		{endpoint: "/service/interactive/", want: "interactive"},
+		{endpoint: "interactive", want: "interactive"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.endpoint, func(t *testing.T) {
+			assert.Equal(t, tc.want, registry.ServiceName(tc.endpoint))
+		})
+	}
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	return u
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// ServiceInstance 注册中心里的一个服务实例
+type ServiceInstance struct {
+	Name string `json:"name"`
+	Addr string `json:"addr"`
+	// Weight 负载均衡的权重，小于等于 0 的按 1 处理
+	Weight   int               `json:"weight"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type EventType int
+
+const (
+	EventTypeUnknown EventType = iota
+	EventTypeAdd
+	EventTypeDelete
+)
+
+type Event struct {
+	Type     EventType
+	Instance ServiceInstance
+}
+
+type Registry interface {
+	// Register 注册实例，实例存活期间由注册中心负责续约
+	Register(ctx context.Context, si ServiceInstance) error
+	UnRegister(ctx context.Context, si ServiceInstance) error
+	ListServices(ctx context.Context, name string) ([]ServiceInstance, error)
+	// Subscribe 监听服务的变化，ctx 结束之后 channel 会被关闭；
+	// 注册中心自己也可能关闭 channel（比如 etcd 失去 leader），调用方需要重新订阅
+	Subscribe(ctx context.Context, name string) (<-chan Event, error)
+	io.Closer
+}
+
+const keyPrefix = "/service/"
+
+// ServiceKey 服务在注册中心里的前缀，比如 /service/interactive/
+func ServiceKey(name string) string {
+	return keyPrefix + name + "/"
+}
+
+// InstanceKey 实例在注册中心里的 key，比如 /service/interactive/10.0.0.1:8090
+func InstanceKey(si ServiceInstance) string {
+	return ServiceKey(si.Name) + si.Addr
+}
+
+// ServiceName 从 etcd:///service/interactive 里面解析出 interactive
+func ServiceName(endpoint string) string {
+	endpoint = strings.Trim(endpoint, "/")
+	return strings.TrimPrefix(endpoint, strings.Trim(keyPrefix, "/")+"/")
+}
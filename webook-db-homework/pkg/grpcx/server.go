@@ -1,25 +1,264 @@
 package grpcx
 
 import (
+	"context"
+	"errors"
 	"net"
+	"sync"
+	"time"
+	"webook/pkg/grpcx/registry"
+	"webook/pkg/logger"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
+type Config struct {
+	Addr string `mapstructure:"addr"`
+	// ShutdownTimeout 优雅退出最多等多久，超时之后强制关闭
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// DrainDelay 退出的时候先把健康状态改成 NOT_SERVING，等负载均衡摘掉流量再关闭
+	DrainDelay time.Duration `mapstructure:"drain_delay"`
+	// HealthInterval 多久检查一次依赖
+	HealthInterval time.Duration `mapstructure:"health_interval"`
+	// Reflection 开启之后 grpcurl 之类的工具可以直接调用
+	Reflection bool `mapstructure:"reflection"`
+
+	// Name 注册到注册中心的服务名，客户端用 etcd:///service/{Name} 发现
+	Name string `mapstructure:"name"`
+	// AdvertiseAddr 注册到注册中心的地址，不配置的话用监听地址，监听地址没有 IP 就用本机 IP
+	AdvertiseAddr string            `mapstructure:"advertise_addr"`
+	Weight        int               `mapstructure:"weight"`
+	Metadata      map[string]string `mapstructure:"metadata"`
+}
+
+var DefaultConfig = Config{
+	ShutdownTimeout: time.Second * 10,
+	HealthInterval:  time.Second * 5,
+	Reflection:      true,
+	Weight:          100,
+}
+
+// HealthChecker 依赖检查，比如数据库、Redis、Kafka，任何一个失败服务就是 NOT_SERVING
+type HealthChecker struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
 type Server struct {
 	*grpc.Server
 	Addr string
+
+	cfg      Config
+	l        logger.LoggerV1
+	health   *health.Server
+	checkers []HealthChecker
+
+	registry registry.Registry
+	// mu 保护 instance，注册和注销可能在不同的 goroutine 里面
+	mu       sync.Mutex
+	instance registry.ServiceInstance
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// NewServer 注册健康检查服务和反射服务，interceptors 按顺序组成调用链
+func NewServer(cfg Config, l logger.LoggerV1, interceptors ...grpc.UnaryServerInterceptor) *Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(server, hs)
+	if cfg.Reflection {
+		reflection.Register(server)
+	}
+	// 依赖检查通过之前不接收流量
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return &Server{
+		Server:  server,
+		Addr:    cfg.Addr,
+		cfg:     cfg,
+		l:       l,
+		health:  hs,
+		stopped: make(chan struct{}),
+	}
 }
 
-func NewServer(server *grpc.Server, addr string) *Server {
-	return &Server{Server: server, Addr: addr}
+// AddChecker 要在 Serve 之前调用
+func (s *Server) AddChecker(checkers ...HealthChecker) {
+	s.checkers = append(s.checkers, checkers...)
 }
 
+// SetRegistry 要在 Serve 之前调用，启动之后注册，退出的时候第一步就是注销
+func (s *Server) SetRegistry(r registry.Registry) {
+	s.registry = r
+}
+
+// Serve 阻塞直到服务器关闭
 func (s *Server) Serve() error {
 	l, err := net.Listen("tcp", s.Addr)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	return s.ServeListener(l)
+}
+
+func (s *Server) ServeListener(l net.Listener) error {
+	go s.watchHealth()
+	if s.registry != nil {
+		err := s.register(l.Addr())
+		if err != nil {
+			_ = l.Close()
+			return err
+		}
+	}
+	return s.Server.Serve(l)
+}
+
+func (s *Server) register(addr net.Addr) error {
+	advertise := s.cfg.AdvertiseAddr
+	if advertise == "" {
+		advertise = advertiseAddr(addr)
+	}
+	si := registry.ServiceInstance{
+		Name:     s.cfg.Name,
+		Addr:     advertise,
+		Weight:   s.cfg.Weight,
+		Metadata: s.cfg.Metadata,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err := s.registry.Register(ctx, si)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.instance = si
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Server) unregister() {
+	s.mu.Lock()
+	si := s.instance
+	s.mu.Unlock()
+	if s.registry == nil || si.Addr == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err := s.registry.UnRegister(ctx, si)
+	if err != nil {
+		s.l.Error("注销服务失败", logger.String("addr", si.Addr), logger.Error(err))
+	}
+}
+
+// advertiseAddr 监听的是 :8090 或者 0.0.0.0:8090 的时候换成本机的 IP
+func advertiseAddr(addr net.Addr) string {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && !ip.IsUnspecified() {
+		return addr.String()
+	}
+	return net.JoinHostPort(localIP(), port)
+}
+
+func localIP() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "127.0.0.1"
+	}
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+	}
+	return "127.0.0.1"
+}
+
+// Run 启动服务器，ctx 结束的时候优雅退出，一般传 signal.NotifyContext 返回的 ctx。
+// 监听、注册失败或者 Serve 中途出错都返回错误，调用方应该以非零状态码退出
+func (s *Server) Run(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Serve()
+	}()
+	select {
+	case err := <-errCh:
+		// 没有收到退出信号 Serve 就返回了，注销并且停掉健康检查
+		s.Shutdown()
+		if err == nil {
+			err = errors.New("gRPC 服务意外退出")
+		}
+		return err
+	case <-ctx.Done():
+	}
+	s.Shutdown()
+	return <-errCh
+}
+
+// Shutdown 先从注册中心注销，把健康状态改成 NOT_SERVING，等 DrainDelay 让负载均衡摘掉流量，
+// 再 GracefulStop，超过 ShutdownTimeout 还没有结束就强制关闭
+func (s *Server) Shutdown() {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+		s.unregister()
+		// Shutdown 之后的状态更新都会被忽略
+		s.health.Shutdown()
+		if s.cfg.DrainDelay > 0 {
+			time.Sleep(s.cfg.DrainDelay)
+		}
+		done := make(chan struct{})
+		go func() {
+			s.Server.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(s.cfg.ShutdownTimeout):
+			s.l.Warn("gRPC 优雅退出超时，强制关闭")
+			s.Server.Stop()
+			<-done
+		}
+	})
+}
+
+func (s *Server) watchHealth() {
+	interval := s.cfg.HealthInterval
+	if interval <= 0 {
+		interval = DefaultConfig.HealthInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.checkHealth()
+		select {
+		case <-s.stopped:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) checkHealth() {
+	status := healthpb.HealthCheckResponse_SERVING
+	for _, c := range s.checkers {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := c.Check(ctx)
+		cancel()
+		if err != nil {
+			s.l.Error("依赖检查失败", logger.String("name", c.Name), logger.Error(err))
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	// 整体状态和每个服务的状态都设置，客户端可以按服务名检查
+	s.health.SetServingStatus("", status)
+	for name := range s.Server.GetServiceInfo() {
+		s.health.SetServingStatus(name, status)
 	}
-	err = s.Server.Serve(l)
-	return err
 }
@@ -0,0 +1,188 @@
+package grpcx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"webook/pkg/grpcx/registry"
+	"webook/pkg/grpcx/registry/memory"
+	"webook/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func startServer(t *testing.T, cfg Config, checkers ...HealthChecker) (*Server, *grpc.ClientConn) {
+	lis := bufconn.Listen(1024 * 1024)
+	s := NewServer(cfg, logger.NewZapLogger(zap.NewNop()))
+	s.AddChecker(checkers...)
+	go func() {
+		_ = s.ServeListener(lis)
+	}()
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = cc.Close()
+	})
+	return s, cc
+}
+
+func TestServer_Health(t *testing.T) {
+	var healthy atomic.Bool
+	cfg := DefaultConfig
+	cfg.HealthInterval = time.Millisecond * 10
+	s, cc := startServer(t, cfg, HealthChecker{
+		Name: "db",
+		Check: func(ctx context.Context) error {
+			if healthy.Load() {
+				return nil
+			}
+			return errors.New("数据库连不上")
+		},
+	})
+	defer s.Shutdown()
+	client := healthpb.NewHealthClient(cc)
+	status := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		return resp.Status
+	}
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status())
+	// 依赖恢复之后开始接收流量
+	healthy.Store(true)
+	assert.Eventually(t, func() bool {
+		return status() == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, time.Millisecond*10)
+	// 按服务名检查也可以
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: healthpb.Health_ServiceDesc.ServiceName})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	assert.Contains(t, s.GetServiceInfo(), "grpc.reflection.v1.ServerReflection")
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.HealthInterval = time.Millisecond * 10
+	cfg.ShutdownTimeout = time.Millisecond * 100
+	s, cc := startServer(t, cfg)
+	client := healthpb.NewHealthClient(cc)
+	assert.Eventually(t, func() bool {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		return err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, time.Millisecond*10)
+
+	// Watch 是一个不会自己结束的流，GracefulStop 会一直等它
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		s.Shutdown()
+		close(done)
+	}()
+	// 关闭之前先通知客户端不要再发请求过来
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("超时之后没有强制关闭")
+	}
+	assert.GreaterOrEqual(t, time.Since(start), cfg.ShutdownTimeout)
+}
+
+func TestServer_Registry(t *testing.T) {
+	r := memory.NewRegistry()
+	l := logger.NewZapLogger(zap.NewNop())
+	startInstance := func(weight int) *Server {
+		cfg := DefaultConfig
+		cfg.Name = "interactive"
+		cfg.Addr = "127.0.0.1:0"
+		cfg.Weight = weight
+		cfg.Metadata = map[string]string{"zone": "a"}
+		cfg.HealthInterval = time.Millisecond * 10
+		s := NewServer(cfg, l)
+		s.SetRegistry(r)
+		lis, err := net.Listen("tcp", cfg.Addr)
+		require.NoError(t, err)
+		go func() {
+			_ = s.ServeListener(lis)
+		}()
+		return s
+	}
+	s1, s2 := startInstance(100), startInstance(200)
+	defer s2.Shutdown()
+	assert.Eventually(t, func() bool {
+		instances, err := r.ListServices(context.Background(), "interactive")
+		return err == nil && len(instances) == 2
+	}, time.Second, time.Millisecond*10)
+	instances, err := r.ListServices(context.Background(), "interactive")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []int{100, 200}, []int{instances[0].Weight, instances[1].Weight})
+	assert.Equal(t, map[string]string{"zone": "a"}, instances[0].Metadata)
+
+	cc, err := grpc.NewClient("etcd:///service/interactive",
+		grpc.WithResolvers(registry.NewResolverBuilder(r, l)),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+	client := healthpb.NewHealthClient(cc)
+	check := func() bool {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		return err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING
+	}
+	assert.Eventually(t, check, time.Second, time.Millisecond*10)
+
+	// 退出的时候先注销，客户端切换到剩下的实例
+	s1.Shutdown()
+	instances, err = r.ListServices(context.Background(), "interactive")
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	assert.Equal(t, 200, instances[0].Weight)
+	for i := 0; i < 10; i++ {
+		assert.True(t, check())
+	}
+}
+
+func TestAdvertiseAddr(t *testing.T) {
+	addr := advertiseAddr(&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8090})
+	assert.Equal(t, "10.0.0.1:8090", addr)
+	addr = advertiseAddr(&net.TCPAddr{IP: net.IPv6unspecified, Port: 8090})
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	assert.Equal(t, "8090", port)
+	assert.False(t, net.ParseIP(host).IsUnspecified())
+}
+
+func TestServer_RunServeError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	cfg := DefaultConfig
+	// 端口已经被占用，Run 要返回错误而不是当成正常退出
+	cfg.Addr = lis.Addr().String()
+	s := NewServer(cfg, logger.NewZapLogger(zap.NewNop()))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Error(t, s.Run(ctx))
+	assert.NoError(t, ctx.Err())
+}
@@ -0,0 +1,90 @@
+package logger
+
+import "context"
+
+type contextKey struct{}
+
+// contextFields 请求级别的字段，由 gin 中间件和 gRPC 拦截器放进 ctx
+type contextFields struct {
+	requestID string
+	traceID   string
+	uid       int64
+	fields    []Field
+}
+
+func fromContext(ctx context.Context) contextFields {
+	cf, _ := ctx.Value(contextKey{}).(contextFields)
+	return cf
+}
+
+// update 复制一份再修改，不影响父 ctx
+func update(ctx context.Context, fn func(cf *contextFields)) context.Context {
+	cf := fromContext(ctx)
+	cf.fields = append([]Field(nil), cf.fields...)
+	fn(&cf)
+	return context.WithValue(ctx, contextKey{}, cf)
+}
+
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return update(ctx, func(cf *contextFields) {
+		cf.requestID = id
+	})
+}
+
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return update(ctx, func(cf *contextFields) {
+		cf.traceID = id
+	})
+}
+
+func ContextWithUserID(ctx context.Context, uid int64) context.Context {
+	return update(ctx, func(cf *contextFields) {
+		cf.uid = uid
+	})
+}
+
+// ContextWithFields 其它需要在整个请求里都打印的字段
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	return update(ctx, func(cf *contextFields) {
+		cf.fields = append(cf.fields, fields...)
+	})
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	return fromContext(ctx).requestID
+}
+
+func TraceIDFromContext(ctx context.Context) string {
+	return fromContext(ctx).traceID
+}
+
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	uid := fromContext(ctx).uid
+	return uid, uid > 0
+}
+
+// FieldsFromContext 没有设置的字段不返回
+func FieldsFromContext(ctx context.Context) []Field {
+	cf := fromContext(ctx)
+	res := make([]Field, 0, len(cf.fields)+3)
+	if cf.requestID != "" {
+		res = append(res, RequestID(cf.requestID))
+	}
+	if cf.traceID != "" {
+		res = append(res, TraceID(cf.traceID))
+	}
+	if cf.uid > 0 {
+		res = append(res, UserID(cf.uid))
+	}
+	return append(res, cf.fields...)
+}
+
+// FromContext 带上 ctx 里面的 request_id、trace_id、uid，
+// 处理请求的时候用 logger.FromContext(ctx, l).Error(...)
+func FromContext(ctx context.Context, l LoggerV1) LoggerV1 {
+	fields := FieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
+}
@@ -1,6 +1,11 @@
 package logger
 
+import "time"
+
 func Error(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
 	return Field{
 		Key:   "error",
 		Value: err.Error(),
@@ -20,3 +25,65 @@ func Int(key string, value int) Field {
 		Value: value,
 	}
 }
+
+func Int32(key string, value int32) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+func Bool(key string, value bool) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+func Duration(key string, value time.Duration) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+func Time(key string, value time.Time) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+// Any 结构体、map 之类的，其它类型尽量用上面的方法
+func Any(key string, value any) Field {
+	return Field{
+		Key:   key,
+		Value: value,
+	}
+}
+
+func RequestID(id string) Field {
+	return String("request_id", id)
+}
+
+func TraceID(id string) Field {
+	return String("trace_id", id)
+}
+
+func UserID(uid int64) Field {
+	return Int64("uid", uid)
+}
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFieldsFromContext(t *testing.T) {
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	ctx = ContextWithTraceID(ctx, "trace-1")
+	parent := ContextWithFields(ctx, String("biz", "article"))
+	child := ContextWithUserID(parent, 123)
+	child = ContextWithFields(child, Int64("article_id", 1))
+
+	assert.Equal(t, []Field{RequestID("req-1"), TraceID("trace-1"), UserID(123),
+		String("biz", "article"), Int64("article_id", 1)}, FieldsFromContext(child))
+	// 子 ctx 的修改不影响父 ctx
+	assert.Equal(t, []Field{RequestID("req-1"), TraceID("trace-1"),
+		String("biz", "article")}, FieldsFromContext(parent))
+	uid, ok := UserIDFromContext(child)
+	assert.True(t, ok)
+	assert.Equal(t, int64(123), uid)
+	_, ok = UserIDFromContext(parent)
+	assert.False(t, ok)
+	assert.Empty(t, FieldsFromContext(context.Background()))
+}
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewZapLogger(zap.New(core))
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	ctx = ContextWithUserID(ctx, 123)
+
+	FromContext(ctx, l).With(String("biz", "article")).Info("点赞", Int64("id", 1))
+	FromContext(context.Background(), l).Warn("没有请求信息")
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+	assert.Equal(t, map[string]any{"request_id": "req-1", "uid": int64(123), "biz": "article", "id": int64(1)},
+		entries[0].ContextMap())
+	assert.Empty(t, entries[1].ContextMap())
+}
+
+func TestZapLogger_SetLevel(t *testing.T) {
+	l, err := NewZapLoggerWithConfig(Config{Level: "warn"})
+	require.NoError(t, err)
+	assert.False(t, l.logger.Core().Enabled(zapcore.InfoLevel))
+	require.NoError(t, l.SetLevel("debug"))
+	assert.True(t, l.logger.Core().Enabled(zapcore.DebugLevel))
+	// 子 logger 和父 logger 共用同一个级别
+	child := l.With(String("biz", "article")).(*ZapLogger)
+	require.NoError(t, l.SetLevel("error"))
+	assert.False(t, child.logger.Core().Enabled(zapcore.WarnLevel))
+	assert.Error(t, l.SetLevel("verbose"))
+
+	assert.Error(t, NewZapLogger(zap.NewNop()).SetLevel("debug"))
+}
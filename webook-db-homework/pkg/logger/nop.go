@@ -0,0 +1,20 @@
+package logger
+
+// NopLogger 什么都不打印，测试和可选的 logger 用
+type NopLogger struct{}
+
+func NewNopLogger() LoggerV1 {
+	return NopLogger{}
+}
+
+func (n NopLogger) Info(msg string, fields ...Field) {}
+
+func (n NopLogger) Debug(msg string, fields ...Field) {}
+
+func (n NopLogger) Warn(msg string, fields ...Field) {}
+
+func (n NopLogger) Error(msg string, fields ...Field) {}
+
+func (n NopLogger) With(fields ...Field) LoggerV1 {
+	return n
+}
@@ -24,6 +24,9 @@ type LoggerV1 interface {
 	Warn(msg string, fields ...Field)
 
 	Error(msg string, fields ...Field)
+
+	// With 返回带上这些字段的子 logger，之后打印的每条日志都带上
+	With(fields ...Field) LoggerV1
 }
 
 type Field struct {
@@ -1,15 +1,83 @@
 package logger
 
-import "go.uber.org/zap"
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
 
 type ZapLogger struct {
 	logger *zap.Logger
+	// level 通过 NewZapLoggerWithConfig 创建的才有，用来在运行的时候调整级别
+	level *zap.AtomicLevel
 }
 
 func NewZapLogger(logger *zap.Logger) *ZapLogger {
 	return &ZapLogger{logger: logger}
 }
 
+type Config struct {
+	// Level 可选 debug/info/warn/error
+	Level string `mapstructure:"level"`
+	// Sampling 同一个级别同一条消息每秒先打印 Initial 条，之后每 Thereafter 条打印一条，
+	// 避免循环里面的错误把日志刷满；Initial 为 0 的时候不采样
+	Sampling SamplingConfig `mapstructure:"sampling"`
+}
+
+type SamplingConfig struct {
+	Initial    int `mapstructure:"initial"`
+	Thereafter int `mapstructure:"thereafter"`
+}
+
+// NewZapLoggerWithConfig JSON 格式输出到标准输出，级别可以在运行的时候调整
+func NewZapLoggerWithConfig(cfg Config) (*ZapLogger, error) {
+	level := zap.NewAtomicLevel()
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil || cfg.Level == "" {
+		level.SetLevel(zapcore.InfoLevel)
+	}
+	zc := zap.NewProductionConfig()
+	zc.Level = level
+	zc.Sampling = nil
+	if cfg.Sampling.Initial > 0 {
+		zc.Sampling = &zap.SamplingConfig{
+			Initial:    cfg.Sampling.Initial,
+			Thereafter: cfg.Sampling.Thereafter,
+		}
+	}
+	zc.EncoderConfig.TimeKey = "ts"
+	zc.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	zl, err := zc.Build(zap.AddCaller(), zap.AddCallerSkip(1))
+	if err != nil {
+		return nil, err
+	}
+	return &ZapLogger{logger: zl, level: &level}, nil
+}
+
+// SetLevel 运行的时候调整日志级别，比如配置文件修改之后
+func (l *ZapLogger) SetLevel(level string) error {
+	if l.level == nil {
+		return errors.New("logger 不支持调整级别")
+	}
+	return l.level.UnmarshalText([]byte(level))
+}
+
+// LevelHandler GET 查看当前级别，PUT {"level":"debug"} 修改级别
+func (l *ZapLogger) LevelHandler() http.Handler {
+	if l.level == nil {
+		return http.NotFoundHandler()
+	}
+	return l.level
+}
+
+func (l *ZapLogger) With(fields ...Field) LoggerV1 {
+	return &ZapLogger{
+		logger: l.logger.With(l.toZapFields(fields)...),
+		level:  l.level,
+	}
+}
+
 func (l *ZapLogger) Info(msg string, fields ...Field) {
 	l.logger.Info(msg, l.toZapFields(fields)...)
 }
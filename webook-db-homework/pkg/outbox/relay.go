@@ -0,0 +1,282 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HeaderOutboxId 发件箱里的 id，发送是至少一次的，消费者可以用它去重
+const HeaderOutboxId = "x-outbox-id"
+
+type Config struct {
+	// BatchSize 每一轮最多取多少条
+	BatchSize int `mapstructure:"batch_size"`
+	// Interval 没有消息的时候多久查一次
+	Interval time.Duration `mapstructure:"interval"`
+	// Backoff 发送失败之后的等待时间，每失败一次翻倍，最多 MaxBackoff
+	Backoff    time.Duration `mapstructure:"backoff"`
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// MaxAttempts 失败这么多次之后标记为 StatusDead，不再重试，免得一条坏消息一直挡住同 key 后面的消息。0 表示一直重试
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Retention 发送成功的消息保留多久
+	Retention       time.Duration `mapstructure:"retention"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+	// LeaseTimeout 取走一批消息之后多久必须发送完，超过之后其它 Relay 可以重新取走
+	LeaseTimeout time.Duration `mapstructure:"lease_timeout"`
+}
+
+var DefaultConfig = Config{
+	BatchSize:       100,
+	Interval:        time.Second,
+	Backoff:         time.Second,
+	MaxBackoff:      time.Minute,
+	MaxAttempts:     20,
+	Retention:       time.Hour * 24 * 3,
+	CleanupInterval: time.Hour,
+	LeaseTimeout:    time.Second * 30,
+}
+
+// Relay 把发件箱里的消息转发到 Kafka。
+// 先在一个很短的事务里用 SELECT ... FOR UPDATE 取出一批消息并写上租约，提交之后再发送，
+// Kafka 慢或者不可用的时候不会一直锁着发件箱，挡住业务写入。
+// 一个 key 前面有消息还在别的 Relay 的租约里，后面的消息就不取，这样同一个 key 的顺序不会乱
+type Relay struct {
+	db       *gorm.DB
+	producer sarama.SyncProducer
+	l        logger.LoggerV1
+	cfg      Config
+	now      func() time.Time
+}
+
+func NewRelay(db *gorm.DB, producer sarama.SyncProducer, l logger.LoggerV1, cfg Config) *Relay {
+	return &Relay{db: db, producer: producer, l: l, cfg: cfg, now: time.Now}
+}
+
+// Start 阻塞直到 ctx 结束
+func (r *Relay) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		n, err := r.RelayOnce(ctx)
+		if err != nil {
+			r.l.Error("转发发件箱消息失败", logger.Error(err))
+		}
+		// 取满了说明还有积压，马上取下一批
+		wait := r.cfg.Interval
+		if err == nil && n >= r.cfg.BatchSize {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err = r.Cleanup(ctx); err != nil {
+				r.l.Error("清理发件箱失败", logger.Error(err))
+			}
+		case <-time.After(wait):
+		}
+	}
+}
+
+// RelayOnce 转发一批消息，返回这一批取走发送的条数，等待重试或者在别的 Relay 租约里的不算。
+// Kafka 发送失败也会返回错误
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	msgs, leaseUntil, err := r.claim(ctx)
+	if err != nil || len(msgs) == 0 {
+		return 0, err
+	}
+	var (
+		sent    []int64
+		failed  *Message
+		sendErr error
+	)
+	for i := range msgs {
+		// 租约过期之后别的 Relay 可能已经在发了，继续发会打乱顺序
+		if !r.now().Before(leaseUntil) {
+			break
+		}
+		_, _, sendErr = r.producer.SendMessage(r.producerMessage(msgs[i]))
+		if sendErr != nil {
+			// Kafka 出问题的时候后面的消息大概率也发不出去，这一批直接停下
+			failed = &msgs[i]
+			break
+		}
+		sent = append(sent, msgs[i].Id)
+	}
+	if err = r.finish(ctx, msgs, sent, failed, sendErr); err != nil {
+		return len(msgs), err
+	}
+	if failed != nil {
+		return len(msgs), fmt.Errorf("发送发件箱消息 %d 失败，这一批剩下的 %d 条下一轮再发: %w",
+			failed.Id, len(msgs)-len(sent)-1, sendErr)
+	}
+	return len(msgs), nil
+}
+
+// claim 取出这一批可以发送的消息并写上租约，事务里面不做任何网络调用
+func (r *Relay) claim(ctx context.Context) ([]Message, time.Time, error) {
+	var claimed []Message
+	now := r.now()
+	leaseUntil := now.Add(r.cfg.LeaseTimeout)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var msgs []Message
+		// 同 key 前面（包括自己）有消息在等重试或者在租约里的，整个 key 后面的消息都跳过，
+		// 不能只过滤 next_retry 和租约，否则同 key 后面的消息会先发出去；
+		// 也不能查出来再在内存里跳过，被挡住的 key 多了会占满 BatchSize，别的 key 一条都取不到
+		nowMs := now.UnixMilli()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND NOT EXISTS (SELECT 1 FROM `outbox_messages` AS b "+
+				"WHERE b.`key` = `outbox_messages`.`key` AND b.id <= `outbox_messages`.id AND b.status = ? "+
+				"AND (b.next_retry > ? OR b.lease_until > ?))", StatusPending, StatusPending, nowMs, nowMs).
+			Order("id").Limit(r.cfg.BatchSize).Find(&msgs).Error
+		if err != nil {
+			return err
+		}
+		// 子查询里面的行没有加锁，这里再检查一遍，前面有消息不能发的 key，这一轮后面的消息都不发
+		blocked := make(map[string]bool)
+		ids := make([]int64, 0, len(msgs))
+		for _, msg := range msgs {
+			if blocked[msg.Key] {
+				continue
+			}
+			if msg.NextRetry > nowMs || msg.LeaseUntil > nowMs {
+				blocked[msg.Key] = true
+				continue
+			}
+			claimed = append(claimed, msg)
+			ids = append(ids, msg.Id)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&Message{}).Where("id IN ?", ids).Updates(map[string]any{
+			"lease_until": leaseUntil.UnixMilli(),
+			"u_time":      nowMs,
+		}).Error
+	})
+	return claimed, leaseUntil, err
+}
+
+// finish 发送成功的标记为已发送，失败的那一条记录重试时间，没来得及发的释放租约
+func (r *Relay) finish(ctx context.Context, msgs []Message, sent []int64, failed *Message, sendErr error) error {
+	now := r.now()
+	db := r.db.WithContext(ctx)
+	if len(sent) > 0 {
+		err := db.Model(&Message{}).Where("id IN ?", sent).Updates(map[string]any{
+			"status":      StatusSent,
+			"lease_until": 0,
+			"u_time":      now.UnixMilli(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	var unsent []int64
+	for _, msg := range msgs[len(sent):] {
+		if failed != nil && msg.Id == failed.Id {
+			continue
+		}
+		unsent = append(unsent, msg.Id)
+	}
+	if failed != nil {
+		attempts := failed.Attempts + 1
+		values := map[string]any{
+			"attempts":    attempts,
+			"next_retry":  now.Add(r.backoff(attempts)).UnixMilli(),
+			"last_error":  truncate(sendErr.Error(), 1024),
+			"lease_until": 0,
+			"u_time":      now.UnixMilli(),
+		}
+		dead := r.cfg.MaxAttempts > 0 && attempts >= r.cfg.MaxAttempts
+		if dead {
+			values["status"] = StatusDead
+		}
+		if err := db.Model(&Message{}).Where("id = ?", failed.Id).Updates(values).Error; err != nil {
+			return err
+		}
+		if dead {
+			r.l.Error("发件箱消息重试次数用完，不再发送",
+				logger.Int64("id", failed.Id),
+				logger.String("topic", failed.Topic),
+				logger.String("key", failed.Key),
+				logger.Int("attempts", attempts),
+				logger.Error(sendErr))
+		}
+	}
+	if len(unsent) == 0 {
+		return nil
+	}
+	return db.Model(&Message{}).Where("id IN ?", unsent).Updates(map[string]any{
+		"lease_until": 0,
+		"u_time":      now.UnixMilli(),
+	}).Error
+}
+
+// Cleanup 删除超过保留时间的已发送消息，返回删除的条数
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	before := r.now().Add(-r.cfg.Retention).UnixMilli()
+	var total int64
+	for {
+		// 分批删，避免一次锁住太多行
+		res := r.db.WithContext(ctx).
+			Exec("DELETE FROM `outbox_messages` WHERE `status` = ? AND `u_time` < ? LIMIT ?",
+				StatusSent, before, r.cfg.BatchSize)
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < int64(r.cfg.BatchSize) {
+			return total, nil
+		}
+	}
+}
+
+func (r *Relay) producerMessage(msg Message) *sarama.ProducerMessage {
+	pm := &sarama.ProducerMessage{
+		Topic: msg.Topic,
+		Value: sarama.ByteEncoder(msg.Payload),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(HeaderOutboxId), Value: []byte(strconv.FormatInt(msg.Id, 10))},
+		},
+	}
+	if msg.Key != "" {
+		pm.Key = sarama.StringEncoder(msg.Key)
+	}
+	var headers map[string]string
+	if len(msg.Headers) > 0 && json.Unmarshal(msg.Headers, &headers) == nil {
+		keys := make([]string, 0, len(headers))
+		for k := range headers {
+			keys = append(keys, k)
+		}
+		// 固定顺序，方便排查
+		sort.Strings(keys)
+		for _, k := range keys {
+			pm.Headers = append(pm.Headers, sarama.RecordHeader{Key: []byte(k), Value: []byte(headers[k])})
+		}
+	}
+	return pm
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.cfg.Backoff
+	for i := 1; i < attempts && d < r.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, r.cfg.MaxBackoff)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,183 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"webook/pkg/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(gormMysql.New(gormMysql.Config{
+		Conn:                      mockDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return db, mock
+}
+
+func TestAdd(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectExec("INSERT INTO `outbox_messages`.*").
+		WithArgs("article_published", "1", []byte(`{"id":1}`), []byte(`{"x-event-id":"e1"}`), StatusPending, 0, 0, 0, "",
+			sqlmock.AnyArg(), sqlmock.AnyArg(),
+			"read_event", "2", []byte(`{"id":2}`), []byte(nil), StatusPending, 0, 0, 0, "",
+			sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	err := Add(context.Background(), db,
+		Event{Topic: "article_published", Key: "1", Payload: map[string]int{"id": 1},
+			Headers: map[string]string{"x-event-id": "e1"}},
+		Event{Topic: "read_event", Key: "2", Payload: map[string]int{"id": 2}})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_RelayOnce(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	leaseUntil := now.Add(DefaultConfig.LeaseTimeout).UnixMilli()
+	db, mock := newMockDB(t)
+	// 取消息和写租约在一个短事务里面，发送之前就提交
+	mock.ExpectBegin()
+	// 被挡住的 key 在 SQL 里面就排除掉，不占 BatchSize
+	mock.ExpectQuery("SELECT \\* FROM `outbox_messages` WHERE status = \\? AND NOT EXISTS \\(SELECT 1 FROM `outbox_messages` AS b .*\\) ORDER BY id LIMIT \\? FOR UPDATE").
+		WithArgs(StatusPending, StatusPending, now.UnixMilli(), now.UnixMilli(), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "key", "payload", "headers", "status", "attempts", "next_retry", "lease_until"}).
+			AddRow(1, "evt", "b", []byte("1"), []byte(`{"x-event-type":"b","x-event-id":"e1"}`), StatusPending, 0, 0, 0).
+			// c 的第一条还没到重试时间，后面的也要等
+			AddRow(2, "evt", "c", []byte("2"), nil, StatusPending, 1, now.Add(time.Second).UnixMilli(), 0).
+			AddRow(3, "evt", "c", []byte("3"), nil, StatusPending, 0, 0, 0).
+			// d 的第一条在别的 Relay 的租约里，后面的也要等
+			AddRow(4, "evt", "d", []byte("4"), nil, StatusPending, 0, 0, now.Add(time.Second).UnixMilli()).
+			AddRow(5, "evt", "d", []byte("5"), nil, StatusPending, 0, 0, 0).
+			AddRow(6, "evt", "a", []byte("6"), nil, StatusPending, 0, 0, 0).
+			AddRow(7, "evt", "e", []byte("7"), nil, StatusPending, 0, 0, 0))
+	mock.ExpectExec("UPDATE `outbox_messages` SET `lease_until`=\\?,`u_time`=\\? WHERE id IN \\(\\?,\\?,\\?\\)").
+		WithArgs(leaseUntil, now.UnixMilli(), 1, 6, 7).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	// 发送在事务外面，a 发送失败之后这一批剩下的 e 不再尝试
+	mock.ExpectExec("UPDATE `outbox_messages` SET .* WHERE id IN \\(\\?\\)").
+		WithArgs(0, StatusSent, now.UnixMilli(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `outbox_messages` SET .* WHERE id = \\?").
+		WithArgs(1, "kafka 不可用", 0, now.Add(time.Second).UnixMilli(), now.UnixMilli(), 6).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// 没来得及发的释放租约，下一轮马上可以再取
+	mock.ExpectExec("UPDATE `outbox_messages` SET `lease_until`=\\?,`u_time`=\\? WHERE id IN \\(\\?\\)").
+		WithArgs(0, now.UnixMilli(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(pm *sarama.ProducerMessage) error {
+		key, _ := pm.Key.Encode()
+		val, _ := pm.Value.Encode()
+		assert.Equal(t, "b", string(key))
+		assert.Equal(t, "1", string(val))
+		headers := make([]string, 0, len(pm.Headers))
+		for _, h := range pm.Headers {
+			headers = append(headers, string(h.Key)+"="+string(h.Value))
+		}
+		assert.Equal(t, []string{HeaderOutboxId + "=1", "x-event-id=e1", "x-event-type=b"}, headers)
+		return nil
+	})
+	producer.ExpectSendMessageAndFail(errors.New("kafka 不可用"))
+
+	r := NewRelay(db, producer, logger.NewZapLogger(zap.NewNop()), DefaultConfig)
+	r.now = func() time.Time { return now }
+	n, err := r.RelayOnce(context.Background())
+	assert.ErrorContains(t, err, "kafka 不可用")
+	assert.Equal(t, 3, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_RelayOnceDead(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	cfg := DefaultConfig
+	cfg.MaxAttempts = 3
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `outbox_messages`").
+		WithArgs(StatusPending, StatusPending, now.UnixMilli(), now.UnixMilli(), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "key", "payload", "status", "attempts"}).
+			AddRow(1, "evt", "a", []byte("1"), StatusPending, 2))
+	mock.ExpectExec("UPDATE `outbox_messages` SET `lease_until`=\\?,`u_time`=\\? WHERE id IN \\(\\?\\)").
+		WithArgs(now.Add(cfg.LeaseTimeout).UnixMilli(), now.UnixMilli(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// 第三次失败，不再重试，同 key 后面的消息不用再等它
+	mock.ExpectExec("UPDATE `outbox_messages` SET .*`status`=\\?.* WHERE id = \\?").
+		WithArgs(3, "kafka 不可用", 0, now.Add(time.Second*4).UnixMilli(), StatusDead, now.UnixMilli(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	producer.ExpectSendMessageAndFail(errors.New("kafka 不可用"))
+
+	r := NewRelay(db, producer, logger.NewZapLogger(zap.NewNop()), cfg)
+	r.now = func() time.Time { return now }
+	_, err := r.RelayOnce(context.Background())
+	assert.ErrorContains(t, err, "kafka 不可用")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_RelayOnceNothingToClaim(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	db, mock := newMockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT \\* FROM `outbox_messages`").
+		WithArgs(StatusPending, StatusPending, now.UnixMilli(), now.UnixMilli(), 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "key", "status", "lease_until"}).
+			AddRow(1, "evt", "a", StatusPending, now.Add(time.Second).UnixMilli()))
+	mock.ExpectCommit()
+
+	r := NewRelay(db, nil, logger.NewZapLogger(zap.NewNop()), DefaultConfig)
+	r.now = func() time.Time { return now }
+	n, err := r.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_Cleanup(t *testing.T) {
+	now := time.UnixMilli(1_000_000_000)
+	db, mock := newMockDB(t)
+	cfg := DefaultConfig
+	cfg.BatchSize = 2
+	cfg.Retention = time.Hour
+	before := now.Add(-time.Hour).UnixMilli()
+	mock.ExpectExec("DELETE FROM `outbox_messages`").
+		WithArgs(StatusSent, before, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM `outbox_messages`").
+		WithArgs(StatusSent, before, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r := NewRelay(db, nil, logger.NewZapLogger(zap.NewNop()), cfg)
+	r.now = func() time.Time { return now }
+	n, err := r.Cleanup(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	r := NewRelay(nil, nil, logger.NewZapLogger(zap.NewNop()), DefaultConfig)
+	assert.Equal(t, time.Second, r.backoff(1))
+	assert.Equal(t, time.Second*4, r.backoff(3))
+	assert.Equal(t, time.Minute, r.backoff(10))
+}
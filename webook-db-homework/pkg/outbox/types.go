@@ -0,0 +1,85 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// StatusPending 还没有发送成功，包括发送失败等待重试
+	StatusPending uint8 = iota
+	StatusSent
+	// StatusDead 失败次数达到 MaxAttempts，不再发送，同 key 后面的消息不再等它，需要人工处理
+	StatusDead
+)
+
+// Message 发件箱表，和业务数据在同一个库里面，同一个事务写入
+type Message struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Topic string `gorm:"type:varchar(255)"`
+	// Key 聚合的 key，比如文章 id。同一个 key 的消息按照 Id 的顺序发送，
+	// 也会作为 Kafka 消息的 key，保证进入同一个分区
+	Key     string `gorm:"type:varchar(255)"`
+	Payload []byte `gorm:"type:blob"`
+	// Headers Kafka 消息头，map[string]string 的 JSON
+	Headers []byte `gorm:"type:text"`
+	Status  uint8  `gorm:"index"`
+	// Attempts 发送失败的次数
+	Attempts int
+	// NextRetry 发送失败之后，这个时间之前不会再发送
+	NextRetry int64
+	// LeaseUntil 被某个 Relay 取走正在发送，这个时间之前其它 Relay 不会再发送这个 key 的消息，
+	// Relay 挂了之后租约过期，别的 Relay 会接着发
+	LeaseUntil int64
+	LastError  string `gorm:"type:varchar(1024)"`
+	CTime      int64  `gorm:"column:c_time"`
+	UTime      int64  `gorm:"column:u_time;index"`
+}
+
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Event 业务方要发送的消息，Payload 会被序列化成 JSON
+type Event struct {
+	Topic   string
+	Key     string
+	Payload any
+	Headers map[string]string
+}
+
+// Add 把消息写入发件箱，tx 必须是业务数据所在的事务，
+// 这样业务数据提交了消息就一定会发出去，回滚了消息也不会发
+func Add(ctx context.Context, tx *gorm.DB, evts ...Event) error {
+	if len(evts) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	msgs := make([]Message, 0, len(evts))
+	for _, evt := range evts {
+		payload, err := json.Marshal(evt.Payload)
+		if err != nil {
+			return err
+		}
+		var headers []byte
+		if len(evt.Headers) > 0 {
+			headers, err = json.Marshal(evt.Headers)
+			if err != nil {
+				return err
+			}
+		}
+		msgs = append(msgs, Message{
+			Topic:   evt.Topic,
+			Key:     evt.Key,
+			Payload: payload,
+			Headers: headers,
+			Status:  StatusPending,
+			CTime:   now,
+			UTime:   now,
+		})
+	}
+	return tx.WithContext(ctx).Create(&msgs).Error
+}
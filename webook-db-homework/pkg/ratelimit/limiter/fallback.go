@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"webook/pkg/logger"
+)
+
+// FallbackLimiter 平时用 primary（一般是 Redis），primary 出错的时候切换到 fallback（一般是本地限流），
+// 而不是让所有请求都失败。切换之后 retryAfter 时间内都用 fallback，之后再尝试 primary
+type FallbackLimiter struct {
+	primary    Limiter
+	fallback   Limiter
+	l          logger.LoggerV1
+	retryAfter time.Duration
+	// degradedUntil 降级到什么时候，毫秒时间戳，0 代表没有降级
+	degradedUntil atomic.Int64
+	now           func() time.Time
+}
+
+func NewFallbackLimiter(primary Limiter, fallback Limiter, retryAfter time.Duration, l logger.LoggerV1) *FallbackLimiter {
+	return &FallbackLimiter{
+		primary:    primary,
+		fallback:   fallback,
+		l:          l,
+		retryAfter: retryAfter,
+		now:        time.Now,
+	}
+}
+
+func (f *FallbackLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	now := f.now().UnixMilli()
+	if now < f.degradedUntil.Load() {
+		return f.fallback.Limit(ctx, key)
+	}
+	limited, err := f.primary.Limit(ctx, key)
+	if err == nil {
+		return limited, nil
+	}
+	f.degrade(now, key, err)
+	return f.fallback.Limit(ctx, key)
+}
+
+// LimitResult primary 和 fallback 都要实现 ResultLimiter，否则只能拿到是否限流
+func (f *FallbackLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	now := f.now().UnixMilli()
+	if now < f.degradedUntil.Load() {
+		return limitResult(ctx, f.fallback, key)
+	}
+	res, err := limitResult(ctx, f.primary, key)
+	if err == nil {
+		return res, nil
+	}
+	f.degrade(now, key, err)
+	return limitResult(ctx, f.fallback, key)
+}
+
+func (f *FallbackLimiter) degrade(now int64, key string, err error) {
+	// 只有第一个发现出错的请求打日志，避免 Redis 挂了的时候日志刷屏
+	old := f.degradedUntil.Load()
+	if f.degradedUntil.CompareAndSwap(old, now+f.retryAfter.Milliseconds()) && old <= now {
+		f.l.Warn("限流器出错，降级到本地限流", logger.Error(err), logger.String("key", key))
+	}
+}
+
+func limitResult(ctx context.Context, l Limiter, key string) (Result, error) {
+	if rl, ok := l.(ResultLimiter); ok {
+		return rl.LimitResult(ctx, key)
+	}
+	limited, err := l.Limit(ctx, key)
+	return Result{Limited: limited}, err
+}
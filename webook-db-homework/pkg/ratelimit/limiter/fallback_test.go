@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"webook/pkg/logger"
+	ratelimitmocks "webook/pkg/ratelimit/limiter/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"go.uber.org/zap"
+)
+
+func TestFallbackLimiter_Limit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	primary := ratelimitmocks.NewMockLimiter(ctrl)
+	fallback := ratelimitmocks.NewMockLimiter(ctrl)
+	now := time.UnixMilli(1_000_000)
+	l := NewFallbackLimiter(primary, fallback, time.Second*10, logger.NewZapLogger(zap.NewNop()))
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// primary 正常
+	primary.EXPECT().Limit(gomock.Any(), "a").Return(true, nil)
+	limited, err := l.Limit(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, limited)
+
+	// primary 出错，用 fallback 的结果
+	primary.EXPECT().Limit(gomock.Any(), "a").Return(false, errors.New("redis 错误"))
+	fallback.EXPECT().Limit(gomock.Any(), "a").Return(false, nil)
+	limited, err = l.Limit(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, limited)
+
+	// 降级期间不再访问 primary
+	now = now.Add(time.Second * 5)
+	fallback.EXPECT().Limit(gomock.Any(), "a").Return(true, nil)
+	limited, err = l.Limit(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, limited)
+
+	// 过了 retryAfter 重新尝试 primary
+	now = now.Add(time.Second * 5)
+	primary.EXPECT().Limit(gomock.Any(), "a").Return(false, nil)
+	limited, err = l.Limit(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, limited)
+}
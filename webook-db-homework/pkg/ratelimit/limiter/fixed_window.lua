@@ -0,0 +1,22 @@
+-- 固定窗口，窗口按 Redis 的时间对齐，不同实例的时钟有偏差也落在同一个窗口里面
+local key = KEYS[1]
+-- 窗口大小，毫秒
+local window = tonumber(ARGV[1])
+-- 阈值
+local threshold = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+-- 到窗口结束还有多久，key 在窗口结束的时候过期，下一个窗口重新计数
+local ttl = window - now % window
+
+local cnt = redis.call('INCR', key)
+if cnt == 1 then
+    redis.call('PEXPIRE', key, ttl)
+end
+-- 返回值：是否限流、剩余额度、多久之后可以重试、多久之后额度完全恢复，时间都是毫秒
+if cnt > threshold then
+    return {1, 0, ttl, ttl}
+else
+    return {0, threshold - cnt, 0, ttl}
+end
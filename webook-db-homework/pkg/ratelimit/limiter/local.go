@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// defaultShards 本地限流器的分片数量，分片之后不同 key 之间不会抢同一把锁
+const defaultShards = 64
+
+type localEntry[T any] struct {
+	val      T
+	lastSeen time.Time
+}
+
+type localShard[T any] struct {
+	mutex     sync.Mutex
+	entries   map[string]*localEntry[T]
+	lastSweep time.Time
+}
+
+// localStore 分片的本地状态，超过 idle 没有访问的 key 会被清理掉，避免 key 太多把内存撑爆
+type localStore[T any] struct {
+	shards []*localShard[T]
+	idle   time.Duration
+}
+
+func newLocalStore[T any](shards int, idle time.Duration) *localStore[T] {
+	if shards <= 0 {
+		shards = defaultShards
+	}
+	s := &localStore[T]{
+		shards: make([]*localShard[T], shards),
+		idle:   idle,
+	}
+	for i := range s.shards {
+		s.shards[i] = &localShard[T]{entries: make(map[string]*localEntry[T])}
+	}
+	return s
+}
+
+// with 在分片的锁里面执行 fn，fn 里面可以直接修改状态。exists 为 false 代表是新的 key
+func (s *localStore[T]) with(key string, now time.Time, fn func(val *T, exists bool) Result) Result {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	shard := s.shards[h.Sum32()%uint32(len(s.shards))]
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if now.Sub(shard.lastSweep) > s.idle {
+		for k, e := range shard.entries {
+			if now.Sub(e.lastSeen) > s.idle {
+				delete(shard.entries, k)
+			}
+		}
+		shard.lastSweep = now
+	}
+	e, exists := shard.entries[key]
+	if !exists {
+		e = &localEntry[T]{}
+		shard.entries[key] = e
+	}
+	e.lastSeen = now
+	return fn(&e.val, exists)
+}
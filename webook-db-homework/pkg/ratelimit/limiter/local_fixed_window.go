@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+type localFixedWindow struct {
+	window int64
+	cnt    int
+}
+
+// LocalFixedWindowLimiter 本地固定窗口，和 RedisFixedWindowLimiter 的语义一样，只是只在当前节点计数
+type LocalFixedWindowLimiter struct {
+	store    *localStore[localFixedWindow]
+	interval time.Duration
+	rate     int
+	now      func() time.Time
+}
+
+func NewLocalFixedWindowLimiter(interval time.Duration, rate int) *LocalFixedWindowLimiter {
+	return &LocalFixedWindowLimiter{
+		store:    newLocalStore[localFixedWindow](defaultShards, interval),
+		interval: interval,
+		rate:     rate,
+		now:      time.Now,
+	}
+}
+
+func (l *LocalFixedWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := l.LimitResult(ctx, key)
+	return res.Limited, err
+}
+
+func (l *LocalFixedWindowLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	now := l.now()
+	intervalMs := l.interval.Milliseconds()
+	window := now.UnixMilli() / intervalMs
+	// 当前窗口剩下的时间
+	reset := time.Duration((window+1)*intervalMs-now.UnixMilli()) * time.Millisecond
+	return l.store.with(key, now, func(w *localFixedWindow, exists bool) Result {
+		if w.window != window {
+			w.window = window
+			w.cnt = 0
+		}
+		res := Result{Limit: l.rate, Reset: reset}
+		if w.cnt >= l.rate {
+			res.Limited = true
+			res.RetryAfter = reset
+			return res
+		}
+		w.cnt++
+		res.Remaining = l.rate - w.cnt
+		return res
+	}), nil
+}
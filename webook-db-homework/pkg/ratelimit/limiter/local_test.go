@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalTokenBucketLimiter_Limit(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	// 每秒 2 个，最多突发 3 个
+	l := NewLocalTokenBucketLimiter(time.Second, 2, 3)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		limited, err := l.Limit(ctx, "a")
+		assert.NoError(t, err)
+		assert.False(t, limited)
+	}
+	limited, _ := l.Limit(ctx, "a")
+	assert.True(t, limited)
+	// 不同的 key 互不影响
+	limited, _ = l.Limit(ctx, "b")
+	assert.False(t, limited)
+
+	// 过了 500ms 补充一个令牌
+	now = now.Add(time.Millisecond * 500)
+	limited, _ = l.Limit(ctx, "a")
+	assert.False(t, limited)
+	limited, _ = l.Limit(ctx, "a")
+	assert.True(t, limited)
+
+	// 很久之后也只能补满到容量
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		limited, _ = l.Limit(ctx, "a")
+		assert.False(t, limited)
+	}
+	limited, _ = l.Limit(ctx, "a")
+	assert.True(t, limited)
+}
+
+func TestLocalFixedWindowLimiter_Limit(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	l := NewLocalFixedWindowLimiter(time.Second, 2)
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		limited, err := l.Limit(ctx, "a")
+		assert.NoError(t, err)
+		assert.False(t, limited)
+	}
+	limited, _ := l.Limit(ctx, "a")
+	assert.True(t, limited)
+
+	// 同一个窗口里面还是限流
+	now = now.Add(time.Millisecond * 999)
+	limited, _ = l.Limit(ctx, "a")
+	assert.True(t, limited)
+
+	// 进入下一个窗口
+	now = now.Add(time.Millisecond)
+	limited, _ = l.Limit(ctx, "a")
+	assert.False(t, limited)
+}
+
+func TestLocalStore_Sweep(t *testing.T) {
+	s := newLocalStore[int](1, time.Second)
+	now := time.UnixMilli(1_000_000)
+	s.with("a", now, func(val *int, exists bool) Result { return Result{} })
+	s.with("b", now.Add(time.Millisecond*800), func(val *int, exists bool) Result { return Result{} })
+	assert.Len(t, s.shards[0].entries, 2)
+
+	// a 超过 1 秒没有访问，下一次清理的时候删掉
+	s.with("b", now.Add(time.Millisecond*1600), func(val *int, exists bool) Result { return Result{} })
+	assert.Len(t, s.shards[0].entries, 1)
+	assert.Contains(t, s.shards[0].entries, "b")
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+type localTokenBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// LocalTokenBucketLimiter 本地令牌桶，单机部署或者 Redis 不可用的时候使用。
+// 多个节点各自计数，所以整体的阈值是单个节点的 N 倍
+type LocalTokenBucketLimiter struct {
+	store    *localStore[localTokenBucket]
+	interval time.Duration
+	rate     int
+	capacity int
+	now      func() time.Time
+}
+
+func NewLocalTokenBucketLimiter(interval time.Duration, rate int, capacity int) *LocalTokenBucketLimiter {
+	return &LocalTokenBucketLimiter{
+		// 桶从空到满之后，状态就和新的 key 没有区别了，可以清理掉
+		store:    newLocalStore[localTokenBucket](defaultShards, time.Duration(capacity)*interval/time.Duration(rate)),
+		interval: interval,
+		rate:     rate,
+		capacity: capacity,
+		now:      time.Now,
+	}
+}
+
+func (l *LocalTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := l.LimitResult(ctx, key)
+	return res.Limited, err
+}
+
+func (l *LocalTokenBucketLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	now := l.now()
+	return l.store.with(key, now, func(b *localTokenBucket, exists bool) Result {
+		if !exists {
+			b.tokens = float64(l.capacity)
+			b.ts = now
+		}
+		elapsed := now.Sub(b.ts)
+		if elapsed > 0 {
+			b.tokens = math.Min(float64(l.capacity),
+				b.tokens+float64(elapsed)*float64(l.rate)/float64(l.interval))
+			b.ts = now
+		}
+		res := Result{Limit: l.capacity}
+		if b.tokens < 1 {
+			res.Limited = true
+			res.RetryAfter = l.refillTime(1 - b.tokens)
+		} else {
+			b.tokens--
+		}
+		res.Remaining = int(b.tokens)
+		res.Reset = l.refillTime(float64(l.capacity) - b.tokens)
+		return res
+	}), nil
+}
+
+// refillTime 补充 tokens 个令牌需要的时间
+func (l *LocalTokenBucketLimiter) refillTime(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens * float64(l.interval) / float64(l.rate)))
+}
@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+//go:embed fixed_window.lua
+var luaFixedWindow string
+
+// RedisFixedWindowLimiter 固定窗口，实现简单开销也小，但是窗口边界上最多会放过 2 * rate 个请求
+type RedisFixedWindowLimiter struct {
+	cmd      redis.Cmdable
+	interval time.Duration
+	rate     int
+}
+
+func NewRedisFixedWindowLimiter(cmd redis.Cmdable, interval time.Duration, rate int) *RedisFixedWindowLimiter {
+	return &RedisFixedWindowLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+	}
+}
+
+func (r *RedisFixedWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := r.LimitResult(ctx, key)
+	return res.Limited, err
+}
+
+func (r *RedisFixedWindowLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	// 窗口在脚本里面按 Redis 的时间对齐，key 在窗口结束的时候过期
+	return evalResult(ctx, r.cmd, luaFixedWindow, key, r.rate,
+		r.interval.Milliseconds(), r.rate)
+}
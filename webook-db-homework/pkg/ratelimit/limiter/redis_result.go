@@ -0,0 +1,28 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// evalResult 执行限流脚本，脚本统一返回 {是否限流, 剩余额度, 重试时间毫秒, 恢复时间毫秒}
+func evalResult(ctx context.Context, cmd redis.Cmdable, script string, key string,
+	limit int, args ...any) (Result, error) {
+	vals, err := cmd.Eval(ctx, script, []string{key}, args...).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 4 {
+		return Result{}, fmt.Errorf("限流脚本返回值不合法: %v", vals)
+	}
+	return Result{
+		Limited:    vals[0] == 1,
+		Limit:      limit,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+		Reset:      time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
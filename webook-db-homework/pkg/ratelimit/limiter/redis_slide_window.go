@@ -2,8 +2,10 @@ package ratelimit
 
 import (
 	"context"
+	_ "embed"
 	"time"
 
+	"github.com/google/uuid"
 	redis "github.com/redis/go-redis/v9"
 )
 
@@ -27,6 +29,11 @@ func NewRedisSlideWindowLimiter(cmd redis.Cmdable, interval time.Duration, rate
 	}
 }
 func (r *RedisSlideWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return r.cmd.Eval(ctx, luaSlideWindow, []string{key},
-		r.interval.Milliseconds(), r.rate, time.Now().UnixMilli()).Bool()
+	res, err := r.LimitResult(ctx, key)
+	return res.Limited, err
+}
+
+func (r *RedisSlideWindowLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	return evalResult(ctx, r.cmd, luaSlideWindow, key, r.rate,
+		r.interval.Milliseconds(), r.rate, uuid.NewString())
 }
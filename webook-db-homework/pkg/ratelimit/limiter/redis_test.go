@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRedis 需要一个可以随便写的 Redis，通过 WEBOOK_TEST_REDIS_ADDR 指定
+func testRedis(t *testing.T) redis.Cmdable {
+	addr := os.Getenv("WEBOOK_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("没有设置 WEBOOK_TEST_REDIS_ADDR")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+func TestRedisSlideWindowLimiter_Concurrent(t *testing.T) {
+	cmd := testRedis(t)
+	ctx := context.Background()
+	key := "test:ratelimit:slide_window"
+	require.NoError(t, cmd.Del(ctx, key).Err())
+	l := NewRedisSlideWindowLimiter(cmd, time.Minute, 10)
+
+	// 并发的请求很多落在同一毫秒里面，每一个都要算进窗口
+	var passed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limited, err := l.Limit(ctx, key)
+			assert.NoError(t, err)
+			if !limited {
+				passed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(10), passed.Load())
+}
+
+func TestRedisFixedWindowLimiter(t *testing.T) {
+	cmd := testRedis(t)
+	ctx := context.Background()
+	key := "test:ratelimit:fixed_window"
+	require.NoError(t, cmd.Del(ctx, key).Err())
+	l := NewRedisFixedWindowLimiter(cmd, time.Minute, 2)
+
+	for i := 0; i < 2; i++ {
+		res, err := l.LimitResult(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, res.Limited)
+	}
+	res, err := l.LimitResult(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, res.Limited)
+	// 窗口按 Redis 的时间对齐，key 在窗口结束的时候过期
+	ttl, err := cmd.PTTL(ctx, key).Result()
+	require.NoError(t, err)
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= time.Minute)
+}
+
+func TestRedisTokenBucketLimiter(t *testing.T) {
+	cmd := testRedis(t)
+	ctx := context.Background()
+	key := "test:ratelimit:token_bucket"
+	require.NoError(t, cmd.Del(ctx, key).Err())
+	l := NewRedisTokenBucketLimiter(cmd, time.Minute, 1, 2)
+
+	for i := 0; i < 2; i++ {
+		res, err := l.LimitResult(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, res.Limited)
+	}
+	res, err := l.LimitResult(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, res.Limited)
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= time.Minute)
+
+	// 补充令牌的时间是脚本用 Redis 的时间写进去的，调用方的时钟不参与
+	ts, err := cmd.HGet(ctx, key, "ts").Int64()
+	require.NoError(t, err)
+	now, err := cmd.Time(ctx).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, now.UnixMilli(), ts, float64(time.Second.Milliseconds()))
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+//go:embed token_bucket.lua
+var luaTokenBucket string
+
+// RedisTokenBucketLimiter 令牌桶，平均 interval 内允许 rate 个请求，最多允许 capacity 个突发请求
+type RedisTokenBucketLimiter struct {
+	cmd      redis.Cmdable
+	interval time.Duration
+	rate     int
+	capacity int
+}
+
+func NewRedisTokenBucketLimiter(cmd redis.Cmdable, interval time.Duration, rate int, capacity int) *RedisTokenBucketLimiter {
+	return &RedisTokenBucketLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+		capacity: capacity,
+	}
+}
+
+func (r *RedisTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := r.LimitResult(ctx, key)
+	return res.Limited, err
+}
+
+// LimitResult Limit 返回的是桶的容量，Remaining 是桶里面剩下的整数个令牌
+func (r *RedisTokenBucketLimiter) LimitResult(ctx context.Context, key string) (Result, error) {
+	return evalResult(ctx, r.cmd, luaTokenBucket, key, r.capacity,
+		r.interval.Milliseconds(), r.rate, r.capacity)
+}
@@ -8,19 +8,28 @@ local key = KEYS[1]
 local window = tonumber(ARGV[1])
 -- 阈值
 local threshold = tonumber( ARGV[2])
-local now = tonumber(ARGV[3])
+-- 用 Redis 的时间，不同实例的时钟有偏差的时候窗口也是同一个
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+-- 同一毫秒可能有多个请求，member 带上调用方生成的唯一后缀，否则会互相覆盖只算一次
+local member = now .. '-' .. ARGV[3]
 -- 窗口的起始时间
 local min = now - window
 
 redis.call('ZREMRANGEBYSCORE', key, '-inf', min)
 local cnt = redis.call('ZCOUNT', key, '-inf', '+inf')
 -- local cnt = redis.call('ZCOUNT', key, min, '+inf')
+-- 返回值：是否限流、剩余额度、多久之后可以重试、多久之后额度完全恢复，时间都是毫秒
 if cnt >= threshold then
-    -- 执行限流
-    return "true"
+    -- 执行限流，最早的那个请求滑出窗口之后就可以重试
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    local retry = window
+    if oldest[2] then
+        retry = tonumber(oldest[2]) + window - now
+    end
+    return {1, 0, retry, window}
 else
-    -- 把 score 和 member 都设置成 now
-    redis.call('ZADD', key, now, now)
+    redis.call('ZADD', key, now, member)
     redis.call('PEXPIRE', key, window)
-    return "false"
-end
\ No newline at end of file
+    return {0, threshold - cnt - 1, 0, window}
+end
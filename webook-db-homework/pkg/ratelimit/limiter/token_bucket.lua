@@ -0,0 +1,37 @@
+-- 令牌桶，桶里面保存剩余令牌数和上一次补充令牌的时间
+local key = KEYS[1]
+-- interval 毫秒内补充 rate 个令牌
+local interval = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+-- 桶的容量，也就是允许的突发流量
+local capacity = tonumber(ARGV[3])
+-- 用 Redis 的时间，不同实例的时钟有偏差的时候，慢的那个会一直补充不了令牌
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local info = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(info[1])
+local ts = tonumber(info[2])
+if tokens == nil or ts == nil then
+    tokens = capacity
+    ts = now
+end
+
+local elapsed = math.max(0, now - ts)
+tokens = math.min(capacity, tokens + elapsed * rate / interval)
+
+local limited = 1
+local retry = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    limited = 0
+else
+    -- 补充到一个令牌需要的时间
+    retry = math.ceil((1 - tokens) * interval / rate)
+end
+
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', now)
+-- 桶从空到满需要的时间之后，这个 key 就没有意义了
+redis.call('PEXPIRE', key, math.ceil(capacity * interval / rate))
+-- 返回值：是否限流、剩余额度、多久之后可以重试、多久之后额度完全恢复，时间都是毫秒
+return {limited, math.floor(tokens), retry, math.ceil((capacity - tokens) * interval / rate)}
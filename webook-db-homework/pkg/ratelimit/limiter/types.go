@@ -1,6 +1,9 @@
 package ratelimit
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Limiter interface {
 	Limit(ctx context.Context, key string) (bool, error)
@@ -8,3 +11,22 @@ type Limiter interface {
 	//返回值代表是否有触发限流，如果触发限流，则返回true，否则返回false
 	//err代表限流器内部错误
 }
+
+// Result 限流的详细结果，HTTP 接口用它来返回 X-RateLimit-* 和 Retry-After
+type Result struct {
+	Limited bool
+	// Limit 阈值
+	Limit int
+	// Remaining 这次请求之后还剩下的额度
+	Remaining int
+	// RetryAfter 被限流的时候，多久之后可以重试
+	RetryAfter time.Duration
+	// Reset 多久之后额度完全恢复
+	Reset time.Duration
+}
+
+// ResultLimiter 能返回详细结果的限流器，Limit 的结果和 LimitResult 的 Limited 一致
+type ResultLimiter interface {
+	Limiter
+	LimitResult(ctx context.Context, key string) (Result, error)
+}
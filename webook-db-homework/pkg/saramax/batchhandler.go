@@ -3,6 +3,7 @@ package saramax
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 	"webook/pkg/logger"
 
@@ -20,10 +21,18 @@ type BatchHandler_[T any] struct {
 	l         logger.LoggerV1
 	batchSize int
 	timeout   time.Duration
+	retrier   *Retrier
 }
 
+// NewBatchHandler_ 只在进程内重试，最终失败的批次打日志之后提交
 func NewBatchHandler_[T any](fn func(msg []*sarama.ConsumerMessage, ts []T) error, l logger.LoggerV1, batchSize int, timeout time.Duration) BatchHandler {
-	return &BatchHandler_[T]{fn: fn, l: l, batchSize: batchSize, timeout: timeout}
+	return NewRetryBatchHandler[T](fn, l, batchSize, timeout, NewRetrier(DefaultRetryPolicy, nil, l))
+}
+
+// NewRetryBatchHandler 整批重试，还是失败就把批次里的消息逐条转发到重试 topic 或者死信 topic
+func NewRetryBatchHandler[T any](fn func(msg []*sarama.ConsumerMessage, ts []T) error, l logger.LoggerV1,
+	batchSize int, timeout time.Duration, retrier *Retrier) BatchHandler {
+	return &BatchHandler_[T]{fn: fn, l: l, batchSize: batchSize, timeout: timeout, retrier: retrier}
 }
 
 func (h *BatchHandler_[T]) Setup(session sarama.ConsumerGroupSession) error {
@@ -37,29 +46,37 @@ func (h *BatchHandler_[T]) Cleanup(session sarama.ConsumerGroupSession) error {
 //把处理数据之前的重复逻辑全部封装起来
 
 func (h *BatchHandler_[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	sessCtx := session.Context()
 	msgsCh := claim.Messages()
 	for {
-		ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
-		defer cancel()
+		ctx, cancel := context.WithTimeout(sessCtx, h.timeout)
 		var msgs = make([]*sarama.ConsumerMessage, 0, h.batchSize)
 		var ts = make([]T, 0, h.batchSize)
-		var done = false
+		// 凑批过程中转发出去的坏消息，和批次一起提交
+		var forwarded []*sarama.ConsumerMessage
+		// last 会话结束或者分区被收回，处理完手上这一批就退出
+		var done, last = false, false
 		for i := 0; i < h.batchSize && !done; i++ {
 			select {
 			case msg, ok := <-msgsCh:
 				if !ok {
-					//这里代表通道关闭了，没必要继续
-					cancel()
-					return nil
+					//这里代表通道关闭了，处理完已经拿到的消息就退出
+					last, done = true, true
+					break
+				}
+				if h.retrier.Wait(sessCtx, msg) != nil {
+					// 这条消息没有处理，也不会提交
+					last, done = true, true
+					break
 				}
 				var t T
 				err := json.Unmarshal(msg.Value, &t)
 				if err != nil {
-					h.l.Error("反序列化消息失败", logger.Error(err),
-						logger.String("topic", msg.Topic),
-						logger.Int("partition", int(msg.Partition)),
-						logger.Int("offset", int(msg.Offset)),
-					)
+					if h.retrier.Forward(sessCtx, msg, fmt.Errorf("%w: %w", ErrPoisonMessage, err)) != nil {
+						last, done = true, true
+						break
+					}
+					forwarded = append(forwarded, msg)
 					continue
 				}
 				//把他们两个放在一起，确保长度相同
@@ -67,19 +84,59 @@ func (h *BatchHandler_[T]) ConsumeClaim(session sarama.ConsumerGroupSession, cla
 				ts = append(ts, t)
 			case <-ctx.Done(): //避免等待一批时间过长
 				done = true
+				last = sessCtx.Err() != nil
 			}
 		}
 		cancel()
-		if len(msgs) == 0 {
-			continue
+		if !h.flush(sessCtx, claim, msgs, ts) {
+			return nil
 		}
-		err := h.fn(msgs, ts)
-		if err != nil {
-			h.l.Error("处理消息失败", logger.Error(err)) //这里其实实际上应该记录整个批次的信息才算完整
-			continue
+		for _, msg := range forwarded {
+			session.MarkMessage(msg, "")
 		}
 		for _, msg := range msgs {
 			session.MarkMessage(msg, "")
 		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// flush 处理一批消息，返回 false 说明没处理完，这一批不能提交。
+// 会话结束的时候 sessCtx 已经取消了，用一个新的超时把手上的批次处理完，
+// sarama 会等 ConsumeClaim 返回之后才提交偏移量
+func (h *BatchHandler_[T]) flush(sessCtx context.Context, claim sarama.ConsumerGroupClaim,
+	msgs []*sarama.ConsumerMessage, ts []T) bool {
+	if len(msgs) == 0 {
+		return true
+	}
+	ctx := sessCtx
+	if sessCtx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(sessCtx), h.timeout)
+		defer cancel()
+	}
+	err := h.retrier.Do(ctx, func() error {
+		return h.fn(msgs, ts)
+	})
+	if err == nil {
+		return true
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	h.l.Error("批量处理消息失败", logger.Error(err),
+		logger.String("topic", claim.Topic()),
+		logger.Int("partition", int(claim.Partition())),
+		logger.Int64("first_offset", msgs[0].Offset),
+		logger.Int64("last_offset", msgs[len(msgs)-1].Offset),
+	)
+	// 不知道是哪条消息出的问题，只能逐条转发
+	for _, msg := range msgs {
+		if h.retrier.Forward(ctx, msg, err) != nil {
+			return false
+		}
 	}
+	return true
 }
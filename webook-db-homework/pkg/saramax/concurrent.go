@@ -0,0 +1,154 @@
+package saramax
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+)
+
+// ConcurrentHandler_ 同一个分区的消息按照 key 分给多个 worker 并发处理，
+// 同一个 key 的消息总是在同一个 worker 上，所以同一个 key 的顺序不变。
+// 提交的时候只提交到最小的连续已完成的偏移量，再平衡的时候不会丢消息
+type ConcurrentHandler_[T any] struct {
+	*Handler_[T]
+	workers int
+	// buffer 每个 worker 排队的消息数量，
+	// 在途消息最多 workers * buffer 条，超过了就不再从分区拉消息
+	buffer int
+}
+
+// NewConcurrentHandler workers 小于等于 1 的时候退化成 NewRetryHandler
+func NewConcurrentHandler[T any](fn func(msg *sarama.ConsumerMessage, t T) error, l logger.LoggerV1,
+	retrier *Retrier, workers, buffer int) Handler {
+	return NewDecodingHandler[T](JSONDecoder[T](), fn, l, retrier, workers, buffer)
+}
+
+// NewDecodingHandler 用 decode 解析消息，比如 Schema.Decoder，其余和 NewConcurrentHandler 一样
+func NewDecodingHandler[T any](decode Decoder[T], fn func(msg *sarama.ConsumerMessage, t T) error,
+	l logger.LoggerV1, retrier *Retrier, workers, buffer int) Handler {
+	h := &Handler_[T]{fn: fn, l: l, retrier: retrier, decode: decode}
+	if workers <= 1 {
+		return h
+	}
+	if buffer <= 0 {
+		buffer = 1
+	}
+	return &ConcurrentHandler_[T]{Handler_: h, workers: workers, buffer: buffer}
+}
+
+func (h *ConcurrentHandler_[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx, cancel := context.WithCancel(session.Context())
+	maxInFlight := h.workers * h.buffer
+	// 容量和在途消息上限一样，worker 回报的时候不会阻塞
+	done := make(chan *sarama.ConsumerMessage, maxInFlight)
+	queues := make([]chan *sarama.ConsumerMessage, h.workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan *sarama.ConsumerMessage, h.buffer)
+		wg.Add(1)
+		go func(q <-chan *sarama.ConsumerMessage) {
+			defer wg.Done()
+			for msg := range q {
+				// 已经退出了，剩下的消息不处理，也不会提交
+				if ctx.Err() != nil {
+					continue
+				}
+				// 处理失败说明 ctx 结束了，不回报，这条和后面的都不会提交
+				if h.handle(ctx, msg) {
+					done <- msg
+				}
+			}
+		}(queues[i])
+	}
+	defer func() {
+		cancel()
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+	}()
+
+	tracker := newOffsetTracker()
+	msgs := claim.Messages()
+	var rr int
+	for {
+		in := msgs
+		if tracker.len() >= maxInFlight {
+			// 背压：worker 处理不过来就先不拉新消息
+			in = nil
+		}
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				// 分区被收回了，等在途的消息处理完再退出
+				for tracker.len() > 0 {
+					select {
+					case m := <-done:
+						tracker.complete(session, m)
+					case <-ctx.Done():
+						return nil
+					}
+				}
+				return nil
+			}
+			tracker.add(msg)
+			idx := rr
+			if msg.Key != nil {
+				idx = keyIndex(msg.Key, h.workers)
+			} else {
+				rr = (rr + 1) % h.workers
+			}
+			select {
+			case queues[idx] <- msg:
+			case <-ctx.Done():
+				return nil
+			}
+		case msg := <-done:
+			tracker.complete(session, msg)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func keyIndex(key []byte, n int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write(key)
+	return int(hash.Sum32() % uint32(n))
+}
+
+// offsetTracker 按照拉取顺序记录在途消息，只有前面的消息都完成了才提交。
+// 偏移量不一定连续（比如压缩过的 topic），所以记录的是消息本身
+type offsetTracker struct {
+	pending  []*sarama.ConsumerMessage
+	finished map[int64]bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{finished: make(map[int64]bool)}
+}
+
+func (t *offsetTracker) add(msg *sarama.ConsumerMessage) {
+	t.pending = append(t.pending, msg)
+}
+
+func (t *offsetTracker) len() int {
+	return len(t.pending)
+}
+
+func (t *offsetTracker) complete(session sarama.ConsumerGroupSession, msg *sarama.ConsumerMessage) {
+	t.finished[msg.Offset] = true
+	var last *sarama.ConsumerMessage
+	for len(t.pending) > 0 && t.finished[t.pending[0].Offset] {
+		last = t.pending[0]
+		delete(t.finished, last.Offset)
+		t.pending = t.pending[1:]
+	}
+	if last != nil {
+		// 提交最后一条就相当于提交了前面所有的
+		session.MarkMessage(last, "")
+	}
+}
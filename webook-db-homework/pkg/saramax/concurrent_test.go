@@ -0,0 +1,141 @@
+package saramax
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newKeyedMsg(t *testing.T, offset int64, key string) *sarama.ConsumerMessage {
+	msg := newMsg(t, offset, testEvt{Id: offset})
+	msg.Key = []byte(key)
+	return msg
+}
+
+func newConcurrentHandler(fn func(msg *sarama.ConsumerMessage, evt testEvt) error, workers, buffer int) Handler {
+	l := logger.NewZapLogger(zap.NewNop())
+	return NewConcurrentHandler[testEvt](fn, l, NewRetrier(DefaultRetryPolicy, nil, l), workers, buffer)
+}
+
+func TestConcurrentHandler_KeyOrder(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string][]int64)
+	h := newConcurrentHandler(func(msg *sarama.ConsumerMessage, evt testEvt) error {
+		// 打乱各个 worker 的完成顺序
+		time.Sleep(time.Duration(evt.Id%3) * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		seen[string(msg.Key)] = append(seen[string(msg.Key)], evt.Id)
+		return nil
+	}, 4, 8)
+
+	msgs := make([]*sarama.ConsumerMessage, 0, 100)
+	for i := int64(0); i < 100; i++ {
+		msgs = append(msgs, newKeyedMsg(t, i, fmt.Sprintf("k%d", i%7)))
+	}
+	sess := &fakeSession{ctx: context.Background()}
+	require.NoError(t, h.ConsumeClaim(sess, newClaim(msgs...)))
+
+	total := 0
+	for key, ids := range seen {
+		total += len(ids)
+		assert.IsIncreasing(t, ids, key)
+	}
+	assert.Equal(t, 100, total)
+	assert.Equal(t, int64(99), sess.lastMarked())
+	assert.IsIncreasing(t, sess.marked)
+}
+
+func TestConcurrentHandler_MarkContiguous(t *testing.T) {
+	release := make(chan struct{})
+	processed := make(chan int64, 3)
+	h := newConcurrentHandler(func(msg *sarama.ConsumerMessage, evt testEvt) error {
+		if string(msg.Key) == "slow" {
+			<-release
+		}
+		processed <- evt.Id
+		return nil
+	}, 4, 4)
+
+	// 第一条很慢，后面两条在别的 worker 上先完成
+	ch := make(chan *sarama.ConsumerMessage, 3)
+	ch <- newKeyedMsg(t, 0, "slow")
+	ch <- newKeyedMsg(t, 1, "fast")
+	ch <- newKeyedMsg(t, 2, "fast")
+	sess := &fakeSession{ctx: context.Background()}
+	res := make(chan error, 1)
+	go func() {
+		res <- h.ConsumeClaim(sess, &fakeClaim{ch: ch})
+	}()
+	assert.Equal(t, int64(1), <-processed)
+	assert.Equal(t, int64(2), <-processed)
+	// 给分发协程一点时间处理完成通知
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, int64(-1), sess.lastMarked())
+
+	close(release)
+	assert.Equal(t, int64(0), <-processed)
+	assert.Eventually(t, func() bool {
+		return sess.lastMarked() == 2
+	}, time.Second, time.Millisecond*5)
+	close(ch)
+	require.NoError(t, <-res)
+}
+
+func TestConcurrentHandler_BackPressure(t *testing.T) {
+	release := make(chan struct{})
+	h := newConcurrentHandler(func(msg *sarama.ConsumerMessage, evt testEvt) error {
+		<-release
+		return nil
+	}, 2, 1)
+
+	ch := make(chan *sarama.ConsumerMessage, 5)
+	for i := int64(0); i < 5; i++ {
+		ch <- newKeyedMsg(t, i, fmt.Sprintf("k%d", i))
+	}
+	close(ch)
+	sess := &fakeSession{ctx: context.Background()}
+	res := make(chan error, 1)
+	go func() {
+		res <- h.ConsumeClaim(sess, &fakeClaim{ch: ch})
+	}()
+	// 在途消息最多 2 * 1 条，剩下的留在分区里
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, 3, len(ch))
+
+	close(release)
+	require.NoError(t, <-res)
+	assert.Equal(t, int64(4), sess.lastMarked())
+}
+
+func TestConcurrentHandler_Cancel(t *testing.T) {
+	h := newConcurrentHandler(func(msg *sarama.ConsumerMessage, evt testEvt) error {
+		time.Sleep(time.Millisecond * 10)
+		return nil
+	}, 2, 2)
+	ch := make(chan *sarama.ConsumerMessage)
+	ctx, cancel := context.WithCancel(context.Background())
+	sess := &fakeSession{ctx: ctx}
+	res := make(chan error, 1)
+	go func() {
+		res <- h.ConsumeClaim(sess, &fakeClaim{ch: ch})
+	}()
+	ch <- newKeyedMsg(t, 0, "a")
+	// 会话结束的时候分区通道不一定关闭，也要能退出
+	cancel()
+	select {
+	case err := <-res:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("会话结束之后没有退出")
+	}
+}
@@ -0,0 +1,173 @@
+package saramax
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+)
+
+// 消息信封放在 Kafka 消息头里面，消息体只有业务数据
+const (
+	HeaderEventType     = "x-event-type"
+	HeaderSchemaVersion = "x-schema-version"
+	HeaderEventId       = "x-event-id"
+	HeaderEventTime     = "x-event-time"
+	HeaderPartitionKey  = "x-partition-key"
+	// HeaderTraceParent W3C trace context
+	HeaderTraceParent = "traceparent"
+)
+
+// Envelope 消息的元数据。没有信封的老消息 Version 是 0
+type Envelope struct {
+	Type    string
+	Version int
+	Id      string
+	Time    time.Time
+	Trace   string
+	Key     string
+}
+
+// Headers 转换成 Kafka 消息头
+func (e Envelope) Headers() []sarama.RecordHeader {
+	headers := []sarama.RecordHeader{
+		{Key: []byte(HeaderEventType), Value: []byte(e.Type)},
+		{Key: []byte(HeaderSchemaVersion), Value: []byte(strconv.Itoa(e.Version))},
+		{Key: []byte(HeaderEventId), Value: []byte(e.Id)},
+		{Key: []byte(HeaderEventTime), Value: []byte(strconv.FormatInt(e.Time.UnixMilli(), 10))},
+	}
+	if e.Key != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(HeaderPartitionKey), Value: []byte(e.Key)})
+	}
+	if e.Trace != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(HeaderTraceParent), Value: []byte(e.Trace)})
+	}
+	return headers
+}
+
+// EnvelopeFromMessage 从消息头里面解析信封，没有信封返回零值
+func EnvelopeFromMessage(msg *sarama.ConsumerMessage) (Envelope, error) {
+	env := Envelope{
+		Type:  header(msg, HeaderEventType),
+		Id:    header(msg, HeaderEventId),
+		Trace: header(msg, HeaderTraceParent),
+		Key:   header(msg, HeaderPartitionKey),
+	}
+	if v := header(msg, HeaderSchemaVersion); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("%w: 非法的版本号 %s", ErrPoisonMessage, v)
+		}
+		env.Version = version
+	}
+	if ts := header(msg, HeaderEventTime); ts != "" {
+		ms, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("%w: 非法的事件时间 %s", ErrPoisonMessage, ts)
+		}
+		env.Time = time.UnixMilli(ms)
+	}
+	return env, nil
+}
+
+type traceKey struct{}
+
+// ContextWithTrace 生产消息的时候会把 trace 写进信封，优先级比 logger 里的 trace_id 高
+func ContextWithTrace(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, traceKey{}, traceparent)
+}
+
+// TraceFromContext 没有通过 ContextWithTrace 设置的时候，用 HTTP 中间件或者 gRPC 拦截器
+// 放进 ctx 的 trace_id 生成一个 traceparent，trace_id 不是 32 位十六进制的不传
+func TraceFromContext(ctx context.Context) string {
+	if trace, ok := ctx.Value(traceKey{}).(string); ok {
+		return trace
+	}
+	traceID := logger.TraceIDFromContext(ctx)
+	if !validTraceID(traceID) {
+		return ""
+	}
+	// 生产消息是一个新的 span
+	var span [8]byte
+	_, _ = rand.Read(span[:])
+	return "00-" + traceID + "-" + hex.EncodeToString(span[:]) + "-01"
+}
+
+func validTraceID(id string) bool {
+	if len(id) != 32 || id == strings.Repeat("0", 32) {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Schema 一种事件的类型和当前版本，生产者和消费者共用。
+// Upgrades[v] 把版本 v 的消息体升级到 v+1，没有注册的版本认为消息体没有变化
+type Schema[T any] struct {
+	Type     string
+	Version  int
+	Upgrades map[int]func(data []byte) ([]byte, error)
+}
+
+// NewEnvelope 生成一个新的信封，事件 id 是随机的 UUID
+func (s Schema[T]) NewEnvelope(ctx context.Context, key string) Envelope {
+	return Envelope{
+		Type:    s.Type,
+		Version: s.Version,
+		Id:      uuid.NewString(),
+		Time:    time.Now(),
+		Trace:   TraceFromContext(ctx),
+		Key:     key,
+	}
+}
+
+// Decode 解析信封并且把消息体升级到当前版本。
+// 类型不对或者版本比自己新的消息处理不了，返回 ErrPoisonMessage
+func (s Schema[T]) Decode(msg *sarama.ConsumerMessage) (Envelope, T, error) {
+	var t T
+	env, err := EnvelopeFromMessage(msg)
+	if err != nil {
+		return env, t, err
+	}
+	if env.Type != "" && env.Type != s.Type {
+		return env, t, fmt.Errorf("%w: 事件类型 %s 不是 %s", ErrPoisonMessage, env.Type, s.Type)
+	}
+	if env.Version > s.Version {
+		return env, t, fmt.Errorf("%w: 事件版本 %d 高于 %d", ErrPoisonMessage, env.Version, s.Version)
+	}
+	data := msg.Value
+	for v := env.Version; v < s.Version; v++ {
+		upgrade, ok := s.Upgrades[v]
+		if !ok {
+			continue
+		}
+		data, err = upgrade(data)
+		if err != nil {
+			return env, t, fmt.Errorf("%w: 升级版本 %d 失败 %w", ErrPoisonMessage, v, err)
+		}
+	}
+	if err = json.Unmarshal(data, &t); err != nil {
+		return env, t, fmt.Errorf("%w: %w", ErrPoisonMessage, err)
+	}
+	return env, t, nil
+}
+
+// Decoder 给 Handler 用
+func (s Schema[T]) Decoder() Decoder[T] {
+	return func(msg *sarama.ConsumerMessage) (T, error) {
+		_, t, err := s.Decode(msg)
+		return t, err
+	}
+}
@@ -0,0 +1,199 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEvtV2 第 2 版把 id 改名成了 evt_id
+type testEvtV2 struct {
+	EvtId int64 `json:"evt_id"`
+}
+
+var testSchema = Schema[testEvtV2]{
+	Type:    "test_evt",
+	Version: 2,
+	Upgrades: map[int]func(data []byte) ([]byte, error){
+		1: func(data []byte) ([]byte, error) {
+			var v1 testEvt
+			if err := json.Unmarshal(data, &v1); err != nil {
+				return nil, err
+			}
+			return json.Marshal(testEvtV2{EvtId: v1.Id})
+		},
+	},
+}
+
+func TestProducer_Produce(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	var sent *sarama.ProducerMessage
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(pm *sarama.ProducerMessage) error {
+		sent = pm
+		return nil
+	})
+	p := NewProducer(producer, "evt", testSchema)
+	ctx := ContextWithTrace(context.Background(), "00-trace-span-01")
+	env, err := p.Produce(ctx, "k1", testEvtV2{EvtId: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "test_evt", env.Type)
+	assert.Equal(t, 2, env.Version)
+	assert.NotEmpty(t, env.Id)
+
+	// 消费者能从消息头还原信封
+	val, _ := sent.Value.Encode()
+	key, _ := sent.Key.Encode()
+	assert.Equal(t, "evt", sent.Topic)
+	assert.Equal(t, "k1", string(key))
+	msg := &sarama.ConsumerMessage{Topic: sent.Topic, Value: val}
+	for i := range sent.Headers {
+		msg.Headers = append(msg.Headers, &sent.Headers[i])
+	}
+	got, evt, err := testSchema.Decode(msg)
+	require.NoError(t, err)
+	assert.Equal(t, testEvtV2{EvtId: 3}, evt)
+	assert.Equal(t, env.Id, got.Id)
+	assert.Equal(t, "00-trace-span-01", got.Trace)
+	assert.Equal(t, "k1", got.Key)
+	assert.Equal(t, env.Time.UnixMilli(), got.Time.UnixMilli())
+}
+
+func TestSchema_Decode(t *testing.T) {
+	envHeaders := func(typ string, version int) []*sarama.RecordHeader {
+		return []*sarama.RecordHeader{
+			{Key: []byte(HeaderEventType), Value: []byte(typ)},
+			{Key: []byte(HeaderSchemaVersion), Value: []byte(strconv.Itoa(version))},
+			{Key: []byte(HeaderEventId), Value: []byte("e1")},
+		}
+	}
+	testCases := []struct {
+		name    string
+		msg     *sarama.ConsumerMessage
+		wantEvt testEvtV2
+		wantErr error
+	}{
+		{
+			name:    "当前版本",
+			msg:     &sarama.ConsumerMessage{Value: []byte(`{"evt_id":1}`), Headers: envHeaders("test_evt", 2)},
+			wantEvt: testEvtV2{EvtId: 1},
+		},
+		{
+			name:    "旧版本升级",
+			msg:     &sarama.ConsumerMessage{Value: []byte(`{"id":2}`), Headers: envHeaders("test_evt", 1)},
+			wantEvt: testEvtV2{EvtId: 2},
+		},
+		{
+			// 没有信封是版本 0，0 到 1 没有注册升级，再从 1 升级到 2
+			name:    "没有信封的老消息",
+			msg:     &sarama.ConsumerMessage{Value: []byte(`{"id":3}`)},
+			wantEvt: testEvtV2{EvtId: 3},
+		},
+		{
+			name:    "版本比自己新",
+			msg:     &sarama.ConsumerMessage{Value: []byte(`{"evt_id":1}`), Headers: envHeaders("test_evt", 3)},
+			wantErr: ErrPoisonMessage,
+		},
+		{
+			name:    "类型不对",
+			msg:     &sarama.ConsumerMessage{Value: []byte(`{"evt_id":1}`), Headers: envHeaders("other", 2)},
+			wantErr: ErrPoisonMessage,
+		},
+		{
+			name:    "消息体错误",
+			msg:     &sarama.ConsumerMessage{Value: []byte(`not json`), Headers: envHeaders("test_evt", 2)},
+			wantErr: ErrPoisonMessage,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, evt, err := testSchema.Decode(tc.msg)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.Equal(t, tc.wantEvt, evt)
+			}
+		})
+	}
+}
+
+type memoryIdempotencyStore struct {
+	seen    map[string]bool
+	err     error
+	markErr error
+}
+
+func (s *memoryIdempotencyStore) Seen(ctx context.Context, id string) (bool, error) {
+	return s.seen[id], s.err
+}
+
+func (s *memoryIdempotencyStore) Mark(ctx context.Context, id string) error {
+	if s.markErr != nil {
+		return s.markErr
+	}
+	s.seen[id] = true
+	return s.err
+}
+
+func TestIdempotent(t *testing.T) {
+	store := &memoryIdempotencyStore{seen: map[string]bool{}}
+	var calls int
+	var fail bool
+	fn := Idempotent[testEvt](store, logger.NewNopLogger(), func(msg *sarama.ConsumerMessage, evt testEvt) error {
+		calls++
+		if fail {
+			return errors.New("db 错误")
+		}
+		return nil
+	})
+	withId := func(id string) *sarama.ConsumerMessage {
+		return &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{{Key: []byte(HeaderEventId), Value: []byte(id)}}}
+	}
+
+	// 处理失败不记录，重试的时候还会处理
+	fail = true
+	assert.Error(t, fn(withId("e1"), testEvt{}))
+	fail = false
+	assert.NoError(t, fn(withId("e1"), testEvt{}))
+	assert.Equal(t, 2, calls)
+	// 重复的事件跳过
+	assert.NoError(t, fn(withId("e1"), testEvt{}))
+	assert.Equal(t, 2, calls)
+	// 没有事件 id 不去重
+	assert.NoError(t, fn(&sarama.ConsumerMessage{}, testEvt{}))
+	assert.NoError(t, fn(&sarama.ConsumerMessage{}, testEvt{}))
+	assert.Equal(t, 4, calls)
+
+	// 处理成功但是记录失败不返回错误，不然 Retrier 会马上再处理一次
+	store.markErr = errors.New("redis 错误")
+	assert.NoError(t, fn(withId("e3"), testEvt{}))
+	assert.Equal(t, 5, calls)
+	store.markErr = nil
+
+	// 查询去重存储出错交给重试
+	store.err = errors.New("redis 错误")
+	assert.Error(t, fn(withId("e2"), testEvt{}))
+	assert.Equal(t, 5, calls)
+}
+
+func TestTraceFromContext(t *testing.T) {
+	// 显式设置的优先
+	ctx := logger.ContextWithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, "00-trace-span-01", TraceFromContext(ContextWithTrace(ctx, "00-trace-span-01")))
+
+	// 用 HTTP 中间件放进 ctx 的 trace_id 生成，每次生产都是新的 span
+	trace := TraceFromContext(ctx)
+	assert.Regexp(t, `^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01$`, trace)
+	assert.NotEqual(t, trace, TraceFromContext(ctx))
+
+	// 格式不对的 trace_id 不传
+	assert.Empty(t, TraceFromContext(logger.ContextWithTraceID(context.Background(), "abc")))
+	assert.Empty(t, TraceFromContext(context.Background()))
+}
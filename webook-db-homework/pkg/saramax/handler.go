@@ -1,7 +1,10 @@
 package saramax
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"webook/pkg/logger"
 
 	"github.com/IBM/sarama"
@@ -13,13 +16,33 @@ type Handler interface {
 	ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error
 }
 
+// Decoder 把消息解析成 T，解析失败的消息会直接进入死信
+type Decoder[T any] func(msg *sarama.ConsumerMessage) (T, error)
+
+// JSONDecoder 消息体直接是 T 的 JSON，不看信封
+func JSONDecoder[T any]() Decoder[T] {
+	return func(msg *sarama.ConsumerMessage) (T, error) {
+		var t T
+		err := json.Unmarshal(msg.Value, &t)
+		return t, err
+	}
+}
+
 type Handler_[T any] struct {
-	fn func(msg *sarama.ConsumerMessage, t T) error
-	l  logger.LoggerV1
+	fn      func(msg *sarama.ConsumerMessage, t T) error
+	l       logger.LoggerV1
+	retrier *Retrier
+	decode  Decoder[T]
 }
 
+// NewHandler_ 只在进程内重试，最终失败的消息打日志之后提交
 func NewHandler_[T any](fn func(msg *sarama.ConsumerMessage, t T) error, l logger.LoggerV1) Handler {
-	return &Handler_[T]{fn: fn, l: l}
+	return NewRetryHandler[T](fn, l, NewRetrier(DefaultRetryPolicy, nil, l))
+}
+
+// NewRetryHandler 按照 retrier 的策略重试，最终失败的消息转发到重试 topic 或者死信 topic
+func NewRetryHandler[T any](fn func(msg *sarama.ConsumerMessage, t T) error, l logger.LoggerV1, retrier *Retrier) Handler {
+	return &Handler_[T]{fn: fn, l: l, retrier: retrier, decode: JSONDecoder[T]()}
 }
 
 func (h *Handler_[T]) Setup(session sarama.ConsumerGroupSession) error {
@@ -33,32 +56,41 @@ func (h *Handler_[T]) Cleanup(session sarama.ConsumerGroupSession) error {
 //把处理数据之前的重复逻辑全部封装起来
 
 func (h *Handler_[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx := session.Context()
 	msgs := claim.Messages()
 	for msg := range msgs {
-		var t T
-		err := json.Unmarshal(msg.Value, &t)
-		if err != nil {
-			h.l.Error("反序列化消息失败", logger.Error(err),
-				logger.String("topic", msg.Topic),
-				logger.Int("partition", int(msg.Partition)),
-				logger.Int("offset", int(msg.Offset)),
-			)
-			continue
-		}
-		err = h.fn(msg, t)
-		//在这里执行重试,比如简单的回调多次
-		//在这里回调真正的处理逻辑，也就是我们只要写好具体的消费逻辑就好
-		if err != nil {
-			h.l.Error("处理消息失败", logger.Error(err),
-				logger.String("topic", msg.Topic),
-				logger.Int("partition", int(msg.Partition)),
-				logger.Int("offset", int(msg.Offset)),
-			)
-			return err
-		} else {
-			session.MarkMessage(msg, "")
+		if !h.handle(ctx, msg) {
+			// 再平衡或者退出，不提交，交给下一个消费者
+			return nil
 		}
+		session.MarkMessage(msg, "")
 	}
-
 	return nil
 }
+
+// handle 处理一条消息，包括重试和转发，返回 false 说明 ctx 已经结束，这条消息不能提交
+func (h *Handler_[T]) handle(ctx context.Context, msg *sarama.ConsumerMessage) bool {
+	// 重试 topic 的消息没到时间先等着
+	if h.retrier.Wait(ctx, msg) != nil {
+		return false
+	}
+	t, err := h.decode(msg)
+	if err != nil {
+		if !errors.Is(err, ErrPoisonMessage) {
+			err = fmt.Errorf("%w: %w", ErrPoisonMessage, err)
+		}
+	} else {
+		//在这里回调真正的处理逻辑，也就是我们只要写好具体的消费逻辑就好
+		err = h.retrier.Do(ctx, func() error {
+			return h.fn(msg, t)
+		})
+	}
+	if err == nil {
+		return true
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	// 转发出去之后才能提交，转发不出去就不提交
+	return h.retrier.Forward(ctx, msg, err) == nil
+}
@@ -0,0 +1,75 @@
+package saramax
+
+import (
+	"context"
+	"time"
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyStore 记录处理过的事件 id
+type IdempotencyStore interface {
+	Seen(ctx context.Context, id string) (bool, error)
+	Mark(ctx context.Context, id string) error
+}
+
+// Idempotent 按照信封里的事件 id 去重，处理成功之后才记录。
+// 记录失败只打日志不返回错误，返回错误会让 Retrier 马上再处理一次，反而一定重复；
+// 不返回的话只有这条消息以后被重新投递才会重复处理，业务要能容忍。
+// 没有事件 id 的老消息不去重
+func Idempotent[T any](store IdempotencyStore, l logger.LoggerV1,
+	fn func(msg *sarama.ConsumerMessage, t T) error) func(msg *sarama.ConsumerMessage, t T) error {
+	return func(msg *sarama.ConsumerMessage, t T) error {
+		id := header(msg, HeaderEventId)
+		if id == "" {
+			return fn(msg, t)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		seen, err := store.Seen(ctx, id)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if seen {
+			return nil
+		}
+		if err = fn(msg, t); err != nil {
+			return err
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err = store.Mark(ctx, id); err != nil {
+			l.Error("记录已处理的事件失败", logger.Error(err),
+				logger.String("event_id", id),
+				logger.String("topic", msg.Topic),
+				logger.Int64("offset", msg.Offset))
+		}
+		return nil
+	}
+}
+
+type RedisIdempotencyStore struct {
+	cmd    redis.Cmdable
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisIdempotencyStore ttl 要比消息可能重复投递的时间窗口长
+func NewRedisIdempotencyStore(cmd redis.Cmdable, prefix string, ttl time.Duration) IdempotencyStore {
+	return &RedisIdempotencyStore{cmd: cmd, prefix: prefix, ttl: ttl}
+}
+
+func (s *RedisIdempotencyStore) Seen(ctx context.Context, id string) (bool, error) {
+	n, err := s.cmd.Exists(ctx, s.key(id)).Result()
+	return n > 0, err
+}
+
+func (s *RedisIdempotencyStore) Mark(ctx context.Context, id string) error {
+	return s.cmd.Set(ctx, s.key(id), 1, s.ttl).Err()
+}
+
+func (s *RedisIdempotencyStore) key(id string) string {
+	return s.prefix + ":" + id
+}
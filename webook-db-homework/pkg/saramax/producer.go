@@ -0,0 +1,38 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/IBM/sarama"
+)
+
+// Producer 发送 T 类型的事件，消息体是 JSON，信封放在消息头里
+type Producer[T any] struct {
+	producer sarama.SyncProducer
+	topic    string
+	schema   Schema[T]
+}
+
+func NewProducer[T any](producer sarama.SyncProducer, topic string, schema Schema[T]) *Producer[T] {
+	return &Producer[T]{producer: producer, topic: topic, schema: schema}
+}
+
+// Produce key 决定分区，同一个 key 的消息有序
+func (p *Producer[T]) Produce(ctx context.Context, key string, evt T) (Envelope, error) {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return Envelope{}, err
+	}
+	env := p.schema.NewEnvelope(ctx, key)
+	msg := &sarama.ProducerMessage{
+		Topic:   p.topic,
+		Value:   sarama.ByteEncoder(data),
+		Headers: env.Headers(),
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	_, _, err = p.producer.SendMessage(msg)
+	return env, err
+}
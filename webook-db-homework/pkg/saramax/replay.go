@@ -0,0 +1,129 @@
+package saramax
+
+import (
+	"context"
+	"errors"
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+)
+
+// Replayer 把死信 topic 里面的消息重新投递回原始 topic。
+// 只重放启动那一刻已经存在的消息，重放过程中新进来的死信留给下一次
+type Replayer struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	l        logger.LoggerV1
+}
+
+func NewReplayer(client sarama.Client, producer sarama.SyncProducer, l logger.LoggerV1) *Replayer {
+	return &Replayer{client: client, producer: producer, l: l}
+}
+
+// ReplayResult 每个分区重放到哪里了，下一次可以从 Next 开始
+type ReplayResult struct {
+	Partition int32
+	Replayed  int
+	Skipped   int
+	Next      int64
+}
+
+// Replay 重放 dlqTopic 所有分区，from 是每个分区开始的偏移量，一般是上一次返回的 Next，
+// 不在 from 里面的分区从头开始
+func (r *Replayer) Replay(ctx context.Context, dlqTopic string, from map[int32]int64) ([]ReplayResult, error) {
+	partitions, err := r.client.Partitions(dlqTopic)
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(r.client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+	res := make([]ReplayResult, 0, len(partitions))
+	for _, p := range partitions {
+		offset, ok := from[p]
+		if !ok {
+			offset = sarama.OffsetOldest
+		}
+		pr, err := r.replayPartition(ctx, consumer, dlqTopic, p, offset)
+		res = append(res, pr)
+		if err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func (r *Replayer) replayPartition(ctx context.Context, consumer sarama.Consumer,
+	topic string, partition int32, from int64) (ReplayResult, error) {
+	res := ReplayResult{Partition: partition, Next: from}
+	oldest, err := r.client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return res, err
+	}
+	hwm, err := r.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return res, err
+	}
+	if from < oldest {
+		from = oldest
+	}
+	res.Next = from
+	if from >= hwm {
+		return res, nil
+	}
+	pc, err := consumer.ConsumePartition(topic, partition, from)
+	if err != nil {
+		return res, err
+	}
+	defer pc.Close()
+	for res.Next < hwm {
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		case msg, ok := <-pc.Messages():
+			if !ok {
+				return res, errors.New("分区消费意外结束")
+			}
+			pm := ReplayMessage(msg)
+			if pm == nil {
+				r.l.Warn("死信消息缺少原始 topic，跳过",
+					logger.String("topic", topic),
+					logger.Int("partition", int(partition)),
+					logger.Int64("offset", msg.Offset))
+				res.Skipped++
+			} else if _, _, err = r.producer.SendMessage(pm); err != nil {
+				return res, err
+			} else {
+				res.Replayed++
+			}
+			res.Next = msg.Offset + 1
+		}
+	}
+	return res, nil
+}
+
+// ReplayMessage 还原成最开始的消息：原始 topic、key、value，去掉重试相关的消息头。
+// 没有原始 topic 的消息返回 nil
+func ReplayMessage(msg *sarama.ConsumerMessage) *sarama.ProducerMessage {
+	topic := header(msg, HeaderOriginalTopic)
+	if topic == "" {
+		return nil
+	}
+	var headers []sarama.RecordHeader
+	for _, h := range msg.Headers {
+		if h != nil && !isRetryHeader(string(h.Key)) {
+			headers = append(headers, *h)
+		}
+	}
+	pm := &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+	if msg.Key != nil {
+		pm.Key = sarama.ByteEncoder(msg.Key)
+	}
+	return pm
+}
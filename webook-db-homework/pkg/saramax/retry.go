@@ -0,0 +1,231 @@
+package saramax
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+)
+
+// 转发到重试 topic 和死信 topic 的时候带上的消息头
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+	// HeaderAttempts 已经处理过几轮，进程内的多次重试算一轮
+	HeaderAttempts = "x-attempts"
+	// HeaderRetryAt 重试 topic 的消息要等到这个时间（毫秒时间戳）之后才处理
+	HeaderRetryAt = "x-retry-at"
+)
+
+// ErrPoisonMessage 没办法处理的消息，比如反序列化失败，不会重试直接进入死信 topic
+var ErrPoisonMessage = errors.New("消息格式错误")
+
+// RetryTopic 延迟重试的 topic，消息至少在 Delay 之后才会被再次处理
+type RetryTopic struct {
+	Topic string        `yaml:"topic"`
+	Delay time.Duration `yaml:"delay"`
+}
+
+// RetryPolicy 先在进程内重试 MaxAttempts 次，还是失败就依次转发到 RetryTopics，
+// 所有的重试 topic 都失败之后进入 DLQTopic
+type RetryPolicy struct {
+	// MaxAttempts 进程内最多处理几次，包括第一次
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	RetryTopics []RetryTopic
+	// DLQTopic 为空的时候最终失败的消息只打日志
+	DLQTopic string
+}
+
+// DefaultRetryPolicy 只在进程内重试，不转发
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     time.Millisecond * 100,
+	MaxBackoff:  time.Second,
+}
+
+// Topics 消费者除了原始 topic 之外还要订阅这些重试 topic
+func (p RetryPolicy) Topics() []string {
+	topics := make([]string, 0, len(p.RetryTopics))
+	for _, rt := range p.RetryTopics {
+		topics = append(topics, rt.Topic)
+	}
+	return topics
+}
+
+// Retrier 实现 RetryPolicy，Handler_ 和 BatchHandler_ 共用
+type Retrier struct {
+	policy   RetryPolicy
+	producer sarama.SyncProducer
+	l        logger.LoggerV1
+	now      func() time.Time
+}
+
+// NewRetrier producer 为 nil 的时候不会转发，只在进程内重试
+func NewRetrier(policy RetryPolicy, producer sarama.SyncProducer, l logger.LoggerV1) *Retrier {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	return &Retrier{
+		policy:   policy,
+		producer: producer,
+		l:        l,
+		now:      time.Now,
+	}
+}
+
+// Topics 见 RetryPolicy.Topics
+func (r *Retrier) Topics() []string {
+	return r.policy.Topics()
+}
+
+// Do 进程内重试，ctx 结束的时候返回 ctx 的错误
+func (r *Retrier) Do(ctx context.Context, fn func() error) error {
+	backoff := r.policy.Backoff
+	var err error
+	for i := 0; i < r.policy.MaxAttempts; i++ {
+		if i > 0 {
+			if er := sleep(ctx, backoff); er != nil {
+				return er
+			}
+			backoff *= 2
+			if backoff > r.policy.MaxBackoff {
+				backoff = r.policy.MaxBackoff
+			}
+		}
+		err = fn()
+		if err == nil || errors.Is(err, ErrPoisonMessage) {
+			return err
+		}
+	}
+	return err
+}
+
+// Wait 重试 topic 的消息要等到 HeaderRetryAt 之后才处理。
+// 同一个重试 topic 的延迟都一样，所以前面的消息没到时间，后面的也不会到
+func (r *Retrier) Wait(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	retryAt, err := strconv.ParseInt(header(msg, HeaderRetryAt), 10, 64)
+	if err != nil {
+		return nil
+	}
+	return sleep(ctx, time.UnixMilli(retryAt).Sub(r.now()))
+}
+
+// Forward 最终处理失败的消息转发到下一个重试 topic 或者死信 topic。
+// 转发失败会一直重试，直到 ctx 结束，因为转发不出去的话这条消息就不能提交
+func (r *Retrier) Forward(ctx context.Context, msg *sarama.ConsumerMessage, cause error) error {
+	attempts, _ := strconv.Atoi(header(msg, HeaderAttempts))
+	attempts++
+	fields := []logger.Field{
+		logger.String("topic", msg.Topic),
+		logger.Int("partition", int(msg.Partition)),
+		logger.Int64("offset", msg.Offset),
+		logger.Int("attempts", attempts),
+		logger.Error(cause),
+	}
+	var target string
+	var retryAt time.Time
+	switch {
+	case !errors.Is(cause, ErrPoisonMessage) && attempts <= len(r.policy.RetryTopics):
+		rt := r.policy.RetryTopics[attempts-1]
+		target, retryAt = rt.Topic, r.now().Add(rt.Delay)
+	case r.policy.DLQTopic != "":
+		target = r.policy.DLQTopic
+	}
+	if target == "" || r.producer == nil {
+		r.l.Error("消息处理失败，丢弃", fields...)
+		return nil
+	}
+	pm := r.message(msg, target, attempts, retryAt, cause)
+	backoff := max(r.policy.Backoff, time.Millisecond*10)
+	for {
+		_, _, err := r.producer.SendMessage(pm)
+		if err == nil {
+			r.l.Warn("消息处理失败，转发到 "+target, fields...)
+			return nil
+		}
+		r.l.Error("转发失败消息失败", append(fields, logger.String("target", target),
+			logger.String("produce_error", err.Error()))...)
+		if er := sleep(ctx, backoff); er != nil {
+			return er
+		}
+		backoff = min(backoff*2, max(r.policy.MaxBackoff, time.Second))
+	}
+}
+
+func (r *Retrier) message(msg *sarama.ConsumerMessage, target string, attempts int,
+	retryAt time.Time, cause error) *sarama.ProducerMessage {
+	// 重试过的消息保留最开始的来源
+	origTopic, origPartition, origOffset := header(msg, HeaderOriginalTopic),
+		header(msg, HeaderOriginalPartition), header(msg, HeaderOriginalOffset)
+	if origTopic == "" {
+		origTopic = msg.Topic
+		origPartition = strconv.Itoa(int(msg.Partition))
+		origOffset = strconv.FormatInt(msg.Offset, 10)
+	}
+	headers := []sarama.RecordHeader{
+		{Key: []byte(HeaderOriginalTopic), Value: []byte(origTopic)},
+		{Key: []byte(HeaderOriginalPartition), Value: []byte(origPartition)},
+		{Key: []byte(HeaderOriginalOffset), Value: []byte(origOffset)},
+		{Key: []byte(HeaderError), Value: []byte(cause.Error())},
+		{Key: []byte(HeaderAttempts), Value: []byte(strconv.Itoa(attempts))},
+	}
+	if !retryAt.IsZero() {
+		headers = append(headers, sarama.RecordHeader{
+			Key: []byte(HeaderRetryAt), Value: []byte(strconv.FormatInt(retryAt.UnixMilli(), 10)),
+		})
+	}
+	// 业务自己的消息头原样带上
+	for _, h := range msg.Headers {
+		if h != nil && !isRetryHeader(string(h.Key)) {
+			headers = append(headers, *h)
+		}
+	}
+	pm := &sarama.ProducerMessage{
+		Topic:   target,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+	if msg.Key != nil {
+		pm.Key = sarama.ByteEncoder(msg.Key)
+	}
+	return pm
+}
+
+func isRetryHeader(key string) bool {
+	switch key {
+	case HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset,
+		HeaderError, HeaderAttempts, HeaderRetryAt:
+		return true
+	}
+	return false
+}
+
+func header(msg *sarama.ConsumerMessage, key string) string {
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
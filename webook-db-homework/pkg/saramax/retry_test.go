@@ -0,0 +1,335 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type testEvt struct {
+	Id int64 `json:"id"`
+}
+
+type fakeSession struct {
+	ctx    context.Context
+	mu     sync.Mutex
+	marked []int64
+}
+
+func (s *fakeSession) Claims() map[string][]int32               { return nil }
+func (s *fakeSession) MemberID() string                         { return "" }
+func (s *fakeSession) GenerationID() int32                      { return 0 }
+func (s *fakeSession) MarkOffset(string, int32, int64, string)  {}
+func (s *fakeSession) Commit()                                  {}
+func (s *fakeSession) ResetOffset(string, int32, int64, string) {}
+func (s *fakeSession) Context() context.Context                 { return s.ctx }
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *fakeSession) lastMarked() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.marked) == 0 {
+		return -1
+	}
+	return s.marked[len(s.marked)-1]
+}
+
+type fakeClaim struct {
+	ch chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string                            { return "evt" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.ch }
+
+func newClaim(msgs ...*sarama.ConsumerMessage) *fakeClaim {
+	ch := make(chan *sarama.ConsumerMessage, len(msgs))
+	for _, msg := range msgs {
+		ch <- msg
+	}
+	close(ch)
+	return &fakeClaim{ch: ch}
+}
+
+func newMsg(t *testing.T, offset int64, value any, headers ...*sarama.RecordHeader) *sarama.ConsumerMessage {
+	var val []byte
+	switch v := value.(type) {
+	case string:
+		val = []byte(v)
+	default:
+		var err error
+		val, err = json.Marshal(v)
+		require.NoError(t, err)
+	}
+	return &sarama.ConsumerMessage{Topic: "evt", Offset: offset, Key: []byte("k"), Value: val, Headers: headers}
+}
+
+func headerMap(pm *sarama.ProducerMessage) map[string]string {
+	res := make(map[string]string, len(pm.Headers))
+	for _, h := range pm.Headers {
+		res[string(h.Key)] = string(h.Value)
+	}
+	return res
+}
+
+var testPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     time.Millisecond,
+	MaxBackoff:  time.Millisecond * 2,
+	RetryTopics: []RetryTopic{{Topic: "evt_retry_10s", Delay: time.Second * 10}},
+	DLQTopic:    "evt_dlq",
+}
+
+func TestHandler_ConsumeClaim(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	testCases := []struct {
+		name string
+		msg  func(t *testing.T) *sarama.ConsumerMessage
+		fn   func(calls *int) func(msg *sarama.ConsumerMessage, evt testEvt) error
+		// 期望转发出去的消息
+		forward func(t *testing.T, pm *sarama.ProducerMessage)
+
+		wantCalls int
+	}{
+		{
+			name: "处理成功",
+			msg: func(t *testing.T) *sarama.ConsumerMessage {
+				return newMsg(t, 1, testEvt{Id: 1})
+			},
+			fn: func(calls *int) func(msg *sarama.ConsumerMessage, evt testEvt) error {
+				return func(msg *sarama.ConsumerMessage, evt testEvt) error {
+					*calls++
+					return nil
+				}
+			},
+			wantCalls: 1,
+		},
+		{
+			name: "进程内重试成功",
+			msg: func(t *testing.T) *sarama.ConsumerMessage {
+				return newMsg(t, 1, testEvt{Id: 1})
+			},
+			fn: func(calls *int) func(msg *sarama.ConsumerMessage, evt testEvt) error {
+				return func(msg *sarama.ConsumerMessage, evt testEvt) error {
+					*calls++
+					if *calls < 3 {
+						return errors.New("db 错误")
+					}
+					return nil
+				}
+			},
+			wantCalls: 3,
+		},
+		{
+			name: "重试失败转发到重试 topic",
+			msg: func(t *testing.T) *sarama.ConsumerMessage {
+				return newMsg(t, 7, testEvt{Id: 1}, &sarama.RecordHeader{Key: []byte("trace"), Value: []byte("abc")})
+			},
+			fn: func(calls *int) func(msg *sarama.ConsumerMessage, evt testEvt) error {
+				return func(msg *sarama.ConsumerMessage, evt testEvt) error {
+					*calls++
+					return errors.New("db 错误")
+				}
+			},
+			forward: func(t *testing.T, pm *sarama.ProducerMessage) {
+				assert.Equal(t, "evt_retry_10s", pm.Topic)
+				assert.Equal(t, map[string]string{
+					HeaderOriginalTopic:     "evt",
+					HeaderOriginalPartition: "0",
+					HeaderOriginalOffset:    "7",
+					HeaderError:             "db 错误",
+					HeaderAttempts:          "1",
+					HeaderRetryAt:           strconv.FormatInt(now.Add(time.Second*10).UnixMilli(), 10),
+					"trace":                 "abc",
+				}, headerMap(pm))
+				val, _ := pm.Value.Encode()
+				assert.JSONEq(t, `{"id":1}`, string(val))
+			},
+			wantCalls: 3,
+		},
+		{
+			name: "重试 topic 也失败，进入死信",
+			msg: func(t *testing.T) *sarama.ConsumerMessage {
+				msg := newMsg(t, 2, testEvt{Id: 1},
+					&sarama.RecordHeader{Key: []byte(HeaderOriginalTopic), Value: []byte("evt")},
+					&sarama.RecordHeader{Key: []byte(HeaderOriginalPartition), Value: []byte("3")},
+					&sarama.RecordHeader{Key: []byte(HeaderOriginalOffset), Value: []byte("100")},
+					&sarama.RecordHeader{Key: []byte(HeaderAttempts), Value: []byte("1")},
+					// 已经到时间了
+					&sarama.RecordHeader{Key: []byte(HeaderRetryAt), Value: []byte(strconv.FormatInt(now.UnixMilli(), 10))},
+				)
+				msg.Topic = "evt_retry_10s"
+				return msg
+			},
+			fn: func(calls *int) func(msg *sarama.ConsumerMessage, evt testEvt) error {
+				return func(msg *sarama.ConsumerMessage, evt testEvt) error {
+					*calls++
+					return errors.New("db 错误")
+				}
+			},
+			forward: func(t *testing.T, pm *sarama.ProducerMessage) {
+				assert.Equal(t, "evt_dlq", pm.Topic)
+				assert.Equal(t, map[string]string{
+					HeaderOriginalTopic:     "evt",
+					HeaderOriginalPartition: "3",
+					HeaderOriginalOffset:    "100",
+					HeaderError:             "db 错误",
+					HeaderAttempts:          "2",
+				}, headerMap(pm))
+			},
+			wantCalls: 3,
+		},
+		{
+			name: "反序列化失败直接进入死信",
+			msg: func(t *testing.T) *sarama.ConsumerMessage {
+				return newMsg(t, 5, "not json")
+			},
+			fn: func(calls *int) func(msg *sarama.ConsumerMessage, evt testEvt) error {
+				return func(msg *sarama.ConsumerMessage, evt testEvt) error {
+					*calls++
+					return nil
+				}
+			},
+			forward: func(t *testing.T, pm *sarama.ProducerMessage) {
+				assert.Equal(t, "evt_dlq", pm.Topic)
+				assert.Equal(t, "1", headerMap(pm)[HeaderAttempts])
+				val, _ := pm.Value.Encode()
+				assert.Equal(t, "not json", string(val))
+			},
+			wantCalls: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			producer := mocks.NewSyncProducer(t, nil)
+			defer producer.Close()
+			if tc.forward != nil {
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(pm *sarama.ProducerMessage) error {
+					tc.forward(t, pm)
+					return nil
+				})
+			}
+			r := NewRetrier(testPolicy, producer, logger.NewZapLogger(zap.NewNop()))
+			r.now = func() time.Time { return now }
+			var calls int
+			h := NewRetryHandler[testEvt](tc.fn(&calls), logger.NewZapLogger(zap.NewNop()), r)
+			msg := tc.msg(t)
+			sess := &fakeSession{ctx: context.Background()}
+			err := h.ConsumeClaim(sess, newClaim(msg))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantCalls, calls)
+			// 不管成功还是转发出去，都要提交
+			assert.Equal(t, []int64{msg.Offset}, sess.marked)
+		})
+	}
+}
+
+func TestHandler_ForwardFailedNotMarked(t *testing.T) {
+	producer := &failProducer{}
+	r := NewRetrier(testPolicy, producer, logger.NewZapLogger(zap.NewNop()))
+	h := NewRetryHandler[testEvt](func(msg *sarama.ConsumerMessage, evt testEvt) error {
+		return errors.New("db 错误")
+	}, logger.NewZapLogger(zap.NewNop()), r)
+
+	// 转发一直失败，直到会话结束，不能提交
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	sess := &fakeSession{ctx: ctx}
+	err := h.ConsumeClaim(sess, newClaim(newMsg(t, 1, testEvt{Id: 1})))
+	require.NoError(t, err)
+	assert.Empty(t, sess.marked)
+	assert.Greater(t, producer.cnt, 1)
+}
+
+// failProducer 一直发送失败
+type failProducer struct {
+	sarama.SyncProducer
+	cnt int
+}
+
+func (p *failProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.cnt++
+	return 0, 0, errors.New("kafka 不可用")
+}
+
+func TestRetrier_Wait(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	r := NewRetrier(testPolicy, nil, logger.NewZapLogger(zap.NewNop()))
+	r.now = func() time.Time { return now }
+	msg := newMsg(t, 1, testEvt{Id: 1}, &sarama.RecordHeader{
+		Key: []byte(HeaderRetryAt), Value: []byte(strconv.FormatInt(now.Add(time.Minute).UnixMilli(), 10)),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	// 还没到时间，等到 ctx 结束
+	assert.Equal(t, context.DeadlineExceeded, r.Wait(ctx, msg))
+	// 没有 HeaderRetryAt 不用等
+	assert.NoError(t, r.Wait(context.Background(), newMsg(t, 1, testEvt{Id: 1})))
+}
+
+func TestBatchHandler_ConsumeClaim(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	var forwarded []string
+	for i := 0; i < 3; i++ {
+		producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(pm *sarama.ProducerMessage) error {
+			forwarded = append(forwarded, pm.Topic+":"+headerMap(pm)[HeaderOriginalOffset])
+			return nil
+		})
+	}
+	r := NewRetrier(testPolicy, producer, logger.NewZapLogger(zap.NewNop()))
+	var calls int
+	h := NewRetryBatchHandler[testEvt](func(msgs []*sarama.ConsumerMessage, ts []testEvt) error {
+		calls++
+		return errors.New("db 错误")
+	}, logger.NewZapLogger(zap.NewNop()), 10, time.Second, r)
+	sess := &fakeSession{ctx: context.Background()}
+	err := h.ConsumeClaim(sess, newClaim(
+		newMsg(t, 1, testEvt{Id: 1}),
+		newMsg(t, 2, "not json"),
+		newMsg(t, 3, testEvt{Id: 3}),
+	))
+	require.NoError(t, err)
+	// 整批重试 3 次
+	assert.Equal(t, 3, calls)
+	// 坏消息直接进入死信，其余的逐条进入重试 topic
+	assert.Equal(t, []string{"evt_dlq:2", "evt_retry_10s:1", "evt_retry_10s:3"}, forwarded)
+	assert.ElementsMatch(t, []int64{1, 2, 3}, sess.marked)
+}
+
+func TestReplayMessage(t *testing.T) {
+	msg := newMsg(t, 9, testEvt{Id: 1},
+		&sarama.RecordHeader{Key: []byte(HeaderOriginalTopic), Value: []byte("evt")},
+		&sarama.RecordHeader{Key: []byte(HeaderError), Value: []byte("db 错误")},
+		&sarama.RecordHeader{Key: []byte(HeaderAttempts), Value: []byte("2")},
+		&sarama.RecordHeader{Key: []byte("trace"), Value: []byte("abc")},
+	)
+	msg.Topic = "evt_dlq"
+	pm := ReplayMessage(msg)
+	require.NotNil(t, pm)
+	assert.Equal(t, "evt", pm.Topic)
+	assert.Equal(t, map[string]string{"trace": "abc"}, headerMap(pm))
+	key, _ := pm.Key.Encode()
+	assert.Equal(t, "k", string(key))
+
+	// 不是转发过来的消息不知道回放到哪里
+	assert.Nil(t, ReplayMessage(newMsg(t, 1, testEvt{Id: 1})))
+}
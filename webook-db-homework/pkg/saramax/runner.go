@@ -0,0 +1,243 @@
+package saramax
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+)
+
+// 消费者的状态
+const (
+	StateStarting = "starting"
+	// StateConsuming 已经加入消费者组并且分到了分区
+	StateConsuming = "consuming"
+	// StateRebalancing 会话结束，正在重新加入消费者组
+	StateRebalancing = "rebalancing"
+	// StateError Consume 出错，比如 broker 或者协调者不可用，等一会儿重试
+	StateError   = "error"
+	StateStopped = "stopped"
+)
+
+// PartitionLag Lag 是分区最新的偏移量减去下一条要消费的偏移量
+type PartitionLag struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Offset        int64  `json:"offset"`
+	HighWaterMark int64  `json:"high_water_mark"`
+	Lag           int64  `json:"lag"`
+}
+
+type Health struct {
+	Name       string         `json:"name"`
+	State      string         `json:"state"`
+	Ready      bool           `json:"ready"`
+	LastError  string         `json:"last_error,omitempty"`
+	Partitions []PartitionLag `json:"partitions,omitempty"`
+}
+
+// ConsumerRunner 管理一个消费者组的生命周期：
+// 再平衡之后重新调用 Consume，Stop 的时候等处理中的消息处理完，提交偏移量之后关闭
+type ConsumerRunner struct {
+	name    string
+	cg      sarama.ConsumerGroup
+	topics  []string
+	handler sarama.ConsumerGroupHandler
+	l       logger.LoggerV1
+	// backoff Consume 出错之后多久重试
+	backoff time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	state   atomic.Value
+	lastErr atomic.Value
+	mu      sync.Mutex
+	lags    map[topicPartition]*PartitionLag
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+func NewConsumerRunner(name string, cg sarama.ConsumerGroup, topics []string,
+	handler sarama.ConsumerGroupHandler, l logger.LoggerV1) *ConsumerRunner {
+	r := &ConsumerRunner{
+		name:    name,
+		cg:      cg,
+		topics:  topics,
+		handler: handler,
+		l:       l,
+		backoff: time.Second,
+		done:    make(chan struct{}),
+		lags:    make(map[topicPartition]*PartitionLag),
+	}
+	r.state.Store(StateStarting)
+	r.lastErr.Store("")
+	return r
+}
+
+// Start 在后台运行，直到调用 Stop
+func (r *ConsumerRunner) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.run(ctx)
+	return nil
+}
+
+// Stop 结束当前会话，等处理中的消息处理完、批量消息刷完，然后提交偏移量并关闭消费者组。
+// ctx 超时之后直接返回，不再等待
+func (r *ConsumerRunner) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *ConsumerRunner) run(ctx context.Context) {
+	defer close(r.done)
+	go r.watchErrors()
+	for ctx.Err() == nil {
+		// 每次再平衡之后 Consume 都会返回，要重新调用才能重新加入消费者组
+		err := r.cg.Consume(ctx, r.topics, &runnerHandler{ConsumerGroupHandler: r.handler, r: r})
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			break
+		}
+		if err != nil {
+			// 不改状态的话上一次会话的 consuming 会一直留着，健康检查看不出来已经不在消费了
+			r.state.Store(StateError)
+			r.recordError(err)
+			r.l.Error("消费者组消费失败", logger.String("name", r.name), logger.Error(err))
+			select {
+			case <-ctx.Done():
+			case <-time.After(r.backoff):
+			}
+			continue
+		}
+		if ctx.Err() == nil {
+			r.state.Store(StateRebalancing)
+		}
+	}
+	// Close 会提交已经标记的偏移量
+	if err := r.cg.Close(); err != nil {
+		r.l.Error("关闭消费者组失败", logger.String("name", r.name), logger.Error(err))
+	}
+	r.state.Store(StateStopped)
+}
+
+// watchErrors 需要 Consumer.Return.Errors = true，否则这里收不到错误
+func (r *ConsumerRunner) watchErrors() {
+	for err := range r.cg.Errors() {
+		r.recordError(err)
+		r.l.Error("消费者组错误", logger.String("name", r.name), logger.Error(err))
+	}
+}
+
+func (r *ConsumerRunner) recordError(err error) {
+	r.lastErr.Store(err.Error())
+}
+
+// Health Ready 表示已经加入消费者组，可以对外提供服务
+func (r *ConsumerRunner) Health() Health {
+	state := r.state.Load().(string)
+	h := Health{
+		Name:      r.name,
+		State:     state,
+		Ready:     state == StateConsuming,
+		LastError: r.lastErr.Load().(string),
+	}
+	r.mu.Lock()
+	for _, lag := range r.lags {
+		h.Partitions = append(h.Partitions, *lag)
+	}
+	r.mu.Unlock()
+	sort.Slice(h.Partitions, func(i, j int) bool {
+		if h.Partitions[i].Topic != h.Partitions[j].Topic {
+			return h.Partitions[i].Topic < h.Partitions[j].Topic
+		}
+		return h.Partitions[i].Partition < h.Partitions[j].Partition
+	})
+	return h
+}
+
+func (r *ConsumerRunner) observe(msg *sarama.ConsumerMessage, hwm int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	lag, ok := r.lags[key]
+	if !ok {
+		lag = &PartitionLag{Topic: msg.Topic, Partition: msg.Partition}
+		r.lags[key] = lag
+	}
+	lag.Offset = msg.Offset + 1
+	lag.HighWaterMark = hwm
+	lag.Lag = max(hwm-lag.Offset, 0)
+}
+
+// runnerHandler 记录会话状态和每个分区的消费进度
+type runnerHandler struct {
+	sarama.ConsumerGroupHandler
+	r *ConsumerRunner
+}
+
+func (h *runnerHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.r.mu.Lock()
+	// 分区可能已经分给别人了，只保留这次分到的
+	claimed := make(map[topicPartition]*PartitionLag)
+	for topic, partitions := range session.Claims() {
+		for _, p := range partitions {
+			key := topicPartition{topic: topic, partition: p}
+			if lag, ok := h.r.lags[key]; ok {
+				claimed[key] = lag
+			}
+		}
+	}
+	h.r.lags = claimed
+	h.r.mu.Unlock()
+	h.r.state.Store(StateConsuming)
+	return h.ConsumerGroupHandler.Setup(session)
+}
+
+func (h *runnerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	return h.ConsumerGroupHandler.ConsumeClaim(session, &observedClaim{ConsumerGroupClaim: claim, r: h.r, ctx: session.Context()})
+}
+
+// observedClaim 消息被 handler 拿走的时候记录进度
+type observedClaim struct {
+	sarama.ConsumerGroupClaim
+	r    *ConsumerRunner
+	ctx  context.Context
+	once sync.Once
+	ch   chan *sarama.ConsumerMessage
+}
+
+func (c *observedClaim) Messages() <-chan *sarama.ConsumerMessage {
+	c.once.Do(func() {
+		c.ch = make(chan *sarama.ConsumerMessage)
+		go func() {
+			defer close(c.ch)
+			for msg := range c.ConsumerGroupClaim.Messages() {
+				c.r.observe(msg, c.ConsumerGroupClaim.HighWaterMarkOffset())
+				select {
+				case c.ch <- msg:
+				case <-c.ctx.Done():
+					// 会话结束了 handler 不一定还在读，没送出去的消息不会被提交
+					return
+				}
+			}
+		}()
+	})
+	return c.ch
+}
@@ -0,0 +1,200 @@
+package saramax
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"webook/pkg/logger"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeGroup 每次 Consume 模拟一次会话，rebalance 或者 ctx 结束的时候会话结束
+type fakeGroup struct {
+	msgs      chan *sarama.ConsumerMessage
+	hwm       int64
+	rebalance chan struct{}
+	errs      chan error
+	sessions  atomic.Int32
+	closed    atomic.Bool
+	// failing 为 true 的时候 Consume 直接返回错误，模拟协调者不可用
+	failing atomic.Bool
+	session *fakeSession
+}
+
+func newFakeGroup(hwm int64) *fakeGroup {
+	return &fakeGroup{
+		msgs:      make(chan *sarama.ConsumerMessage, 10),
+		hwm:       hwm,
+		rebalance: make(chan struct{}),
+		errs:      make(chan error, 1),
+		session:   &fakeSession{},
+	}
+}
+
+func (g *fakeGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	if g.closed.Load() {
+		return sarama.ErrClosedConsumerGroup
+	}
+	if g.failing.Load() {
+		return errors.New("coordinator 不可用")
+	}
+	g.sessions.Add(1)
+	sessCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sess := &sessionWithClaims{fakeSession: g.session, ctx: sessCtx}
+	if err := handler.Setup(sess); err != nil {
+		return err
+	}
+	claimCh := make(chan *sarama.ConsumerMessage)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = handler.ConsumeClaim(sess, &hwmClaim{fakeClaim: &fakeClaim{ch: claimCh}, hwm: g.hwm})
+	}()
+	// 模拟 sarama 的分区消费者：会话结束的时候先取消 ctx，再关闭消息通道
+loop:
+	for {
+		select {
+		case msg := <-g.msgs:
+			select {
+			case claimCh <- msg:
+			case <-sessCtx.Done():
+				break loop
+			}
+		case <-g.rebalance:
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	cancel()
+	close(claimCh)
+	<-done
+	return handler.Cleanup(sess)
+}
+
+func (g *fakeGroup) Errors() <-chan error { return g.errs }
+func (g *fakeGroup) Close() error {
+	g.closed.Store(true)
+	close(g.errs)
+	return nil
+}
+func (g *fakeGroup) Pause(map[string][]int32)  {}
+func (g *fakeGroup) Resume(map[string][]int32) {}
+func (g *fakeGroup) PauseAll()                 {}
+func (g *fakeGroup) ResumeAll()                {}
+
+type sessionWithClaims struct {
+	*fakeSession
+	ctx context.Context
+}
+
+func (s *sessionWithClaims) Claims() map[string][]int32 { return map[string][]int32{"evt": {0}} }
+func (s *sessionWithClaims) Context() context.Context   { return s.ctx }
+
+type hwmClaim struct {
+	*fakeClaim
+	hwm int64
+}
+
+func (c *hwmClaim) HighWaterMarkOffset() int64 { return c.hwm }
+
+func TestConsumerRunner_Rejoin(t *testing.T) {
+	g := newFakeGroup(10)
+	l := logger.NewZapLogger(zap.NewNop())
+	r := NewConsumerRunner("test", g, []string{"evt"}, NewHandler_[testEvt](func(msg *sarama.ConsumerMessage, evt testEvt) error {
+		return nil
+	}, l), l)
+	require.NoError(t, r.Start())
+	assert.Eventually(t, func() bool { return r.Health().Ready }, time.Second, time.Millisecond*5)
+
+	g.msgs <- newMsg(t, 2, testEvt{Id: 1})
+	assert.Eventually(t, func() bool { return g.session.lastMarked() == 2 }, time.Second, time.Millisecond*5)
+	h := r.Health()
+	assert.Equal(t, StateConsuming, h.State)
+	assert.Equal(t, []PartitionLag{{Topic: "evt", Partition: 0, Offset: 3, HighWaterMark: 10, Lag: 7}}, h.Partitions)
+
+	// 再平衡之后重新加入
+	g.rebalance <- struct{}{}
+	assert.Eventually(t, func() bool { return g.sessions.Load() == 2 && r.Health().Ready }, time.Second, time.Millisecond*5)
+
+	// 消费者组的错误要能看到
+	g.errs <- errors.New("broker 不可用")
+	assert.Eventually(t, func() bool { return r.Health().LastError == "broker 不可用" }, time.Second, time.Millisecond*5)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, r.Stop(ctx))
+	assert.True(t, g.closed.Load())
+	assert.Equal(t, StateStopped, r.Health().State)
+	assert.False(t, r.Health().Ready)
+}
+
+func TestConsumerRunner_ConsumeError(t *testing.T) {
+	g := newFakeGroup(10)
+	l := logger.NewZapLogger(zap.NewNop())
+	r := NewConsumerRunner("test", g, []string{"evt"}, NewHandler_[testEvt](func(msg *sarama.ConsumerMessage, evt testEvt) error {
+		return nil
+	}, l), l)
+	r.backoff = time.Millisecond * 10
+	require.NoError(t, r.Start())
+	assert.Eventually(t, func() bool { return r.Health().Ready }, time.Second, time.Millisecond*5)
+
+	// 会话结束之后重新加入失败，不能再报告 consuming
+	g.failing.Store(true)
+	g.rebalance <- struct{}{}
+	assert.Eventually(t, func() bool {
+		h := r.Health()
+		return h.State == StateError && !h.Ready && h.LastError == "coordinator 不可用"
+	}, time.Second, time.Millisecond*5)
+
+	// 恢复之后重新加入
+	g.failing.Store(false)
+	assert.Eventually(t, func() bool { return r.Health().Ready }, time.Second, time.Millisecond*5)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, r.Stop(ctx))
+	assert.Equal(t, StateStopped, r.Health().State)
+}
+
+func TestConsumerRunner_StopFlushesBatch(t *testing.T) {
+	g := newFakeGroup(10)
+	l := logger.NewZapLogger(zap.NewNop())
+	var mu sync.Mutex
+	var got []int64
+	// 批次很大，超时很长，只有退出的时候才会处理
+	h := NewBatchHandler_[testEvt](func(msgs []*sarama.ConsumerMessage, ts []testEvt) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, evt := range ts {
+			got = append(got, evt.Id)
+		}
+		return nil
+	}, l, 100, time.Minute)
+	r := NewConsumerRunner("test", g, []string{"evt"}, h, l)
+	require.NoError(t, r.Start())
+	for i := int64(0); i < 3; i++ {
+		g.msgs <- newMsg(t, i, testEvt{Id: i})
+	}
+	assert.Eventually(t, func() bool { return len(g.msgs) == 0 }, time.Second, time.Millisecond*5)
+	// 等最后一条被 handler 拿走
+	time.Sleep(time.Millisecond * 20)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, r.Stop(ctx))
+	mu.Lock()
+	assert.Equal(t, []int64{0, 1, 2}, got)
+	mu.Unlock()
+	assert.Equal(t, int64(2), g.session.lastMarked())
+	assert.True(t, g.closed.Load())
+}
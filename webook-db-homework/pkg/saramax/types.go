@@ -1,5 +1,10 @@
 package saramax
 
+import "context"
+
 type Consumer interface {
 	Start() error
+	// Stop 等处理中的消息处理完，提交偏移量之后退出
+	Stop(ctx context.Context) error
+	Health() Health
 }
@@ -0,0 +1,71 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试向量，密钥是 ASCII 的 "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		unix int64
+		want string
+	}{
+		{name: "59", unix: 59, want: "287082"},
+		{name: "1111111109", unix: 1111111109, want: "081804"},
+		{name: "1111111111", unix: 1111111111, want: "050471"},
+		{name: "1234567890", unix: 1234567890, want: "005924"},
+		{name: "2000000000", unix: 2000000000, want: "279037"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, err := GenerateCode(rfcSecret, Step(time.Unix(tc.unix, 0)))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, code)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	testCases := []struct {
+		name     string
+		code     string
+		at       time.Time
+		wantStep int64
+		wantOk   bool
+	}{
+		{name: "当前时间步", code: "005924", at: now, wantStep: Step(now), wantOk: true},
+		{name: "允许落后一个时间步", code: "005924", at: now.Add(Period), wantStep: Step(now), wantOk: true},
+		{name: "超过误差范围", code: "005924", at: now.Add(2 * Period), wantOk: false},
+		{name: "验证码错误", code: "123456", at: now, wantOk: false},
+		{name: "位数不对", code: "5924", at: now, wantOk: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tc.code, tc.at, 1)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantStep, step)
+		})
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	raw := ProvisioningURI("webook", "alice@example.com", secret)
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/webook:alice@example.com", u.Path)
+	assert.Equal(t, secret, u.Query().Get("secret"))
+	assert.Equal(t, "webook", u.Query().Get("issuer"))
+}
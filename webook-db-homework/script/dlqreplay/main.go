@@ -0,0 +1,96 @@
+// dlqreplay 把死信 topic 里的消息重新投递回原始 topic，用法：
+//
+//	go run ./script/dlqreplay -brokers localhost:9094 -topic read_event_dlq -from 0=123,1=456
+//
+// -from 按分区指定开始的偏移量，没有指定的分区从头开始。
+// 输出每个分区重放到的位置，最后一行是下次接着重放要用的 -from
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"webook/pkg/logger"
+	"webook/pkg/saramax"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+)
+
+func main() {
+	brokers := flag.String("brokers", "localhost:9094", "Kafka 地址，多个用逗号分隔")
+	topic := flag.String("topic", "", "死信 topic")
+	fromStr := flag.String("from", "", "每个分区从哪个偏移量开始重放，格式 分区=偏移量，多个用逗号分隔，没有指定的分区从头开始")
+	flag.Parse()
+	if *topic == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	from, err := parseOffsets(*fromStr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "-from 格式不对:", err)
+		os.Exit(2)
+	}
+
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	client, err := sarama.NewClient(strings.Split(*brokers, ","), cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer client.Close()
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		panic(err)
+	}
+	defer producer.Close()
+
+	zl, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	res, err := saramax.NewReplayer(client, producer, logger.NewZapLogger(zl)).Replay(ctx, *topic, from)
+	next := make([]string, 0, len(res))
+	for _, r := range res {
+		fmt.Printf("partition=%d replayed=%d skipped=%d next=%d\n", r.Partition, r.Replayed, r.Skipped, r.Next)
+		next = append(next, fmt.Sprintf("%d=%d", r.Partition, r.Next))
+	}
+	if len(next) > 0 {
+		fmt.Printf("-from %s\n", strings.Join(next, ","))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "重放失败:", err)
+		os.Exit(1)
+	}
+}
+
+// parseOffsets 解析 0=123,1=456
+func parseOffsets(s string) (map[int32]int64, error) {
+	res := make(map[int32]int64)
+	if s == "" {
+		return res, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		p, o, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return nil, fmt.Errorf("%q 缺少 =", kv)
+		}
+		partition, err := strconv.ParseInt(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("分区 %q: %w", p, err)
+		}
+		offset, err := strconv.ParseInt(o, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("偏移量 %q: %w", o, err)
+		}
+		res[int32(partition)] = offset
+	}
+	return res, nil
+}